@@ -0,0 +1,8 @@
+package client
+
+import "github.com/yosssi/gmq/mqtt/packet"
+
+// AbandonHandler is the handler which is called with the PUBLISH
+// or PUBREL Packet when its flow is abandoned because the Server
+// has not acknowledged it after the resends.
+type AbandonHandler func(p packet.Packet)
@@ -0,0 +1,9 @@
+package client
+
+import "github.com/yosssi/gmq/mqtt/packet"
+
+// AuthHandler is the handler which handles the AUTH Packet of MQTT 5.0
+// sent from the Server during the enhanced authentication. It returns
+// the Properties of the AUTH Packet which the Client sends back with
+// the Reason Code packet.ReasonContinueAuthentication.
+type AuthHandler func(reasonCode byte, props *packet.Properties) (*packet.Properties, error)
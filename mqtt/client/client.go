@@ -1,9 +1,9 @@
 package client
 
 import (
+	"context"
 	"errors"
-	"io"
-	"strings"
+	"net"
 	"sync"
 	"time"
 
@@ -19,13 +19,30 @@ const (
 
 // Error values
 var (
-	ErrAlreadyConnected = errors.New("the Client has already connected to the Server")
-	ErrNotYetConnected  = errors.New("the Client has not yet connected to the Server")
-	ErrCONNACKTimeout   = errors.New("the CONNACK Packet was not received within a reasonalbe amount of time")
-	ErrPINGRESPTimeout  = errors.New("the PINGRESP Packet was not received within a reasonalbe amount of time")
-	ErrPacketIDExhaused = errors.New("Packet Identifiers are exhausted")
-	ErrInvalidPINGRESP  = errors.New("invalid PINGRESP Packet")
-	ErrInvalidSUBACK    = errors.New("invalid SUBACK Packet")
+	ErrAlreadyConnected  = errors.New("the Client has already connected to the Server")
+	ErrNotYetConnected   = errors.New("the Client has not yet connected to the Server")
+	ErrCONNACKTimeout    = errors.New("the CONNACK Packet was not received within a reasonalbe amount of time")
+	ErrPINGRESPTimeout   = errors.New("the PINGRESP Packet was not received within a reasonalbe amount of time")
+	ErrPacketIDExhaused  = errors.New("Packet Identifiers are exhausted")
+	ErrInvalidPINGRESP   = errors.New("invalid PINGRESP Packet")
+	ErrInvalidSUBACK     = errors.New("invalid SUBACK Packet")
+	ErrInvalidCONNACK    = errors.New("invalid CONNACK Packet")
+	ErrInvalidTopicAlias = errors.New("invalid Topic Alias")
+
+	ErrReconnectRetriesExceeded = errors.New("the number of the reconnection attempts exceeds the maximum")
+	ErrDisconnected             = errors.New("the Network Connection was disconnected before the flow completed")
+	ErrAbandoned                = errors.New("the flow was abandoned because the Server did not acknowledge the resent Packet")
+	ErrRetryIntervalV5          = errors.New("the Packets must not be resent while the Network Connection is alive in MQTT 5.0")
+)
+
+// Error values which represent the Connect Return codes
+// of the CONNACK Packet
+var (
+	ErrUnacceptableProtocolVersion = errors.New("the Server does not support the level of the MQTT protocol requested by the Client")
+	ErrIdentifierRejected          = errors.New("the Client Identifier is not allowed by the Server")
+	ErrServerUnavailable           = errors.New("the MQTT service is unavailable")
+	ErrBadUserNameOrPassword       = errors.New("the data in the User Name or Password is malformed")
+	ErrNotAuthorized               = errors.New("the Client is not authorized to connect")
 )
 
 // Client represents a Client.
@@ -35,6 +52,11 @@ type Client struct {
 	// conn is the Network Connection.
 	conn *connection
 
+	// muState is the Mutex for the state.
+	muState sync.RWMutex
+	// state is the state of the connection.
+	state State
+
 	// muSess is the Mutex for the Session.
 	muSess sync.RWMutex
 	// sess is the Session.
@@ -44,19 +66,89 @@ type Client struct {
 	// which are launched by the New method.
 	wg sync.WaitGroup
 	// disconnc is the channel which handles the signal
-	// to disconnect the Network Connection.
-	disconnc chan struct{}
+	// to disconnect the Network Connection with its cause.
+	disconnc chan error
 	// disconnEndc is the channel which ends the goroutine
 	// which disconnects the Network Connection.
 	disconnEndc chan struct{}
 
+	// connectOpts is the options of the last successful
+	// Connect method call which are used for reconnecting.
+	connectOpts *ConnectOptions
+	// reconnEndc is the channel which ends the reconnection
+	// attempts. It is not nil while the Client is reconnecting.
+	reconnEndc chan struct{}
+	// serverIndex is the index of the server of the options
+	// which the Client tries first when connecting.
+	serverIndex int
+
 	// errorHandler is the error handler.
 	errorHandler ErrorHandler
+	// dispatcher dispatches the Application Messages
+	// to the handlers.
+	dispatcher *dispatcher
+	// sessionStore is the SessionStore which persists
+	// the Packets of the Session.
+	sessionStore SessionStore
+	// bufferPool is the pool of the buffers into which the PUBLISH
+	// Packets are read. It is nil if the buffers are not pooled.
+	bufferPool *packet.BufferPool
+	// tracer observes the Packets which are sent and received.
+	tracer Tracer
+	// metrics collects the metrics of the Client.
+	metrics Metrics
+	// onConnect is called when the Server has accepted the connection.
+	onConnect ConnectHandler
+	// onConnectionLost is called when the Network Connection has been lost.
+	onConnectionLost ConnectionLostHandler
+	// onReconnecting is called before each reconnection attempt.
+	onReconnecting ReconnectingHandler
+	// onDisconnect is called when the Disconnect method has disconnected
+	// the Network Connection.
+	onDisconnect DisconnectHandler
+	// onAbandon is called when the flow of the resent Packet is abandoned.
+	onAbandon AbandonHandler
 }
 
-// Connect establishes a Network Connection to the Server and
-// sends a CONNECT Packet to the Server.
+// Connect establishes a Network Connection to the Server,
+// sends a CONNECT Packet to the Server and waits for
+// the CONNACK Packet sent from the Server. An error which
+// represents the Connect Return code is returned if the Server
+// refuses the connection.
 func (cli *Client) Connect(opts *ConnectOptions) error {
+	return cli.ConnectContext(context.Background(), opts)
+}
+
+// ConnectContext establishes a Network Connection to the Server,
+// sends a CONNECT Packet to the Server and waits for the CONNACK
+// Packet sent from the Server. The context is used for canceling
+// the dialing, the opening handshake of the WebSocket and the waiting
+// for the CONNACK Packet.
+func (cli *Client) ConnectContext(ctx context.Context, opts *ConnectOptions) error {
+	return cli.connectWithSubs(ctx, opts, nil)
+}
+
+// connectWithSubs establishes a Network Connection to the Server
+// and calls the handler. The acknowledged subscriptions are restored
+// before receiving the Packets if the Server has resumed the Session.
+func (cli *Client) connectWithSubs(ctx context.Context, opts *ConnectOptions, ackedSubReqs []*SubReq) error {
+	sessionPresent, err := cli.connect(ctx, opts, ackedSubReqs)
+	if err != nil {
+		return err
+	}
+
+	// Notify the connection to the handler.
+	if cli.onConnect != nil {
+		cli.onConnect(sessionPresent)
+	}
+
+	return nil
+}
+
+// connect establishes a Network Connection to the Server and returns
+// the Session Present of the CONNACK Packet. The handler is not called
+// in it because the Mutexes are locked.
+func (cli *Client) connect(ctx context.Context, opts *ConnectOptions, ackedSubReqs []*SubReq) (bool, error) {
 	// Lock for the connection.
 	cli.muConn.Lock()
 
@@ -65,7 +157,7 @@ func (cli *Client) Connect(opts *ConnectOptions) error {
 
 	// Return an error if the Client has already connected to the Server.
 	if cli.conn != nil {
-		return ErrAlreadyConnected
+		return false, ErrAlreadyConnected
 	}
 
 	// Initialize the options.
@@ -73,56 +165,63 @@ func (cli *Client) Connect(opts *ConnectOptions) error {
 		opts = &ConnectOptions{}
 	}
 
-	// Establish a Network Connection.
-	conn, err := newConnection(opts.Network, opts.Address, opts.TLSConfig)
-	if err != nil {
-		return err
+	// Return an error if the Packets would be resent while
+	// the Network Connection is alive in MQTT 5.0.
+	if opts.RetryInterval > 0 && opts.ProtocolVersion == mqtt.ProtocolVersion5 {
+		return false, ErrRetryIntervalV5
 	}
 
-	// Set the Network Connection to the Client.
-	cli.conn = conn
-
-	// Lock for reading and updating the Session.
-	cli.muSess.Lock()
+	// Keep the reconnecting state while the Client is reconnecting.
+	reconnecting := cli.reconnEndc != nil
 
-	// Create a Session or reuse the current Session.
-	if opts.CleanSession || cli.sess == nil {
-		// Create a Session and set it to the Client.
-		cli.sess = newSession(opts.CleanSession, opts.ClientID)
-	} else {
-		// Reuse the Session and set its Client Identifier to the options.
-		opts.ClientID = cli.sess.clientID
+	if !reconnecting {
+		cli.setState(StateConnecting)
 	}
 
-	// Unlock.
-	cli.muSess.Unlock()
+	// Copy the options not to modify the caller's ones.
+	copiedOpts := *opts
+	opts = &copiedOpts
 
-	// Send a CONNECT Packet to the Server.
-	err = cli.sendCONNECT(&packet.CONNECTOptions{
-		ClientID:     opts.ClientID,
-		UserName:     opts.UserName,
-		Password:     opts.Password,
-		CleanSession: opts.CleanSession,
-		KeepAlive:    opts.KeepAlive,
-		WillTopic:    opts.WillTopic,
-		WillMessage:  opts.WillMessage,
-		WillQoS:      opts.WillQoS,
-		WillRetain:   opts.WillRetain,
-	})
+	// Connect to one of the servers.
+	if err := cli.connectServers(ctx, opts); err != nil {
+		if !reconnecting {
+			cli.setState(StateDisconnected)
+		}
 
-	if err != nil {
-		// Close the Network Connection.
-		cli.conn.Close()
+		return false, err
+	}
 
-		// Clean the Network Connection and the Session if necessary.
-		cli.clean()
+	// Apply the Properties of the CONNACK Packet.
+	keepAlive := opts.KeepAlive
 
-		return err
+	if props := cli.conn.connackProps; props != nil {
+		// Use the Keep Alive which the Server assigns.
+		if props.ServerKeepAlive != nil {
+			keepAlive = *props.ServerKeepAlive
+		}
+
+		// Use the Client Identifier which the Server assigns
+		// for the Session and the reconnection.
+		if len(opts.ClientID) == 0 && len(props.AssignedClientIdentifier) > 0 {
+			opts.ClientID = props.AssignedClientIdentifier
+
+			cli.muSess.Lock()
+			cli.sess.clientID = opts.ClientID
+			cli.muSess.Unlock()
+		}
 	}
 
-	// Launch a goroutine which waits for receiving the CONNACK Packet.
-	cli.conn.wg.Add(1)
-	go cli.waitPacket(cli.conn.connack, opts.CONNACKTimeout, ErrCONNACKTimeout)
+	// Set the options to the Client for reconnecting.
+	cli.connectOpts = opts
+
+	// Restore the acknowledged subscriptions before receiving
+	// the Application Messages which the Server has queued for
+	// the resumed Session.
+	if cli.conn.sessionPresent {
+		for _, s := range ackedSubReqs {
+			cli.conn.ackedSubs.Add(string(s.TopicFilter), s)
+		}
+	}
 
 	// Launch a goroutine which receives a Packet from the Server.
 	cli.conn.wg.Add(1)
@@ -130,7 +229,16 @@ func (cli *Client) Connect(opts *ConnectOptions) error {
 
 	// Launch a goroutine which sends a Packet to the Server.
 	cli.conn.wg.Add(1)
-	go cli.sendPackets(time.Duration(opts.KeepAlive), opts.PINGRESPTimeout)
+	go cli.sendPackets(time.Duration(keepAlive), opts.PINGRESPTimeout)
+
+	// Launch a goroutine which resends the unacknowledged Packets
+	// while the Network Connection is alive if necessary.
+	if opts.RetryInterval > 0 {
+		cli.conn.wg.Add(1)
+		go cli.retryPackets(cli.conn, opts.RetryInterval, opts.MaxRetries)
+	}
+
+	cli.setState(StateConnected)
 
 	// Resend the unacknowledged PUBLISH and PUBREL Packets to the Server
 	// if the Clean Session is false.
@@ -141,51 +249,108 @@ func (cli *Client) Connect(opts *ConnectOptions) error {
 		// Unlock.
 		defer cli.muSess.Unlock()
 
-		for id, p := range cli.sess.sendingPackets {
+		// Resend the Packets in the order in which they were
+		// set to the Session as the specification requires.
+		for _, id := range cli.sess.sendingIDs() {
+			p := cli.sess.sendingPackets[id]
+
 			// Extract the MQTT Control MQTT Control Packet type.
 			ptype, err := p.Type()
 			if err != nil {
-				return err
+				return false, err
 			}
 
 			switch ptype {
 			case packet.TypePUBLISH:
 				// Set the DUP flag of the PUBLISH Packet to true.
-				p.(*packet.PUBLISH).DUP = true
+				p = p.(*packet.PUBLISH).Duplicate()
+				cli.sess.sendingPackets[id] = p
+				cli.sess.sendingTimes[id] = time.Now()
 				// Resend the PUBLISH Packet to the Server.
+				cli.reportSendQueue(cli.conn)
 				cli.conn.send <- p
 			case packet.TypePUBREL:
+				cli.sess.sendingTimes[id] = time.Now()
 				// Resend the PUBREL Packet to the Server.
+				cli.reportSendQueue(cli.conn)
 				cli.conn.send <- p
 			default:
 				// Delete the Packet from the Session.
-				delete(cli.sess.sendingPackets, id)
+				if err := cli.sess.deletePacket(Outgoing, id); err != nil {
+					return false, err
+				}
 			}
 		}
 	}
 
-	return nil
+	return cli.conn.sessionPresent, nil
 }
 
 // Disconnect sends a DISCONNECT Packet to the Server and
-// closes the Network Connection.
+// closes the Network Connection. It stops the reconnection
+// attempts if the Client is reconnecting to the Server.
 func (cli *Client) Disconnect() error {
+	return cli.DisconnectContext(context.Background())
+}
+
+// DisconnectContext sends a DISCONNECT Packet to the Server and
+// closes the Network Connection. It returns the error of the context
+// if the context is done before all goroutines of the Network Connection
+// end. In that case, the disconnection is completed in the background.
+func (cli *Client) DisconnectContext(ctx context.Context) error {
+	return cli.disconnect(ctx, nil)
+}
+
+// disconnect disconnects the Network Connection. cause is the cause
+// of the loss of the Network Connection and it is nil if the Disconnect
+// method is called. The handler of the cause is called after the cleaning.
+func (cli *Client) disconnect(ctx context.Context, cause error) error {
 	// Lock for the disconnection.
 	cli.muConn.Lock()
 
 	// Return an error if the Client has not yet connected to the Server.
 	if cli.conn == nil {
+		// Stop the reconnection attempts if the Client is reconnecting.
+		if cli.reconnEndc != nil {
+			close(cli.reconnEndc)
+
+			cli.reconnEndc = nil
+
+			cli.setState(StateDisconnected)
+
+			// Unlock.
+			cli.muConn.Unlock()
+
+			// Notify the disconnection to the handler.
+			if cli.onDisconnect != nil {
+				cli.onDisconnect()
+			}
+
+			return nil
+		}
+
 		// Unlock.
 		cli.muConn.Unlock()
 
 		return ErrNotYetConnected
 	}
 
+	// Set the deadline of the context for sending the DISCONNECT Packet.
+	if deadline, ok := ctx.Deadline(); ok {
+		cli.conn.SetWriteDeadline(deadline)
+	}
+
+	// Create a DISCONNECT Packet. No error occurs because
+	// the protocol version has been validated when connecting.
+	disconnect, _ := packet.NewDISCONNECTWithOptions(&packet.DISCONNECTOptions{
+		ProtocolVersion: cli.conn.protocolVersion(),
+	})
+
 	// Send a DISCONNECT Packet to the Server.
 	// Ignore the error returned by the send method because
 	// we proceed to the subsequent disconnecting processing
 	// even if the send method returns the error.
-	cli.send(packet.NewDISCONNECT())
+	cli.send(disconnect)
 
 	// Close the Network Connection.
 	if err := cli.conn.Close(); err != nil {
@@ -204,32 +369,103 @@ func (cli *Client) Disconnect() error {
 	default:
 	}
 
+	// Get the Network Connection.
+	conn := cli.conn
+
 	// Unlock.
 	cli.muConn.Unlock()
 
-	// Wait until all goroutines end.
-	cli.conn.wg.Wait()
+	// Create a channel which is closed when the cleaning ends.
+	cleanedc := make(chan struct{})
 
-	// Lock for cleaning the Network Connection.
-	cli.muConn.Lock()
+	go func() {
+		defer close(cleanedc)
 
-	// Lock for cleaning the Session.
-	cli.muSess.Lock()
+		// Wait until all goroutines end.
+		conn.wg.Wait()
 
-	// Clean the Network Connection and the Session.
-	cli.clean()
+		// Lock for cleaning the Network Connection.
+		cli.muConn.Lock()
 
-	// Unlock.
-	cli.muSess.Unlock()
+		// Lock for cleaning the Session.
+		cli.muSess.Lock()
 
-	// Unlock.
-	cli.muConn.Unlock()
+		// Complete the Tokens of the uncompleted flows with the error.
+		if cli.sess != nil {
+			cli.sess.failTokens(ErrDisconnected)
+		}
 
-	return nil
+		// Clean the Network Connection and the Session.
+		cli.clean()
+
+		// Fail over to the next server if the Network Connection has been lost.
+		if cause != nil && cli.connectOpts != nil {
+			cli.skipServer(cli.connectOpts, cli.serverIndex)
+		}
+
+		// Keep the Client reconnecting if the Network Connection
+		// has been lost and it will be reconnected.
+		if cause != nil && cli.connectOpts != nil && cli.connectOpts.AutoReconnect {
+			cli.setState(StateReconnecting)
+		} else {
+			cli.setState(StateDisconnected)
+		}
+
+		// Unlock.
+		cli.muSess.Unlock()
+
+		// Unlock.
+		cli.muConn.Unlock()
+
+		// Notify the disconnection or the loss
+		// of the Network Connection to the handler.
+		if cause == nil {
+			if cli.onDisconnect != nil {
+				cli.onDisconnect()
+			}
+		} else if cli.onConnectionLost != nil {
+			cli.onConnectionLost(cause)
+		}
+	}()
+
+	select {
+	case <-cleanedc:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
 }
 
 // Publish sends a PUBLISH Packet to the Server.
 func (cli *Client) Publish(opts *PublishOptions) error {
+	_, err := cli.PublishAsync(opts)
+	return err
+}
+
+// PublishAsync sends a PUBLISH Packet to the Server and returns
+// a Token which is completed when the PUBACK Packet (QoS 1) or
+// the PUBCOMP Packet (QoS 2) is received. The Token of QoS 0 is
+// completed immediately.
+func (cli *Client) PublishAsync(opts *PublishOptions) (*Token, error) {
+	return cli.publish(context.Background(), opts)
+}
+
+// PublishContext sends a PUBLISH Packet to the Server and waits until
+// the PUBACK Packet (QoS 1) or the PUBCOMP Packet (QoS 2) is received.
+// It returns the error of the context if the context is done before
+// the PUBLISH Packet is queued or acknowledged.
+func (cli *Client) PublishContext(ctx context.Context, opts *PublishOptions) error {
+	t, err := cli.publish(ctx, opts)
+	if err != nil {
+		return err
+	}
+
+	return t.WaitContext(ctx)
+}
+
+// publish sends a PUBLISH Packet to the Server and returns a Token.
+// The context is used for canceling the queuing of the Packet.
+func (cli *Client) publish(ctx context.Context, opts *PublishOptions) (*Token, error) {
 	// Lock for reading.
 	cli.muConn.RLock()
 
@@ -238,7 +474,7 @@ func (cli *Client) Publish(opts *PublishOptions) error {
 
 	// Check the Network Connection.
 	if cli.conn == nil {
-		return ErrNotYetConnected
+		return nil, ErrNotYetConnected
 	}
 
 	// Initialize the options.
@@ -249,17 +485,87 @@ func (cli *Client) Publish(opts *PublishOptions) error {
 	// Create a PUBLISH Packet.
 	p, err := cli.newPUBLISHPacket(opts)
 	if err != nil {
-		return err
+		return nil, err
+	}
+
+	// Create a Token.
+	t := newToken()
+
+	if opts.QoS == mqtt.QoS0 {
+		// Complete the Token because there is no acknowledgement.
+		t.complete(nil, nil)
+	} else {
+		// Lock for updating the Session.
+		cli.muSess.Lock()
+
+		// Set the Token to the Session.
+		cli.sess.tokens[p.(*packet.PUBLISH).PacketID] = t
+
+		// Unlock.
+		cli.muSess.Unlock()
 	}
 
 	// Send the Packet to the Server.
-	cli.conn.send <- p
+	cli.reportSendQueue(cli.conn)
 
-	return nil
+	select {
+	case cli.conn.send <- p:
+	case <-ctx.Done():
+		if opts.QoS != mqtt.QoS0 {
+			// Lock for updating the Session.
+			cli.muSess.Lock()
+
+			// Delete the Packet and the Token from the Session.
+			id := p.(*packet.PUBLISH).PacketID
+
+			// The error of the SessionStore is ignored because
+			// the error of the context takes precedence.
+			cli.sess.deletePacket(Outgoing, id)
+			delete(cli.sess.tokens, id)
+
+			// Unlock.
+			cli.muSess.Unlock()
+		}
+
+		return nil, ctx.Err()
+	}
+
+	return t, nil
 }
 
 // Subscribe sends a SUBSCRIBE Packet to the Server.
 func (cli *Client) Subscribe(opts *SubscribeOptions) error {
+	_, err := cli.SubscribeAsync(opts)
+	return err
+}
+
+// SubscribeAsync sends a SUBSCRIBE Packet to the Server and returns
+// a Token which is completed when the SUBACK Packet is received.
+// The Return Codes of the SUBACK Packet are available via the Token.
+func (cli *Client) SubscribeAsync(opts *SubscribeOptions) (*Token, error) {
+	return cli.subscribe(context.Background(), opts)
+}
+
+// SubscribeContext sends a SUBSCRIBE Packet to the Server, waits until
+// the SUBACK Packet is received and returns its Return Codes. It returns
+// the error of the context if the context is done before the SUBSCRIBE
+// Packet is queued or acknowledged.
+func (cli *Client) SubscribeContext(ctx context.Context, opts *SubscribeOptions) ([]byte, error) {
+	t, err := cli.subscribe(ctx, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := t.WaitContext(ctx); err != nil {
+		return nil, err
+	}
+
+	return t.ReturnCodes(), nil
+}
+
+// subscribe sends a SUBSCRIBE Packet to the Server and returns a Token.
+// The context is used for canceling the queuing of the Packet.
+func (cli *Client) subscribe(ctx context.Context, opts *SubscribeOptions) (*Token, error) {
 	// Lock for reading and updating.
 	cli.muConn.Lock()
 
@@ -268,12 +574,12 @@ func (cli *Client) Subscribe(opts *SubscribeOptions) error {
 
 	// Check the Network Connection.
 	if cli.conn == nil {
-		return ErrNotYetConnected
+		return nil, ErrNotYetConnected
 	}
 
 	// Check the existence of the options.
 	if opts == nil || len(opts.SubReqs) == 0 {
-		return packet.ErrInvalidNoSubReq
+		return nil, packet.ErrInvalidNoSubReq
 	}
 
 	// Define a Packet Identifier.
@@ -289,7 +595,7 @@ func (cli *Client) Subscribe(opts *SubscribeOptions) error {
 
 	// Generate a Packet Identifer.
 	if packetID, err = cli.generatePacketID(); err != nil {
-		return err
+		return nil, err
 	}
 
 	// Create subscription requests for the SUBSCRIBE Packet.
@@ -297,37 +603,92 @@ func (cli *Client) Subscribe(opts *SubscribeOptions) error {
 
 	for _, s := range opts.SubReqs {
 		subReqs = append(subReqs, &packet.SubReq{
-			TopicFilter: s.TopicFilter,
-			QoS:         s.QoS,
+			TopicFilter:       s.TopicFilter,
+			QoS:               s.QoS,
+			NoLocal:           s.NoLocal,
+			RetainAsPublished: s.RetainAsPublished,
+			RetainHandling:    s.RetainHandling,
 		})
 	}
 
 	// Create a SUBSCRIBE Packet.
 	p, err := packet.NewSUBSCRIBE(&packet.SUBSCRIBEOptions{
-		PacketID: packetID,
-		SubReqs:  subReqs,
+		PacketID:        packetID,
+		SubReqs:         subReqs,
+		ProtocolVersion: cli.conn.protocolVersion(),
+		Properties:      opts.Properties,
 	})
 	if err != nil {
-		return err
+		return nil, err
 	}
 
 	// Set the Packet to the Session.
-	cli.sess.sendingPackets[packetID] = p
+	if err := cli.sess.setPacket(Outgoing, packetID, p); err != nil {
+		cli.sess.forgetSendingPacket(packetID)
+		cli.sess.updateInflight()
+		return nil, err
+	}
+
+	// Send the Packet to the Server.
+	// The SUBACK Packet is not handled until this method
+	// returns because the Mutexes are locked.
+	cli.reportSendQueue(cli.conn)
+
+	select {
+	case cli.conn.send <- p:
+	case <-ctx.Done():
+		// The error of the SessionStore is ignored because
+		// the error of the context takes precedence.
+		cli.sess.deletePacket(Outgoing, packetID)
+
+		return nil, ctx.Err()
+	}
+
+	// Create a Token and set it to the Session.
+	t := newToken()
+
+	cli.sess.tokens[packetID] = t
 
 	// Set the subscription information to
 	// the Network Connection.
 	for _, s := range opts.SubReqs {
-		cli.conn.unackSubs[string(s.TopicFilter)] = s.Handler
-	}
+		// Copy the subscription request not to be
+		// affected by the caller's modification.
+		subReq := *s
 
-	// Send the Packet to the Server.
-	cli.conn.send <- p
+		cli.conn.unackSubs[string(s.TopicFilter)] = &subReq
+	}
 
-	return nil
+	return t, nil
 }
 
 // Unsubscribe sends an UNSUBSCRIBE Packet to the Server.
 func (cli *Client) Unsubscribe(opts *UnsubscribeOptions) error {
+	_, err := cli.UnsubscribeAsync(opts)
+	return err
+}
+
+// UnsubscribeAsync sends an UNSUBSCRIBE Packet to the Server and returns
+// a Token which is completed when the UNSUBACK Packet is received.
+func (cli *Client) UnsubscribeAsync(opts *UnsubscribeOptions) (*Token, error) {
+	return cli.unsubscribe(context.Background(), opts)
+}
+
+// UnsubscribeContext sends an UNSUBSCRIBE Packet to the Server and waits
+// until the UNSUBACK Packet is received. It returns the error of the context
+// if the context is done before the UNSUBSCRIBE Packet is queued or acknowledged.
+func (cli *Client) UnsubscribeContext(ctx context.Context, opts *UnsubscribeOptions) error {
+	t, err := cli.unsubscribe(ctx, opts)
+	if err != nil {
+		return err
+	}
+
+	return t.WaitContext(ctx)
+}
+
+// unsubscribe sends an UNSUBSCRIBE Packet to the Server and returns a Token.
+// The context is used for canceling the queuing of the Packet.
+func (cli *Client) unsubscribe(ctx context.Context, opts *UnsubscribeOptions) (*Token, error) {
 	// Lock for reading and updating.
 	cli.muConn.Lock()
 
@@ -336,12 +697,12 @@ func (cli *Client) Unsubscribe(opts *UnsubscribeOptions) error {
 
 	// Check the Network Connection.
 	if cli.conn == nil {
-		return ErrNotYetConnected
+		return nil, ErrNotYetConnected
 	}
 
 	// Check the existence of the options.
 	if opts == nil || len(opts.TopicFilters) == 0 {
-		return packet.ErrNoTopicFilter
+		return nil, packet.ErrNoTopicFilter
 	}
 
 	// Define a Packet Identifier.
@@ -357,25 +718,80 @@ func (cli *Client) Unsubscribe(opts *UnsubscribeOptions) error {
 
 	// Generate a Packet Identifer.
 	if packetID, err = cli.generatePacketID(); err != nil {
-		return err
+		return nil, err
 	}
 
 	// Create an UNSUBSCRIBE Packet.
 	p, err := packet.NewUNSUBSCRIBE(&packet.UNSUBSCRIBEOptions{
-		PacketID:     packetID,
-		TopicFilters: opts.TopicFilters,
+		PacketID:        packetID,
+		TopicFilters:    opts.TopicFilters,
+		ProtocolVersion: cli.conn.protocolVersion(),
+		Properties:      opts.Properties,
 	})
 	if err != nil {
-		return err
+		return nil, err
 	}
 
-	// Set the Packet to the Session.
+	// Send the Packet to the Server.
+	// The UNSUBACK Packet is not handled until this method
+	// returns because the Mutexes are locked.
+	cli.reportSendQueue(cli.conn)
+
+	select {
+	case cli.conn.send <- p:
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+
+	// Set the Packet to the Session. The UNSUBSCRIBE
+	// Packet is not stored to the SessionStore.
 	cli.sess.sendingPackets[packetID] = p
+	cli.sess.updateInflight()
 
-	// Send the Packet to the Server.
-	cli.conn.send <- p
+	// Create a Token and set it to the Session.
+	t := newToken()
 
-	return nil
+	cli.sess.tokens[packetID] = t
+
+	return t, nil
+}
+
+// SessionPresent returns the Session Present of the CONNACK Packet
+// which the Client received from the Server when connecting.
+// It returns false if the Client has not yet connected to the Server
+// or communicates with the Server in MQTT 3.1 which has no Session Present.
+func (cli *Client) SessionPresent() bool {
+	// Lock for reading.
+	cli.muConn.RLock()
+
+	// Unlock.
+	defer cli.muConn.RUnlock()
+
+	// Check the Network Connection.
+	if cli.conn == nil {
+		return false
+	}
+
+	return cli.conn.sessionPresent
+}
+
+// CONNACKProperties returns the Properties of the MQTT 5.0 CONNACK
+// Packet which the Client received from the Server when connecting.
+// It returns nil if the Client has not yet connected to the Server
+// or the protocol version is not MQTT 5.0.
+func (cli *Client) CONNACKProperties() *packet.Properties {
+	// Lock for reading.
+	cli.muConn.RLock()
+
+	// Unlock.
+	defer cli.muConn.RUnlock()
+
+	// Check the Network Connection.
+	if cli.conn == nil {
+		return nil
+	}
+
+	return cli.conn.connackProps
 }
 
 // Terminate ternimates the Client.
@@ -394,13 +810,36 @@ func (cli *Client) send(p packet.Packet) error {
 		return ErrNotYetConnected
 	}
 
-	// Write the Packet to the buffered writer.
-	if _, err := p.WriteTo(cli.conn.w); err != nil {
+	// Write the Packet to the Network Connection.
+	n, err := cli.conn.writePacket(p)
+	if err != nil {
 		return err
 	}
 
-	// Flush the buffered writer.
-	return cli.conn.w.Flush()
+	// Trace the Packet.
+	if cli.tracer != nil {
+		cli.tracer.Trace(time.Now(), Outgoing, p)
+	}
+
+	// Update the metrics.
+	if cli.metrics != nil {
+		ptype, _ := p.Type()
+
+		cli.metrics.PacketSent(ptype, int(n))
+		cli.metrics.SendQueue(len(cli.conn.send))
+	}
+
+	return nil
+}
+
+// reportSendQueue reports the number of the Packets which are
+// queued for sending before a Packet is queued to the Network
+// Connection so that the backlog is observed even while
+// the sending is blocked.
+func (cli *Client) reportSendQueue(conn *connection) {
+	if cli.metrics != nil {
+		cli.metrics.SendQueue(len(conn.send))
+	}
 }
 
 // sendCONNECT creates a CONNECT Packet and sends it to the Server.
@@ -410,65 +849,218 @@ func (cli *Client) sendCONNECT(opts *packet.CONNECTOptions) error {
 		opts = &packet.CONNECTOptions{}
 	}
 
-	// Create a CONNECT Packet.
-	p, err := packet.NewCONNECT(opts)
-	if err != nil {
-		return err
-	}
+	// Create a CONNECT Packet.
+	p, err := packet.NewCONNECT(opts)
+	if err != nil {
+		return err
+	}
+
+	// Send a CONNECT Packet to the Server.
+	return cli.send(p)
+}
+
+// receiveCONNACK receives the CONNACK Packet from the Server
+// and checks its Connect Return code. The deadline of the context
+// is applied if it is earlier than the timeout.
+func (cli *Client) receiveCONNACK(ctx context.Context, timeout time.Duration) error {
+	// Calculate the deadline for receiving the CONNACK Packet.
+	var deadline time.Time
+
+	if timeout > 0 {
+		deadline = time.Now().Add(timeout * time.Second)
+	}
+
+	ctxDeadline, ok := ctx.Deadline()
+
+	// Check if the deadline of the context is applied.
+	ctxDeadlineApplied := ok && (deadline.IsZero() || ctxDeadline.Before(deadline))
+
+	if ctxDeadlineApplied {
+		deadline = ctxDeadline
+	}
+
+	// Set the deadline for receiving the CONNACK Packet.
+	if !deadline.IsZero() {
+		if err := cli.conn.SetReadDeadline(deadline); err != nil {
+			return err
+		}
+	}
+
+	// Receive a Packet from the Server. The AUTH Packets of
+	// the enhanced authentication can precede the CONNACK Packet.
+	var p packet.Packet
+	var err error
+
+	for {
+		p, err = cli.receive()
+		if err != nil {
+			// Return the timeout error if the deadline has been exceeded.
+			if ne, ok := err.(net.Error); ok && ne.Timeout() {
+				if ctxDeadlineApplied {
+					return context.DeadlineExceeded
+				}
+
+				return ErrCONNACKTimeout
+			}
+
+			return err
+		}
+
+		auth, ok := p.(*packet.AUTH)
+		if !ok {
+			break
+		}
+
+		// Respond to the AUTH Packet.
+		if err := cli.respondAUTH(auth, cli.send); err != nil {
+			return err
+		}
+	}
+
+	// Clear the deadline.
+	if !deadline.IsZero() {
+		if err := cli.conn.SetReadDeadline(time.Time{}); err != nil {
+			return err
+		}
+	}
+
+	// Check if the Packet is a CONNACK Packet.
+	connack, ok := p.(*packet.CONNACK)
+	if !ok {
+		return ErrInvalidCONNACK
+	}
+
+	// Check the Connect Return code or the Connect Reason Code.
+	if connack.ProtocolVersion() == mqtt.ProtocolVersion5 {
+		if err := reasonCodeErr(packet.TypeCONNACK, connack.ConnectReturnCode, connack.Properties); err != nil {
+			return err
+		}
+	} else if err := connectReturnCodeErr(connack.ConnectReturnCode); err != nil {
+		return err
+	}
+
+	// Set the Session Present and the Properties to the Network Connection.
+	cli.conn.sessionPresent = connack.SessionPresent
+	cli.conn.connackProps = connack.Properties
+
+	return nil
+}
+
+// receive receives an MQTT Control Packet from the Server.
+func (cli *Client) receive() (packet.Packet, error) {
+	// Return an error if the Client has not yet connected to the Server.
+	if cli.conn == nil {
+		return nil, ErrNotYetConnected
+	}
+
+	// Read a Packet.
+	p, err := cli.conn.r.ReadPacket()
+	if err != nil {
+		return nil, err
+	}
+
+	// Trace the Packet.
+	if cli.tracer != nil {
+		cli.tracer.Trace(time.Now(), Incoming, p)
+	}
+
+	// Update the metrics.
+	if cli.metrics != nil {
+		ptype, _ := p.Type()
+
+		cli.metrics.PacketReceived(ptype, cli.conn.r.Size())
+	}
+
+	return p, nil
+}
+
+// handshake establishes a Network Connection to the server by
+// the dialing options, sends a CONNECT Packet to it and waits for
+// the CONNACK Packet. The Network Connection is closed and cleaned
+// if the server does not accept the connection.
+func (cli *Client) handshake(ctx context.Context, opts, dialOpts *ConnectOptions, server string) error {
+	// Establish a Network Connection.
+	conn, err := newConnection(ctx, dialOpts)
+	if err != nil {
+		return err
+	}
+
+	conn.server = server
+
+	// Read the PUBLISH Packets into the pooled buffers.
+	conn.r.BufferPool = cli.bufferPool
+
+	// Set the Network Connection to the Client.
+	cli.conn = conn
+
+	// Lock for reading and updating the Session.
+	cli.muSess.Lock()
+
+	// Create a Session or reuse the current Session.
+	if opts.CleanSession || cli.sess == nil {
+		// Create a Session and set it to the Client.
+		cli.sess = newSession(opts.CleanSession, opts.ClientID)
+
+		// Collect the metrics of the Session.
+		cli.sess.metrics = cli.metrics
+		cli.sess.updateInflight()
+
+		// Clear or load the stored Session.
+		err = cli.initSessionStore()
+	} else {
+		// Reuse the Session and set its Client Identifier to the options.
+		opts.ClientID = cli.sess.clientID
+	}
+
+	// Unlock.
+	cli.muSess.Unlock()
 
-	// Send a CONNECT Packet to the Server.
-	return cli.send(p)
-}
+	// Interrupt sending the CONNECT Packet and receiving
+	// the CONNACK Packet when the context is done.
+	stopWatching := conn.watchContext(ctx)
 
-// receive receives an MQTT Control Packet from the Server.
-func (cli *Client) receive() (packet.Packet, error) {
-	// Return an error if the Client has not yet connected to the Server.
-	if cli.conn == nil {
-		return nil, ErrNotYetConnected
+	// Send a CONNECT Packet to the Server.
+	if err == nil {
+		err = cli.sendCONNECT(&packet.CONNECTOptions{
+			ClientID:        opts.ClientID,
+			UserName:        opts.UserName,
+			Password:        opts.Password,
+			CleanSession:    opts.CleanSession,
+			KeepAlive:       opts.KeepAlive,
+			WillTopic:       opts.WillTopic,
+			WillMessage:     opts.WillMessage,
+			WillQoS:         opts.WillQoS,
+			WillRetain:      opts.WillRetain,
+			ProtocolVersion: opts.ProtocolVersion,
+			Properties:      opts.Properties,
+			WillProperties:  opts.WillProperties,
+		})
 	}
 
-	// Get the first byte of the Packet.
-	b, err := cli.conn.r.ReadByte()
-	if err != nil {
-		return nil, err
+	// Receive the CONNACK Packet from the Server.
+	if err == nil {
+		err = cli.receiveCONNACK(ctx, opts.CONNACKTimeout)
 	}
 
-	// Create the Fixed header.
-	fixedHeader := packet.FixedHeader([]byte{b})
-
-	// Get and decode the Remaining Length.
-	var mp uint32 = 1 // multiplier
-	var rl uint32     // the Remaining Length
-	for {
-		// Get the next byte of the Packet.
-		b, err = cli.conn.r.ReadByte()
-		if err != nil {
-			return nil, err
-		}
+	// Stop watching the context.
+	stopWatching()
 
-		fixedHeader = append(fixedHeader, b)
+	if err != nil {
+		// Close the Network Connection.
+		cli.conn.Close()
 
-		rl += uint32(b&0x7F) * mp
+		// Clean the Network Connection and the Session if necessary.
+		cli.clean()
 
-		if b&0x80 == 0 {
-			break
+		// Return the error of the context if it is done.
+		if ctx.Err() != nil {
+			return ctx.Err()
 		}
 
-		mp *= 128
-	}
-
-	// Create the Remaining (the Variable header and the Payload).
-	remaining := make([]byte, rl)
-
-	if rl > 0 {
-		// Get the remaining of the Packet.
-		if _, err = io.ReadFull(cli.conn.r, remaining); err != nil {
-			return nil, err
-		}
+		return err
 	}
 
-	// Create and return a Packet.
-	return packet.NewFromBytes(fixedHeader, remaining)
+	return nil
 }
 
 // clean cleans the Network Connection and the Session if necessary.
@@ -479,7 +1071,37 @@ func (cli *Client) clean() {
 	// Clean the Session if the Clean Session is true.
 	if cli.sess != nil && cli.sess.cleanSession {
 		cli.sess = nil
+
+		// Reset the metrics of the inflight Packets.
+		if cli.metrics != nil {
+			cli.metrics.Inflight(0, 0)
+		}
+	}
+}
+
+// initSessionStore clears the stored Session if the Clean Session
+// is true or loads the stored Packets to the Session otherwise.
+func (cli *Client) initSessionStore() error {
+	if cli.sessionStore == nil {
+		return nil
+	}
+
+	if cli.sess.cleanSession {
+		return cli.sessionStore.Clear(cli.sess.clientID)
+	}
+
+	// Load the stored Packets.
+	cli.sess.store = cli.sessionStore
+
+	if err := cli.sess.load(); err != nil {
+		// Discard the Session so that the stored Packets
+		// are loaded again by the next connection.
+		cli.sess = nil
+
+		return err
 	}
+
+	return nil
 }
 
 // waitPacket waits for receiving the Packet.
@@ -502,13 +1124,7 @@ func (cli *Client) waitPacket(packetc <-chan struct{}, timeout time.Duration, er
 
 // receivePackets receives Packets from the Server.
 func (cli *Client) receivePackets() {
-	defer func() {
-		// Close the channel which handles a signal which
-		// notifies the arrival of the CONNACK Packet.
-		close(cli.conn.connack)
-
-		cli.conn.wg.Done()
-	}()
+	defer cli.conn.wg.Done()
 
 	for {
 		// Receive a Packet from the Server.
@@ -543,9 +1159,6 @@ func (cli *Client) handlePacket(p packet.Packet) error {
 	}
 
 	switch ptype {
-	case packet.TypeCONNACK:
-		cli.handleCONNACK()
-		return nil
 	case packet.TypePUBLISH:
 		return cli.handlePUBLISH(p)
 	case packet.TypePUBACK:
@@ -562,57 +1175,52 @@ func (cli *Client) handlePacket(p packet.Packet) error {
 		return cli.handleUNSUBACK(p)
 	case packet.TypePINGRESP:
 		return cli.handlePINGRESP()
+	case packet.TypeDISCONNECT:
+		return cli.handleDISCONNECT(p)
+	case packet.TypeAUTH:
+		return cli.handleAUTH(p)
 	default:
 		return packet.ErrInvalidPacketType
 	}
 }
 
-// handleCONNACK handles the CONNACK Packet.
-func (cli *Client) handleCONNACK() {
-	// Notify the arrival of the CONNACK Packet if possible.
-	select {
-	case cli.conn.connack <- struct{}{}:
-	default:
-	}
-}
-
 // handlePUBLISH handles the PUBLISH Packet.
 func (cli *Client) handlePUBLISH(p packet.Packet) error {
 	// Get the PUBLISH Packet.
 	publish := p.(*packet.PUBLISH)
 
-	switch publish.QoS {
-	case mqtt.QoS0:
-		// Lock for reading.
-		cli.muConn.RLock()
+	// Get the Network Connection which receives the Packet.
+	cli.muConn.RLock()
+	conn := cli.conn
+	cli.muConn.RUnlock()
 
-		// Unlock.
-		defer cli.muConn.RUnlock()
+	// Resolve the Topic Alias.
+	if err := conn.resolveTopicAlias(publish); err != nil {
+		return err
+	}
 
+	switch publish.QoS {
+	case mqtt.QoS0:
 		// Handle the Application Message.
-		cli.handleMessage(publish.TopicName, publish.Message)
+		cli.handleMessage(publish, nil)
 
 		return nil
 	case mqtt.QoS1:
-		// Lock for reading.
-		cli.muConn.RLock()
-
-		// Unlock.
-		defer cli.muConn.RUnlock()
-
-		// Handle the Application Message.
-		cli.handleMessage(publish.TopicName, publish.Message)
-
 		// Create a PUBACK Packet.
 		puback, err := packet.NewPUBACK(&packet.PUBACKOptions{
-			PacketID: publish.PacketID,
+			PacketID:        publish.PacketID,
+			ProtocolVersion: conn.protocolVersion(),
 		})
 		if err != nil {
 			return err
 		}
 
-		// Send the Packet to the Server.
-		cli.conn.send <- puback
+		// Handle the Application Message. The PUBACK Packet is sent
+		// when the handlers acknowledge it if the manual acknowledgement
+		// is required.
+		cli.handleMessage(publish, func() {
+			cli.sendAck(conn, puback)
+		})
 
 		return nil
 	default:
@@ -628,17 +1236,21 @@ func (cli *Client) handlePUBLISH(p packet.Packet) error {
 		}
 
 		// Set the Packet to the Session.
-		cli.sess.receivingPackets[publish.PacketID] = p
+		if err := cli.sess.setPacket(Incoming, publish.PacketID, p); err != nil {
+			return err
+		}
 
 		// Create a PUBREC Packet.
 		pubrec, err := packet.NewPUBREC(&packet.PUBRECOptions{
-			PacketID: publish.PacketID,
+			PacketID:        publish.PacketID,
+			ProtocolVersion: conn.protocolVersion(),
 		})
 		if err != nil {
 			return err
 		}
 
 		// Send the Packet to the Server.
+		cli.reportSendQueue(cli.conn)
 		cli.conn.send <- pubrec
 
 		return nil
@@ -654,7 +1266,8 @@ func (cli *Client) handlePUBACK(p packet.Packet) error {
 	defer cli.muSess.Unlock()
 
 	// Extract the Packet Identifier of the Packet.
-	id := p.(*packet.PUBACK).PacketID
+	puback := p.(*packet.PUBACK)
+	id := puback.PacketID
 
 	// Validate the Packet Identifier.
 	if err := cli.validatePacketID(cli.sess.sendingPackets, id, packet.TypePUBLISH); err != nil {
@@ -662,7 +1275,15 @@ func (cli *Client) handlePUBACK(p packet.Packet) error {
 	}
 
 	// Delete the PUBLISH Packet from the Session.
-	delete(cli.sess.sendingPackets, id)
+	if err := cli.sess.deletePacket(Outgoing, id); err != nil {
+		return err
+	}
+
+	// Update the metrics of the publication latency.
+	cli.publishCompleted(mqtt.QoS1, id)
+
+	// Complete the Token with the error of the Reason Code.
+	cli.sess.completeToken(id, nil, reasonCodeErr(packet.TypePUBACK, puback.ReasonCode, puback.Properties))
 
 	return nil
 }
@@ -676,25 +1297,43 @@ func (cli *Client) handlePUBREC(p packet.Packet) error {
 	defer cli.muSess.Unlock()
 
 	// Extract the Packet Identifier of the Packet.
-	id := p.(*packet.PUBREC).PacketID
+	pubrec := p.(*packet.PUBREC)
+	id := pubrec.PacketID
 
 	// Validate the Packet Identifier.
 	if err := cli.validatePacketID(cli.sess.sendingPackets, id, packet.TypePUBLISH); err != nil {
 		return err
 	}
 
+	// End the flow if the Server refuses the Application Message.
+	if err := reasonCodeErr(packet.TypePUBREC, pubrec.ReasonCode, pubrec.Properties); err != nil {
+		// Delete the PUBLISH Packet from the Session.
+		if err := cli.sess.deletePacket(Outgoing, id); err != nil {
+			return err
+		}
+
+		// Complete the Token with the error.
+		cli.sess.completeToken(id, nil, err)
+
+		return nil
+	}
+
 	// Create a PUBREL Packet.
 	pubrel, err := packet.NewPUBREL(&packet.PUBRELOptions{
-		PacketID: id,
+		PacketID:        id,
+		ProtocolVersion: cli.conn.protocolVersion(),
 	})
 	if err != nil {
 		return err
 	}
 
 	// Set the PUBREL Packet to the Session.
-	cli.sess.sendingPackets[id] = pubrel
+	if err := cli.sess.setPacket(Outgoing, id, pubrel); err != nil {
+		return err
+	}
 
 	// Send the Packet to the Server.
+	cli.reportSendQueue(cli.conn)
 	cli.conn.send <- pubrel
 
 	return nil
@@ -702,47 +1341,122 @@ func (cli *Client) handlePUBREC(p packet.Packet) error {
 
 // handlePUBREL handles the PUBREL Packet.
 func (cli *Client) handlePUBREL(p packet.Packet) error {
+	// Extract the Packet Identifier of the Packet.
+	id := p.(*packet.PUBREL).PacketID
+
 	// Lock for update.
 	cli.muSess.Lock()
 
-	// Unlock.
-	defer cli.muSess.Unlock()
-
-	// Extract the Packet Identifier of the Packet.
-	id := p.(*packet.PUBREL).PacketID
+	// Ignore the Packet if the Application Message has been
+	// released and is waiting for the manual acknowledgement.
+	if _, released := cli.sess.receivingPackets[id].(*packet.PUBREL); released {
+		cli.muSess.Unlock()
+		return nil
+	}
 
 	// Validate the Packet Identifier.
 	if err := cli.validatePacketID(cli.sess.receivingPackets, id, packet.TypePUBLISH); err != nil {
+		cli.muSess.Unlock()
+		return err
+	}
+
+	// Create a PUBCOMP Packet.
+	pubcomp, err := packet.NewPUBCOMP(&packet.PUBCOMPOptions{
+		PacketID:        id,
+		ProtocolVersion: cli.conn.protocolVersion(),
+	})
+	if err != nil {
+		cli.muSess.Unlock()
 		return err
 	}
 
 	// Get the Packet from the Session.
 	publish := cli.sess.receivingPackets[id].(*packet.PUBLISH)
 
-	// Lock for reading.
-	cli.muConn.RLock()
-
-	// Handle the Application Message.
-	cli.handleMessage(publish.TopicName, publish.Message)
+	// Replace the Packet with the PUBREL Packet to mark the Application
+	// Message as released. The SessionStore keeps the PUBLISH Packet
+	// so that the Application Message is handled again after
+	// the restart of the process if it has not been acknowledged.
+	cli.sess.receivingPackets[id] = p
 
 	// Unlock.
-	cli.muConn.RUnlock()
+	cli.muSess.Unlock()
+
+	// Handle the Application Message. The PUBCOMP Packet is sent
+	// when the handlers acknowledge it if the manual acknowledgement
+	// is required.
+	cli.handleMessage(publish, func() {
+		cli.completePUBREL(id, pubcomp)
+	})
+
+	return nil
+}
+
+// completePUBREL deletes the released Application Message from
+// the Session and sends the PUBCOMP Packet to the Server.
+func (cli *Client) completePUBREL(id uint16, pubcomp packet.Packet) {
+	// Lock for update.
+	cli.muSess.Lock()
+
+	// Do nothing if the Session has been cleaned. The Session is nil
+	// if the Clean Session is true and the Network Connection has been
+	// lost before the acknowledgement.
+	if cli.sess == nil {
+		cli.muSess.Unlock()
+		return
+	}
+
+	if _, released := cli.sess.receivingPackets[id].(*packet.PUBREL); !released {
+		cli.muSess.Unlock()
+		return
+	}
 
 	// Delete the Packet from the Session
-	delete(cli.sess.receivingPackets, id)
+	err := cli.sess.deletePacket(Incoming, id)
 
-	// Create a PUBCOMP Packet.
-	pubcomp, err := packet.NewPUBCOMP(&packet.PUBCOMPOptions{
-		PacketID: id,
-	})
-	if err != nil {
-		return err
+	// Unlock.
+	cli.muSess.Unlock()
+
+	// Handle the error of the SessionStore.
+	if err != nil && cli.errorHandler != nil {
+		cli.errorHandler(err)
 	}
 
 	// Send the Packet to the Server.
-	cli.conn.send <- pubcomp
+	cli.sendAck(nil, pubcomp)
+}
 
-	return nil
+// publishCompleted reports the time from the publication of the Application
+// Message which has the Packet Identifier to the completion of its flow to
+// the Metrics. The Mutex for the Session must be locked by the caller.
+func (cli *Client) publishCompleted(qos byte, id uint16) {
+	if cli.metrics == nil {
+		return
+	}
+
+	// The Token does not exist if the Packet was loaded from the SessionStore.
+	if t, exist := cli.sess.tokens[id]; exist {
+		cli.metrics.PublishCompleted(qos, time.Since(t.created))
+	}
+}
+
+// sendAck sends the acknowledgement Packet to the Server. The Packet
+// is discarded if the Client is not connected to the Server or if
+// conn is not nil and is not the current Network Connection.
+func (cli *Client) sendAck(conn *connection, p packet.Packet) {
+	// Lock for reading.
+	cli.muConn.RLock()
+
+	// Unlock.
+	defer cli.muConn.RUnlock()
+
+	if cli.conn == nil || (conn != nil && cli.conn != conn) {
+		return
+	}
+
+	// Send the Packet to the Server.
+	cli.reportSendQueue(cli.conn)
+	cli.conn.send <- p
 }
 
 // handlePUBCOMP handles the PUBCOMP Packet.
@@ -754,7 +1468,8 @@ func (cli *Client) handlePUBCOMP(p packet.Packet) error {
 	defer cli.muSess.Unlock()
 
 	// Extract the Packet Identifier of the Packet.
-	id := p.(*packet.PUBCOMP).PacketID
+	pubcomp := p.(*packet.PUBCOMP)
+	id := pubcomp.PacketID
 
 	// Validate the Packet Identifier.
 	if err := cli.validatePacketID(cli.sess.sendingPackets, id, packet.TypePUBREL); err != nil {
@@ -762,7 +1477,15 @@ func (cli *Client) handlePUBCOMP(p packet.Packet) error {
 	}
 
 	// Delete the PUBREL Packet from the Session.
-	delete(cli.sess.sendingPackets, id)
+	if err := cli.sess.deletePacket(Outgoing, id); err != nil {
+		return err
+	}
+
+	// Update the metrics of the publication latency.
+	cli.publishCompleted(mqtt.QoS2, id)
+
+	// Complete the Token with the error of the Reason Code.
+	cli.sess.completeToken(id, nil, reasonCodeErr(packet.TypePUBCOMP, pubcomp.ReasonCode, pubcomp.Properties))
 
 	return nil
 }
@@ -789,20 +1512,28 @@ func (cli *Client) handleSUBACK(p packet.Packet) error {
 	subreqs := cli.sess.sendingPackets[id].(*packet.SUBSCRIBE).SubReqs
 
 	// Delete the SUBSCRIBE Packet from the Session.
-	delete(cli.sess.sendingPackets, id)
+	if err := cli.sess.deletePacket(Outgoing, id); err != nil {
+		return err
+	}
 
 	// Get the Return Codes of the SUBACK Packet.
 	returnCodes := p.(*packet.SUBACK).ReturnCodes
 
 	// Check the lengths of the Return Codes.
 	if len(returnCodes) != len(subreqs) {
+		// Complete the Token with the error.
+		cli.sess.completeToken(id, nil, ErrInvalidSUBACK)
+
 		return ErrInvalidSUBACK
 	}
 
+	// Complete the Token.
+	cli.sess.completeToken(id, returnCodes, nil)
+
 	// Set the subscriptions to the Network Connection.
 	for i, code := range returnCodes {
-		// Skip if the Return Code is failure.
-		if code == packet.SUBACKRetFailure {
+		// Skip if the Return Code (the Reason Code) is failure.
+		if code >= packet.SUBACKRetFailure {
 			continue
 		}
 
@@ -811,7 +1542,7 @@ func (cli *Client) handleSUBACK(p packet.Packet) error {
 
 		// Move the subscription information from
 		// unackSubs to ackedSubs.
-		cli.conn.ackedSubs[topicFilter] = cli.conn.unackSubs[topicFilter]
+		cli.conn.ackedSubs.Add(topicFilter, cli.conn.unackSubs[topicFilter])
 		delete(cli.conn.unackSubs, topicFilter)
 	}
 
@@ -829,7 +1560,8 @@ func (cli *Client) handleUNSUBACK(p packet.Packet) error {
 	defer cli.muSess.Unlock()
 
 	// Extract the Packet Identifier of the Packet.
-	id := p.(*packet.UNSUBACK).PacketID
+	unsuback := p.(*packet.UNSUBACK)
+	id := unsuback.PacketID
 
 	// Validate the Packet Identifier.
 	if err := cli.validatePacketID(cli.sess.sendingPackets, id, packet.TypeUNSUBSCRIBE); err != nil {
@@ -840,11 +1572,15 @@ func (cli *Client) handleUNSUBACK(p packet.Packet) error {
 	topicFilters := cli.sess.sendingPackets[id].(*packet.UNSUBSCRIBE).TopicFilters
 
 	// Delete the UNSUBSCRIBE Packet from the Session.
-	delete(cli.sess.sendingPackets, id)
+	cli.sess.forgetSendingPacket(id)
+	cli.sess.updateInflight()
+
+	// Complete the Token with the Reason Codes of MQTT 5.0.
+	cli.sess.completeToken(id, unsuback.ReasonCodes, nil)
 
 	// Delete the Topic Filters from the Network Connection.
 	for _, topicFilter := range topicFilters {
-		delete(cli.conn.ackedSubs, string(topicFilter))
+		cli.conn.ackedSubs.Remove(string(topicFilter))
 	}
 
 	return nil
@@ -870,6 +1606,15 @@ func (cli *Client) handlePINGRESP() error {
 	// Remove the first channel from pingrespcs.
 	cli.conn.pingresps = cli.conn.pingresps[1:]
 
+	// Update the metrics of the latency of the PINGREQ Packet.
+	if len(cli.conn.pingreqTimes) > 0 {
+		if cli.metrics != nil {
+			cli.metrics.PINGRESPReceived(time.Since(cli.conn.pingreqTimes[0]))
+		}
+
+		cli.conn.pingreqTimes = cli.conn.pingreqTimes[1:]
+	}
+
 	// Unlock.
 	cli.conn.muPINGRESPs.Unlock()
 
@@ -882,6 +1627,68 @@ func (cli *Client) handlePINGRESP() error {
 	return nil
 }
 
+// handleDISCONNECT handles the DISCONNECT Packet of MQTT 5.0.
+// It returns the error of the Reason Code so that the Network
+// Connection is disconnected.
+func (cli *Client) handleDISCONNECT(p packet.Packet) error {
+	// Get the DISCONNECT Packet.
+	disconnect := p.(*packet.DISCONNECT)
+
+	// The Server must not send the DISCONNECT Packet in MQTT 3.1.1.
+	if disconnect.ProtocolVersion() != mqtt.ProtocolVersion5 {
+		return packet.ErrInvalidPacketType
+	}
+
+	return &ReasonCodeError{
+		PacketType: packet.TypeDISCONNECT,
+		ReasonCode: disconnect.ReasonCode,
+		Properties: disconnect.Properties,
+	}
+}
+
+// handleAUTH handles the AUTH Packet of the re-authentication.
+func (cli *Client) handleAUTH(p packet.Packet) error {
+	return cli.respondAUTH(p.(*packet.AUTH), func(auth packet.Packet) error {
+		cli.reportSendQueue(cli.conn)
+		cli.conn.send <- auth
+		return nil
+	})
+}
+
+// respondAUTH calls the AuthHandler with the AUTH Packet and sends
+// the AUTH Packet which continues the authentication by the send
+// function. It does nothing if the Server reports the success of
+// the re-authentication.
+func (cli *Client) respondAUTH(auth *packet.AUTH, send func(packet.Packet) error) error {
+	// Return an error if the AUTH Packet is not expected.
+	if cli.conn.authHandler == nil {
+		return packet.ErrInvalidPacketType
+	}
+
+	// End the process if the authentication succeeds.
+	if auth.ReasonCode == packet.ReasonSuccess {
+		return nil
+	}
+
+	// Handle the AUTH Packet.
+	props, err := cli.conn.authHandler(auth.ReasonCode, auth.Properties)
+	if err != nil {
+		return err
+	}
+
+	// Create an AUTH Packet.
+	p, err := packet.NewAUTH(&packet.AUTHOptions{
+		ReasonCode: packet.ReasonContinueAuthentication,
+		Properties: props,
+	})
+	if err != nil {
+		return err
+	}
+
+	// Send the Packet to the Server.
+	return send(p)
+}
+
 // handleError handles the error and disconnects
 // the Network Connection.
 func (cli *Client) handleErrorAndDisconn(err error) {
@@ -906,10 +1713,10 @@ func (cli *Client) handleErrorAndDisconn(err error) {
 		cli.errorHandler(err)
 	}
 
-	// Send a disconnect signal to the goroutine
-	// via the channel if possible.
+	// Send a disconnect signal with the cause to
+	// the goroutine via the channel if possible.
 	select {
-	case cli.disconnc <- struct{}{}:
+	case cli.disconnc <- err:
 	default:
 	}
 }
@@ -928,10 +1735,16 @@ func (cli *Client) sendPackets(keepAlive time.Duration, pingrespTimeout time.Dur
 
 		// Initialize pingrespcs
 		cli.conn.pingresps = make([]chan struct{}, 0)
+		cli.conn.pingreqTimes = nil
 
 		// Unlock.
 		cli.conn.muPINGRESPs.Unlock()
 
+		// End the goroutine which resends the Packets.
+		if cli.conn.retryEnd != nil {
+			close(cli.conn.retryEnd)
+		}
+
 		cli.conn.wg.Done()
 	}()
 
@@ -970,6 +1783,7 @@ func (cli *Client) sendPackets(keepAlive time.Duration, pingrespTimeout time.Dur
 
 			// Append the channel to pingrespcs.
 			cli.conn.pingresps = append(cli.conn.pingresps, pingresp)
+			cli.conn.pingreqTimes = append(cli.conn.pingreqTimes, time.Now())
 
 			// Launch a goroutine which waits for receiving the PINGRESP Packet.
 			cli.conn.wg.Add(1)
@@ -1046,11 +1860,13 @@ func (cli *Client) newPUBLISHPacket(opts *PublishOptions) (packet.Packet, error)
 
 	// Create a PUBLISH Packet.
 	p, err := packet.NewPUBLISH(&packet.PUBLISHOptions{
-		QoS:       opts.QoS,
-		Retain:    opts.Retain,
-		TopicName: opts.TopicName,
-		PacketID:  packetID,
-		Message:   opts.Message,
+		QoS:             opts.QoS,
+		Retain:          opts.Retain,
+		TopicName:       opts.TopicName,
+		PacketID:        packetID,
+		Message:         opts.Message,
+		ProtocolVersion: cli.conn.protocolVersion(),
+		Properties:      opts.Properties,
 	})
 	if err != nil {
 		return nil, err
@@ -1058,7 +1874,11 @@ func (cli *Client) newPUBLISHPacket(opts *PublishOptions) (packet.Packet, error)
 
 	if opts.QoS != mqtt.QoS0 {
 		// Set the Packet to the Session.
-		cli.sess.sendingPackets[packetID] = p
+		if err := cli.sess.setPacket(Outgoing, packetID, p); err != nil {
+			cli.sess.forgetSendingPacket(packetID)
+			cli.sess.updateInflight()
+			return nil, err
+		}
 	}
 
 	// Return the Packet.
@@ -1094,18 +1914,112 @@ func (cli *Client) validatePacketID(packets map[uint16]packet.Packet, id uint16,
 	return nil
 }
 
-// handleMessage handles the Application Message.
-func (cli *Client) handleMessage(topicName, message []byte) {
+// handleMessage dispatches the Application Message of
+// the PUBLISH Packet to the handlers of the matching
+// subscriptions. ack is executed to send the acknowledgement
+// when all handlers which require the manual acknowledgement
+// acknowledge the Application Message or immediately if there
+// is no such handler. It blocks while the number of the pending
+// handler executions reaches the limit.
+func (cli *Client) handleMessage(publish *packet.PUBLISH, ack func()) {
 	// Get the string of the Topic Name.
-	topicNameStr := string(topicName)
+	topicNameStr := string(publish.TopicName)
 
-	for topicFilter, handler := range cli.conn.ackedSubs {
-		if handler == nil || !match(topicNameStr, topicFilter) {
-			continue
+	// Define the matching subscriptions and their keys.
+	var keys []string
+	var subReqs []*SubReq
+
+	// Define the number of the handlers which require
+	// the manual acknowledgement.
+	var manualAcks int32
+
+	// Lock for reading.
+	cli.muConn.RLock()
+
+	// Find the subscriptions whose Topic Filters match the Topic Name.
+	cli.conn.ackedSubs.Match(topicNameStr, func(topicFilter string, v interface{}) {
+		subReq := v.(*SubReq)
+
+		if subReq == nil || (subReq.Handler == nil && subReq.OnMessage == nil) {
+			return
+		}
+
+		// Set the key.
+		if cli.dispatcher.mode == DispatchOrderedByTopic {
+			keys = append(keys, topicNameStr)
+		} else {
+			keys = append(keys, topicFilter)
+		}
+
+		subReqs = append(subReqs, subReq)
+
+		if subReq.manualAck() && ack != nil {
+			manualAcks++
+		}
+	})
+
+	// Unlock.
+	cli.muConn.RUnlock()
+
+	// Create the buffer shared by the handlers which is released when
+	// all of them return, or release it immediately if there are no
+	// handlers. The buffer of the QoS 2 Application Message is not
+	// released because the Session holds the PUBLISH Packet.
+	var mbuf *messageBuffer
+
+	if cli.bufferPool != nil && publish.QoS != mqtt.QoS2 {
+		if len(subReqs) > 0 {
+			mbuf = &messageBuffer{
+				remaining: int32(len(subReqs)),
+				publish:   publish,
+			}
+		} else {
+			publish.Release()
+		}
+	}
+
+	// Create the acknowledgement shared by the handlers which require
+	// the manual acknowledgement or send the acknowledgement immediately.
+	var mack *messageAck
+
+	if manualAcks > 0 {
+		mack = &messageAck{
+			remaining: manualAcks,
+			send:      ack,
+		}
+	} else if ack != nil {
+		ack()
+	}
+
+	// Dispatch the handler executions.
+	for i, subReq := range subReqs {
+		var f func()
+
+		if subReq.OnMessage != nil {
+			msg := newMessage(publish)
+
+			if subReq.manualAck() {
+				msg.ack = mack
+			}
+
+			msg.buf = mbuf
+
+			onMessage := subReq.OnMessage
+
+			f = func() {
+				onMessage(msg)
+				mbuf.done()
+			}
+		} else {
+			handler := subReq.Handler
+
+			f = func() {
+				handler(publish.TopicName, publish.Message)
+				mbuf.done()
+			}
 		}
 
-		// Execute the handler.
-		go handler(topicName, message)
+		cli.dispatcher.dispatch(keys[i], f)
 	}
 }
 
@@ -1117,9 +2031,23 @@ func New(opts *Options) *Client {
 	}
 	// Create a Client.
 	cli := &Client{
-		disconnc:     make(chan struct{}, 1),
-		disconnEndc:  make(chan struct{}),
-		errorHandler: opts.ErrorHandler,
+		disconnc:         make(chan error, 1),
+		disconnEndc:      make(chan struct{}),
+		errorHandler:     opts.ErrorHandler,
+		dispatcher:       newDispatcher(opts.DispatchMode, opts.DispatchWorkers, opts.MaxPendingMessages),
+		sessionStore:     opts.SessionStore,
+		tracer:           opts.Tracer,
+		metrics:          opts.Metrics,
+		onConnect:        opts.OnConnect,
+		onConnectionLost: opts.OnConnectionLost,
+		onReconnecting:   opts.OnReconnecting,
+		onDisconnect:     opts.OnDisconnect,
+		onAbandon:        opts.OnAbandon,
+	}
+
+	// Create a BufferPool if the receive buffers are pooled.
+	if opts.PoolReceiveBuffers {
+		cli.bufferPool = packet.NewBufferPool()
 	}
 
 	// Launch a goroutine which disconnects the Network Connection.
@@ -1131,12 +2059,24 @@ func New(opts *Options) *Client {
 
 		for {
 			select {
-			case <-cli.disconnc:
-				if err := cli.Disconnect(); err != nil {
+			case cause := <-cli.disconnc:
+				// Get the information for reconnecting
+				// before it is cleaned by the disconnection.
+				opts, ackedSubReqs, unackSubReqs := cli.reconnectInfo()
+
+				if err := cli.disconnect(context.Background(), cause); err != nil {
 					if cli.errorHandler != nil {
 						cli.errorHandler(err)
 					}
 				}
+
+				// Reconnect to the Server if necessary.
+				if opts != nil && opts.AutoReconnect {
+					if terminated := cli.reconnect(opts, ackedSubReqs, unackSubReqs); terminated {
+						// End the goroutine.
+						return
+					}
+				}
 			case <-cli.disconnEndc:
 				// End the goroutine.
 				return
@@ -1149,33 +2089,24 @@ func New(opts *Options) *Client {
 	return cli
 }
 
-// match checks if the Topic Name matches the Topic Filter.
-func match(topicName, topicFilter string) bool {
-	// Tokenize the Topic Name.
-	nameTokens := strings.Split(topicName, "/")
-	nameTokensLen := len(nameTokens)
-
-	// Tolenize the Topic Filter.
-	filterTokens := strings.Split(topicFilter, "/")
-
-	for i, t := range filterTokens {
-		switch t {
-		case "#":
-			return i != 0 || !strings.HasPrefix(nameTokens[0], "$")
-		case "+":
-			if i == 0 && strings.HasPrefix(nameTokens[0], "$") {
-				return false
-			}
-
-			if nameTokensLen <= i {
-				return false
-			}
-		default:
-			if nameTokensLen <= i || t != nameTokens[i] {
-				return false
-			}
-		}
+// connectReturnCodeErr returns the error which represents
+// the Connect Return code. It returns nil if the Connect Return code
+// represents "Connection Accepted".
+func connectReturnCodeErr(code byte) error {
+	switch code {
+	case packet.ConnRetAccepted:
+		return nil
+	case packet.ConnRetUnacceptableProtocolVersion:
+		return ErrUnacceptableProtocolVersion
+	case packet.ConnRetIdentifierRejected:
+		return ErrIdentifierRejected
+	case packet.ConnRetServerUnavailable:
+		return ErrServerUnavailable
+	case packet.ConnRetBadUserNameOrPassword:
+		return ErrBadUserNameOrPassword
+	case packet.ConnRetNotAuthorized:
+		return ErrNotAuthorized
+	default:
+		return packet.ErrInvalidConnectReturnCode
 	}
-
-	return len(filterTokens) == nameTokensLen
 }
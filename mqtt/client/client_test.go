@@ -1,14 +1,17 @@
 package client
 
 import (
+	"context"
 	"errors"
 	"io"
 	"net"
+	"reflect"
 	"testing"
 	"time"
 
 	"github.com/yosssi/gmq/mqtt"
 	"github.com/yosssi/gmq/mqtt/packet"
+	"github.com/yosssi/gmq/mqtt/topic"
 )
 
 var errTest = errors.New("test error")
@@ -23,6 +26,119 @@ func (p *packetErr) Type() (byte, error) {
 	return 0x00, errTest
 }
 
+// testCONNACK is the CONNACK Packet which accepts the connection.
+var testCONNACK = []byte{packet.TypeCONNACK << 4, 0x02, 0x00, 0x00}
+
+// newTestServer launches a server which handles each accepted
+// connection by the handler and returns its listener.
+func newTestServer(t *testing.T, handler func(conn net.Conn)) net.Listener {
+	ln, err := net.Listen("tcp", "localhost:0")
+	if err != nil {
+		t.Fatalf("err => %q, want => nil", err)
+	}
+
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+
+			go handler(conn)
+		}
+	}()
+
+	return ln
+}
+
+// newTestClient launches a server which accepts the CONNECT Packet
+// and handles the subsequent Packets by the handler, and returns
+// a Client which has connected to the server and its listener.
+func newTestClient(t *testing.T, handler func(conn net.Conn)) (*Client, net.Listener) {
+	return newTestClientWithOptions(t, &Options{
+		ErrorHandler: func(_ error) {},
+	}, &ConnectOptions{
+		ClientID: []byte("clientID"),
+	}, handler)
+}
+
+// newTestClientWithOptions is the same as newTestClient except that
+// the Client is created and connected with the options.
+func newTestClientWithOptions(t *testing.T, opts *Options, connectOpts *ConnectOptions, handler func(conn net.Conn)) (*Client, net.Listener) {
+	ln := newTestServer(t, func(conn net.Conn) {
+		defer conn.Close()
+
+		if _, _, err := readTestPacket(conn); err != nil {
+			return
+		}
+
+		if _, err := conn.Write(testCONNACK); err != nil {
+			return
+		}
+
+		handler(conn)
+	})
+
+	cli := New(opts)
+
+	connectOpts.Network = "tcp"
+	connectOpts.Address = ln.Addr().String()
+
+	if err := cli.Connect(connectOpts); err != nil {
+		ln.Close()
+		t.Fatalf("err => %q, want => nil", err)
+	}
+
+	return cli, ln
+}
+
+// readTestPacket reads an MQTT Control Packet from the reader
+// and returns its first byte of the fixed header and its remaining.
+func readTestPacket(r io.Reader) (byte, []byte, error) {
+	b := make([]byte, 1)
+
+	if _, err := io.ReadFull(r, b); err != nil {
+		return 0, nil, err
+	}
+
+	first := b[0]
+
+	var mp, rl uint32 = 1, 0
+
+	for {
+		if _, err := io.ReadFull(r, b); err != nil {
+			return 0, nil, err
+		}
+
+		rl += uint32(b[0]&0x7F) * mp
+
+		if b[0]&0x80 == 0 {
+			break
+		}
+
+		mp *= 128
+	}
+
+	remaining := make([]byte, rl)
+
+	if _, err := io.ReadFull(r, remaining); err != nil {
+		return 0, nil, err
+	}
+
+	return first, remaining, nil
+}
+
+// newTestTrie creates and returns a Trie of the subscriptions.
+func newTestTrie(subs map[string]*SubReq) *topic.Trie {
+	tr := topic.NewTrie()
+
+	for topicFilter, subReq := range subs {
+		tr.Add(topicFilter, subReq)
+	}
+
+	return tr
+}
+
 func TestClient_Connect_ErrAlreadyConnected(t *testing.T) {
 	cli := New(&Options{
 		ErrorHandler: func(_ error) {},
@@ -71,7 +187,8 @@ func TestClient_Connect_restoreSession(t *testing.T) {
 	cli.sess = newSession(false, []byte("cliendID"))
 
 	publish, err := packet.NewPUBLISH(&packet.PUBLISHOptions{
-		PacketID: 1,
+		TopicName: []byte("topicName"),
+		PacketID:  1,
 	})
 	if err != nil {
 		nilErrorExpected(t, err)
@@ -146,1002 +263,1076 @@ func TestClient_Connect_CloseErr(t *testing.T) {
 	ln.Close()
 }
 
-func TestClient_Disconnect_sendEndDefault(t *testing.T) {
+func TestClient_Connect_connectReturnCodeErr(t *testing.T) {
+	ln := newTestServer(t, func(conn net.Conn) {
+		conn.Write([]byte{packet.TypeCONNACK << 4, 0x02, 0x00, packet.ConnRetNotAuthorized})
+		conn.Close()
+	})
+
+	defer ln.Close()
+
 	cli := New(&Options{
 		ErrorHandler: func(_ error) {},
 	})
 
+	defer cli.Terminate()
+
 	err := cli.Connect(&ConnectOptions{
 		Network:  "tcp",
-		Address:  testAddress,
+		Address:  ln.Addr().String(),
 		ClientID: []byte("clientID"),
 	})
-	if err != nil {
-		nilErrorExpected(t, err)
+	if err != ErrNotAuthorized {
+		invalidError(t, err, ErrNotAuthorized)
 	}
 
-	cli.conn.sendEnd <- struct{}{}
-
-	time.Sleep(3 * time.Second)
-
-	cli.conn.sendEnd <- struct{}{}
-
-	if err := cli.Disconnect(); err != nil {
-		nilErrorExpected(t, err)
+	if cli.conn != nil {
+		t.Error("cli.conn => not nil, want => nil")
 	}
 }
 
-func TestClient_Publish_connNil(t *testing.T) {
-	cli := New(&Options{
-		ErrorHandler: func(_ error) {},
+func TestClient_Connect_ErrInvalidCONNACK(t *testing.T) {
+	ln := newTestServer(t, func(conn net.Conn) {
+		conn.Write([]byte{packet.TypePINGRESP << 4, 0x00})
 	})
 
-	if err := cli.Publish(nil); err != ErrNotYetConnected {
-		invalidError(t, err, ErrNotYetConnected)
-	}
-}
+	defer ln.Close()
 
-func TestClient_Publish_newPUBLISHPacketErr(t *testing.T) {
 	cli := New(&Options{
 		ErrorHandler: func(_ error) {},
 	})
 
-	cli.conn = &connection{}
-
-	cli.sess = newSession(false, []byte("cliendID"))
+	defer cli.Terminate()
 
-	err := cli.Publish(&PublishOptions{
-		QoS: 0x03,
+	err := cli.Connect(&ConnectOptions{
+		Network:  "tcp",
+		Address:  ln.Addr().String(),
+		ClientID: []byte("clientID"),
 	})
-
-	if err != packet.ErrInvalidQoS {
-		invalidError(t, err, packet.ErrInvalidQoS)
+	if err != ErrInvalidCONNACK {
+		invalidError(t, err, ErrInvalidCONNACK)
 	}
 }
 
-func TestClient_Publish(t *testing.T) {
+func TestClient_Connect_ErrCONNACKTimeout(t *testing.T) {
+	ln := newTestServer(t, func(conn net.Conn) {})
+
+	defer ln.Close()
+
 	cli := New(&Options{
 		ErrorHandler: func(_ error) {},
 	})
 
-	cli.conn = &connection{}
-
-	cli.conn.send = make(chan packet.Packet, 1)
+	defer cli.Terminate()
 
-	if err := cli.Publish(nil); err != nil {
-		nilErrorExpected(t, err)
+	err := cli.Connect(&ConnectOptions{
+		Network:        "tcp",
+		Address:        ln.Addr().String(),
+		ClientID:       []byte("clientID"),
+		CONNACKTimeout: 1,
+	})
+	if err != ErrCONNACKTimeout {
+		invalidError(t, err, ErrCONNACKTimeout)
 	}
 }
 
-func TestClient_Subscribe_connNil(t *testing.T) {
+func TestClient_Connect_SessionPresent(t *testing.T) {
+	ln := newTestServer(t, func(conn net.Conn) {
+		conn.Write([]byte{packet.TypeCONNACK << 4, 0x02, 0x01, packet.ConnRetAccepted})
+	})
+
+	defer ln.Close()
+
 	cli := New(&Options{
 		ErrorHandler: func(_ error) {},
 	})
 
-	if err := cli.Subscribe(nil); err != ErrNotYetConnected {
-		invalidError(t, err, ErrNotYetConnected)
+	defer cli.Terminate()
+
+	if cli.SessionPresent() {
+		t.Error("cli.SessionPresent() => true, want => false")
 	}
-}
 
-func TestClient_Subscribe_ErrInvalidNoSubReq(t *testing.T) {
-	cli := New(&Options{
-		ErrorHandler: func(_ error) {},
+	err := cli.Connect(&ConnectOptions{
+		Network:  "tcp",
+		Address:  ln.Addr().String(),
+		ClientID: []byte("clientID"),
 	})
+	if err != nil {
+		nilErrorExpected(t, err)
+		return
+	}
 
-	cli.conn = &connection{}
+	if !cli.SessionPresent() {
+		t.Error("cli.SessionPresent() => false, want => true")
+	}
 
-	if err := cli.Subscribe(nil); err != packet.ErrInvalidNoSubReq {
-		invalidError(t, err, packet.ErrInvalidNoSubReq)
+	if err := cli.Disconnect(); err != nil {
+		nilErrorExpected(t, err)
 	}
 }
 
-func TestClient_Subscribe_generatePacketIDErr(t *testing.T) {
+func TestClient_ConnectContext_DeadlineExceeded(t *testing.T) {
+	// Keep the Network Connection without sending the CONNACK Packet.
+	ln := newTestServer(t, func(conn net.Conn) {
+		io.Copy(io.Discard, conn)
+	})
+
+	defer ln.Close()
+
 	cli := New(&Options{
 		ErrorHandler: func(_ error) {},
 	})
 
-	cli.conn = &connection{}
-
-	cli.sess = newSession(false, []byte("clientID"))
+	defer cli.Terminate()
 
-	id := minPacketID
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
 
-	for {
-		cli.sess.sendingPackets[id] = nil
+	defer cancel()
 
-		if id == maxPacketID {
-			break
-		}
+	err := cli.ConnectContext(ctx, &ConnectOptions{
+		Network:        "tcp",
+		Address:        ln.Addr().String(),
+		ClientID:       []byte("clientID"),
+		CONNACKTimeout: 10,
+	})
+	if err != context.DeadlineExceeded {
+		invalidError(t, err, context.DeadlineExceeded)
+	}
 
-		id++
+	if cli.conn != nil {
+		t.Error("cli.conn => not nil, want => nil")
 	}
+}
 
-	err := cli.Subscribe(&SubscribeOptions{
-		SubReqs: []*SubReq{
-			&SubReq{},
-		},
+func TestClient_ConnectContext_Canceled(t *testing.T) {
+	// Keep the Network Connection without sending the CONNACK Packet.
+	ln := newTestServer(t, func(conn net.Conn) {
+		io.Copy(io.Discard, conn)
 	})
 
-	if err != ErrPacketIDExhaused {
-		invalidError(t, err, ErrPacketIDExhaused)
-	}
-}
+	defer ln.Close()
 
-func TestClient_Subscribe_NewSUBSCRIBEErr(t *testing.T) {
 	cli := New(&Options{
 		ErrorHandler: func(_ error) {},
 	})
 
-	cli.conn = &connection{}
+	defer cli.Terminate()
 
-	cli.sess = newSession(false, []byte("clientID"))
+	ctx, cancel := context.WithCancel(context.Background())
 
-	err := cli.Subscribe(&SubscribeOptions{
-		SubReqs: []*SubReq{
-			&SubReq{},
-		},
-	})
+	time.AfterFunc(100*time.Millisecond, cancel)
 
-	if err != packet.ErrNoTopicFilter {
-		invalidError(t, err, packet.ErrNoTopicFilter)
+	err := cli.ConnectContext(ctx, &ConnectOptions{
+		Network:  "tcp",
+		Address:  ln.Addr().String(),
+		ClientID: []byte("clientID"),
+	})
+	if err != context.Canceled {
+		invalidError(t, err, context.Canceled)
 	}
 }
 
-func TestClient_Subscribe(t *testing.T) {
+func TestClient_ConnectContext_dialCanceled(t *testing.T) {
 	cli := New(&Options{
 		ErrorHandler: func(_ error) {},
 	})
 
-	cli.conn = &connection{}
-
-	cli.sess = newSession(false, []byte("clientID"))
+	defer cli.Terminate()
 
-	cli.conn.unackSubs = make(map[string]MessageHandler)
+	ctx, cancel := context.WithCancel(context.Background())
 
-	cli.conn.send = make(chan packet.Packet, 1)
+	cancel()
 
-	err := cli.Subscribe(&SubscribeOptions{
-		SubReqs: []*SubReq{
-			&SubReq{
-				TopicFilter: []byte("topicFilter"),
-			},
-		},
+	err := cli.ConnectContext(ctx, &ConnectOptions{
+		Network:  "tcp",
+		Address:  "localhost:1883",
+		ClientID: []byte("clientID"),
 	})
-
-	if err != nil {
-		nilErrorExpected(t, err)
+	if err == nil {
+		notNilErrorExpected(t)
 	}
 }
 
-func TestClient_Unsubscribe_connNil(t *testing.T) {
-	cli := New(&Options{
-		ErrorHandler: func(_ error) {},
+func TestClient_DisconnectContext(t *testing.T) {
+	cli, ln := newTestClient(t, func(conn net.Conn) {
+		readTestPacket(conn)
 	})
 
-	if err := cli.Unsubscribe(nil); err != ErrNotYetConnected {
-		invalidError(t, err, ErrNotYetConnected)
-	}
-}
+	defer ln.Close()
 
-func TestClient_Unsubscribe_ErrNoTopicFilter(t *testing.T) {
-	cli := New(&Options{
-		ErrorHandler: func(_ error) {},
-	})
+	defer cli.Terminate()
 
-	cli.conn = &connection{}
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
 
-	if err := cli.Unsubscribe(nil); err != packet.ErrNoTopicFilter {
-		invalidError(t, err, packet.ErrNoTopicFilter)
+	defer cancel()
+
+	if err := cli.DisconnectContext(ctx); err != nil {
+		nilErrorExpected(t, err)
+	}
+
+	if err := cli.DisconnectContext(ctx); err != ErrNotYetConnected {
+		invalidError(t, err, ErrNotYetConnected)
 	}
 }
 
-func TestClient_Unsubscribe_generatePacketIDErr(t *testing.T) {
+func TestClient_Disconnect_sendEndDefault(t *testing.T) {
 	cli := New(&Options{
 		ErrorHandler: func(_ error) {},
 	})
 
-	cli.conn = &connection{}
-
-	cli.sess = newSession(false, []byte("clientID"))
+	err := cli.Connect(&ConnectOptions{
+		Network:  "tcp",
+		Address:  testAddress,
+		ClientID: []byte("clientID"),
+	})
+	if err != nil {
+		nilErrorExpected(t, err)
+	}
 
-	id := minPacketID
+	cli.conn.sendEnd <- struct{}{}
 
-	for {
-		cli.sess.sendingPackets[id] = nil
+	time.Sleep(3 * time.Second)
 
-		if id == maxPacketID {
-			break
-		}
+	cli.conn.sendEnd <- struct{}{}
 
-		id++
+	if err := cli.Disconnect(); err != nil {
+		nilErrorExpected(t, err)
 	}
+}
 
-	err := cli.Unsubscribe(&UnsubscribeOptions{
-		TopicFilters: [][]byte{
-			[]byte{0x00},
-		},
+func TestClient_Publish_connNil(t *testing.T) {
+	cli := New(&Options{
+		ErrorHandler: func(_ error) {},
 	})
 
-	if err != ErrPacketIDExhaused {
-		invalidError(t, err, ErrPacketIDExhaused)
+	if err := cli.Publish(nil); err != ErrNotYetConnected {
+		invalidError(t, err, ErrNotYetConnected)
 	}
 }
 
-func TestClient_Unsubscribe_NewUNSUBSCRIBEErr(t *testing.T) {
+func TestClient_Publish_newPUBLISHPacketErr(t *testing.T) {
 	cli := New(&Options{
 		ErrorHandler: func(_ error) {},
 	})
 
 	cli.conn = &connection{}
 
-	cli.sess = newSession(false, []byte("clientID"))
+	cli.sess = newSession(false, []byte("cliendID"))
 
-	err := cli.Unsubscribe(&UnsubscribeOptions{
-		TopicFilters: [][]byte{
-			make([]byte, 0),
-		},
+	err := cli.Publish(&PublishOptions{
+		QoS: 0x03,
 	})
 
-	if err != packet.ErrNoTopicFilter {
-		invalidError(t, err, packet.ErrNoTopicFilter)
+	if err != packet.ErrInvalidQoS {
+		invalidError(t, err, packet.ErrInvalidQoS)
 	}
 }
 
-func TestClient_Unsubscribe(t *testing.T) {
+func TestClient_Publish(t *testing.T) {
 	cli := New(&Options{
 		ErrorHandler: func(_ error) {},
 	})
 
 	cli.conn = &connection{}
 
-	cli.sess = newSession(false, []byte("clientID"))
-
 	cli.conn.send = make(chan packet.Packet, 1)
 
-	err := cli.Unsubscribe(&UnsubscribeOptions{
-		TopicFilters: [][]byte{
-			[]byte{0x00},
-		},
-	})
+	if err := cli.Publish(nil); err != packet.ErrNoTopicName {
+		invalidError(t, err, packet.ErrNoTopicName)
+	}
 
+	err := cli.Publish(&PublishOptions{
+		TopicName: []byte("topicName"),
+	})
 	if err != nil {
 		nilErrorExpected(t, err)
 	}
 }
 
-func TestClient_Terminate(t *testing.T) {
-	cli := &Client{}
+func TestClient_PublishAsync_QoS1(t *testing.T) {
+	cli, ln := newTestClient(t, func(conn net.Conn) {
+		_, remaining, err := readTestPacket(conn)
+		if err != nil {
+			return
+		}
 
-	cli.disconnEndc = make(chan struct{}, 1)
+		l := len(remaining)
 
-	cli.Terminate()
-}
+		conn.Write([]byte{packet.TypePUBACK << 4, 0x02, remaining[l-2], remaining[l-1]})
 
-func TestClient_send_connNil(t *testing.T) {
-	cli := New(&Options{
-		ErrorHandler: func(_ error) {},
+		readTestPacket(conn)
 	})
 
-	if err := cli.send(nil); err != ErrNotYetConnected {
-		invalidError(t, err, ErrNotYetConnected)
-	}
-}
-
-func TestClient_sendCONNECT_optsNil(t *testing.T) {
-	cli := New(&Options{
-		ErrorHandler: func(_ error) {},
-	})
+	defer ln.Close()
 
-	if err := cli.sendCONNECT(nil); err != packet.ErrInvalidClientIDCleanSession {
-		invalidError(t, err, packet.ErrInvalidClientIDCleanSession)
-	}
-}
+	defer cli.Terminate()
 
-func TestClient_receive_connNil(t *testing.T) {
-	cli := New(&Options{
-		ErrorHandler: func(_ error) {},
+	tk, err := cli.PublishAsync(&PublishOptions{
+		QoS:       mqtt.QoS1,
+		TopicName: []byte("topicName"),
 	})
+	if err != nil {
+		nilErrorExpected(t, err)
+		return
+	}
 
-	if _, err := cli.receive(); err != ErrNotYetConnected {
-		invalidError(t, err, ErrNotYetConnected)
+	if err := tk.Wait(); err != nil {
+		nilErrorExpected(t, err)
 	}
-}
 
-func TestClient_receive_ReadByteErr(t *testing.T) {
-	ln, err := net.Listen("tcp", ":1883")
-	if err != nil {
+	if err := cli.Disconnect(); err != nil {
 		nilErrorExpected(t, err)
-		return
 	}
+}
 
-	c := make(chan struct{})
+func TestClient_PublishAsync_QoS2(t *testing.T) {
+	cli, ln := newTestClient(t, func(conn net.Conn) {
+		_, remaining, err := readTestPacket(conn)
+		if err != nil {
+			return
+		}
 
-	go func() {
-		for {
-			conn, err := ln.Accept()
-			if err != nil {
-				return
-			}
-			<-c
-			conn.Write([]byte{packet.TypePUBACK << 4})
-			conn.Close()
+		l := len(remaining)
+
+		conn.Write([]byte{packet.TypePUBREC << 4, 0x02, remaining[l-2], remaining[l-1]})
+
+		first, remaining, err := readTestPacket(conn)
+		if err != nil || first>>4 != packet.TypePUBREL {
+			return
 		}
-	}()
 
-	cli := New(&Options{
-		ErrorHandler: func(_ error) {},
+		conn.Write([]byte{packet.TypePUBCOMP << 4, 0x02, remaining[0], remaining[1]})
+
+		readTestPacket(conn)
 	})
 
-	err = cli.Connect(&ConnectOptions{
-		Network:  "tcp",
-		Address:  "localhost:1883",
-		ClientID: []byte("clientID"),
+	defer ln.Close()
+
+	defer cli.Terminate()
+
+	tk, err := cli.PublishAsync(&PublishOptions{
+		QoS:       mqtt.QoS2,
+		TopicName: []byte("topicName"),
 	})
 	if err != nil {
 		nilErrorExpected(t, err)
 		return
 	}
 
-	defer cli.Disconnect()
-
-	c <- struct{}{}
-
-	cli.conn.wg.Wait()
-
-	if err := ln.Close(); err != nil {
+	if err := tk.Wait(); err != nil {
 		nilErrorExpected(t, err)
 	}
-}
 
-func TestClient_receive_ReadFullErr(t *testing.T) {
-	ln, err := net.Listen("tcp", ":1884")
-	if err != nil {
+	if err := cli.Disconnect(); err != nil {
 		nilErrorExpected(t, err)
-		return
 	}
+}
 
-	c := make(chan struct{})
+func TestClient_PublishAsync_ErrDisconnected(t *testing.T) {
+	receivedc := make(chan struct{})
 
-	go func() {
-		for {
-			conn, err := ln.Accept()
-			if err != nil {
-				return
-			}
-			<-c
-			conn.Write([]byte{packet.TypePUBACK << 4, 0x80, 0x01})
-			conn.Close()
+	cli, ln := newTestClient(t, func(conn net.Conn) {
+		if _, _, err := readTestPacket(conn); err != nil {
+			return
 		}
-	}()
 
-	cli := New(&Options{
-		ErrorHandler: func(_ error) {},
+		close(receivedc)
+
+		readTestPacket(conn)
 	})
 
-	err = cli.Connect(&ConnectOptions{
-		Network:  "tcp",
-		Address:  "localhost:1884",
-		ClientID: []byte("clientID"),
+	defer ln.Close()
+
+	defer cli.Terminate()
+
+	tk, err := cli.PublishAsync(&PublishOptions{
+		QoS:       mqtt.QoS1,
+		TopicName: []byte("topicName"),
 	})
 	if err != nil {
 		nilErrorExpected(t, err)
 		return
 	}
 
-	defer cli.Disconnect()
-
-	c <- struct{}{}
-
-	cli.conn.wg.Wait()
+	<-receivedc
 
-	if err := ln.Close(); err != nil {
+	if err := cli.Disconnect(); err != nil {
 		nilErrorExpected(t, err)
 	}
+
+	if err := tk.Wait(); err != ErrDisconnected {
+		invalidError(t, err, ErrDisconnected)
+	}
 }
 
-func TestClient_clean_cleanSess(t *testing.T) {
+func TestClient_PublishAsync_QoS0(t *testing.T) {
 	cli := New(&Options{
 		ErrorHandler: func(_ error) {},
 	})
 
-	cli.sess = newSession(true, []byte("clientID"))
-
-	cli.clean()
-}
+	cli.conn = &connection{}
 
-func TestClient_waitPacket_timeout(t *testing.T) {
-	cli := New(&Options{
-		ErrorHandler: func(_ error) {},
-	})
+	cli.conn.send = make(chan packet.Packet, 1)
 
-	err := cli.Connect(&ConnectOptions{
-		Network:  "tcp",
-		Address:  testAddress,
-		ClientID: []byte("clientID"),
+	tk, err := cli.PublishAsync(&PublishOptions{
+		TopicName: []byte("topicName"),
 	})
 	if err != nil {
 		nilErrorExpected(t, err)
+		return
 	}
 
-	defer cli.Disconnect()
-
-	cli.conn.wg.Add(1)
-
-	cli.waitPacket(nil, 1, errTest)
-}
-
-func TestClient_receivePackets_handlePacketErr(t *testing.T) {
-	ln, err := net.Listen("tcp", ":1883")
-	if err != nil {
+	if err := tk.Wait(); err != nil {
 		nilErrorExpected(t, err)
-		return
 	}
+}
 
-	c := make(chan struct{})
-
-	go func() {
-		for {
-			conn, err := ln.Accept()
-			if err != nil {
-				return
-			}
-			<-c
-			if _, err := conn.Write([]byte{packet.TypePUBACK << 4, 0x02, 0x00, 0x01}); err != nil {
-				return
-			}
+func TestClient_SubscribeAsync(t *testing.T) {
+	cli, ln := newTestClient(t, func(conn net.Conn) {
+		_, remaining, err := readTestPacket(conn)
+		if err != nil {
+			return
 		}
-	}()
 
-	cli := New(&Options{
-		ErrorHandler: func(_ error) {},
+		conn.Write([]byte{packet.TypeSUBACK << 4, 0x04, remaining[0], remaining[1], mqtt.QoS1, packet.SUBACKRetFailure})
+
+		readTestPacket(conn)
 	})
 
-	err = cli.Connect(&ConnectOptions{
-		Network:  "tcp",
-		Address:  "localhost:1883",
-		ClientID: []byte("clientID"),
+	defer ln.Close()
+
+	defer cli.Terminate()
+
+	tk, err := cli.SubscribeAsync(&SubscribeOptions{
+		SubReqs: []*SubReq{
+			&SubReq{
+				TopicFilter: []byte("a"),
+				QoS:         mqtt.QoS2,
+			},
+			&SubReq{
+				TopicFilter: []byte("b"),
+				QoS:         mqtt.QoS1,
+			},
+		},
 	})
 	if err != nil {
 		nilErrorExpected(t, err)
 		return
 	}
 
-	defer cli.Disconnect()
+	if err := tk.Wait(); err != nil {
+		nilErrorExpected(t, err)
+	}
 
-	c <- struct{}{}
+	want := []byte{mqtt.QoS1, packet.SUBACKRetFailure}
 
-	cli.conn.wg.Wait()
+	if returnCodes := tk.ReturnCodes(); string(returnCodes) != string(want) {
+		t.Errorf("returnCodes => %v, want => %v", returnCodes, want)
+	}
 
-	if err := ln.Close(); err != nil {
+	if err := cli.Disconnect(); err != nil {
 		nilErrorExpected(t, err)
 	}
 }
 
-func TestClient_handlePacket_TypeErr(t *testing.T) {
-	cli := New(&Options{
-		ErrorHandler: func(_ error) {},
-	})
-
-	p := &packetErr{}
+func TestClient_UnsubscribeAsync(t *testing.T) {
+	cli, ln := newTestClient(t, func(conn net.Conn) {
+		_, remaining, err := readTestPacket(conn)
+		if err != nil {
+			return
+		}
 
-	if err := cli.handlePacket(p); err != errTest {
-		invalidError(t, err, errTest)
-	}
-}
+		conn.Write([]byte{packet.TypeUNSUBACK << 4, 0x02, remaining[0], remaining[1]})
 
-func TestClient_handlePacket_PUBLISH(t *testing.T) {
-	cli := New(&Options{
-		ErrorHandler: func(_ error) {},
+		readTestPacket(conn)
 	})
 
-	err := cli.Connect(&ConnectOptions{
-		Network:  "tcp",
-		Address:  testAddress,
-		ClientID: []byte("clientID"),
-	})
-	if err != nil {
-		nilErrorExpected(t, err)
-	}
+	defer ln.Close()
 
-	defer cli.Disconnect()
+	defer cli.Terminate()
 
-	p, err := packet.NewPUBLISH(&packet.PUBLISHOptions{
-		PacketID: 1,
+	tk, err := cli.UnsubscribeAsync(&UnsubscribeOptions{
+		TopicFilters: [][]byte{
+			[]byte("a"),
+		},
 	})
 	if err != nil {
 		nilErrorExpected(t, err)
 		return
 	}
 
-	cli.handlePacket(p)
+	if err := tk.Wait(); err != nil {
+		nilErrorExpected(t, err)
+	}
+
+	if err := cli.Disconnect(); err != nil {
+		nilErrorExpected(t, err)
+	}
 }
 
-func TestClient_handlePacket_PUBACK(t *testing.T) {
-	cli := New(&Options{
-		ErrorHandler: func(_ error) {},
+func TestClient_PublishContext(t *testing.T) {
+	cli, ln := newTestClient(t, func(conn net.Conn) {
+		_, remaining, err := readTestPacket(conn)
+		if err != nil {
+			return
+		}
+
+		l := len(remaining)
+
+		conn.Write([]byte{packet.TypePUBACK << 4, 0x02, remaining[l-2], remaining[l-1]})
+
+		readTestPacket(conn)
 	})
 
-	err := cli.Connect(&ConnectOptions{
-		Network:  "tcp",
-		Address:  testAddress,
-		ClientID: []byte("clientID"),
+	defer ln.Close()
+
+	defer cli.Terminate()
+
+	err := cli.PublishContext(context.Background(), &PublishOptions{
+		QoS:       mqtt.QoS1,
+		TopicName: []byte("topicName"),
 	})
 	if err != nil {
 		nilErrorExpected(t, err)
 	}
 
-	defer cli.Disconnect()
+	if err := cli.Disconnect(); err != nil {
+		nilErrorExpected(t, err)
+	}
+}
 
-	p, err := packet.NewPUBACK(&packet.PUBACKOptions{
-		PacketID: 1,
+func TestClient_PublishContext_notAcknowledged(t *testing.T) {
+	cli, ln := newTestClient(t, func(conn net.Conn) {
+		readTestPacket(conn)
+		readTestPacket(conn)
 	})
 
-	cli.handlePacket(p)
-}
+	defer ln.Close()
 
-func TestClient_handlePacket_PUBREC(t *testing.T) {
-	cli := New(&Options{
-		ErrorHandler: func(_ error) {},
-	})
+	defer cli.Terminate()
 
-	err := cli.Connect(&ConnectOptions{
-		Network:  "tcp",
-		Address:  testAddress,
-		ClientID: []byte("clientID"),
-	})
-	if err != nil {
-		nilErrorExpected(t, err)
-	}
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
 
-	defer cli.Disconnect()
+	defer cancel()
 
-	p, err := packet.NewPUBREC(&packet.PUBRECOptions{
-		PacketID: 1,
+	err := cli.PublishContext(ctx, &PublishOptions{
+		QoS:       mqtt.QoS1,
+		TopicName: []byte("topicName"),
 	})
+	if err != context.DeadlineExceeded {
+		invalidError(t, err, context.DeadlineExceeded)
+	}
 
-	cli.handlePacket(p)
+	if err := cli.Disconnect(); err != nil {
+		nilErrorExpected(t, err)
+	}
 }
 
-func TestClient_handlePacket_PUBREL(t *testing.T) {
+func TestClient_PublishContext_sendBlocked(t *testing.T) {
 	cli := New(&Options{
 		ErrorHandler: func(_ error) {},
 	})
 
-	err := cli.Connect(&ConnectOptions{
-		Network:  "tcp",
-		Address:  testAddress,
-		ClientID: []byte("clientID"),
-	})
-	if err != nil {
-		nilErrorExpected(t, err)
-	}
+	cli.conn = &connection{}
 
-	defer cli.Disconnect()
+	cli.conn.send = make(chan packet.Packet)
 
-	p, err := packet.NewPUBREL(&packet.PUBRELOptions{
-		PacketID: 1,
-	})
+	cli.sess = newSession(false, []byte("clientID"))
 
-	cli.handlePacket(p)
-}
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
 
-func TestClient_handlePacket_PUBCOMP(t *testing.T) {
-	cli := New(&Options{
-		ErrorHandler: func(_ error) {},
-	})
+	defer cancel()
 
-	err := cli.Connect(&ConnectOptions{
-		Network:  "tcp",
-		Address:  testAddress,
-		ClientID: []byte("clientID"),
+	err := cli.PublishContext(ctx, &PublishOptions{
+		QoS:       mqtt.QoS1,
+		TopicName: []byte("topicName"),
 	})
-	if err != nil {
-		nilErrorExpected(t, err)
+	if err != context.DeadlineExceeded {
+		invalidError(t, err, context.DeadlineExceeded)
 	}
 
-	defer cli.Disconnect()
-
-	p, err := packet.NewPUBCOMP(&packet.PUBCOMPOptions{
-		PacketID: 1,
-	})
+	if l := len(cli.sess.sendingPackets); l != 0 {
+		t.Errorf("len(cli.sess.sendingPackets) => %d, want => 0", l)
+	}
 
-	cli.handlePacket(p)
+	if l := len(cli.sess.tokens); l != 0 {
+		t.Errorf("len(cli.sess.tokens) => %d, want => 0", l)
+	}
 }
 
-func TestClient_handlePacket_SUBACK(t *testing.T) {
-	cli := New(&Options{
-		ErrorHandler: func(_ error) {},
+func TestClient_SubscribeContext(t *testing.T) {
+	cli, ln := newTestClient(t, func(conn net.Conn) {
+		_, remaining, err := readTestPacket(conn)
+		if err != nil {
+			return
+		}
+
+		conn.Write([]byte{packet.TypeSUBACK << 4, 0x03, remaining[0], remaining[1], mqtt.QoS1})
+
+		readTestPacket(conn)
 	})
 
-	err := cli.Connect(&ConnectOptions{
-		Network:  "tcp",
-		Address:  testAddress,
-		ClientID: []byte("clientID"),
+	defer ln.Close()
+
+	defer cli.Terminate()
+
+	returnCodes, err := cli.SubscribeContext(context.Background(), &SubscribeOptions{
+		SubReqs: []*SubReq{
+			&SubReq{
+				TopicFilter: []byte("a"),
+				QoS:         mqtt.QoS1,
+			},
+		},
 	})
 	if err != nil {
 		nilErrorExpected(t, err)
 	}
 
-	defer cli.Disconnect()
+	if len(returnCodes) != 1 || returnCodes[0] != mqtt.QoS1 {
+		t.Errorf("returnCodes => %v, want => %v", returnCodes, []byte{mqtt.QoS1})
+	}
 
-	p, err := packet.NewSUBACKFromBytes([]byte{packet.TypeSUBACK << 4, 0x03}, []byte{0x00, 0x01, 0x00})
-	if err != nil {
+	if err := cli.Disconnect(); err != nil {
 		nilErrorExpected(t, err)
 	}
-
-	cli.handlePacket(p)
 }
 
-func TestClient_handlePacket_UNSUBACK(t *testing.T) {
+func TestClient_SubscribeContext_sendBlocked(t *testing.T) {
 	cli := New(&Options{
 		ErrorHandler: func(_ error) {},
 	})
 
-	err := cli.Connect(&ConnectOptions{
-		Network:  "tcp",
-		Address:  testAddress,
-		ClientID: []byte("clientID"),
-	})
-	if err != nil {
-		nilErrorExpected(t, err)
-	}
+	cli.conn = &connection{}
 
-	defer cli.Disconnect()
+	cli.conn.send = make(chan packet.Packet)
 
-	p, err := packet.NewUNSUBACKFromBytes([]byte{packet.TypeUNSUBACK << 4, 0x02}, []byte{0x00, 0x01})
-	if err != nil {
-		nilErrorExpected(t, err)
+	cli.conn.unackSubs = make(map[string]*SubReq)
+
+	cli.sess = newSession(false, []byte("clientID"))
+
+	ctx, cancel := context.WithCancel(context.Background())
+
+	cancel()
+
+	_, err := cli.SubscribeContext(ctx, &SubscribeOptions{
+		SubReqs: []*SubReq{
+			&SubReq{
+				TopicFilter: []byte("a"),
+			},
+		},
+	})
+	if err != context.Canceled {
+		invalidError(t, err, context.Canceled)
 	}
 
-	cli.handlePacket(p)
+	if l := len(cli.conn.unackSubs); l != 0 {
+		t.Errorf("len(cli.conn.unackSubs) => %d, want => 0", l)
+	}
 }
 
-func TestClient_handlePacket_ErrInvalidPacketType(t *testing.T) {
-	cli := New(&Options{
-		ErrorHandler: func(_ error) {},
-	})
+func TestClient_UnsubscribeContext(t *testing.T) {
+	cli, ln := newTestClient(t, func(conn net.Conn) {
+		_, remaining, err := readTestPacket(conn)
+		if err != nil {
+			return
+		}
 
-	err := cli.Connect(&ConnectOptions{
-		Network:  "tcp",
-		Address:  testAddress,
-		ClientID: []byte("clientID"),
+		conn.Write([]byte{packet.TypeUNSUBACK << 4, 0x02, remaining[0], remaining[1]})
+
+		readTestPacket(conn)
 	})
-	if err != nil {
-		nilErrorExpected(t, err)
-	}
 
-	defer cli.Disconnect()
+	defer ln.Close()
 
-	p, err := packet.NewCONNECT(&packet.CONNECTOptions{
-		ClientID: []byte("cliendID"),
+	defer cli.Terminate()
+
+	err := cli.UnsubscribeContext(context.Background(), &UnsubscribeOptions{
+		TopicFilters: [][]byte{
+			[]byte("a"),
+		},
 	})
 	if err != nil {
 		nilErrorExpected(t, err)
 	}
 
-	cli.handlePacket(p)
+	if err := cli.Disconnect(); err != nil {
+		nilErrorExpected(t, err)
+	}
 }
 
-func TestClient_handleCONNACK_default(t *testing.T) {
+func TestClient_UnsubscribeContext_sendBlocked(t *testing.T) {
 	cli := New(&Options{
 		ErrorHandler: func(_ error) {},
 	})
 
 	cli.conn = &connection{}
 
-	cli.conn.connack = make(chan struct{})
+	cli.conn.send = make(chan packet.Packet)
+
+	cli.sess = newSession(false, []byte("clientID"))
+
+	ctx, cancel := context.WithCancel(context.Background())
+
+	cancel()
+
+	err := cli.UnsubscribeContext(ctx, &UnsubscribeOptions{
+		TopicFilters: [][]byte{
+			[]byte("a"),
+		},
+	})
+	if err != context.Canceled {
+		invalidError(t, err, context.Canceled)
+	}
 
-	cli.handleCONNACK()
+	if l := len(cli.sess.sendingPackets); l != 0 {
+		t.Errorf("len(cli.sess.sendingPackets) => %d, want => 0", l)
+	}
 }
 
-func TestClient_handlePUBLISH_QoS0(t *testing.T) {
+func TestClient_Subscribe_connNil(t *testing.T) {
 	cli := New(&Options{
 		ErrorHandler: func(_ error) {},
 	})
 
-	err := cli.Connect(&ConnectOptions{
-		Network:  "tcp",
-		Address:  testAddress,
-		ClientID: []byte("clientID"),
-	})
-	if err != nil {
-		nilErrorExpected(t, err)
+	if err := cli.Subscribe(nil); err != ErrNotYetConnected {
+		invalidError(t, err, ErrNotYetConnected)
 	}
+}
 
-	defer cli.Disconnect()
-
-	p, err := packet.NewPUBLISH(&packet.PUBLISHOptions{
-		PacketID: 1,
+func TestClient_Subscribe_ErrInvalidNoSubReq(t *testing.T) {
+	cli := New(&Options{
+		ErrorHandler: func(_ error) {},
 	})
-	if err != nil {
-		nilErrorExpected(t, err)
-		return
-	}
 
-	if err = cli.handlePUBLISH(p); err != nil {
-		nilErrorExpected(t, err)
+	cli.conn = &connection{}
+
+	if err := cli.Subscribe(nil); err != packet.ErrInvalidNoSubReq {
+		invalidError(t, err, packet.ErrInvalidNoSubReq)
 	}
 }
 
-func TestClient_handlePUBLISH_QoS1_Err(t *testing.T) {
+func TestClient_Subscribe_generatePacketIDErr(t *testing.T) {
 	cli := New(&Options{
 		ErrorHandler: func(_ error) {},
 	})
 
-	err := cli.Connect(&ConnectOptions{
-		Network:  "tcp",
-		Address:  testAddress,
-		ClientID: []byte("clientID"),
-	})
-	if err != nil {
-		nilErrorExpected(t, err)
-	}
+	cli.conn = &connection{}
 
-	defer cli.Disconnect()
+	cli.sess = newSession(false, []byte("clientID"))
 
-	p, err := packet.NewPUBLISH(&packet.PUBLISHOptions{
-		QoS:      mqtt.QoS1,
-		PacketID: 1,
-	})
-	if err != nil {
-		nilErrorExpected(t, err)
-		return
+	id := minPacketID
+
+	for {
+		cli.sess.sendingPackets[id] = nil
+
+		if id == maxPacketID {
+			break
+		}
+
+		id++
 	}
 
-	p.(*packet.PUBLISH).PacketID = 0
+	err := cli.Subscribe(&SubscribeOptions{
+		SubReqs: []*SubReq{
+			&SubReq{},
+		},
+	})
 
-	if err = cli.handlePUBLISH(p); err != packet.ErrInvalidPacketID {
-		invalidError(t, err, packet.ErrInvalidPacketID)
+	if err != ErrPacketIDExhaused {
+		invalidError(t, err, ErrPacketIDExhaused)
 	}
 }
 
-func TestClient_handlePUBLISH_QoS1(t *testing.T) {
+func TestClient_Subscribe_NewSUBSCRIBEErr(t *testing.T) {
 	cli := New(&Options{
 		ErrorHandler: func(_ error) {},
 	})
 
-	err := cli.Connect(&ConnectOptions{
-		Network:  "tcp",
-		Address:  testAddress,
-		ClientID: []byte("clientID"),
-	})
-	if err != nil {
-		nilErrorExpected(t, err)
-	}
+	cli.conn = &connection{}
 
-	defer cli.Disconnect()
+	cli.sess = newSession(false, []byte("clientID"))
 
-	p, err := packet.NewPUBLISH(&packet.PUBLISHOptions{
-		QoS:      mqtt.QoS1,
-		PacketID: 1,
+	err := cli.Subscribe(&SubscribeOptions{
+		SubReqs: []*SubReq{
+			&SubReq{},
+		},
 	})
-	if err != nil {
-		nilErrorExpected(t, err)
-		return
-	}
 
-	if err = cli.handlePUBLISH(p); err != nil {
-		nilErrorExpected(t, err)
+	if err != packet.ErrNoTopicFilter {
+		invalidError(t, err, packet.ErrNoTopicFilter)
 	}
 }
 
-func TestClient_handlePUBLISH_QoS2_ErrInvalidPacketID(t *testing.T) {
+func TestClient_Subscribe(t *testing.T) {
 	cli := New(&Options{
 		ErrorHandler: func(_ error) {},
 	})
 
-	err := cli.Connect(&ConnectOptions{
-		Network:  "tcp",
-		Address:  testAddress,
-		ClientID: []byte("clientID"),
-	})
-	if err != nil {
-		nilErrorExpected(t, err)
-	}
+	cli.conn = &connection{}
 
-	defer cli.Disconnect()
+	cli.sess = newSession(false, []byte("clientID"))
 
-	p, err := packet.NewPUBLISH(&packet.PUBLISHOptions{
-		QoS:      mqtt.QoS2,
-		PacketID: 1,
+	cli.conn.unackSubs = make(map[string]*SubReq)
+
+	cli.conn.send = make(chan packet.Packet, 1)
+
+	err := cli.Subscribe(&SubscribeOptions{
+		SubReqs: []*SubReq{
+			&SubReq{
+				TopicFilter: []byte("topicFilter"),
+			},
+		},
 	})
+
 	if err != nil {
 		nilErrorExpected(t, err)
-		return
 	}
+}
 
-	cli.sess.receivingPackets[1] = p
+func TestClient_Unsubscribe_connNil(t *testing.T) {
+	cli := New(&Options{
+		ErrorHandler: func(_ error) {},
+	})
 
-	if err = cli.handlePUBLISH(p); err != packet.ErrInvalidPacketID {
-		invalidError(t, err, packet.ErrInvalidPacketID)
+	if err := cli.Unsubscribe(nil); err != ErrNotYetConnected {
+		invalidError(t, err, ErrNotYetConnected)
 	}
 }
 
-func TestClient_handlePUBLISH_QoS2_NewPUBRECErr(t *testing.T) {
+func TestClient_Unsubscribe_ErrNoTopicFilter(t *testing.T) {
 	cli := New(&Options{
 		ErrorHandler: func(_ error) {},
 	})
 
-	err := cli.Connect(&ConnectOptions{
-		Network:  "tcp",
-		Address:  testAddress,
-		ClientID: []byte("clientID"),
-	})
-	if err != nil {
-		nilErrorExpected(t, err)
-	}
+	cli.conn = &connection{}
 
-	defer cli.Disconnect()
+	if err := cli.Unsubscribe(nil); err != packet.ErrNoTopicFilter {
+		invalidError(t, err, packet.ErrNoTopicFilter)
+	}
+}
 
-	p, err := packet.NewPUBLISH(&packet.PUBLISHOptions{
-		QoS:      mqtt.QoS2,
-		PacketID: 1,
+func TestClient_Unsubscribe_generatePacketIDErr(t *testing.T) {
+	cli := New(&Options{
+		ErrorHandler: func(_ error) {},
 	})
-	if err != nil {
-		nilErrorExpected(t, err)
-		return
+
+	cli.conn = &connection{}
+
+	cli.sess = newSession(false, []byte("clientID"))
+
+	id := minPacketID
+
+	for {
+		cli.sess.sendingPackets[id] = nil
+
+		if id == maxPacketID {
+			break
+		}
+
+		id++
 	}
 
-	p.(*packet.PUBLISH).PacketID = 0
+	err := cli.Unsubscribe(&UnsubscribeOptions{
+		TopicFilters: [][]byte{
+			[]byte("topicFilter"),
+		},
+	})
 
-	if err = cli.handlePUBLISH(p); err != packet.ErrInvalidPacketID {
-		invalidError(t, err, packet.ErrInvalidPacketID)
+	if err != ErrPacketIDExhaused {
+		invalidError(t, err, ErrPacketIDExhaused)
 	}
 }
 
-func TestClient_handlePUBLISH_QoS2(t *testing.T) {
+func TestClient_Unsubscribe_NewUNSUBSCRIBEErr(t *testing.T) {
 	cli := New(&Options{
 		ErrorHandler: func(_ error) {},
 	})
 
-	err := cli.Connect(&ConnectOptions{
-		Network:  "tcp",
-		Address:  testAddress,
-		ClientID: []byte("clientID"),
-	})
-	if err != nil {
-		nilErrorExpected(t, err)
-	}
+	cli.conn = &connection{}
 
-	defer cli.Disconnect()
+	cli.sess = newSession(false, []byte("clientID"))
 
-	p, err := packet.NewPUBLISH(&packet.PUBLISHOptions{
-		QoS:      mqtt.QoS2,
-		PacketID: 1,
+	err := cli.Unsubscribe(&UnsubscribeOptions{
+		TopicFilters: [][]byte{
+			make([]byte, 0),
+		},
 	})
-	if err != nil {
-		nilErrorExpected(t, err)
-		return
-	}
 
-	if err = cli.handlePUBLISH(p); err != nil {
-		nilErrorExpected(t, err)
+	if err != packet.ErrNoTopicFilter {
+		invalidError(t, err, packet.ErrNoTopicFilter)
 	}
 }
 
-func TestClient_handlePUBACK_validatePacketIDErr(t *testing.T) {
+func TestClient_Unsubscribe(t *testing.T) {
 	cli := New(&Options{
 		ErrorHandler: func(_ error) {},
 	})
 
-	err := cli.Connect(&ConnectOptions{
-		Network:  "tcp",
-		Address:  testAddress,
-		ClientID: []byte("clientID"),
+	cli.conn = &connection{}
+
+	cli.sess = newSession(false, []byte("clientID"))
+
+	cli.conn.send = make(chan packet.Packet, 1)
+
+	err := cli.Unsubscribe(&UnsubscribeOptions{
+		TopicFilters: [][]byte{
+			[]byte("topicFilter"),
+		},
 	})
+
 	if err != nil {
 		nilErrorExpected(t, err)
 	}
+}
 
-	defer cli.Disconnect()
+func TestClient_Terminate(t *testing.T) {
+	cli := &Client{}
 
-	p := &packet.PUBACK{
-		PacketID: 1,
-	}
+	cli.disconnEndc = make(chan struct{}, 1)
 
-	if err := cli.handlePUBACK(p); err != packet.ErrInvalidPacketID {
-		invalidError(t, err, packet.ErrInvalidPacketID)
-	}
+	cli.Terminate()
 }
 
-func TestClient_handlePUBACK(t *testing.T) {
+func TestClient_send_connNil(t *testing.T) {
 	cli := New(&Options{
 		ErrorHandler: func(_ error) {},
 	})
 
-	err := cli.Connect(&ConnectOptions{
-		Network:  "tcp",
-		Address:  testAddress,
-		ClientID: []byte("clientID"),
-	})
-	if err != nil {
-		nilErrorExpected(t, err)
+	if err := cli.send(nil); err != ErrNotYetConnected {
+		invalidError(t, err, ErrNotYetConnected)
 	}
+}
 
-	defer cli.Disconnect()
+func TestClient_sendCONNECT_optsNil(t *testing.T) {
+	cli := New(&Options{
+		ErrorHandler: func(_ error) {},
+	})
 
-	p := &packet.PUBACK{
-		PacketID: 1,
+	if err := cli.sendCONNECT(nil); err != packet.ErrInvalidClientIDCleanSession {
+		invalidError(t, err, packet.ErrInvalidClientIDCleanSession)
 	}
+}
 
-	publish, err := packet.NewPUBLISH(&packet.PUBLISHOptions{
-		PacketID: 1,
+func TestClient_receive_connNil(t *testing.T) {
+	cli := New(&Options{
+		ErrorHandler: func(_ error) {},
 	})
+
+	if _, err := cli.receive(); err != ErrNotYetConnected {
+		invalidError(t, err, ErrNotYetConnected)
+	}
+}
+
+func TestClient_receive_ReadByteErr(t *testing.T) {
+	ln, err := net.Listen("tcp", ":1883")
 	if err != nil {
 		nilErrorExpected(t, err)
 		return
 	}
 
-	cli.sess.sendingPackets[1] = publish
+	c := make(chan struct{})
 
-	if err := cli.handlePUBACK(p); err != nil {
-		nilErrorExpected(t, err)
-	}
-}
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			conn.Write(testCONNACK)
+			<-c
+			conn.Write([]byte{packet.TypePUBACK << 4})
+			conn.Close()
+		}
+	}()
 
-func TestClient_handlePUBREC_validatePacketIDErr(t *testing.T) {
 	cli := New(&Options{
 		ErrorHandler: func(_ error) {},
 	})
 
-	err := cli.Connect(&ConnectOptions{
+	err = cli.Connect(&ConnectOptions{
 		Network:  "tcp",
-		Address:  testAddress,
+		Address:  "localhost:1883",
 		ClientID: []byte("clientID"),
 	})
 	if err != nil {
 		nilErrorExpected(t, err)
+		return
 	}
 
 	defer cli.Disconnect()
 
-	p := &packet.PUBREC{
-		PacketID: 1,
-	}
+	conn := cli.conn
 
-	if err := cli.handlePUBREC(p); err != packet.ErrInvalidPacketID {
-		invalidError(t, err, packet.ErrInvalidPacketID)
+	c <- struct{}{}
+
+	conn.wg.Wait()
+
+	if err := ln.Close(); err != nil {
+		nilErrorExpected(t, err)
 	}
 }
 
-func TestClient_handlePUBREC_NewPUBRELErr(t *testing.T) {
+func TestClient_receive_ReadFullErr(t *testing.T) {
+	ln, err := net.Listen("tcp", ":1884")
+	if err != nil {
+		nilErrorExpected(t, err)
+		return
+	}
+
+	c := make(chan struct{})
+
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			conn.Write(testCONNACK)
+			<-c
+			conn.Write([]byte{packet.TypePUBACK << 4, 0x80, 0x01})
+			conn.Close()
+		}
+	}()
+
 	cli := New(&Options{
 		ErrorHandler: func(_ error) {},
 	})
 
-	err := cli.Connect(&ConnectOptions{
+	err = cli.Connect(&ConnectOptions{
 		Network:  "tcp",
-		Address:  testAddress,
+		Address:  "localhost:1884",
 		ClientID: []byte("clientID"),
 	})
 	if err != nil {
 		nilErrorExpected(t, err)
+		return
 	}
 
 	defer cli.Disconnect()
 
-	p := &packet.PUBREC{
-		PacketID: 0,
-	}
+	conn := cli.conn
 
-	publish, err := packet.NewPUBLISH(&packet.PUBLISHOptions{
-		PacketID: 1,
-	})
-	if err != nil {
+	c <- struct{}{}
+
+	conn.wg.Wait()
+
+	if err := ln.Close(); err != nil {
 		nilErrorExpected(t, err)
-		return
 	}
+}
 
-	publish.(*packet.PUBLISH).PacketID = 0
+func TestClient_clean_cleanSess(t *testing.T) {
+	cli := New(&Options{
+		ErrorHandler: func(_ error) {},
+	})
 
-	cli.sess.sendingPackets[0] = publish
+	cli.sess = newSession(true, []byte("clientID"))
 
-	if err := cli.handlePUBREC(p); err != packet.ErrInvalidPacketID {
-		invalidError(t, err, packet.ErrInvalidPacketID)
-	}
+	cli.clean()
 }
 
-func TestClient_handlePUBREC(t *testing.T) {
+func TestClient_waitPacket_timeout(t *testing.T) {
 	cli := New(&Options{
 		ErrorHandler: func(_ error) {},
 	})
@@ -1157,51 +1348,74 @@ func TestClient_handlePUBREC(t *testing.T) {
 
 	defer cli.Disconnect()
 
-	p := &packet.PUBREC{
-		PacketID: 1,
-	}
+	cli.conn.wg.Add(1)
 
-	publish, err := packet.NewPUBLISH(&packet.PUBLISHOptions{
-		PacketID: 1,
-	})
+	cli.waitPacket(nil, 1, errTest)
+}
+
+func TestClient_receivePackets_handlePacketErr(t *testing.T) {
+	ln, err := net.Listen("tcp", ":1883")
 	if err != nil {
 		nilErrorExpected(t, err)
 		return
 	}
 
-	cli.sess.sendingPackets[1] = publish
+	c := make(chan struct{})
 
-	if err := cli.handlePUBREC(p); err != nil {
-		nilErrorExpected(t, err)
-	}
-}
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			conn.Write(testCONNACK)
+			<-c
+			if _, err := conn.Write([]byte{packet.TypePUBACK << 4, 0x02, 0x00, 0x01}); err != nil {
+				return
+			}
+		}
+	}()
 
-func TestClient_handlePUBREL_validatePacketIDErr(t *testing.T) {
 	cli := New(&Options{
 		ErrorHandler: func(_ error) {},
 	})
 
-	err := cli.Connect(&ConnectOptions{
+	err = cli.Connect(&ConnectOptions{
 		Network:  "tcp",
-		Address:  testAddress,
+		Address:  "localhost:1883",
 		ClientID: []byte("clientID"),
 	})
 	if err != nil {
 		nilErrorExpected(t, err)
+		return
 	}
 
 	defer cli.Disconnect()
 
-	p := &packet.PUBREL{
-		PacketID: 1,
-	}
+	conn := cli.conn
 
-	if err := cli.handlePUBREL(p); err != packet.ErrInvalidPacketID {
-		invalidError(t, err, packet.ErrInvalidPacketID)
-	}
-}
+	c <- struct{}{}
 
-func TestClient_handlePUBREL_NewPUBCOMPErr(t *testing.T) {
+	conn.wg.Wait()
+
+	if err := ln.Close(); err != nil {
+		nilErrorExpected(t, err)
+	}
+}
+
+func TestClient_handlePacket_TypeErr(t *testing.T) {
+	cli := New(&Options{
+		ErrorHandler: func(_ error) {},
+	})
+
+	p := &packetErr{}
+
+	if err := cli.handlePacket(p); err != errTest {
+		invalidError(t, err, errTest)
+	}
+}
+
+func TestClient_handlePacket_PUBLISH(t *testing.T) {
 	cli := New(&Options{
 		ErrorHandler: func(_ error) {},
 	})
@@ -1217,28 +1431,19 @@ func TestClient_handlePUBREL_NewPUBCOMPErr(t *testing.T) {
 
 	defer cli.Disconnect()
 
-	p := &packet.PUBREL{
-		PacketID: 0,
-	}
-
-	publish, err := packet.NewPUBLISH(&packet.PUBLISHOptions{
-		PacketID: 1,
+	p, err := packet.NewPUBLISH(&packet.PUBLISHOptions{
+		TopicName: []byte("topicName"),
+		PacketID:  1,
 	})
 	if err != nil {
 		nilErrorExpected(t, err)
 		return
 	}
 
-	publish.(*packet.PUBLISH).PacketID = 0
-
-	cli.sess.receivingPackets[0] = publish
-
-	if err := cli.handlePUBREL(p); err != packet.ErrInvalidPacketID {
-		invalidError(t, err, packet.ErrInvalidPacketID)
-	}
+	cli.handlePacket(p)
 }
 
-func TestClient_handlePUBREL(t *testing.T) {
+func TestClient_handlePacket_PUBACK(t *testing.T) {
 	cli := New(&Options{
 		ErrorHandler: func(_ error) {},
 	})
@@ -1254,26 +1459,37 @@ func TestClient_handlePUBREL(t *testing.T) {
 
 	defer cli.Disconnect()
 
-	p := &packet.PUBREL{
+	p, err := packet.NewPUBACK(&packet.PUBACKOptions{
 		PacketID: 1,
-	}
+	})
 
-	publish, err := packet.NewPUBLISH(&packet.PUBLISHOptions{
-		PacketID: 1,
+	cli.handlePacket(p)
+}
+
+func TestClient_handlePacket_PUBREC(t *testing.T) {
+	cli := New(&Options{
+		ErrorHandler: func(_ error) {},
+	})
+
+	err := cli.Connect(&ConnectOptions{
+		Network:  "tcp",
+		Address:  testAddress,
+		ClientID: []byte("clientID"),
 	})
 	if err != nil {
 		nilErrorExpected(t, err)
-		return
 	}
 
-	cli.sess.receivingPackets[1] = publish
+	defer cli.Disconnect()
 
-	if err := cli.handlePUBREL(p); err != nil {
-		nilErrorExpected(t, err)
-	}
+	p, err := packet.NewPUBREC(&packet.PUBRECOptions{
+		PacketID: 1,
+	})
+
+	cli.handlePacket(p)
 }
 
-func TestClient_handlePUBCOMP_validatePacketIDErr(t *testing.T) {
+func TestClient_handlePacket_PUBREL(t *testing.T) {
 	cli := New(&Options{
 		ErrorHandler: func(_ error) {},
 	})
@@ -1289,16 +1505,14 @@ func TestClient_handlePUBCOMP_validatePacketIDErr(t *testing.T) {
 
 	defer cli.Disconnect()
 
-	p := &packet.PUBCOMP{
+	p, err := packet.NewPUBREL(&packet.PUBRELOptions{
 		PacketID: 1,
-	}
+	})
 
-	if err := cli.handlePUBCOMP(p); err != packet.ErrInvalidPacketID {
-		invalidError(t, err, packet.ErrInvalidPacketID)
-	}
+	cli.handlePacket(p)
 }
 
-func TestClient_handlePUBCOMP(t *testing.T) {
+func TestClient_handlePacket_PUBCOMP(t *testing.T) {
 	cli := New(&Options{
 		ErrorHandler: func(_ error) {},
 	})
@@ -1314,26 +1528,38 @@ func TestClient_handlePUBCOMP(t *testing.T) {
 
 	defer cli.Disconnect()
 
-	p := &packet.PUBCOMP{
+	p, err := packet.NewPUBCOMP(&packet.PUBCOMPOptions{
 		PacketID: 1,
-	}
+	})
 
-	pubrel, err := packet.NewPUBREL(&packet.PUBRELOptions{
-		PacketID: 1,
+	cli.handlePacket(p)
+}
+
+func TestClient_handlePacket_SUBACK(t *testing.T) {
+	cli := New(&Options{
+		ErrorHandler: func(_ error) {},
+	})
+
+	err := cli.Connect(&ConnectOptions{
+		Network:  "tcp",
+		Address:  testAddress,
+		ClientID: []byte("clientID"),
 	})
 	if err != nil {
 		nilErrorExpected(t, err)
-		return
 	}
 
-	cli.sess.sendingPackets[1] = pubrel
+	defer cli.Disconnect()
 
-	if err := cli.handlePUBCOMP(p); err != nil {
+	p, err := packet.NewSUBACKFromBytes([]byte{packet.TypeSUBACK << 4, 0x03}, []byte{0x00, 0x01, 0x00})
+	if err != nil {
 		nilErrorExpected(t, err)
 	}
+
+	cli.handlePacket(p)
 }
 
-func TestClient_handleSUBACK_validatePacketIDErr(t *testing.T) {
+func TestClient_handlePacket_UNSUBACK(t *testing.T) {
 	cli := New(&Options{
 		ErrorHandler: func(_ error) {},
 	})
@@ -1349,17 +1575,15 @@ func TestClient_handleSUBACK_validatePacketIDErr(t *testing.T) {
 
 	defer cli.Disconnect()
 
-	p := &packet.SUBACK{
-		PacketID:    1,
-		ReturnCodes: []byte{mqtt.QoS0},
+	p, err := packet.NewUNSUBACKFromBytes([]byte{packet.TypeUNSUBACK << 4, 0x02}, []byte{0x00, 0x01})
+	if err != nil {
+		nilErrorExpected(t, err)
 	}
 
-	if err := cli.handleSUBACK(p); err != packet.ErrInvalidPacketID {
-		invalidError(t, err, packet.ErrInvalidPacketID)
-	}
+	cli.handlePacket(p)
 }
 
-func TestClient_handleSUBACK_ErrInvalidSUBACK(t *testing.T) {
+func TestClient_handlePacket_ErrInvalidPacketType(t *testing.T) {
 	cli := New(&Options{
 		ErrorHandler: func(_ error) {},
 	})
@@ -1375,36 +1599,17 @@ func TestClient_handleSUBACK_ErrInvalidSUBACK(t *testing.T) {
 
 	defer cli.Disconnect()
 
-	p := &packet.SUBACK{
-		PacketID:    1,
-		ReturnCodes: []byte{mqtt.QoS0},
-	}
-
-	subscribe, err := packet.NewSUBSCRIBE(&packet.SUBSCRIBEOptions{
-		PacketID: 1,
-		SubReqs: []*packet.SubReq{
-			&packet.SubReq{
-				TopicFilter: []byte("test"),
-				QoS:         mqtt.QoS0,
-			},
-			&packet.SubReq{
-				TopicFilter: []byte("test2"),
-				QoS:         mqtt.QoS0,
-			},
-		},
+	p, err := packet.NewCONNECT(&packet.CONNECTOptions{
+		ClientID: []byte("cliendID"),
 	})
 	if err != nil {
 		nilErrorExpected(t, err)
 	}
 
-	cli.sess.sendingPackets[1] = subscribe
-
-	if err := cli.handleSUBACK(p); err != ErrInvalidSUBACK {
-		invalidError(t, err, ErrInvalidSUBACK)
-	}
+	cli.handlePacket(p)
 }
 
-func TestClient_handleSUBACK(t *testing.T) {
+func TestClient_handlePUBLISH_QoS0(t *testing.T) {
 	cli := New(&Options{
 		ErrorHandler: func(_ error) {},
 	})
@@ -1420,36 +1625,21 @@ func TestClient_handleSUBACK(t *testing.T) {
 
 	defer cli.Disconnect()
 
-	p := &packet.SUBACK{
-		PacketID:    1,
-		ReturnCodes: []byte{packet.SUBACKRetFailure, mqtt.QoS0},
-	}
-
-	subscribe, err := packet.NewSUBSCRIBE(&packet.SUBSCRIBEOptions{
-		PacketID: 1,
-		SubReqs: []*packet.SubReq{
-			&packet.SubReq{
-				TopicFilter: []byte("test"),
-				QoS:         mqtt.QoS0,
-			},
-			&packet.SubReq{
-				TopicFilter: []byte("test2"),
-				QoS:         mqtt.QoS0,
-			},
-		},
+	p, err := packet.NewPUBLISH(&packet.PUBLISHOptions{
+		TopicName: []byte("topicName"),
+		PacketID:  1,
 	})
 	if err != nil {
 		nilErrorExpected(t, err)
+		return
 	}
 
-	cli.sess.sendingPackets[1] = subscribe
-
-	if err := cli.handleSUBACK(p); err != nil {
+	if err = cli.handlePUBLISH(p); err != nil {
 		nilErrorExpected(t, err)
 	}
 }
 
-func TestClient_handleUNSUBACK_validatePacketIDErr(t *testing.T) {
+func TestClient_handlePUBLISH_QoS1_Err(t *testing.T) {
 	cli := New(&Options{
 		ErrorHandler: func(_ error) {},
 	})
@@ -1465,16 +1655,24 @@ func TestClient_handleUNSUBACK_validatePacketIDErr(t *testing.T) {
 
 	defer cli.Disconnect()
 
-	p := &packet.UNSUBACK{
-		PacketID: 1,
+	p, err := packet.NewPUBLISH(&packet.PUBLISHOptions{
+		TopicName: []byte("topicName"),
+		QoS:       mqtt.QoS1,
+		PacketID:  1,
+	})
+	if err != nil {
+		nilErrorExpected(t, err)
+		return
 	}
 
-	if err := cli.handleUNSUBACK(p); err != packet.ErrInvalidPacketID {
+	p.(*packet.PUBLISH).PacketID = 0
+
+	if err = cli.handlePUBLISH(p); err != packet.ErrInvalidPacketID {
 		invalidError(t, err, packet.ErrInvalidPacketID)
 	}
 }
 
-func TestClient_handleUNSUBACK(t *testing.T) {
+func TestClient_handlePUBLISH_QoS1(t *testing.T) {
 	cli := New(&Options{
 		ErrorHandler: func(_ error) {},
 	})
@@ -1490,26 +1688,22 @@ func TestClient_handleUNSUBACK(t *testing.T) {
 
 	defer cli.Disconnect()
 
-	p := &packet.UNSUBACK{
-		PacketID: 1,
-	}
-
-	unsubscribe, err := packet.NewUNSUBSCRIBE(&packet.UNSUBSCRIBEOptions{
-		PacketID:     1,
-		TopicFilters: [][]byte{[]byte("test")},
+	p, err := packet.NewPUBLISH(&packet.PUBLISHOptions{
+		TopicName: []byte("topicName"),
+		QoS:       mqtt.QoS1,
+		PacketID:  1,
 	})
 	if err != nil {
 		nilErrorExpected(t, err)
+		return
 	}
 
-	cli.sess.sendingPackets[1] = unsubscribe
-
-	if err := cli.handleUNSUBACK(p); err != nil {
+	if err = cli.handlePUBLISH(p); err != nil {
 		nilErrorExpected(t, err)
 	}
 }
 
-func TestClient_handlePINGRESP_ErrInvalidPINGRESP(t *testing.T) {
+func TestClient_handlePUBLISH_QoS2_ErrInvalidPacketID(t *testing.T) {
 	cli := New(&Options{
 		ErrorHandler: func(_ error) {},
 	})
@@ -1525,12 +1719,24 @@ func TestClient_handlePINGRESP_ErrInvalidPINGRESP(t *testing.T) {
 
 	defer cli.Disconnect()
 
-	if err := cli.handlePINGRESP(); err != ErrInvalidPINGRESP {
-		invalidError(t, err, ErrInvalidPINGRESP)
+	p, err := packet.NewPUBLISH(&packet.PUBLISHOptions{
+		TopicName: []byte("topicName"),
+		QoS:       mqtt.QoS2,
+		PacketID:  1,
+	})
+	if err != nil {
+		nilErrorExpected(t, err)
+		return
+	}
+
+	cli.sess.receivingPackets[1] = p
+
+	if err = cli.handlePUBLISH(p); err != packet.ErrInvalidPacketID {
+		invalidError(t, err, packet.ErrInvalidPacketID)
 	}
 }
 
-func TestClient_handlePINGRESP_default(t *testing.T) {
+func TestClient_handlePUBLISH_QoS2_NewPUBRECErr(t *testing.T) {
 	cli := New(&Options{
 		ErrorHandler: func(_ error) {},
 	})
@@ -1546,14 +1752,24 @@ func TestClient_handlePINGRESP_default(t *testing.T) {
 
 	defer cli.Disconnect()
 
-	cli.conn.pingresps = append(cli.conn.pingresps, make(chan struct{}))
-
-	if err := cli.handlePINGRESP(); err != nil {
+	p, err := packet.NewPUBLISH(&packet.PUBLISHOptions{
+		TopicName: []byte("topicName"),
+		QoS:       mqtt.QoS2,
+		PacketID:  1,
+	})
+	if err != nil {
 		nilErrorExpected(t, err)
+		return
+	}
+
+	p.(*packet.PUBLISH).PacketID = 0
+
+	if err = cli.handlePUBLISH(p); err != packet.ErrInvalidPacketID {
+		invalidError(t, err, packet.ErrInvalidPacketID)
 	}
 }
 
-func TestClient_handlePINGRESP(t *testing.T) {
+func TestClient_handlePUBLISH_QoS2(t *testing.T) {
 	cli := New(&Options{
 		ErrorHandler: func(_ error) {},
 	})
@@ -1569,32 +1785,22 @@ func TestClient_handlePINGRESP(t *testing.T) {
 
 	defer cli.Disconnect()
 
-	cli.conn.pingresps = append(cli.conn.pingresps, make(chan struct{}, 1))
-
-	if err := cli.handlePINGRESP(); err != nil {
+	p, err := packet.NewPUBLISH(&packet.PUBLISHOptions{
+		TopicName: []byte("topicName"),
+		QoS:       mqtt.QoS2,
+		PacketID:  1,
+	})
+	if err != nil {
 		nilErrorExpected(t, err)
+		return
 	}
-}
-
-func TestClient_handleErrorAndDisconn_connNil(t *testing.T) {
-	cli := New(nil)
-
-	cli.handleErrorAndDisconn(errTest)
-}
 
-func TestClient_handleErrorAndDisconn_default(t *testing.T) {
-	cli := &Client{
-		errorHandler: func(_ error) {},
+	if err = cli.handlePUBLISH(p); err != nil {
+		nilErrorExpected(t, err)
 	}
-
-	cli.conn = &connection{}
-
-	cli.disconnc = make(chan struct{})
-
-	cli.handleErrorAndDisconn(errTest)
 }
 
-func TestClient_handleErrorAndDisconn(t *testing.T) {
+func TestClient_handlePUBACK_validatePacketIDErr(t *testing.T) {
 	cli := New(&Options{
 		ErrorHandler: func(_ error) {},
 	})
@@ -1608,13 +1814,21 @@ func TestClient_handleErrorAndDisconn(t *testing.T) {
 		nilErrorExpected(t, err)
 	}
 
-	cli.handleErrorAndDisconn(errTest)
+	defer cli.Disconnect()
 
-	time.Sleep(1 * time.Second)
+	p := &packet.PUBACK{
+		PacketID: 1,
+	}
+
+	if err := cli.handlePUBACK(p); err != packet.ErrInvalidPacketID {
+		invalidError(t, err, packet.ErrInvalidPacketID)
+	}
 }
 
-func TestClient_sendPackets_sendErr(t *testing.T) {
-	cli := New(nil)
+func TestClient_handlePUBACK(t *testing.T) {
+	cli := New(&Options{
+		ErrorHandler: func(_ error) {},
+	})
 
 	err := cli.Connect(&ConnectOptions{
 		Network:  "tcp",
@@ -1625,585 +1839,1385 @@ func TestClient_sendPackets_sendErr(t *testing.T) {
 		nilErrorExpected(t, err)
 	}
 
-	if err := cli.conn.Close(); err != nil {
+	defer cli.Disconnect()
+
+	p := &packet.PUBACK{
+		PacketID: 1,
+	}
+
+	publish, err := packet.NewPUBLISH(&packet.PUBLISHOptions{
+		TopicName: []byte("topicName"),
+		PacketID:  1,
+	})
+	if err != nil {
 		nilErrorExpected(t, err)
+		return
 	}
 
-	cli.conn.send <- &packetErr{}
+	cli.sess.sendingPackets[1] = publish
 
-	cli.conn.wg.Wait()
+	if err := cli.handlePUBACK(p); err != nil {
+		nilErrorExpected(t, err)
+	}
 }
 
-func TestClient_sendPackets_keepAliveErr(t *testing.T) {
-	cli := New(nil)
+func TestClient_handlePUBREC_validatePacketIDErr(t *testing.T) {
+	cli := New(&Options{
+		ErrorHandler: func(_ error) {},
+	})
 
 	err := cli.Connect(&ConnectOptions{
-		Network:   "tcp",
-		Address:   testAddress,
-		ClientID:  []byte("clientID"),
-		KeepAlive: 2,
+		Network:  "tcp",
+		Address:  testAddress,
+		ClientID: []byte("clientID"),
+	})
+	if err != nil {
+		nilErrorExpected(t, err)
+	}
+
+	defer cli.Disconnect()
+
+	p := &packet.PUBREC{
+		PacketID: 1,
+	}
+
+	if err := cli.handlePUBREC(p); err != packet.ErrInvalidPacketID {
+		invalidError(t, err, packet.ErrInvalidPacketID)
+	}
+}
+
+func TestClient_handlePUBREC_NewPUBRELErr(t *testing.T) {
+	cli := New(&Options{
+		ErrorHandler: func(_ error) {},
+	})
+
+	err := cli.Connect(&ConnectOptions{
+		Network:  "tcp",
+		Address:  testAddress,
+		ClientID: []byte("clientID"),
+	})
+	if err != nil {
+		nilErrorExpected(t, err)
+	}
+
+	defer cli.Disconnect()
+
+	p := &packet.PUBREC{
+		PacketID: 0,
+	}
+
+	publish, err := packet.NewPUBLISH(&packet.PUBLISHOptions{
+		TopicName: []byte("topicName"),
+		PacketID:  1,
+	})
+	if err != nil {
+		nilErrorExpected(t, err)
+		return
+	}
+
+	publish.(*packet.PUBLISH).PacketID = 0
+
+	cli.sess.sendingPackets[0] = publish
+
+	if err := cli.handlePUBREC(p); err != packet.ErrInvalidPacketID {
+		invalidError(t, err, packet.ErrInvalidPacketID)
+	}
+}
+
+func TestClient_handlePUBREC(t *testing.T) {
+	cli := New(&Options{
+		ErrorHandler: func(_ error) {},
+	})
+
+	err := cli.Connect(&ConnectOptions{
+		Network:  "tcp",
+		Address:  testAddress,
+		ClientID: []byte("clientID"),
+	})
+	if err != nil {
+		nilErrorExpected(t, err)
+	}
+
+	defer cli.Disconnect()
+
+	p := &packet.PUBREC{
+		PacketID: 1,
+	}
+
+	publish, err := packet.NewPUBLISH(&packet.PUBLISHOptions{
+		TopicName: []byte("topicName"),
+		PacketID:  1,
+	})
+	if err != nil {
+		nilErrorExpected(t, err)
+		return
+	}
+
+	cli.sess.sendingPackets[1] = publish
+
+	if err := cli.handlePUBREC(p); err != nil {
+		nilErrorExpected(t, err)
+	}
+}
+
+func TestClient_handlePUBREL_validatePacketIDErr(t *testing.T) {
+	cli := New(&Options{
+		ErrorHandler: func(_ error) {},
+	})
+
+	err := cli.Connect(&ConnectOptions{
+		Network:  "tcp",
+		Address:  testAddress,
+		ClientID: []byte("clientID"),
+	})
+	if err != nil {
+		nilErrorExpected(t, err)
+	}
+
+	defer cli.Disconnect()
+
+	p := &packet.PUBREL{
+		PacketID: 1,
+	}
+
+	if err := cli.handlePUBREL(p); err != packet.ErrInvalidPacketID {
+		invalidError(t, err, packet.ErrInvalidPacketID)
+	}
+}
+
+func TestClient_handlePUBREL_NewPUBCOMPErr(t *testing.T) {
+	cli := New(&Options{
+		ErrorHandler: func(_ error) {},
+	})
+
+	err := cli.Connect(&ConnectOptions{
+		Network:  "tcp",
+		Address:  testAddress,
+		ClientID: []byte("clientID"),
+	})
+	if err != nil {
+		nilErrorExpected(t, err)
+	}
+
+	defer cli.Disconnect()
+
+	p := &packet.PUBREL{
+		PacketID: 0,
+	}
+
+	publish, err := packet.NewPUBLISH(&packet.PUBLISHOptions{
+		TopicName: []byte("topicName"),
+		PacketID:  1,
+	})
+	if err != nil {
+		nilErrorExpected(t, err)
+		return
+	}
+
+	publish.(*packet.PUBLISH).PacketID = 0
+
+	cli.sess.receivingPackets[0] = publish
+
+	if err := cli.handlePUBREL(p); err != packet.ErrInvalidPacketID {
+		invalidError(t, err, packet.ErrInvalidPacketID)
+	}
+}
+
+func TestClient_handlePUBREL(t *testing.T) {
+	cli := New(&Options{
+		ErrorHandler: func(_ error) {},
+	})
+
+	err := cli.Connect(&ConnectOptions{
+		Network:  "tcp",
+		Address:  testAddress,
+		ClientID: []byte("clientID"),
+	})
+	if err != nil {
+		nilErrorExpected(t, err)
+	}
+
+	defer cli.Disconnect()
+
+	p := &packet.PUBREL{
+		PacketID: 1,
+	}
+
+	publish, err := packet.NewPUBLISH(&packet.PUBLISHOptions{
+		TopicName: []byte("topicName"),
+		PacketID:  1,
+	})
+	if err != nil {
+		nilErrorExpected(t, err)
+		return
+	}
+
+	cli.sess.receivingPackets[1] = publish
+
+	if err := cli.handlePUBREL(p); err != nil {
+		nilErrorExpected(t, err)
+	}
+}
+
+func TestClient_handlePUBCOMP_validatePacketIDErr(t *testing.T) {
+	cli := New(&Options{
+		ErrorHandler: func(_ error) {},
+	})
+
+	err := cli.Connect(&ConnectOptions{
+		Network:  "tcp",
+		Address:  testAddress,
+		ClientID: []byte("clientID"),
 	})
 	if err != nil {
 		nilErrorExpected(t, err)
 	}
 
-	if err := cli.conn.Close(); err != nil {
-		nilErrorExpected(t, err)
+	defer cli.Disconnect()
+
+	p := &packet.PUBCOMP{
+		PacketID: 1,
+	}
+
+	if err := cli.handlePUBCOMP(p); err != packet.ErrInvalidPacketID {
+		invalidError(t, err, packet.ErrInvalidPacketID)
+	}
+}
+
+func TestClient_handlePUBCOMP(t *testing.T) {
+	cli := New(&Options{
+		ErrorHandler: func(_ error) {},
+	})
+
+	err := cli.Connect(&ConnectOptions{
+		Network:  "tcp",
+		Address:  testAddress,
+		ClientID: []byte("clientID"),
+	})
+	if err != nil {
+		nilErrorExpected(t, err)
+	}
+
+	defer cli.Disconnect()
+
+	p := &packet.PUBCOMP{
+		PacketID: 1,
+	}
+
+	pubrel, err := packet.NewPUBREL(&packet.PUBRELOptions{
+		PacketID: 1,
+	})
+	if err != nil {
+		nilErrorExpected(t, err)
+		return
+	}
+
+	cli.sess.sendingPackets[1] = pubrel
+
+	if err := cli.handlePUBCOMP(p); err != nil {
+		nilErrorExpected(t, err)
+	}
+}
+
+func TestClient_handleSUBACK_validatePacketIDErr(t *testing.T) {
+	cli := New(&Options{
+		ErrorHandler: func(_ error) {},
+	})
+
+	err := cli.Connect(&ConnectOptions{
+		Network:  "tcp",
+		Address:  testAddress,
+		ClientID: []byte("clientID"),
+	})
+	if err != nil {
+		nilErrorExpected(t, err)
+	}
+
+	defer cli.Disconnect()
+
+	p := &packet.SUBACK{
+		PacketID:    1,
+		ReturnCodes: []byte{mqtt.QoS0},
+	}
+
+	if err := cli.handleSUBACK(p); err != packet.ErrInvalidPacketID {
+		invalidError(t, err, packet.ErrInvalidPacketID)
+	}
+}
+
+func TestClient_handleSUBACK_ErrInvalidSUBACK(t *testing.T) {
+	cli := New(&Options{
+		ErrorHandler: func(_ error) {},
+	})
+
+	err := cli.Connect(&ConnectOptions{
+		Network:  "tcp",
+		Address:  testAddress,
+		ClientID: []byte("clientID"),
+	})
+	if err != nil {
+		nilErrorExpected(t, err)
+	}
+
+	defer cli.Disconnect()
+
+	p := &packet.SUBACK{
+		PacketID:    1,
+		ReturnCodes: []byte{mqtt.QoS0},
+	}
+
+	subscribe, err := packet.NewSUBSCRIBE(&packet.SUBSCRIBEOptions{
+		PacketID: 1,
+		SubReqs: []*packet.SubReq{
+			&packet.SubReq{
+				TopicFilter: []byte("test"),
+				QoS:         mqtt.QoS0,
+			},
+			&packet.SubReq{
+				TopicFilter: []byte("test2"),
+				QoS:         mqtt.QoS0,
+			},
+		},
+	})
+	if err != nil {
+		nilErrorExpected(t, err)
+	}
+
+	cli.sess.sendingPackets[1] = subscribe
+
+	if err := cli.handleSUBACK(p); err != ErrInvalidSUBACK {
+		invalidError(t, err, ErrInvalidSUBACK)
+	}
+}
+
+func TestClient_handleSUBACK(t *testing.T) {
+	cli := New(&Options{
+		ErrorHandler: func(_ error) {},
+	})
+
+	err := cli.Connect(&ConnectOptions{
+		Network:  "tcp",
+		Address:  testAddress,
+		ClientID: []byte("clientID"),
+	})
+	if err != nil {
+		nilErrorExpected(t, err)
+	}
+
+	defer cli.Disconnect()
+
+	p := &packet.SUBACK{
+		PacketID:    1,
+		ReturnCodes: []byte{packet.SUBACKRetFailure, mqtt.QoS0},
+	}
+
+	subscribe, err := packet.NewSUBSCRIBE(&packet.SUBSCRIBEOptions{
+		PacketID: 1,
+		SubReqs: []*packet.SubReq{
+			&packet.SubReq{
+				TopicFilter: []byte("test"),
+				QoS:         mqtt.QoS0,
+			},
+			&packet.SubReq{
+				TopicFilter: []byte("test2"),
+				QoS:         mqtt.QoS0,
+			},
+		},
+	})
+	if err != nil {
+		nilErrorExpected(t, err)
+	}
+
+	cli.sess.sendingPackets[1] = subscribe
+
+	if err := cli.handleSUBACK(p); err != nil {
+		nilErrorExpected(t, err)
+	}
+}
+
+func TestClient_handleUNSUBACK_validatePacketIDErr(t *testing.T) {
+	cli := New(&Options{
+		ErrorHandler: func(_ error) {},
+	})
+
+	err := cli.Connect(&ConnectOptions{
+		Network:  "tcp",
+		Address:  testAddress,
+		ClientID: []byte("clientID"),
+	})
+	if err != nil {
+		nilErrorExpected(t, err)
+	}
+
+	defer cli.Disconnect()
+
+	p := &packet.UNSUBACK{
+		PacketID: 1,
+	}
+
+	if err := cli.handleUNSUBACK(p); err != packet.ErrInvalidPacketID {
+		invalidError(t, err, packet.ErrInvalidPacketID)
+	}
+}
+
+func TestClient_handleUNSUBACK(t *testing.T) {
+	cli := New(&Options{
+		ErrorHandler: func(_ error) {},
+	})
+
+	err := cli.Connect(&ConnectOptions{
+		Network:  "tcp",
+		Address:  testAddress,
+		ClientID: []byte("clientID"),
+	})
+	if err != nil {
+		nilErrorExpected(t, err)
+	}
+
+	defer cli.Disconnect()
+
+	p := &packet.UNSUBACK{
+		PacketID: 1,
+	}
+
+	unsubscribe, err := packet.NewUNSUBSCRIBE(&packet.UNSUBSCRIBEOptions{
+		PacketID:     1,
+		TopicFilters: [][]byte{[]byte("test")},
+	})
+	if err != nil {
+		nilErrorExpected(t, err)
+	}
+
+	cli.sess.sendingPackets[1] = unsubscribe
+
+	if err := cli.handleUNSUBACK(p); err != nil {
+		nilErrorExpected(t, err)
+	}
+}
+
+func TestClient_handlePINGRESP_ErrInvalidPINGRESP(t *testing.T) {
+	cli := New(&Options{
+		ErrorHandler: func(_ error) {},
+	})
+
+	err := cli.Connect(&ConnectOptions{
+		Network:  "tcp",
+		Address:  testAddress,
+		ClientID: []byte("clientID"),
+	})
+	if err != nil {
+		nilErrorExpected(t, err)
+	}
+
+	defer cli.Disconnect()
+
+	if err := cli.handlePINGRESP(); err != ErrInvalidPINGRESP {
+		invalidError(t, err, ErrInvalidPINGRESP)
+	}
+}
+
+func TestClient_handlePINGRESP_default(t *testing.T) {
+	cli := New(&Options{
+		ErrorHandler: func(_ error) {},
+	})
+
+	err := cli.Connect(&ConnectOptions{
+		Network:  "tcp",
+		Address:  testAddress,
+		ClientID: []byte("clientID"),
+	})
+	if err != nil {
+		nilErrorExpected(t, err)
+	}
+
+	defer cli.Disconnect()
+
+	cli.conn.pingresps = append(cli.conn.pingresps, make(chan struct{}))
+
+	if err := cli.handlePINGRESP(); err != nil {
+		nilErrorExpected(t, err)
+	}
+}
+
+func TestClient_handlePINGRESP(t *testing.T) {
+	cli := New(&Options{
+		ErrorHandler: func(_ error) {},
+	})
+
+	err := cli.Connect(&ConnectOptions{
+		Network:  "tcp",
+		Address:  testAddress,
+		ClientID: []byte("clientID"),
+	})
+	if err != nil {
+		nilErrorExpected(t, err)
+	}
+
+	defer cli.Disconnect()
+
+	cli.conn.pingresps = append(cli.conn.pingresps, make(chan struct{}, 1))
+
+	if err := cli.handlePINGRESP(); err != nil {
+		nilErrorExpected(t, err)
+	}
+}
+
+func TestClient_handleErrorAndDisconn_connNil(t *testing.T) {
+	cli := New(nil)
+
+	cli.handleErrorAndDisconn(errTest)
+}
+
+func TestClient_handleErrorAndDisconn_default(t *testing.T) {
+	cli := &Client{
+		errorHandler: func(_ error) {},
+	}
+
+	cli.conn = &connection{}
+
+	cli.disconnc = make(chan error)
+
+	cli.handleErrorAndDisconn(errTest)
+}
+
+func TestClient_handleErrorAndDisconn(t *testing.T) {
+	cli := New(&Options{
+		ErrorHandler: func(_ error) {},
+	})
+
+	err := cli.Connect(&ConnectOptions{
+		Network:  "tcp",
+		Address:  testAddress,
+		ClientID: []byte("clientID"),
+	})
+	if err != nil {
+		nilErrorExpected(t, err)
+	}
+
+	cli.handleErrorAndDisconn(errTest)
+
+	time.Sleep(1 * time.Second)
+}
+
+func TestClient_sendPackets_sendErr(t *testing.T) {
+	cli := New(nil)
+
+	err := cli.Connect(&ConnectOptions{
+		Network:  "tcp",
+		Address:  testAddress,
+		ClientID: []byte("clientID"),
+	})
+	if err != nil {
+		nilErrorExpected(t, err)
+	}
+
+	if err := cli.conn.Close(); err != nil {
+		nilErrorExpected(t, err)
+	}
+
+	cli.conn.send <- &packetErr{}
+
+	cli.conn.wg.Wait()
+}
+
+func TestClient_sendPackets_keepAliveErr(t *testing.T) {
+	cli := New(nil)
+
+	err := cli.Connect(&ConnectOptions{
+		Network:   "tcp",
+		Address:   testAddress,
+		ClientID:  []byte("clientID"),
+		KeepAlive: 2,
+	})
+	if err != nil {
+		nilErrorExpected(t, err)
+	}
+
+	if err := cli.conn.Close(); err != nil {
+		nilErrorExpected(t, err)
+	}
+
+	cli.conn.wg.Wait()
+}
+
+func TestClient_sendPackets_sendEnd(t *testing.T) {
+	cli := New(nil)
+
+	err := cli.Connect(&ConnectOptions{
+		Network:   "tcp",
+		Address:   testAddress,
+		ClientID:  []byte("clientID"),
+		KeepAlive: 3,
+	})
+	if err != nil {
+		nilErrorExpected(t, err)
+	}
+
+	time.Sleep(5 * time.Second)
+
+	cli.conn.muPINGRESPs.Lock()
+	cli.conn.pingresps = append(cli.conn.pingresps, make(chan struct{}))
+	cli.conn.muPINGRESPs.Unlock()
+
+	cli.conn.sendEnd <- struct{}{}
+
+	cli.muConn.Lock()
+	wg := &cli.conn.wg
+	cli.muConn.Unlock()
+
+	wg.Wait()
+}
+
+func TestClient_newPUBLISHPacket_generatePacketID(t *testing.T) {
+	cli := New(nil)
+
+	cli.sess = newSession(false, []byte("clientID"))
+
+	id := minPacketID
+
+	for {
+		cli.sess.sendingPackets[id] = nil
+
+		if id == maxPacketID {
+			break
+		}
+
+		id++
+	}
+
+	_, err := cli.newPUBLISHPacket(&PublishOptions{
+		TopicName: []byte("topicName"),
+		QoS:       mqtt.QoS1,
+	})
+
+	if err != ErrPacketIDExhaused {
+		invalidError(t, err, ErrPacketIDExhaused)
+	}
+}
+
+func TestClient_newPUBLISHPacket_ErrInvalidQoS(t *testing.T) {
+	cli := New(nil)
+
+	cli.sess = newSession(false, []byte("clientID"))
+
+	_, err := cli.newPUBLISHPacket(&PublishOptions{
+		QoS: 0x03,
+	})
+
+	if err != packet.ErrInvalidQoS {
+		invalidError(t, err, packet.ErrInvalidQoS)
+	}
+}
+
+func TestClient_newPUBLISHPacket(t *testing.T) {
+	cli := New(nil)
+
+	cli.sess = newSession(false, []byte("clientID"))
+
+	_, err := cli.newPUBLISHPacket(&PublishOptions{
+		TopicName: []byte("topicName"),
+		QoS:       mqtt.QoS1,
+	})
+
+	if err != nil {
+		nilErrorExpected(t, err)
+	}
+}
+
+func TestClient_validatePacketID_notExist(t *testing.T) {
+	cli := New(nil)
+
+	if err := cli.validatePacketID(map[uint16]packet.Packet{}, 1, packet.TypePUBLISH); err != packet.ErrInvalidPacketID {
+		invalidError(t, err, packet.ErrInvalidPacketID)
+	}
+}
+
+func TestClient_validatePacketID_ptypeErr(t *testing.T) {
+	cli := New(nil)
+
+	if err := cli.validatePacketID(map[uint16]packet.Packet{1: &packetErr{}}, 1, packet.TypePUBLISH); err != errTest {
+		invalidError(t, err, errTest)
+	}
+}
+
+func TestClient_validatePacketID_ErrInvalidPacketID(t *testing.T) {
+	cli := New(nil)
+
+	p, err := packet.NewPUBACK(&packet.PUBACKOptions{
+		PacketID: 1,
+	})
+	if err != nil {
+		nilErrorExpected(t, err)
+	}
+
+	if err := cli.validatePacketID(map[uint16]packet.Packet{1: p}, 1, packet.TypePUBLISH); err != packet.ErrInvalidPacketID {
+		invalidError(t, err, packet.ErrInvalidPacketID)
+	}
+}
+
+func TestClient_validatePacketID(t *testing.T) {
+	cli := New(nil)
+
+	p, err := packet.NewPUBLISH(&packet.PUBLISHOptions{
+		TopicName: []byte("topicName"),
+		PacketID:  1,
+	})
+	if err != nil {
+		nilErrorExpected(t, err)
+	}
+
+	if err := cli.validatePacketID(map[uint16]packet.Packet{1: p}, 1, packet.TypePUBLISH); err != nil {
+		nilErrorExpected(t, err)
+	}
+}
+
+func TestClient_handleMessage_continue(t *testing.T) {
+	cli := New(nil)
+
+	cli.conn = &connection{}
+
+	cli.conn.ackedSubs = newTestTrie(map[string]*SubReq{
+		"test": nil,
+	})
+
+	cli.handleMessage(&packet.PUBLISH{TopicName: []byte("test")}, nil)
+}
+
+func TestClient_handleMessage(t *testing.T) {
+	cli := New(nil)
+
+	cli.conn = &connection{}
+
+	cli.conn.ackedSubs = newTestTrie(map[string]*SubReq{
+		"test": &SubReq{
+			Handler: func(_, _ []byte) {},
+		},
+	})
+
+	cli.handleMessage(&packet.PUBLISH{TopicName: []byte("test")}, nil)
+}
+
+func TestClient_handleMessage_OnMessage(t *testing.T) {
+	cli := New(nil)
+
+	cli.conn = &connection{}
+
+	msgc := make(chan *Message, 1)
+
+	cli.conn.ackedSubs = newTestTrie(map[string]*SubReq{
+		"test": &SubReq{
+			Handler: func(_, _ []byte) {
+				t.Error("Handler was executed instead of OnMessage")
+			},
+			OnMessage: func(msg *Message) {
+				msgc <- msg
+			},
+		},
+	})
+
+	cli.handleMessage(&packet.PUBLISH{
+		DUP:       true,
+		QoS:       mqtt.QoS1,
+		Retain:    true,
+		TopicName: []byte("test"),
+		PacketID:  1,
+		Message:   []byte("message"),
+	}, nil)
+
+	select {
+	case msg := <-msgc:
+		want := Message{
+			TopicName: []byte("test"),
+			Payload:   []byte("message"),
+			QoS:       mqtt.QoS1,
+			Retain:    true,
+			DUP:       true,
+			PacketID:  1,
+		}
+
+		if !reflect.DeepEqual(*msg, want) {
+			t.Errorf("msg => %+v, want => %+v", *msg, want)
+		}
+	case <-time.After(5 * time.Second):
+		t.Error("OnMessage was not executed")
+	}
+}
+
+func TestNew_optsNil(t *testing.T) {
+	cli := New(nil)
+
+	cli.disconnc <- errTest
+
+	time.Sleep(500 * time.Millisecond)
+
+	cli.disconnEndc <- struct{}{}
+
+	cli.wg.Wait()
+
+}
+
+func TestNew(t *testing.T) {
+	cli := New(&Options{
+		ErrorHandler: func(_ error) {},
+	})
+
+	cli.disconnc <- errTest
+
+	time.Sleep(500 * time.Millisecond)
+
+	cli.disconnEndc <- struct{}{}
+
+	cli.wg.Wait()
+}
+
+func Test_connectReturnCodeErr(t *testing.T) {
+	testCases := []struct {
+		code byte
+		want error
+	}{
+		{packet.ConnRetAccepted, nil},
+		{packet.ConnRetUnacceptableProtocolVersion, ErrUnacceptableProtocolVersion},
+		{packet.ConnRetIdentifierRejected, ErrIdentifierRejected},
+		{packet.ConnRetServerUnavailable, ErrServerUnavailable},
+		{packet.ConnRetBadUserNameOrPassword, ErrBadUserNameOrPassword},
+		{packet.ConnRetNotAuthorized, ErrNotAuthorized},
+		{0x06, packet.ErrInvalidConnectReturnCode},
+	}
+
+	for _, tc := range testCases {
+		if err := connectReturnCodeErr(tc.code); err != tc.want {
+			invalidError(t, err, tc.want)
+		}
+	}
+}
+
+func invalidError(t *testing.T, err, want error) {
+	if err == nil {
+		t.Errorf("err => nil, want => %q", want)
+	} else {
+		t.Errorf("err => %q, want => %q", err, want)
+	}
+}
+
+// subscribeTestClient sets the subscription to the Client
+// as if it has been acknowledged by the Server.
+func subscribeTestClient(cli *Client, subReq *SubReq) {
+	cli.muConn.Lock()
+	cli.conn.ackedSubs.Add(string(subReq.TopicFilter), subReq)
+	cli.muConn.Unlock()
+}
+
+func TestClient_handlePUBLISH_QoS1_ManualAck(t *testing.T) {
+	publishc := make(chan struct{})
+
+	pubackc := make(chan []byte, 1)
+
+	cli, ln := newTestClient(t, func(conn net.Conn) {
+		<-publishc
+
+		conn.Write([]byte{packet.TypePUBLISH<<4 | mqtt.QoS1<<1, 0x05, 0x00, 0x01, 0x61, 0x00, 0x01})
+
+		first, remaining, err := readTestPacket(conn)
+		if err != nil || first>>4 != packet.TypePUBACK {
+			return
+		}
+
+		pubackc <- remaining
+
+		readTestPacket(conn)
+	})
+
+	defer ln.Close()
+	defer cli.Terminate()
+
+	msgc := make(chan *Message, 1)
+
+	subscribeTestClient(cli, &SubReq{
+		TopicFilter: []byte("a"),
+		OnMessage: func(msg *Message) {
+			msgc <- msg
+		},
+		ManualAck: true,
+	})
+
+	close(publishc)
+
+	msg := <-msgc
+
+	select {
+	case <-pubackc:
+		t.Error("the PUBACK Packet was sent before the acknowledgement")
+	case <-time.After(100 * time.Millisecond):
+	}
+
+	msg.Ack()
+
+	select {
+	case remaining := <-pubackc:
+		if remaining[0] != 0x00 || remaining[1] != 0x01 {
+			t.Errorf("remaining => %v, want => [0 1]", remaining)
+		}
+	case <-time.After(5 * time.Second):
+		t.Error("the PUBACK Packet was not sent")
+	}
+
+	if err := cli.Disconnect(); err != nil {
+		nilErrorExpected(t, err)
+	}
+}
+
+func TestClient_handlePUBREL_ManualAck(t *testing.T) {
+	publishc := make(chan struct{})
+
+	pubrelc := make(chan struct{})
+
+	pubcompc := make(chan []byte, 1)
+
+	cli, ln := newTestClient(t, func(conn net.Conn) {
+		<-publishc
+
+		conn.Write([]byte{packet.TypePUBLISH<<4 | mqtt.QoS2<<1, 0x05, 0x00, 0x01, 0x61, 0x00, 0x01})
+
+		if first, _, err := readTestPacket(conn); err != nil || first>>4 != packet.TypePUBREC {
+			return
+		}
+
+		conn.Write([]byte{packet.TypePUBREL<<4 | 0x02, 0x02, 0x00, 0x01})
+
+		<-pubrelc
+
+		// Resend the PUBREL Packet.
+		conn.Write([]byte{packet.TypePUBREL<<4 | 0x02, 0x02, 0x00, 0x01})
+
+		first, remaining, err := readTestPacket(conn)
+		if err != nil || first>>4 != packet.TypePUBCOMP {
+			return
+		}
+
+		pubcompc <- remaining
+
+		readTestPacket(conn)
+	})
+
+	defer ln.Close()
+	defer cli.Terminate()
+
+	msgc := make(chan *Message, 2)
+
+	subscribeTestClient(cli, &SubReq{
+		TopicFilter: []byte("a"),
+		OnMessage: func(msg *Message) {
+			msgc <- msg
+		},
+		ManualAck: true,
+	})
+
+	close(publishc)
+
+	msg := <-msgc
+
+	close(pubrelc)
+
+	select {
+	case <-pubcompc:
+		t.Error("the PUBCOMP Packet was sent before the acknowledgement")
+	case <-msgc:
+		t.Error("the handler was executed twice")
+	case <-time.After(100 * time.Millisecond):
+	}
+
+	msg.Ack()
+
+	select {
+	case remaining := <-pubcompc:
+		if remaining[0] != 0x00 || remaining[1] != 0x01 {
+			t.Errorf("remaining => %v, want => [0 1]", remaining)
+		}
+	case <-time.After(5 * time.Second):
+		t.Error("the PUBCOMP Packet was not sent")
+	}
+
+	if err := cli.Disconnect(); err != nil {
+		nilErrorExpected(t, err)
+	}
+}
+
+func TestClient_completePUBREL_notReleased(t *testing.T) {
+	cli := New(nil)
+
+	cli.sess = newSession(true, []byte("clientID"))
+
+	cli.completePUBREL(1, nil)
+}
+
+func TestClient_handlePUBREL_ManualAck_connectionLost(t *testing.T) {
+	publishc := make(chan struct{})
+
+	lostc := make(chan struct{})
+
+	cli, ln := newTestClientWithOptions(t, &Options{
+		ErrorHandler: func(_ error) {},
+		OnConnectionLost: func(_ error) {
+			close(lostc)
+		},
+	}, &ConnectOptions{
+		ClientID:     []byte("clientID"),
+		CleanSession: true,
+	}, func(conn net.Conn) {
+		<-publishc
+
+		conn.Write([]byte{packet.TypePUBLISH<<4 | mqtt.QoS2<<1, 0x05, 0x00, 0x01, 0x61, 0x00, 0x01})
+
+		if first, _, err := readTestPacket(conn); err != nil || first>>4 != packet.TypePUBREC {
+			return
+		}
+
+		conn.Write([]byte{packet.TypePUBREL<<4 | 0x02, 0x02, 0x00, 0x01})
+
+		// Lose the Network Connection before the acknowledgement.
+	})
+
+	defer ln.Close()
+	defer cli.Terminate()
+
+	msgc := make(chan *Message, 1)
+
+	subscribeTestClient(cli, &SubReq{
+		TopicFilter: []byte("a"),
+		OnMessage: func(msg *Message) {
+			msgc <- msg
+		},
+		ManualAck: true,
+	})
+
+	close(publishc)
+
+	var msg *Message
+
+	select {
+	case msg = <-msgc:
+	case <-time.After(5 * time.Second):
+		t.Error("the handler was not executed")
+		return
+	}
+
+	select {
+	case <-lostc:
+	case <-time.After(5 * time.Second):
+		t.Error("the Network Connection was not lost")
+		return
 	}
 
-	cli.conn.wg.Wait()
+	// Acknowledge the Application Message after the Session has been cleaned.
+	msg.Ack()
 }
 
-func TestClient_sendPackets_sendEnd(t *testing.T) {
+func TestClient_sendAck_notConnected(t *testing.T) {
 	cli := New(nil)
 
-	err := cli.Connect(&ConnectOptions{
-		Network:   "tcp",
-		Address:   testAddress,
-		ClientID:  []byte("clientID"),
-		KeepAlive: 3,
-	})
-	if err != nil {
-		nilErrorExpected(t, err)
-	}
+	cli.sendAck(nil, nil)
+}
 
-	time.Sleep(5 * time.Second)
+func TestClient_Connect_v5(t *testing.T) {
+	var level byte
 
-	cli.conn.muPINGRESPs.Lock()
-	cli.conn.pingresps = append(cli.conn.pingresps, make(chan struct{}))
-	cli.conn.muPINGRESPs.Unlock()
+	ln := newTestServer(t, func(conn net.Conn) {
+		defer conn.Close()
 
-	cli.conn.sendEnd <- struct{}{}
+		_, remaining, err := readTestPacket(conn)
+		if err != nil {
+			return
+		}
 
-	cli.muConn.Lock()
-	wg := &cli.conn.wg
-	cli.muConn.Unlock()
+		level = remaining[6]
 
-	wg.Wait()
-}
+		// CONNACK with the Assigned Client Identifier "id" and
+		// the Server Keep Alive 0.
+		if _, err := conn.Write([]byte{packet.TypeCONNACK << 4, 0x0B, 0x00, 0x00, 0x08, 0x12, 0x00, 0x02, 'i', 'd', 0x13, 0x00, 0x00}); err != nil {
+			return
+		}
 
-func TestClient_newPUBLISHPacket_generatePacketID(t *testing.T) {
-	cli := New(nil)
+		readTestPacket(conn)
+	})
 
-	cli.sess = newSession(false, []byte("clientID"))
+	defer ln.Close()
 
-	id := minPacketID
+	cli := New(&Options{
+		ErrorHandler: func(_ error) {},
+	})
 
-	for {
-		cli.sess.sendingPackets[id] = nil
+	err := cli.Connect(&ConnectOptions{
+		Network:         "tcp",
+		Address:         ln.Addr().String(),
+		CleanSession:    true,
+		KeepAlive:       60,
+		ProtocolVersion: mqtt.ProtocolVersion5,
+	})
+	if err != nil {
+		nilErrorExpected(t, err)
+		return
+	}
 
-		if id == maxPacketID {
-			break
-		}
+	defer cli.Disconnect()
 
-		id++
+	if level != mqtt.ProtocolVersion5 {
+		t.Errorf("level => %d, want => %d", level, mqtt.ProtocolVersion5)
 	}
 
-	_, err := cli.newPUBLISHPacket(&PublishOptions{
-		QoS: mqtt.QoS1,
-	})
+	props := cli.CONNACKProperties()
 
-	if err != ErrPacketIDExhaused {
-		invalidError(t, err, ErrPacketIDExhaused)
+	if props == nil || string(props.AssignedClientIdentifier) != "id" || props.ServerKeepAlive == nil || *props.ServerKeepAlive != 0 {
+		t.Errorf("cli.CONNACKProperties() => %+v", props)
 	}
 }
 
-func TestClient_newPUBLISHPacket_ErrInvalidQoS(t *testing.T) {
-	cli := New(nil)
+func TestClient_Connect_v5ReasonCodeError(t *testing.T) {
+	ln := newTestServer(t, func(conn net.Conn) {
+		defer conn.Close()
 
-	cli.sess = newSession(false, []byte("clientID"))
+		if _, _, err := readTestPacket(conn); err != nil {
+			return
+		}
 
-	_, err := cli.newPUBLISHPacket(&PublishOptions{
-		QoS: 0x03,
+		conn.Write([]byte{packet.TypeCONNACK << 4, 0x03, 0x00, packet.ReasonBanned, 0x00})
 	})
 
-	if err != packet.ErrInvalidQoS {
-		invalidError(t, err, packet.ErrInvalidQoS)
+	defer ln.Close()
+
+	cli := New(&Options{
+		ErrorHandler: func(_ error) {},
+	})
+
+	err := cli.Connect(&ConnectOptions{
+		Network:         "tcp",
+		Address:         ln.Addr().String(),
+		ClientID:        []byte("clientID"),
+		ProtocolVersion: mqtt.ProtocolVersion5,
+	})
+
+	if rerr, ok := err.(*ReasonCodeError); !ok || rerr.PacketType != packet.TypeCONNACK || rerr.ReasonCode != packet.ReasonBanned {
+		t.Errorf("err => %v, want => *ReasonCodeError", err)
 	}
 }
 
-func TestClient_newPUBLISHPacket(t *testing.T) {
+func TestClient_handlePUBACK_ReasonCodeError(t *testing.T) {
 	cli := New(nil)
+	cli.sess = newSession(true, []byte("clientID"))
 
-	cli.sess = newSession(false, []byte("clientID"))
-
-	_, err := cli.newPUBLISHPacket(&PublishOptions{
-		QoS: mqtt.QoS1,
+	publish, err := packet.NewPUBLISH(&packet.PUBLISHOptions{
+		QoS:       mqtt.QoS1,
+		TopicName: []byte("a"),
+		PacketID:  1,
 	})
-
 	if err != nil {
 		nilErrorExpected(t, err)
+		return
 	}
-}
 
-func TestClient_validatePacketID_notExist(t *testing.T) {
-	cli := New(nil)
+	cli.sess.sendingPackets[1] = publish
 
-	if err := cli.validatePacketID(map[uint16]packet.Packet{}, 1, packet.TypePUBLISH); err != packet.ErrInvalidPacketID {
-		invalidError(t, err, packet.ErrInvalidPacketID)
+	token := newToken()
+	cli.sess.tokens[1] = token
+
+	if err := cli.handlePUBACK(&packet.PUBACK{PacketID: 1, ReasonCode: packet.ReasonNotAuthorized}); err != nil {
+		nilErrorExpected(t, err)
+		return
 	}
-}
 
-func TestClient_validatePacketID_ptypeErr(t *testing.T) {
-	cli := New(nil)
+	if _, exist := cli.sess.sendingPackets[1]; exist {
+		t.Error("the PUBLISH Packet was not deleted from the Session")
+	}
 
-	if err := cli.validatePacketID(map[uint16]packet.Packet{1: &packetErr{}}, 1, packet.TypePUBLISH); err != errTest {
-		invalidError(t, err, errTest)
+	if rerr, ok := token.Err().(*ReasonCodeError); !ok || rerr.ReasonCode != packet.ReasonNotAuthorized {
+		t.Errorf("token.Err() => %v, want => *ReasonCodeError", token.Err())
 	}
 }
 
-func TestClient_validatePacketID_ErrInvalidPacketID(t *testing.T) {
+func TestClient_handlePUBREC_ReasonCodeError(t *testing.T) {
 	cli := New(nil)
+	cli.sess = newSession(true, []byte("clientID"))
 
-	p, err := packet.NewPUBACK(&packet.PUBACKOptions{
-		PacketID: 1,
+	publish, err := packet.NewPUBLISH(&packet.PUBLISHOptions{
+		QoS:       mqtt.QoS2,
+		TopicName: []byte("a"),
+		PacketID:  1,
 	})
 	if err != nil {
 		nilErrorExpected(t, err)
+		return
 	}
 
-	if err := cli.validatePacketID(map[uint16]packet.Packet{1: p}, 1, packet.TypePUBLISH); err != packet.ErrInvalidPacketID {
-		invalidError(t, err, packet.ErrInvalidPacketID)
+	cli.sess.sendingPackets[1] = publish
+
+	token := newToken()
+	cli.sess.tokens[1] = token
+
+	if err := cli.handlePUBREC(&packet.PUBREC{PacketID: 1, ReasonCode: packet.ReasonQuotaExceeded}); err != nil {
+		nilErrorExpected(t, err)
+		return
+	}
+
+	if _, exist := cli.sess.sendingPackets[1]; exist {
+		t.Error("the PUBLISH Packet was not deleted from the Session")
+	}
+
+	if rerr, ok := token.Err().(*ReasonCodeError); !ok || rerr.ReasonCode != packet.ReasonQuotaExceeded {
+		t.Errorf("token.Err() => %v, want => *ReasonCodeError", token.Err())
 	}
 }
 
-func TestClient_validatePacketID(t *testing.T) {
+func TestClient_handleDISCONNECT(t *testing.T) {
 	cli := New(nil)
 
-	p, err := packet.NewPUBLISH(&packet.PUBLISHOptions{
-		PacketID: 1,
-	})
+	p, err := packet.NewDISCONNECTWithOptions(nil)
 	if err != nil {
 		nilErrorExpected(t, err)
+		return
 	}
 
-	if err := cli.validatePacketID(map[uint16]packet.Packet{1: p}, 1, packet.TypePUBLISH); err != nil {
+	if err := cli.handleDISCONNECT(p); err != packet.ErrInvalidPacketType {
+		invalidError(t, err, packet.ErrInvalidPacketType)
+	}
+
+	p, err = packet.NewDISCONNECTWithOptions(&packet.DISCONNECTOptions{
+		ProtocolVersion: mqtt.ProtocolVersion5,
+		ReasonCode:      packet.ReasonSessionTakenOver,
+	})
+	if err != nil {
 		nilErrorExpected(t, err)
+		return
+	}
+
+	if rerr, ok := cli.handleDISCONNECT(p).(*ReasonCodeError); !ok || rerr.ReasonCode != packet.ReasonSessionTakenOver {
+		t.Errorf("err => %v, want => *ReasonCodeError", rerr)
 	}
 }
 
-func TestClient_handleMessage_continue(t *testing.T) {
+func TestClient_respondAUTH(t *testing.T) {
 	cli := New(nil)
-
 	cli.conn = &connection{}
 
-	cli.conn.ackedSubs = map[string]MessageHandler{
-		"test": nil,
+	auth := &packet.AUTH{ReasonCode: packet.ReasonContinueAuthentication}
+
+	send := func(p packet.Packet) error {
+		t.Errorf("the Packet %v was sent, want => no Packet", p)
+		return nil
 	}
 
-	cli.handleMessage([]byte("test"), nil)
-}
+	if err := cli.respondAUTH(auth, send); err != packet.ErrInvalidPacketType {
+		invalidError(t, err, packet.ErrInvalidPacketType)
+	}
 
-func TestClient_handleMessage(t *testing.T) {
-	cli := New(nil)
+	cli.conn.authHandler = func(_ byte, _ *packet.Properties) (*packet.Properties, error) {
+		return nil, errTest
+	}
 
-	cli.conn = &connection{}
+	if err := cli.respondAUTH(auth, send); err != errTest {
+		invalidError(t, err, errTest)
+	}
+
+	var sent packet.Packet
+
+	cli.conn.authHandler = func(reasonCode byte, props *packet.Properties) (*packet.Properties, error) {
+		return &packet.Properties{AuthenticationMethod: props.AuthenticationMethod}, nil
+	}
 
-	cli.conn.ackedSubs = map[string]MessageHandler{
-		"test": func(_, _ []byte) {},
+	auth.Properties = &packet.Properties{AuthenticationMethod: []byte("m")}
+
+	if err := cli.respondAUTH(auth, func(p packet.Packet) error {
+		sent = p
+		return nil
+	}); err != nil {
+		nilErrorExpected(t, err)
+		return
 	}
 
-	cli.handleMessage([]byte("test"), nil)
+	if a, ok := sent.(*packet.AUTH); !ok || a.ReasonCode != packet.ReasonContinueAuthentication || string(a.Properties.AuthenticationMethod) != "m" {
+		t.Errorf("sent => %+v, want => an AUTH Packet", sent)
+	}
 }
 
-func TestNew_optsNil(t *testing.T) {
-	cli := New(nil)
+func TestClient_Connect_v31(t *testing.T) {
+	var protocolName string
 
-	cli.disconnc <- struct{}{}
+	ln := newTestServer(t, func(conn net.Conn) {
+		defer conn.Close()
 
-	time.Sleep(500 * time.Millisecond)
+		_, remaining, err := readTestPacket(conn)
+		if err != nil {
+			return
+		}
 
-	cli.disconnEndc <- struct{}{}
+		protocolName = string(remaining[2:8])
 
-	cli.wg.Wait()
+		// The first byte of the variable header is not used in MQTT 3.1.
+		if _, err := conn.Write([]byte{packet.TypeCONNACK << 4, 0x02, 0x01, 0x00}); err != nil {
+			return
+		}
 
-}
+		readTestPacket(conn)
+	})
+
+	defer ln.Close()
 
-func TestNew(t *testing.T) {
 	cli := New(&Options{
 		ErrorHandler: func(_ error) {},
 	})
 
-	cli.disconnc <- struct{}{}
+	err := cli.Connect(&ConnectOptions{
+		Network:         "tcp",
+		Address:         ln.Addr().String(),
+		ClientID:        []byte("clientID"),
+		ProtocolVersion: mqtt.ProtocolVersion31,
+	})
+	if err != nil {
+		nilErrorExpected(t, err)
+		return
+	}
 
-	time.Sleep(500 * time.Millisecond)
+	defer cli.Disconnect()
 
-	cli.disconnEndc <- struct{}{}
+	if protocolName != "MQIsdp" {
+		t.Errorf("protocolName => %q, want => %q", protocolName, "MQIsdp")
+	}
 
-	cli.wg.Wait()
+	if cli.SessionPresent() {
+		t.Error("cli.SessionPresent() => true, want => false")
+	}
 }
 
-func Test_match(t *testing.T) {
-	testCases := []struct {
-		in struct {
-			topicName   string
-			topicFilter string
-		}
-		out bool
-	}{
-		{
-			in: struct {
-				topicName   string
-				topicFilter string
-			}{
-				topicName:   "sport/tennis/player1",
-				topicFilter: "sport/tennis/player1/#",
-			},
-			out: true,
-		},
-		{
-			in: struct {
-				topicName   string
-				topicFilter string
-			}{
-				topicName:   "sport/tennis/player1/ranking",
-				topicFilter: "sport/tennis/player1/#",
-			},
-			out: true,
-		},
-		{
-			in: struct {
-				topicName   string
-				topicFilter string
-			}{
-				topicName:   "sport/tennis/player1/score/wimbledon",
-				topicFilter: "sport/tennis/player1/#",
-			},
-			out: true,
-		},
-		{
-			in: struct {
-				topicName   string
-				topicFilter string
-			}{
-				topicName:   "sport/tennis/player2",
-				topicFilter: "sport/tennis/player1/#",
-			},
-			out: false,
-		},
-		{
-			in: struct {
-				topicName   string
-				topicFilter string
-			}{
-				topicName:   "",
-				topicFilter: "#",
-			},
-			out: true,
-		},
-		{
-			in: struct {
-				topicName   string
-				topicFilter string
-			}{
-				topicName:   "test",
-				topicFilter: "#",
-			},
-			out: true,
-		},
-		{
-			in: struct {
-				topicName   string
-				topicFilter string
-			}{
-				topicName:   "test/test",
-				topicFilter: "#",
-			},
-			out: true,
-		},
-		{
-			in: struct {
-				topicName   string
-				topicFilter string
-			}{
-				topicName:   "sport/tennis/player1",
-				topicFilter: "sport/tennis/+",
-			},
-			out: true,
-		},
-		{
-			in: struct {
-				topicName   string
-				topicFilter string
-			}{
-				topicName:   "sport/tennis/player2",
-				topicFilter: "sport/tennis/+",
-			},
-			out: true,
-		},
-		{
-			in: struct {
-				topicName   string
-				topicFilter string
-			}{
-				topicName:   "sport/tennis/player1/ranking",
-				topicFilter: "sport/tennis/+",
-			},
-			out: false,
-		},
-		{
-			in: struct {
-				topicName   string
-				topicFilter string
-			}{
-				topicName:   "sport/tennis/",
-				topicFilter: "sport/tennis/+",
-			},
-			out: true,
-		},
-		{
-			in: struct {
-				topicName   string
-				topicFilter string
-			}{
-				topicName:   "sport/tennis",
-				topicFilter: "sport/tennis/+",
-			},
-			out: false,
-		},
-		{
-			in: struct {
-				topicName   string
-				topicFilter string
-			}{
-				topicName:   "",
-				topicFilter: "+",
-			},
-			out: true,
-		},
-		{
-			in: struct {
-				topicName   string
-				topicFilter string
-			}{
-				topicName:   "test",
-				topicFilter: "+",
-			},
-			out: true,
-		},
-		{
-			in: struct {
-				topicName   string
-				topicFilter string
-			}{
-				topicName:   "test/test",
-				topicFilter: "+",
-			},
-			out: false,
-		},
-		{
-			in: struct {
-				topicName   string
-				topicFilter string
-			}{
-				topicName:   "/tennis",
-				topicFilter: "+/tennis/#",
-			},
-			out: true,
-		},
-		{
-			in: struct {
-				topicName   string
-				topicFilter string
-			}{
-				topicName:   "test/tennis",
-				topicFilter: "+/tennis/#",
-			},
-			out: true,
-		},
-		{
-			in: struct {
-				topicName   string
-				topicFilter string
-			}{
-				topicName:   "test/tennis/test",
-				topicFilter: "+/tennis/#",
-			},
-			out: true,
-		},
-		{
-			in: struct {
-				topicName   string
-				topicFilter string
-			}{
-				topicName:   "test/tennis/test/test",
-				topicFilter: "+/tennis/#",
-			},
-			out: true,
-		},
-		{
-			in: struct {
-				topicName   string
-				topicFilter string
-			}{
-				topicName:   "test/tennis2/",
-				topicFilter: "+/tennis/#",
-			},
-			out: false,
-		},
-		{
-			in: struct {
-				topicName   string
-				topicFilter string
-			}{
-				topicName:   "sport//player1",
-				topicFilter: "sport/+/player1",
-			},
-			out: true,
-		},
-		{
-			in: struct {
-				topicName   string
-				topicFilter string
-			}{
-				topicName:   "sport/test/player1",
-				topicFilter: "sport/+/player1",
-			},
-			out: true,
-		},
-		{
-			in: struct {
-				topicName   string
-				topicFilter string
-			}{
-				topicName:   "sport/player1",
-				topicFilter: "sport/+/player1",
-			},
-			out: false,
-		},
-		{
-			in: struct {
-				topicName   string
-				topicFilter string
-			}{
-				topicName:   "/finance",
-				topicFilter: "+/+",
-			},
-			out: true,
-		},
-		{
-			in: struct {
-				topicName   string
-				topicFilter string
-			}{
-				topicName:   "/finance",
-				topicFilter: "/+",
-			},
-			out: true,
-		},
-		{
-			in: struct {
-				topicName   string
-				topicFilter string
-			}{
-				topicName:   "/finance",
-				topicFilter: "+",
-			},
-			out: false,
-		},
-		{
-			in: struct {
-				topicName   string
-				topicFilter string
-			}{
-				topicName:   "$SYS",
-				topicFilter: "#",
-			},
-			out: false,
-		},
-		{
-			in: struct {
-				topicName   string
-				topicFilter string
-			}{
-				topicName:   "$SYS/monitor/Clients",
-				topicFilter: "+/monitor/Clients",
-			},
-			out: false,
-		},
-		{
-			in: struct {
-				topicName   string
-				topicFilter string
-			}{
-				topicName:   "$SYS",
-				topicFilter: "$SYS/#",
-			},
-			out: true,
-		},
-		{
-			in: struct {
-				topicName   string
-				topicFilter string
-			}{
-				topicName:   "$SYS/",
-				topicFilter: "$SYS/#",
-			},
-			out: true,
-		},
-		{
-			in: struct {
-				topicName   string
-				topicFilter string
-			}{
-				topicName:   "$SYS/test",
-				topicFilter: "$SYS/#",
-			},
-			out: true,
-		},
-		{
-			in: struct {
-				topicName   string
-				topicFilter string
-			}{
-				topicName:   "$SYS/test/test",
-				topicFilter: "$SYS/#",
-			},
-			out: true,
-		},
-		{
-			in: struct {
-				topicName   string
-				topicFilter string
-			}{
-				topicName:   "$SYS/monitor/",
-				topicFilter: "$SYS/monitor/+",
-			},
-			out: true,
-		},
-		{
-			in: struct {
-				topicName   string
-				topicFilter string
-			}{
-				topicName:   "$SYS/monitor/Clients",
-				topicFilter: "$SYS/monitor/+",
-			},
-			out: true,
-		},
-		{
-			in: struct {
-				topicName   string
-				topicFilter string
-			}{
-				topicName:   "$SYS/monitor/Clients/test",
-				topicFilter: "$SYS/monitor/+",
+func TestClient_handleMessage_PoolReceiveBuffers(t *testing.T) {
+	cli := New(&Options{
+		PoolReceiveBuffers: true,
+	})
+
+	cli.conn = &connection{}
+
+	msgc := make(chan *Message, 2)
+
+	cli.conn.ackedSubs = newTestTrie(map[string]*SubReq{
+		"test": &SubReq{
+			OnMessage: func(msg *Message) {
+				msgc <- msg
 			},
-			out: false,
 		},
-	}
+	})
 
-	for _, tc := range testCases {
-		if got := match(tc.in.topicName, tc.in.topicFilter); got != tc.out {
-			t.Errorf("got => %t, want => %t", got, tc.out)
+	cli.handleMessage(&packet.PUBLISH{QoS: mqtt.QoS1, TopicName: []byte("test"), PacketID: 1}, nil)
+	cli.handleMessage(&packet.PUBLISH{QoS: mqtt.QoS2, TopicName: []byte("test"), PacketID: 2}, nil)
+
+	for i := 0; i < 2; i++ {
+		select {
+		case msg := <-msgc:
+			// The buffer of the QoS 2 Application Message is not released.
+			if (msg.buf == nil) != (msg.QoS == mqtt.QoS2) {
+				t.Errorf("msg.buf => %v, QoS => %d", msg.buf, msg.QoS)
+			}
+		case <-time.After(time.Second):
+			t.Fatal("the handler was not executed")
 		}
 	}
-}
 
-func invalidError(t *testing.T, err, want error) {
-	if err == nil {
-		t.Errorf("err => nil, want => %q", want)
-	} else {
-		t.Errorf("err => %q, want => %q", err, want)
-	}
+	// The buffer is released immediately if there are no handlers.
+	cli.handleMessage(&packet.PUBLISH{QoS: mqtt.QoS0, TopicName: []byte("none")}, nil)
 }
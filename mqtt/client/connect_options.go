@@ -2,21 +2,59 @@ package client
 
 import (
 	"crypto/tls"
+	"net/http"
 	"time"
+
+	"github.com/yosssi/gmq/mqtt/packet"
 )
 
 // ConnectOptions represents options for the Connect method
 // of the Client.
 type ConnectOptions struct {
 	// Network is the network on which the Client connects to.
+	// "ws" or "wss" selects the WebSocket transport which
+	// transmits the MQTT Control Packets in the binary frames
+	// with the "mqtt" subprotocol.
 	Network string
 	// Address is the address which the Client connects to.
 	Address string
+	// Servers is the ordered list of the URIs of the servers such as
+	// "tcp://host:1883", "ssl://host:8883" and "wss://host/mqtt". They
+	// are used instead of the Network and the Address if it is not empty.
+	// The Client tries them in turn from the one which it connected to
+	// last until one of them accepts the connection by the CONNACK Packet
+	// and fails over to the next one when the Network Connection is lost.
+	// The URIs which are invalid are skipped. The Session and the
+	// subscriptions are kept if the servers share the state. The schemes
+	// "tcp" and "mqtt" connect without TLS, "ssl", "tls" and "mqtts"
+	// connect with the TLSConfig or the default configuration, and "ws"
+	// and "wss" select the WebSocket transport.
+	Servers []string
 	// TLSConfig is the configuration for the TLS connection.
 	TLSConfig *tls.Config
+	// Dialer is the dialer which establishes the connection to
+	// the address on the named network. net.Dialer is used if
+	// it is nil. The TLS handshake and the opening handshake of
+	// the WebSocket are performed over the established connection,
+	// so the TLSConfig must be nil and the server URIs must not
+	// select TLS if the Dialer performs the TLS handshake such as
+	// *tls.Dialer.
+	Dialer Dialer
+	// MaxPacketSize is the maximum size in bytes of the Packet
+	// sent from the Server. The Network Connection is disconnected
+	// if the Client receives a larger Packet. The size is not
+	// limited if it is zero.
+	MaxPacketSize uint32
+	// WebSocketPath is the path of the WebSocket endpoint.
+	// "/mqtt" is used if it is empty.
+	WebSocketPath string
+	// WebSocketHeader is the HTTP header which is added to
+	// the opening handshake request of the WebSocket.
+	WebSocketHeader http.Header
 	// CONNACKTimeout is timeout in seconds for the Client
 	// to wait for receiving the CONNACK Packet after sending
-	// the CONNECT Packet.
+	// the CONNECT Packet. It also limits the opening handshake
+	// of the WebSocket.
 	CONNACKTimeout time.Duration
 	// PINGRESPTimeout is timeout in seconds for the Client
 	// to wait for receiving the PINGRESP Packet after sending
@@ -40,4 +78,45 @@ type ConnectOptions struct {
 	WillQoS byte
 	// WillRetain is the Will Retain of the variable header.
 	WillRetain bool
+	// ProtocolVersion is the protocol version in which the Client
+	// communicates with the Server. mqtt.ProtocolVersion311 is
+	// used if it is zero. mqtt.ProtocolVersion31 is for the legacy
+	// Servers which accept only MQTT 3.1 ("MQIsdp").
+	ProtocolVersion byte
+	// Properties is the Properties of the CONNECT Packet.
+	// It is used only in MQTT 5.0.
+	Properties *packet.Properties
+	// WillProperties is the Will Properties of the CONNECT Packet.
+	// It is used only in MQTT 5.0.
+	WillProperties *packet.Properties
+	// AuthHandler is the handler which handles the AUTH Packets
+	// sent from the Server. The AUTH Packets are treated as
+	// a protocol violation if it is nil. It is used only in MQTT 5.0.
+	AuthHandler AuthHandler
+	// AutoReconnect is true if the Client reconnects to the Server
+	// automatically when the Network Connection is lost.
+	AutoReconnect bool
+	// ReconnectInterval is the initial interval in seconds
+	// between the reconnection attempts. The interval is doubled
+	// after each failed attempt.
+	ReconnectInterval time.Duration
+	// MaxReconnectInterval is the maximum interval in seconds
+	// between the reconnection attempts.
+	MaxReconnectInterval time.Duration
+	// MaxReconnectRetries is the maximum number of the reconnection
+	// attempts. The Client keeps on trying to reconnect if it is zero.
+	MaxReconnectRetries int
+	// RetryInterval is the interval in seconds after which the PUBLISH
+	// and PUBREL Packets which have not been acknowledged by the Server
+	// are resent while the Network Connection is alive. The PUBLISH
+	// Packets are resent with the DUP flag set to true. The Packets
+	// are resent only when reconnecting if it is zero. It must be zero
+	// in MQTT 5.0, which forbids resending the Packets while the Network
+	// Connection is alive.
+	RetryInterval time.Duration
+	// MaxRetries is the maximum number of the resends of each Packet.
+	// The flow is abandoned if the Server does not acknowledge the last
+	// resent Packet within the RetryInterval. The Packets are resent
+	// without limit if it is zero.
+	MaxRetries int
 }
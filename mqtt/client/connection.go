@@ -2,11 +2,14 @@ package client
 
 import (
 	"bufio"
+	"context"
 	"crypto/tls"
 	"net"
 	"sync"
+	"time"
 
 	"github.com/yosssi/gmq/mqtt/packet"
+	"github.com/yosssi/gmq/mqtt/topic"
 )
 
 // Buffer size of the send channel
@@ -15,25 +18,43 @@ const sendBufSize = 1024
 // connection represents a Network Connection.
 type connection struct {
 	net.Conn
-	// r is the buffered reader.
-	r *bufio.Reader
+	// r is the reader of the Packets.
+	r *packet.Reader
 	// w is the buffered writer.
 	w *bufio.Writer
 	// disconnected is true if the Network Connection
 	// has been disconnected by the Client.
 	disconnected bool
+	// server is the URI of the server which the Client connects to.
+	server string
+	// sessionPresent is the Session Present of the CONNACK Packet.
+	sessionPresent bool
+	// version is the protocol version of the Network Connection.
+	// Zero represents MQTT 3.1.1.
+	version byte
+	// connackProps is the Properties of the CONNACK Packet.
+	connackProps *packet.Properties
+	// authHandler is the handler which handles the AUTH Packets.
+	authHandler AuthHandler
+	// topicAliasMax is the Topic Alias Maximum which the Client
+	// sent to the Server.
+	topicAliasMax uint16
+	// topicAliases contains the Topic Names by the Topic Aliases
+	// which the Server sent to the Client.
+	topicAliases map[uint16][]byte
 
 	// wg is the Wait Group for the goroutines
 	// which are launched by the Connect method.
 	wg sync.WaitGroup
-	// connack is the channel which handles the signal
-	// to notify the arrival of the CONNACK Packet.
-	connack chan struct{}
 	// send is the channel which handles the Packet.
 	send chan packet.Packet
 	// sendEnd is the channel which ends the goroutine
 	// which sends a Packet to the Server.
 	sendEnd chan struct{}
+	// retryEnd is the channel which is closed when the goroutine
+	// which sends a Packet to the Server ends. It ends the goroutine
+	// which resends the unacknowledged Packets.
+	retryEnd chan struct{}
 
 	// muPINGRESPs is the Mutex for pingresps.
 	muPINGRESPs sync.RWMutex
@@ -41,27 +62,103 @@ type connection struct {
 	// handle the signal to notify the arrival of
 	// the PINGRESP Packet.
 	pingresps []chan struct{}
+	// pingreqTimes is the slice of the times when the PINGREQ
+	// Packets which correspond to pingresps were sent.
+	pingreqTimes []time.Time
 
 	// unackSubs contains the subscription information
 	// which are not acknowledged by the Server.
-	unackSubs map[string]MessageHandler
+	unackSubs map[string]*SubReq
 	// ackedSubs contains the subscription information
-	// which are acknowledged by the Server.
-	ackedSubs map[string]MessageHandler
+	// which are acknowledged by the Server by their Topic Filters.
+	ackedSubs *topic.Trie
 }
 
-// newConnection connects to the address on the named network,
-// creates a Network Connection and returns it.
-func newConnection(network, address string, tlsConfig *tls.Config) (*connection, error) {
+// watchContext interrupts the blocking reads and writes of
+// the Network Connection when the context is done. The returned
+// function stops watching the context and clears the deadline
+// set by the interruption.
+func (c *connection) watchContext(ctx context.Context) func() {
+	// Return a no-op function if the context is never done.
+	if ctx.Done() == nil {
+		return func() {}
+	}
+
+	// Create a channel which stops watching the context.
+	stopc := make(chan struct{})
+
+	// Create a channel which is closed when watching ends.
+	endc := make(chan struct{})
+
+	// Define a flag which is true if the Network Connection is interrupted.
+	var interrupted bool
+
+	go func() {
+		defer close(endc)
+
+		select {
+		case <-ctx.Done():
+			// Set the past deadline to interrupt the reads and writes.
+			c.SetDeadline(time.Now())
+
+			interrupted = true
+		case <-stopc:
+		}
+	}()
+
+	return func() {
+		// Stop watching and wait until the goroutine ends.
+		close(stopc)
+		<-endc
+
+		// Clear the deadline.
+		if interrupted {
+			c.SetDeadline(time.Time{})
+		}
+	}
+}
+
+// writePacket writes the Packet to the Network Connection. The PUBLISH
+// Packet whose Application Message does not fit in the buffered writer
+// is written to the underlying connection directly by the vectored I/O
+// after the buffered data is flushed so as not to copy the message.
+// The WebSocket connection always uses the buffered writer because it
+// sends each of the written buffers in a separate frame.
+func (c *connection) writePacket(p packet.Packet) (int64, error) {
+	// Write the large PUBLISH Packet directly.
+	if publish, ok := p.(*packet.PUBLISH); ok && len(publish.Message) >= c.w.Size() {
+		if _, ok := c.Conn.(*wsConn); !ok {
+			if err := c.w.Flush(); err != nil {
+				return 0, err
+			}
+
+			return p.WriteTo(c.Conn)
+		}
+	}
+
+	// Write the Packet to the buffered writer.
+	n, err := p.WriteTo(c.w)
+	if err != nil {
+		return n, err
+	}
+
+	// Flush the buffered writer.
+	return n, c.w.Flush()
+}
+
+// newConnection connects to the address on the named network
+// of the options, creates a Network Connection and returns it.
+// The context is used for canceling the dialing.
+func newConnection(ctx context.Context, opts *ConnectOptions) (*connection, error) {
 	// Define the local variables.
 	var conn net.Conn
 	var err error
 
 	// Connect to the address on the named network.
-	if tlsConfig != nil {
-		conn, err = tls.Dial(network, address, tlsConfig)
+	if opts.Network == networkWS || opts.Network == networkWSS {
+		conn, err = dialWebSocket(ctx, opts)
 	} else {
-		conn, err = net.Dial(network, address)
+		conn, err = dial(ctx, opts.Dialer, opts.Network, opts.Address, opts.TLSConfig)
 	}
 	if err != nil {
 		return nil, err
@@ -69,16 +166,119 @@ func newConnection(network, address string, tlsConfig *tls.Config) (*connection,
 
 	// Create a Network Connection.
 	c := &connection{
-		Conn:      conn,
-		r:         bufio.NewReader(conn),
-		w:         bufio.NewWriter(conn),
-		connack:   make(chan struct{}, 1),
-		send:      make(chan packet.Packet, sendBufSize),
-		sendEnd:   make(chan struct{}, 1),
-		unackSubs: make(map[string]MessageHandler),
-		ackedSubs: make(map[string]MessageHandler),
+		Conn:         conn,
+		r:            packet.NewReader(conn),
+		w:            bufio.NewWriter(conn),
+		version:      opts.ProtocolVersion,
+		authHandler:  opts.AuthHandler,
+		send:         make(chan packet.Packet, sendBufSize),
+		sendEnd:      make(chan struct{}, 1),
+		retryEnd:     make(chan struct{}),
+		unackSubs:    make(map[string]*SubReq),
+		ackedSubs:    topic.NewTrie(),
+		topicAliases: make(map[uint16][]byte),
+	}
+
+	// Set the Topic Alias Maximum.
+	if opts.Properties != nil {
+		c.topicAliasMax = opts.Properties.TopicAliasMaximum
 	}
 
+	// Set the maximum size and the protocol version of the received Packets.
+	c.r.MaxPacketSize = opts.MaxPacketSize
+	c.r.ProtocolVersion = opts.ProtocolVersion
+
 	// Return the Network Connection.
 	return c, nil
 }
+
+// dial connects to the address on the named network by the dialer
+// and returns the connection. The default net.Dialer is used if
+// the dialer is nil. The TLS handshake is performed over
+// the connection if tlsConfig is not nil.
+func dial(ctx context.Context, d Dialer, network, address string, tlsConfig *tls.Config) (net.Conn, error) {
+	// Use the default dialer if the dialer is not specified.
+	if d == nil {
+		d = &net.Dialer{}
+	}
+
+	// Connect to the address on the named network.
+	conn, err := d.DialContext(ctx, network, address)
+	if err != nil {
+		return nil, err
+	}
+
+	// Return the connection if TLS is not used.
+	if tlsConfig == nil {
+		return conn, nil
+	}
+
+	// Set the server name to the host of the address
+	// if it is not specified.
+	if tlsConfig.ServerName == "" {
+		host, _, err := net.SplitHostPort(address)
+		if err != nil {
+			host = address
+		}
+
+		tlsConfig = tlsConfig.Clone()
+		tlsConfig.ServerName = host
+	}
+
+	// Perform the TLS handshake.
+	tlsConn := tls.Client(conn, tlsConfig)
+
+	if err := tlsConn.HandshakeContext(ctx); err != nil {
+		conn.Close()
+		return nil, err
+	}
+
+	return tlsConn, nil
+}
+
+// resolveTopicAlias resolves the Topic Alias of the MQTT 5.0 PUBLISH
+// Packet sent from the Server. The Topic Name which the Topic Alias
+// refers to is set to the Packet if its Topic Name is zero-byte and
+// the Topic Alias is mapped to its Topic Name otherwise.
+func (c *connection) resolveTopicAlias(p *packet.PUBLISH) error {
+	// End the process if the Packet has no Topic Alias.
+	if p.Properties == nil || p.Properties.TopicAlias == 0 {
+		return nil
+	}
+
+	alias := p.Properties.TopicAlias
+
+	// Check the Topic Alias Maximum.
+	if alias > c.topicAliasMax {
+		return ErrInvalidTopicAlias
+	}
+
+	// Map the Topic Alias to the Topic Name. The Topic Name is
+	// copied because the buffer of the Packet may be recycled.
+	if len(p.TopicName) > 0 {
+		c.topicAliases[alias] = append([]byte(nil), p.TopicName...)
+
+		return nil
+	}
+
+	// Set the Topic Name which the Topic Alias refers to.
+	topicName, ok := c.topicAliases[alias]
+	if !ok {
+		return ErrInvalidTopicAlias
+	}
+
+	p.TopicName = topicName
+
+	return nil
+}
+
+// protocolVersion returns the protocol version of the Network
+// Connection. It returns zero, which represents MQTT 3.1.1,
+// if the Network Connection is nil.
+func (c *connection) protocolVersion() byte {
+	if c == nil {
+		return 0
+	}
+
+	return c.version
+}
@@ -0,0 +1,19 @@
+package client
+
+// ConnectHandler is the handler which is called when the Server
+// has accepted the connection. sessionPresent is the Session Present
+// of the CONNACK Packet.
+type ConnectHandler func(sessionPresent bool)
+
+// ConnectionLostHandler is the handler which is called when
+// the Network Connection has been lost and has been cleaned.
+// err is the cause of the loss.
+type ConnectionLostHandler func(err error)
+
+// ReconnectingHandler is the handler which is called before each
+// reconnection attempt. attempt starts from one.
+type ReconnectingHandler func(attempt int)
+
+// DisconnectHandler is the handler which is called when the Client
+// has been disconnected from the Server by the Disconnect method.
+type DisconnectHandler func()
@@ -1,21 +1,148 @@
 package client
 
 import (
+	"bufio"
+	"bytes"
+	"context"
 	"crypto/tls"
+	"crypto/x509"
+	"io"
+	"net"
+	"net/http"
+	"sync"
 	"testing"
+	"time"
+
+	"github.com/yosssi/gmq/mqtt/packet"
 )
 
 const testAddress = "iot.eclipse.org:1883"
 
 func Test_newConnection_tlsErr(t *testing.T) {
-	if _, err := newConnection("", "", &tls.Config{}); err == nil {
+	if _, err := newConnection(context.Background(), &ConnectOptions{TLSConfig: &tls.Config{}}); err == nil {
 		notNilErrorExpected(t)
 	}
 }
 
 func Test_newConnection(t *testing.T) {
-	if _, err := newConnection("tcp", testAddress, nil); err != nil {
+	if _, err := newConnection(context.Background(), &ConnectOptions{Network: "tcp", Address: testAddress}); err != nil {
+		nilErrorExpected(t, err)
+	}
+}
+
+func Test_newConnection_Dialer(t *testing.T) {
+	cliConn, srvConn := net.Pipe()
+
+	defer srvConn.Close()
+
+	var gotAddress string
+
+	c, err := newConnection(context.Background(), &ConnectOptions{
+		Network: "tcp",
+		Address: "localhost:1883",
+		Dialer: DialFunc(func(_ context.Context, _, address string) (net.Conn, error) {
+			gotAddress = address
+			return cliConn, nil
+		}),
+	})
+	if err != nil {
+		nilErrorExpected(t, err)
+		return
+	}
+
+	defer c.Close()
+
+	if c.Conn != cliConn {
+		t.Error("the connection returned by the Dialer was not used")
+	}
+
+	if gotAddress != "localhost:1883" {
+		t.Errorf("address => %q, want => %q", gotAddress, "localhost:1883")
+	}
+}
+
+func Test_newConnection_MaxPacketSize(t *testing.T) {
+	cliConn, srvConn := net.Pipe()
+
+	defer srvConn.Close()
+
+	c, err := newConnection(context.Background(), &ConnectOptions{
+		Network: "tcp",
+		Address: "localhost:1883",
+		Dialer: DialFunc(func(_ context.Context, _, _ string) (net.Conn, error) {
+			return cliConn, nil
+		}),
+		MaxPacketSize: 3,
+	})
+	if err != nil {
 		nilErrorExpected(t, err)
+		return
+	}
+
+	defer c.Close()
+
+	go srvConn.Write([]byte{packet.TypePUBACK << 4, 0x02, 0x00, 0x01})
+
+	if _, err := c.r.ReadPacket(); err != packet.ErrPacketTooLarge {
+		invalidError(t, err, packet.ErrPacketTooLarge)
+	}
+}
+
+func Test_dial_dialErr(t *testing.T) {
+	d := DialFunc(func(_ context.Context, _, _ string) (net.Conn, error) {
+		return nil, errTest
+	})
+
+	if _, err := dial(context.Background(), d, "tcp", "localhost:1883", nil); err != errTest {
+		invalidError(t, err, errTest)
+	}
+}
+
+func Test_dial_tls(t *testing.T) {
+	srv := newTestTLSServer(http.NotFoundHandler())
+
+	defer srv.Close()
+
+	pool := x509.NewCertPool()
+	pool.AddCert(srv.Certificate())
+
+	var dialed bool
+
+	d := DialFunc(func(ctx context.Context, network, address string) (net.Conn, error) {
+		dialed = true
+
+		var d net.Dialer
+		return d.DialContext(ctx, network, address)
+	})
+
+	conn, err := dial(context.Background(), d, "tcp", srv.Listener.Addr().String(), &tls.Config{RootCAs: pool})
+	if err != nil {
+		nilErrorExpected(t, err)
+		return
+	}
+
+	defer conn.Close()
+
+	if !dialed {
+		t.Error("the Dialer was not used")
+	}
+
+	if _, ok := conn.(*tls.Conn); !ok {
+		t.Errorf("conn => %T, want => *tls.Conn", conn)
+	}
+}
+
+func Test_dial_tlsHandshakeErr(t *testing.T) {
+	cliConn, srvConn := net.Pipe()
+
+	srvConn.Close()
+
+	d := DialFunc(func(_ context.Context, _, _ string) (net.Conn, error) {
+		return cliConn, nil
+	})
+
+	if _, err := dial(context.Background(), d, "tcp", "localhost", &tls.Config{}); err == nil {
+		notNilErrorExpected(t)
 	}
 }
 
@@ -26,3 +153,177 @@ func notNilErrorExpected(t *testing.T) {
 func nilErrorExpected(t *testing.T, err error) {
 	t.Errorf("err => %q, want => nil", err)
 }
+
+func Test_connection_resolveTopicAlias(t *testing.T) {
+	c := &connection{
+		topicAliasMax: 2,
+		topicAliases:  make(map[uint16][]byte),
+	}
+
+	testCases := []struct {
+		p         *packet.PUBLISH
+		want      error
+		topicName string
+	}{
+		{&packet.PUBLISH{TopicName: []byte("a")}, nil, "a"},
+		{&packet.PUBLISH{TopicName: []byte("b"), Properties: &packet.Properties{TopicAlias: 3}}, ErrInvalidTopicAlias, "b"},
+		{&packet.PUBLISH{Properties: &packet.Properties{TopicAlias: 1}}, ErrInvalidTopicAlias, ""},
+		{&packet.PUBLISH{TopicName: []byte("c"), Properties: &packet.Properties{TopicAlias: 1}}, nil, "c"},
+		{&packet.PUBLISH{Properties: &packet.Properties{TopicAlias: 1}}, nil, "c"},
+	}
+
+	for _, tc := range testCases {
+		if err := c.resolveTopicAlias(tc.p); err != tc.want {
+			t.Errorf("c.resolveTopicAlias(%+v) => %v, want => %v", tc.p, err, tc.want)
+		}
+
+		if string(tc.p.TopicName) != tc.topicName {
+			t.Errorf("p.TopicName => %q, want => %q", tc.p.TopicName, tc.topicName)
+		}
+	}
+}
+
+// testWriteConn is a connection which records the written data.
+type testWriteConn struct {
+	net.Conn
+	mu     sync.Mutex
+	writes [][]byte
+}
+
+// Write records the data and writes it to the connection.
+func (c *testWriteConn) Write(b []byte) (int, error) {
+	c.mu.Lock()
+	c.writes = append(c.writes, append([]byte(nil), b...))
+	c.mu.Unlock()
+
+	return c.Conn.Write(b)
+}
+
+// written reports whether the data was written by a single call.
+func (c *testWriteConn) written(b []byte) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	for _, w := range c.writes {
+		if bytes.Equal(w, b) {
+			return true
+		}
+	}
+
+	return false
+}
+
+func TestClient_Publish_largeMessage(t *testing.T) {
+	cliConn, srvConn := net.Pipe()
+
+	conn := &testWriteConn{Conn: cliConn}
+
+	message := bytes.Repeat([]byte("a"), 0x8000)
+
+	receivedc := make(chan []byte, 1)
+
+	go func() {
+		defer srvConn.Close()
+
+		// Read the CONNECT Packet.
+		if _, _, err := readTestPacket(srvConn); err != nil {
+			return
+		}
+
+		srvConn.Write(testCONNACK)
+
+		// Read the PUBLISH Packet.
+		_, remaining, err := readTestPacket(srvConn)
+		if err != nil {
+			return
+		}
+
+		receivedc <- remaining
+
+		readTestPacket(srvConn)
+	}()
+
+	cli := New(&Options{
+		ErrorHandler: func(_ error) {},
+	})
+
+	defer cli.Terminate()
+
+	err := cli.Connect(&ConnectOptions{
+		Network: "pipe",
+		Address: "pipe",
+		Dialer: DialFunc(func(_ context.Context, _, _ string) (net.Conn, error) {
+			return conn, nil
+		}),
+		ClientID: []byte("clientID"),
+	})
+	if err != nil {
+		nilErrorExpected(t, err)
+		return
+	}
+
+	err = cli.Publish(&PublishOptions{
+		TopicName: []byte("a/b"),
+		Message:   message,
+	})
+	if err != nil {
+		nilErrorExpected(t, err)
+		return
+	}
+
+	select {
+	case remaining := <-receivedc:
+		if !bytes.Equal(remaining[5:], message) {
+			t.Error("the Application Message was not received")
+		}
+	case <-time.After(5 * time.Second):
+		t.Error("the PUBLISH Packet was not received")
+		return
+	}
+
+	if !conn.written(message) {
+		t.Error("the Application Message was copied to the buffered writer")
+	}
+
+	if err := cli.Disconnect(); err != nil {
+		nilErrorExpected(t, err)
+	}
+}
+
+func Test_connection_writePacket_webSocket(t *testing.T) {
+	ws, srvConn := newTestWSConn()
+
+	defer ws.Close()
+	defer srvConn.Close()
+
+	c := &connection{
+		Conn: ws,
+		w:    bufio.NewWriter(ws),
+	}
+
+	p, err := packet.NewPUBLISH(&packet.PUBLISHOptions{
+		TopicName: []byte("a/b"),
+		Message:   bytes.Repeat([]byte("a"), c.w.Size()),
+	})
+	if err != nil {
+		nilErrorExpected(t, err)
+		return
+	}
+
+	go c.writePacket(p)
+
+	// Read the header of the first frame.
+	b := make([]byte, 4)
+
+	if _, err := io.ReadFull(srvConn, b); err != nil {
+		nilErrorExpected(t, err)
+		return
+	}
+
+	// The fixed header must not be sent in a separate frame.
+	if b[1]&0x7F != 126 || int(b[2])<<8|int(b[3]) != c.w.Size() {
+		t.Errorf("frame header => %v, want the length %d", b, c.w.Size())
+	}
+
+	go io.Copy(io.Discard, srvConn)
+}
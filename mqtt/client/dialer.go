@@ -0,0 +1,26 @@
+package client
+
+import (
+	"context"
+	"net"
+)
+
+// Dialer is the interface which establishes a connection to
+// the address on the named network. *net.Dialer, *tls.Dialer
+// and the dialers of the proxy packages satisfy this interface.
+// The Client performs the TLS handshake over the connection
+// established by the Dialer if the TLSConfig of the ConnectOptions
+// is set, so it must be nil and the server URIs must not select TLS
+// when the Dialer performs the TLS handshake by itself.
+type Dialer interface {
+	DialContext(ctx context.Context, network, address string) (net.Conn, error)
+}
+
+// DialFunc is an adapter to allow the use of an ordinary function
+// as a Dialer.
+type DialFunc func(ctx context.Context, network, address string) (net.Conn, error)
+
+// DialContext calls f(ctx, network, address).
+func (f DialFunc) DialContext(ctx context.Context, network, address string) (net.Conn, error) {
+	return f(ctx, network, address)
+}
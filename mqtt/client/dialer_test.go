@@ -0,0 +1,76 @@
+package client
+
+import (
+	"context"
+	"net"
+	"testing"
+)
+
+func TestDialFunc_DialContext(t *testing.T) {
+	var gotNetwork, gotAddress string
+
+	cliConn, srvConn := net.Pipe()
+
+	defer srvConn.Close()
+
+	d := DialFunc(func(_ context.Context, network, address string) (net.Conn, error) {
+		gotNetwork, gotAddress = network, address
+		return cliConn, nil
+	})
+
+	conn, err := d.DialContext(context.Background(), "tcp", "localhost:1883")
+	if err != nil {
+		nilErrorExpected(t, err)
+		return
+	}
+
+	defer conn.Close()
+
+	if conn != cliConn {
+		t.Error("the connection returned by the function was not returned")
+	}
+
+	if gotNetwork != "tcp" || gotAddress != "localhost:1883" {
+		t.Errorf("network, address => %q, %q, want => %q, %q", gotNetwork, gotAddress, "tcp", "localhost:1883")
+	}
+}
+
+func TestClient_Connect_Dialer(t *testing.T) {
+	cliConn, srvConn := net.Pipe()
+
+	go func() {
+		defer srvConn.Close()
+
+		// Read the CONNECT Packet.
+		if _, _, err := readTestPacket(srvConn); err != nil {
+			return
+		}
+
+		srvConn.Write(testCONNACK)
+
+		readTestPacket(srvConn)
+	}()
+
+	cli := New(&Options{
+		ErrorHandler: func(_ error) {},
+	})
+
+	defer cli.Terminate()
+
+	err := cli.Connect(&ConnectOptions{
+		Network: "pipe",
+		Address: "pipe",
+		Dialer: DialFunc(func(_ context.Context, _, _ string) (net.Conn, error) {
+			return cliConn, nil
+		}),
+		ClientID: []byte("clientID"),
+	})
+	if err != nil {
+		nilErrorExpected(t, err)
+		return
+	}
+
+	if err := cli.Disconnect(); err != nil {
+		nilErrorExpected(t, err)
+	}
+}
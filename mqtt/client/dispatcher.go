@@ -0,0 +1,170 @@
+package client
+
+import (
+	"runtime"
+	"sync"
+)
+
+// DispatchMode represents the mode of dispatching the Application
+// Messages sent from the Server to the handlers.
+type DispatchMode int
+
+// Dispatch modes
+const (
+	// DispatchConcurrent executes each handler in a new goroutine.
+	// The handlers can be executed out of order.
+	DispatchConcurrent DispatchMode = iota
+	// DispatchOrderedBySubscription executes the handlers of each
+	// subscription one by one in the order of the arrival of
+	// the Application Messages.
+	DispatchOrderedBySubscription
+	// DispatchOrderedByTopic executes the handlers of the Application
+	// Messages which have the same Topic Name one by one in the order
+	// of their arrival.
+	DispatchOrderedByTopic
+	// DispatchWorkerPool executes the handlers in a bounded number of
+	// goroutines. The handlers can be executed out of order.
+	DispatchWorkerPool
+)
+
+// dispatchQueue represents a queue of the handler executions.
+type dispatchQueue struct {
+	// fs is the queued handler executions.
+	fs []func()
+	// running is the number of the running goroutines
+	// which execute the queued handlers.
+	running int
+	// workers is the maximum number of the goroutines.
+	workers int
+}
+
+// dispatcher dispatches the Application Messages to the handlers.
+type dispatcher struct {
+	// mode is the dispatch mode.
+	mode DispatchMode
+	// workers is the number of the goroutines
+	// of the DispatchWorkerPool mode.
+	workers int
+	// slots is the semaphore which limits the number of
+	// the pending handler executions. It is nil if there
+	// is no limit.
+	slots chan struct{}
+
+	// mu is the Mutex for queues.
+	mu sync.Mutex
+	// queues is the queues of the handler executions
+	// by their keys.
+	queues map[string]*dispatchQueue
+}
+
+// dispatch dispatches the handler execution. The key is used for
+// ordering the executions in the ordered modes. It blocks while
+// the number of the pending handler executions reaches the limit.
+func (d *dispatcher) dispatch(key string, f func()) {
+	// Acquire a slot.
+	if d.slots != nil {
+		d.slots <- struct{}{}
+	}
+
+	switch d.mode {
+	case DispatchOrderedBySubscription, DispatchOrderedByTopic:
+		d.enqueue(key, 1, f)
+	case DispatchWorkerPool:
+		d.enqueue("", d.workers, f)
+	default:
+		go func() {
+			f()
+			d.release()
+		}()
+	}
+}
+
+// enqueue appends the handler execution to the queue of the key
+// and launches a goroutine which executes the queued handlers
+// if the number of the running goroutines is less than workers.
+func (d *dispatcher) enqueue(key string, workers int, f func()) {
+	// Lock for update.
+	d.mu.Lock()
+
+	// Unlock.
+	defer d.mu.Unlock()
+
+	// Get or create the queue.
+	q, exist := d.queues[key]
+	if !exist {
+		q = &dispatchQueue{workers: workers}
+		d.queues[key] = q
+	}
+
+	q.fs = append(q.fs, f)
+
+	// Launch a goroutine if possible.
+	if q.running < q.workers {
+		q.running++
+		go d.work(key, q)
+	}
+}
+
+// work executes the queued handlers until the queue becomes empty.
+func (d *dispatcher) work(key string, q *dispatchQueue) {
+	for {
+		// Lock for update.
+		d.mu.Lock()
+
+		// End the goroutine if the queue is empty.
+		if len(q.fs) == 0 {
+			q.running--
+
+			// Delete the queue if it is not used.
+			if q.running == 0 {
+				delete(d.queues, key)
+			}
+
+			d.mu.Unlock()
+
+			return
+		}
+
+		// Dequeue the handler execution.
+		f := q.fs[0]
+		q.fs[0] = nil
+		q.fs = q.fs[1:]
+
+		// Unlock.
+		d.mu.Unlock()
+
+		// Execute the handler.
+		f()
+
+		// Release the slot.
+		d.release()
+	}
+}
+
+// release releases the slot of the pending handler execution.
+func (d *dispatcher) release() {
+	if d.slots != nil {
+		<-d.slots
+	}
+}
+
+// newDispatcher creates and returns a dispatcher.
+func newDispatcher(mode DispatchMode, workers int, maxPending int) *dispatcher {
+	// Set the default number of the workers.
+	if workers <= 0 {
+		workers = runtime.NumCPU()
+	}
+
+	d := &dispatcher{
+		mode:    mode,
+		workers: workers,
+		queues:  make(map[string]*dispatchQueue),
+	}
+
+	// Create the semaphore if the limit is specified.
+	if maxPending > 0 {
+		d.slots = make(chan struct{}, maxPending)
+	}
+
+	return d
+}
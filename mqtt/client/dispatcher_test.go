@@ -0,0 +1,196 @@
+package client
+
+import (
+	"runtime"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/yosssi/gmq/mqtt/packet"
+)
+
+func TestDispatcher_dispatch_ordered(t *testing.T) {
+	for _, mode := range []DispatchMode{DispatchOrderedBySubscription, DispatchOrderedByTopic} {
+		d := newDispatcher(mode, 0, 0)
+
+		const n = 100
+
+		var mu sync.Mutex
+
+		var got []int
+
+		var wg sync.WaitGroup
+
+		wg.Add(n)
+
+		for i := 0; i < n; i++ {
+			i := i
+
+			d.dispatch("a", func() {
+				mu.Lock()
+				got = append(got, i)
+				mu.Unlock()
+
+				wg.Done()
+			})
+		}
+
+		wg.Wait()
+
+		for i, v := range got {
+			if v != i {
+				t.Errorf("mode %d: got[%d] => %d, want => %d", mode, i, v, i)
+				break
+			}
+		}
+	}
+}
+
+func TestDispatcher_dispatch_orderedQueueDeleted(t *testing.T) {
+	d := newDispatcher(DispatchOrderedBySubscription, 0, 0)
+
+	donec := make(chan struct{})
+
+	d.dispatch("a", func() { close(donec) })
+
+	<-donec
+
+	for i := 0; i < 100; i++ {
+		d.mu.Lock()
+		n := len(d.queues)
+		d.mu.Unlock()
+
+		if n == 0 {
+			return
+		}
+
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	t.Error("the queue was not deleted")
+}
+
+func TestDispatcher_dispatch_DispatchWorkerPool(t *testing.T) {
+	const workers = 2
+
+	d := newDispatcher(DispatchWorkerPool, workers, 0)
+
+	var mu sync.Mutex
+
+	var running, maxRunning int
+
+	var wg sync.WaitGroup
+
+	const n = 20
+
+	wg.Add(n)
+
+	for i := 0; i < n; i++ {
+		d.dispatch(strconv.Itoa(i), func() {
+			mu.Lock()
+			running++
+			if running > maxRunning {
+				maxRunning = running
+			}
+			mu.Unlock()
+
+			time.Sleep(time.Millisecond)
+
+			mu.Lock()
+			running--
+			mu.Unlock()
+
+			wg.Done()
+		})
+	}
+
+	wg.Wait()
+
+	if maxRunning > workers {
+		t.Errorf("maxRunning => %d, want => <= %d", maxRunning, workers)
+	}
+}
+
+func TestDispatcher_dispatch_MaxPendingMessages(t *testing.T) {
+	for _, mode := range []DispatchMode{DispatchConcurrent, DispatchOrderedBySubscription, DispatchWorkerPool} {
+		d := newDispatcher(mode, 0, 1)
+
+		blockc := make(chan struct{})
+
+		d.dispatch("a", func() { <-blockc })
+
+		dispatchedc := make(chan struct{})
+
+		go func() {
+			d.dispatch("b", func() {})
+			close(dispatchedc)
+		}()
+
+		select {
+		case <-dispatchedc:
+			t.Errorf("mode %d: the dispatch was not blocked", mode)
+		case <-time.After(50 * time.Millisecond):
+		}
+
+		close(blockc)
+
+		select {
+		case <-dispatchedc:
+		case <-time.After(5 * time.Second):
+			t.Errorf("mode %d: the dispatch was not unblocked", mode)
+		}
+	}
+}
+
+func Test_newDispatcher(t *testing.T) {
+	d := newDispatcher(DispatchWorkerPool, 0, 0)
+
+	if d.workers != runtime.NumCPU() {
+		t.Errorf("d.workers => %d, want => %d", d.workers, runtime.NumCPU())
+	}
+
+	if d.slots != nil {
+		t.Error("d.slots => not nil, want => nil")
+	}
+}
+
+func TestClient_handleMessage_DispatchOrderedByTopic(t *testing.T) {
+	cli := New(&Options{
+		DispatchMode: DispatchOrderedByTopic,
+	})
+
+	const n = 100
+
+	gotc := make(chan string, n)
+
+	cli.conn = &connection{}
+
+	cli.conn.ackedSubs = newTestTrie(map[string]*SubReq{
+		"a/#": &SubReq{
+			Handler: func(_, message []byte) {
+				gotc <- string(message)
+			},
+		},
+	})
+
+	for i := 0; i < n; i++ {
+		cli.handleMessage(&packet.PUBLISH{
+			TopicName: []byte("a/b"),
+			Message:   []byte(strconv.Itoa(i)),
+		}, nil)
+	}
+
+	for i := 0; i < n; i++ {
+		select {
+		case got := <-gotc:
+			if want := strconv.Itoa(i); got != want {
+				t.Errorf("got => %q, want => %q", got, want)
+				return
+			}
+		case <-time.After(5 * time.Second):
+			t.Error("the handler was not executed")
+			return
+		}
+	}
+}
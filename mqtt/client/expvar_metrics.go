@@ -0,0 +1,113 @@
+package client
+
+import (
+	"expvar"
+	"strconv"
+	"time"
+
+	"github.com/yosssi/gmq/mqtt/packet"
+)
+
+// ExpvarMetrics is a Metrics which exposes the metrics of the Client
+// as an expvar.Map. The map has the following variables:
+//
+//	packetsSent, bytesSent          the numbers by the MQTT Control Packet types
+//	packetsReceived, bytesReceived  the numbers by the MQTT Control Packet types
+//	sendQueue                       the number of the Packets queued for sending
+//	outgoing, incoming              the numbers of the inflight Packets
+//	publishes                       the numbers of the completed flows by the QoS
+//	publishLatencySeconds           the total latencies of the flows by the QoS
+//	pingresps                       the number of the received PINGRESP Packets
+//	pingLatencySeconds              the total latency of the PINGRESP Packets
+type ExpvarMetrics struct {
+	packetsSent           *expvar.Map
+	bytesSent             *expvar.Map
+	packetsReceived       *expvar.Map
+	bytesReceived         *expvar.Map
+	sendQueue             *expvar.Int
+	outgoing              *expvar.Int
+	incoming              *expvar.Int
+	publishes             *expvar.Map
+	publishLatencySeconds *expvar.Map
+	pingresps             *expvar.Int
+	pingLatencySeconds    *expvar.Float
+}
+
+// PacketSent increments the numbers of the sent Packets and bytes.
+func (m *ExpvarMetrics) PacketSent(ptype byte, size int) {
+	name := packet.TypeName(ptype)
+
+	m.packetsSent.Add(name, 1)
+	m.bytesSent.Add(name, int64(size))
+}
+
+// PacketReceived increments the numbers of the received Packets and bytes.
+func (m *ExpvarMetrics) PacketReceived(ptype byte, size int) {
+	name := packet.TypeName(ptype)
+
+	m.packetsReceived.Add(name, 1)
+	m.bytesReceived.Add(name, int64(size))
+}
+
+// SendQueue sets the number of the Packets queued for sending.
+func (m *ExpvarMetrics) SendQueue(n int) {
+	m.sendQueue.Set(int64(n))
+}
+
+// Inflight sets the numbers of the inflight Packets.
+func (m *ExpvarMetrics) Inflight(outgoing, incoming int) {
+	m.outgoing.Set(int64(outgoing))
+	m.incoming.Set(int64(incoming))
+}
+
+// PublishCompleted increments the number and the total latency
+// of the completed flows of the QoS.
+func (m *ExpvarMetrics) PublishCompleted(qos byte, latency time.Duration) {
+	key := strconv.Itoa(int(qos))
+
+	m.publishes.Add(key, 1)
+	m.publishLatencySeconds.AddFloat(key, latency.Seconds())
+}
+
+// PINGRESPReceived increments the number and the total
+// latency of the PINGRESP Packets.
+func (m *ExpvarMetrics) PINGRESPReceived(latency time.Duration) {
+	m.pingresps.Add(1)
+	m.pingLatencySeconds.Add(latency.Seconds())
+}
+
+// NewExpvarMetrics creates an ExpvarMetrics, publishes its map
+// as the name and returns it. It panics if the name is already
+// registered as expvar.Publish does.
+func NewExpvarMetrics(name string) *ExpvarMetrics {
+	m := &ExpvarMetrics{
+		packetsSent:           new(expvar.Map).Init(),
+		bytesSent:             new(expvar.Map).Init(),
+		packetsReceived:       new(expvar.Map).Init(),
+		bytesReceived:         new(expvar.Map).Init(),
+		sendQueue:             new(expvar.Int),
+		outgoing:              new(expvar.Int),
+		incoming:              new(expvar.Int),
+		publishes:             new(expvar.Map).Init(),
+		publishLatencySeconds: new(expvar.Map).Init(),
+		pingresps:             new(expvar.Int),
+		pingLatencySeconds:    new(expvar.Float),
+	}
+
+	// Publish the variables.
+	vars := expvar.NewMap(name)
+
+	vars.Set("packetsSent", m.packetsSent)
+	vars.Set("bytesSent", m.bytesSent)
+	vars.Set("packetsReceived", m.packetsReceived)
+	vars.Set("bytesReceived", m.bytesReceived)
+	vars.Set("sendQueue", m.sendQueue)
+	vars.Set("outgoing", m.outgoing)
+	vars.Set("incoming", m.incoming)
+	vars.Set("publishes", m.publishes)
+	vars.Set("publishLatencySeconds", m.publishLatencySeconds)
+	vars.Set("pingresps", m.pingresps)
+	vars.Set("pingLatencySeconds", m.pingLatencySeconds)
+
+	return m
+}
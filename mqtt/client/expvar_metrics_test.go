@@ -0,0 +1,66 @@
+package client
+
+import (
+	"encoding/json"
+	"expvar"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/yosssi/gmq/mqtt"
+	"github.com/yosssi/gmq/mqtt/packet"
+)
+
+func TestExpvarMetrics(t *testing.T) {
+	// The name is unique because the names can not be registered twice.
+	name := "gmqTestExpvarMetrics" + strconv.FormatInt(time.Now().UnixNano(), 10)
+
+	m := NewExpvarMetrics(name)
+
+	m.PacketSent(packet.TypePUBLISH, 10)
+	m.PacketSent(packet.TypePUBLISH, 20)
+	m.PacketReceived(packet.TypePUBACK, 4)
+	m.SendQueue(3)
+	m.Inflight(2, 1)
+	m.PublishCompleted(mqtt.QoS1, 2*time.Second)
+	m.PINGRESPReceived(time.Second)
+
+	var got struct {
+		PacketsSent           map[string]int64
+		BytesSent             map[string]int64
+		PacketsReceived       map[string]int64
+		BytesReceived         map[string]int64
+		SendQueue             int64
+		Outgoing              int64
+		Incoming              int64
+		Publishes             map[string]int64
+		PublishLatencySeconds map[string]float64
+		Pingresps             int64
+		PingLatencySeconds    float64
+	}
+
+	if err := json.Unmarshal([]byte(expvar.Get(name).String()), &got); err != nil {
+		nilErrorExpected(t, err)
+		return
+	}
+
+	if got.PacketsSent["PUBLISH"] != 2 || got.BytesSent["PUBLISH"] != 30 {
+		t.Errorf("packetsSent => %v, bytesSent => %v", got.PacketsSent, got.BytesSent)
+	}
+
+	if got.PacketsReceived["PUBACK"] != 1 || got.BytesReceived["PUBACK"] != 4 {
+		t.Errorf("packetsReceived => %v, bytesReceived => %v", got.PacketsReceived, got.BytesReceived)
+	}
+
+	if got.SendQueue != 3 || got.Outgoing != 2 || got.Incoming != 1 {
+		t.Errorf("sendQueue => %d, outgoing => %d, incoming => %d", got.SendQueue, got.Outgoing, got.Incoming)
+	}
+
+	if got.Publishes["1"] != 1 || got.PublishLatencySeconds["1"] != 2 {
+		t.Errorf("publishes => %v, publishLatencySeconds => %v", got.Publishes, got.PublishLatencySeconds)
+	}
+
+	if got.Pingresps != 1 || got.PingLatencySeconds != 1 {
+		t.Errorf("pingresps => %d, pingLatencySeconds => %v", got.Pingresps, got.PingLatencySeconds)
+	}
+}
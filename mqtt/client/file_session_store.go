@@ -0,0 +1,255 @@
+package client
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/yosssi/gmq/mqtt"
+	"github.com/yosssi/gmq/mqtt/packet"
+)
+
+// Prefixes of the names of the files by the directions
+var fileDirPrefixes = [2]string{"o-", "i-"}
+
+// Prefix of the names of the temporary files
+const fileTempPrefix = ".tmp-"
+
+// Error value
+var ErrInvalidStoredPacket = errors.New("invalid stored Packet")
+
+// FileSessionStore is a SessionStore which stores each Packet
+// as a file in the directory of the Session under the root
+// directory. The Packets are encoded in the same way as they are
+// sent on the Network Connection and are preceded by their sequence
+// numbers in 8 bytes. The Packets of MQTT 5.0 are also preceded by
+// the protocol version.
+type FileSessionStore struct {
+	// dir is the root directory.
+	dir string
+}
+
+// Load returns the stored Packets of the Session of the Client
+// Identifier by their Packet Identifiers.
+// It also removes the temporary files left by a crash during Put.
+func (s *FileSessionStore) Load(clientID []byte) (map[uint16]StoredPacket, map[uint16]StoredPacket, error) {
+	packets := [2]map[uint16]StoredPacket{
+		make(map[uint16]StoredPacket),
+		make(map[uint16]StoredPacket),
+	}
+
+	// Read the directory of the Session.
+	entries, err := os.ReadDir(s.sessionDir(clientID))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return packets[Outgoing], packets[Incoming], nil
+		}
+
+		return nil, nil, err
+	}
+
+	for _, entry := range entries {
+		// Remove the temporary file left by a crash during Put.
+		if strings.HasPrefix(entry.Name(), fileTempPrefix) {
+			if err := os.Remove(filepath.Join(s.sessionDir(clientID), entry.Name())); err != nil && !os.IsNotExist(err) {
+				return nil, nil, err
+			}
+
+			continue
+		}
+
+		// Parse the name of the file.
+		dir, id, ok := parseFileName(entry.Name())
+		if !ok {
+			continue
+		}
+
+		// Read and decode the Packet.
+		b, err := os.ReadFile(filepath.Join(s.sessionDir(clientID), entry.Name()))
+		if err != nil {
+			return nil, nil, err
+		}
+
+		sp, err := decodeStoredPacket(b)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		packets[dir][id] = sp
+	}
+
+	return packets[Outgoing], packets[Incoming], nil
+}
+
+// Put stores the Packet which has the Packet Identifier
+// with its sequence number.
+func (s *FileSessionStore) Put(clientID []byte, dir Direction, id uint16, seq uint64, p packet.Packet) error {
+	// Encode the sequence number and the Packet.
+	var bf bytes.Buffer
+
+	var bseq [8]byte
+
+	binary.BigEndian.PutUint64(bseq[:], seq)
+
+	bf.Write(bseq[:])
+
+	if v, ok := p.(interface{ ProtocolVersion() byte }); ok && v.ProtocolVersion() == mqtt.ProtocolVersion5 {
+		bf.WriteByte(mqtt.ProtocolVersion5)
+	}
+
+	if _, err := p.WriteTo(&bf); err != nil {
+		return err
+	}
+
+	// Create the directory of the Session.
+	sessDir := s.sessionDir(clientID)
+
+	if err := os.MkdirAll(sessDir, 0700); err != nil {
+		return err
+	}
+
+	// Write the Packet to a temporary file and rename it
+	// so as not to leave a partially written file.
+	f, err := os.CreateTemp(sessDir, fileTempPrefix)
+	if err != nil {
+		return err
+	}
+
+	if _, err := f.Write(bf.Bytes()); err != nil {
+		f.Close()
+		os.Remove(f.Name())
+		return err
+	}
+
+	// Commit the file to the stable storage before renaming it
+	// so that the renamed file is not empty after a crash.
+	if err := f.Sync(); err != nil {
+		f.Close()
+		os.Remove(f.Name())
+		return err
+	}
+
+	if err := f.Close(); err != nil {
+		os.Remove(f.Name())
+		return err
+	}
+
+	if err := os.Rename(f.Name(), filepath.Join(sessDir, fileName(dir, id))); err != nil {
+		os.Remove(f.Name())
+		return err
+	}
+
+	// Commit the renaming to the stable storage.
+	return syncDir(sessDir)
+}
+
+// Delete deletes the Packet which has the Packet Identifier.
+func (s *FileSessionStore) Delete(clientID []byte, dir Direction, id uint16) error {
+	err := os.Remove(filepath.Join(s.sessionDir(clientID), fileName(dir, id)))
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+
+	return nil
+}
+
+// Clear deletes all Packets of the Session of the Client Identifier.
+func (s *FileSessionStore) Clear(clientID []byte) error {
+	return os.RemoveAll(s.sessionDir(clientID))
+}
+
+// sessionDir returns the directory of the Session of the Client Identifier.
+func (s *FileSessionStore) sessionDir(clientID []byte) string {
+	return filepath.Join(s.dir, hex.EncodeToString(clientID))
+}
+
+// NewFileSessionStore creates and returns a FileSessionStore
+// which stores the Packets under the directory.
+func NewFileSessionStore(dir string) *FileSessionStore {
+	return &FileSessionStore{
+		dir: dir,
+	}
+}
+
+// syncDir commits the entries of the directory to the stable storage.
+func syncDir(dir string) error {
+	d, err := os.Open(dir)
+	if err != nil {
+		return err
+	}
+
+	if err := d.Sync(); err != nil {
+		d.Close()
+		return err
+	}
+
+	return d.Close()
+}
+
+// fileName returns the name of the file of the Packet.
+func fileName(dir Direction, id uint16) string {
+	return fileDirPrefixes[dir] + strconv.Itoa(int(id))
+}
+
+// parseFileName parses the name of the file and returns
+// the direction and the Packet Identifier of the Packet.
+func parseFileName(name string) (Direction, uint16, bool) {
+	for dir, prefix := range fileDirPrefixes {
+		if !strings.HasPrefix(name, prefix) {
+			continue
+		}
+
+		id, err := strconv.ParseUint(name[len(prefix):], 10, 16)
+		if err != nil || id == 0 {
+			return 0, 0, false
+		}
+
+		return Direction(dir), uint16(id), true
+	}
+
+	return 0, 0, false
+}
+
+// decodeStoredPacket decodes the stored byte data into the sequence
+// number and a PUBLISH, PUBREL or SUBSCRIBE Packet and returns them.
+func decodeStoredPacket(b []byte) (StoredPacket, error) {
+	// Extract the sequence number.
+	if len(b) < 8 {
+		return StoredPacket{}, ErrInvalidStoredPacket
+	}
+
+	seq, b := binary.BigEndian.Uint64(b[:8]), b[8:]
+
+	// Extract the protocol version. The first byte of
+	// a Packet is never the protocol version because
+	// the MQTT Control Packet type 0 is reserved.
+	var version byte
+
+	if len(b) > 0 && b[0] == mqtt.ProtocolVersion5 {
+		version, b = b[0], b[1:]
+	}
+
+	// Read a Packet from the byte data.
+	br := bytes.NewReader(b)
+
+	r := packet.NewReader(br)
+	r.ProtocolVersion = version
+
+	p, err := r.ReadPacket()
+	if err != nil || br.Len() != 0 {
+		return StoredPacket{}, ErrInvalidStoredPacket
+	}
+
+	// Check the MQTT Control Packet type.
+	switch p.(type) {
+	case *packet.PUBLISH, *packet.PUBREL, *packet.SUBSCRIBE:
+		return StoredPacket{Packet: p, Seq: seq}, nil
+	default:
+		return StoredPacket{}, ErrInvalidStoredPacket
+	}
+}
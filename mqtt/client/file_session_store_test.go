@@ -0,0 +1,307 @@
+package client
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/yosssi/gmq/mqtt"
+	"github.com/yosssi/gmq/mqtt/packet"
+)
+
+// encodeTestPacket encodes the Packet and returns the byte data.
+func encodeTestPacket(p packet.Packet) []byte {
+	var bf bytes.Buffer
+
+	p.WriteTo(&bf)
+
+	return bf.Bytes()
+}
+
+func TestFileSessionStore(t *testing.T) {
+	s := NewFileSessionStore(t.TempDir())
+
+	clientID := []byte("clientID")
+
+	publish, err := packet.NewPUBLISH(&packet.PUBLISHOptions{
+		QoS:       mqtt.QoS1,
+		TopicName: []byte("a"),
+		PacketID:  1,
+		Message:   []byte("message"),
+	})
+	if err != nil {
+		nilErrorExpected(t, err)
+		return
+	}
+
+	pubrel, err := packet.NewPUBREL(&packet.PUBRELOptions{
+		PacketID: 2,
+	})
+	if err != nil {
+		nilErrorExpected(t, err)
+		return
+	}
+
+	subscribe, err := packet.NewSUBSCRIBE(&packet.SUBSCRIBEOptions{
+		PacketID: 3,
+		SubReqs: []*packet.SubReq{
+			&packet.SubReq{
+				TopicFilter: []byte("a/#"),
+				QoS:         mqtt.QoS1,
+			},
+		},
+	})
+	if err != nil {
+		nilErrorExpected(t, err)
+		return
+	}
+
+	for id, p := range map[uint16]packet.Packet{1: publish, 2: pubrel, 3: subscribe} {
+		if err := s.Put(clientID, Outgoing, id, uint64(id), p); err != nil {
+			nilErrorExpected(t, err)
+			return
+		}
+	}
+
+	if err := s.Put(clientID, Incoming, 1, 1, publish); err != nil {
+		nilErrorExpected(t, err)
+		return
+	}
+
+	outgoing, incoming, err := s.Load(clientID)
+	if err != nil {
+		nilErrorExpected(t, err)
+		return
+	}
+
+	for id, want := range map[uint16]packet.Packet{1: publish, 2: pubrel, 3: subscribe} {
+		sp, exist := outgoing[id]
+		if !exist {
+			t.Errorf("the Packet %d was not loaded", id)
+			continue
+		}
+
+		if got, want := encodeTestPacket(sp.Packet), encodeTestPacket(want); !bytes.Equal(got, want) {
+			t.Errorf("Packet %d => %v, want => %v", id, got, want)
+		}
+
+		if sp.Seq != uint64(id) {
+			t.Errorf("sp.Seq => %d, want => %d", sp.Seq, id)
+		}
+	}
+
+	if len(incoming) != 1 {
+		t.Errorf("len(incoming) => %d, want => 1", len(incoming))
+	}
+
+	if err := s.Delete(clientID, Outgoing, 1); err != nil {
+		nilErrorExpected(t, err)
+	}
+
+	if err := s.Delete(clientID, Outgoing, 1); err != nil {
+		nilErrorExpected(t, err)
+	}
+
+	if outgoing, _, _ := s.Load(clientID); len(outgoing) != 2 {
+		t.Errorf("len(outgoing) => %d, want => 2", len(outgoing))
+	}
+
+	if err := s.Clear(clientID); err != nil {
+		nilErrorExpected(t, err)
+	}
+
+	outgoing, incoming, err = s.Load(clientID)
+	if err != nil {
+		nilErrorExpected(t, err)
+	}
+
+	if len(outgoing) != 0 || len(incoming) != 0 {
+		t.Errorf("len(outgoing), len(incoming) => %d, %d, want => 0, 0", len(outgoing), len(incoming))
+	}
+}
+
+func TestFileSessionStore_Load_ErrInvalidStoredPacket(t *testing.T) {
+	s := NewFileSessionStore(t.TempDir())
+
+	clientID := []byte("clientID")
+
+	if err := os.MkdirAll(s.sessionDir(clientID), 0700); err != nil {
+		nilErrorExpected(t, err)
+		return
+	}
+
+	// Ignored files
+	os.WriteFile(filepath.Join(s.sessionDir(clientID), ".tmp-1"), nil, 0600)
+	os.WriteFile(filepath.Join(s.sessionDir(clientID), "o-0"), nil, 0600)
+
+	if _, _, err := s.Load(clientID); err != nil {
+		nilErrorExpected(t, err)
+	}
+
+	os.WriteFile(filepath.Join(s.sessionDir(clientID), "o-1"), []byte{0, 0, 0, 0, 0, 0, 0, 1, packet.TypePUBREL<<4 | 0x02}, 0600)
+
+	if _, _, err := s.Load(clientID); err != ErrInvalidStoredPacket {
+		invalidError(t, err, ErrInvalidStoredPacket)
+	}
+}
+
+func TestFileSessionStore_Load_removeTempFile(t *testing.T) {
+	s := NewFileSessionStore(t.TempDir())
+
+	clientID := []byte("clientID")
+
+	p, err := packet.NewPUBREL(&packet.PUBRELOptions{
+		PacketID: 1,
+	})
+	if err != nil {
+		nilErrorExpected(t, err)
+		return
+	}
+
+	if err := s.Put(clientID, Outgoing, 1, 1, p); err != nil {
+		nilErrorExpected(t, err)
+		return
+	}
+
+	// Leave a temporary file as a crash during Put does.
+	tmp := filepath.Join(s.sessionDir(clientID), fileTempPrefix+"1")
+
+	if err := os.WriteFile(tmp, []byte{0x00}, 0600); err != nil {
+		nilErrorExpected(t, err)
+		return
+	}
+
+	outgoing, _, err := s.Load(clientID)
+	if err != nil {
+		nilErrorExpected(t, err)
+		return
+	}
+
+	if len(outgoing) != 1 {
+		t.Errorf("len(outgoing) => %d, want => %d", len(outgoing), 1)
+	}
+
+	if _, err := os.Stat(tmp); !os.IsNotExist(err) {
+		t.Errorf("os.Stat(tmp) => %v, want => %v", err, os.ErrNotExist)
+	}
+}
+
+func TestFileSessionStore_Put_MkdirAllErr(t *testing.T) {
+	dir := t.TempDir()
+
+	// Create a file which prevents creating the directory.
+	file := filepath.Join(dir, "file")
+
+	os.WriteFile(file, nil, 0600)
+
+	s := NewFileSessionStore(file)
+
+	if err := s.Put([]byte("clientID"), Outgoing, 1, 1, &packet.PUBREL{PacketID: 1}); err == nil {
+		notNilErrorExpected(t)
+	}
+}
+
+func TestFileSessionStore_Put_WriteToErr(t *testing.T) {
+	s := NewFileSessionStore(t.TempDir())
+
+	if err := s.Put([]byte("clientID"), Outgoing, 1, 1, &packetErr{}); err != errTest {
+		invalidError(t, err, errTest)
+	}
+}
+
+func Test_syncDir(t *testing.T) {
+	dir := t.TempDir()
+
+	if err := syncDir(dir); err != nil {
+		nilErrorExpected(t, err)
+	}
+
+	if err := syncDir(filepath.Join(dir, "none")); err == nil {
+		notNilErrorExpected(t)
+	}
+}
+
+func Test_parseFileName(t *testing.T) {
+	testCases := []struct {
+		name string
+		dir  Direction
+		id   uint16
+		ok   bool
+	}{
+		{"o-1", Outgoing, 1, true},
+		{"i-65535", Incoming, 65535, true},
+		{"o-0", 0, 0, false},
+		{"i-65536", 0, 0, false},
+		{"x-1", 0, 0, false},
+	}
+
+	for _, tc := range testCases {
+		dir, id, ok := parseFileName(tc.name)
+
+		if dir != tc.dir || id != tc.id || ok != tc.ok {
+			t.Errorf("parseFileName(%q) => %d, %d, %t, want => %d, %d, %t", tc.name, dir, id, ok, tc.dir, tc.id, tc.ok)
+		}
+	}
+}
+
+func Test_decodeStoredPacket_ErrInvalidStoredPacket(t *testing.T) {
+	testCases := [][]byte{
+		nil,
+		{0, 0, 0, 0, 0, 0, 0},
+		{packet.TypePUBREL<<4 | 0x02, 0x80},
+		{packet.TypePUBREL<<4 | 0x02, 0x80, 0x80, 0x80, 0x80, 0x01},
+		{packet.TypePUBREL<<4 | 0x02, 0x02, 0x00},
+		{packet.TypePUBACK << 4, 0x02, 0x00, 0x01},
+		{packet.TypePUBREL<<4 | 0x02, 0x02, 0x00, 0x01, 0x00},
+	}
+
+	for i, b := range testCases {
+		// Prepend the sequence number.
+		if i > 1 {
+			b = append([]byte{0, 0, 0, 0, 0, 0, 0, 1}, b...)
+		}
+
+		if _, err := decodeStoredPacket(b); err != ErrInvalidStoredPacket {
+			t.Errorf("decodeStoredPacket(%v) => %v, want => %v", b, err, ErrInvalidStoredPacket)
+		}
+	}
+}
+
+func TestFileSessionStore_v5(t *testing.T) {
+	s := NewFileSessionStore(t.TempDir())
+
+	clientID := []byte("clientID")
+
+	p, err := packet.NewPUBLISH(&packet.PUBLISHOptions{
+		QoS:             mqtt.QoS1,
+		TopicName:       []byte("a"),
+		PacketID:        1,
+		ProtocolVersion: mqtt.ProtocolVersion5,
+		Properties:      &packet.Properties{ContentType: []byte("text/plain")},
+	})
+	if err != nil {
+		nilErrorExpected(t, err)
+		return
+	}
+
+	if err := s.Put(clientID, Outgoing, 1, 1, p); err != nil {
+		nilErrorExpected(t, err)
+		return
+	}
+
+	sending, _, err := s.Load(clientID)
+	if err != nil {
+		nilErrorExpected(t, err)
+		return
+	}
+
+	publish, ok := sending[1].Packet.(*packet.PUBLISH)
+	if !ok {
+		t.Fatalf("sending[1].Packet => %T, want => *packet.PUBLISH", sending[1].Packet)
+	}
+
+	if publish.ProtocolVersion() != mqtt.ProtocolVersion5 || publish.Properties == nil || string(publish.Properties.ContentType) != "text/plain" {
+		t.Errorf("publish => %+v", publish)
+	}
+}
@@ -0,0 +1,126 @@
+package client
+
+import (
+	"sync/atomic"
+
+	"github.com/yosssi/gmq/mqtt/packet"
+)
+
+// Message represents an Application Message sent from the Server
+// with the attributes of the PUBLISH Packet which carried it.
+type Message struct {
+	// TopicName is the Topic Name of the PUBLISH Packet.
+	TopicName []byte
+	// Payload is the Application Message.
+	Payload []byte
+	// QoS is the QoS of the PUBLISH Packet.
+	QoS byte
+	// Retain is true if the Application Message was retained
+	// by the Server and is not a live one.
+	Retain bool
+	// DUP is the DUP flag of the PUBLISH Packet. It is true
+	// if the PUBLISH Packet might be a redelivery.
+	DUP bool
+	// PacketID is the Packet Identifier of the PUBLISH Packet.
+	// It is zero if the QoS is 0.
+	PacketID uint16
+	// Properties is the Properties of the PUBLISH Packet.
+	// It is nil unless the protocol version is MQTT 5.0.
+	Properties *packet.Properties
+
+	// ack is the acknowledgement shared by the handlers which
+	// require the manual acknowledgement. It is nil if
+	// the handler does not require it.
+	ack *messageAck
+	// acked is set to 1 when the Message is acknowledged.
+	acked uint32
+	// buf is the pooled buffer of the Message. It is nil
+	// if the receive buffers are not pooled.
+	buf *messageBuffer
+}
+
+// Keep takes the ownership of the pooled buffer of the Message so
+// that the TopicName, the Payload and the Properties remain valid
+// after the handler returns. The buffer is not recycled and is left
+// to the garbage collector. It does nothing unless the receive
+// buffers are pooled by Options.PoolReceiveBuffers.
+func (m *Message) Keep() {
+	if m.buf == nil {
+		return
+	}
+
+	atomic.StoreUint32(&m.buf.kept, 1)
+}
+
+// Ack acknowledges the Message. The Client sends the PUBACK Packet
+// of the QoS 1 Application Message or the PUBCOMP Packet of the QoS 2
+// Application Message when all handlers which require the manual
+// acknowledgement acknowledge it. It does nothing if the handler
+// does not require the manual acknowledgement or the Message has
+// already been acknowledged.
+func (m *Message) Ack() {
+	if m.ack == nil || !atomic.CompareAndSwapUint32(&m.acked, 0, 1) {
+		return
+	}
+
+	m.ack.done()
+}
+
+// messageAck represents the acknowledgement of an Application Message
+// which is sent when all handlers which require the manual
+// acknowledgement acknowledge it.
+type messageAck struct {
+	// remaining is the number of the handlers which have
+	// not yet acknowledged the Application Message.
+	remaining int32
+	// send sends the acknowledgement.
+	send func()
+}
+
+// done decrements the number of the remaining handlers and
+// sends the acknowledgement if all handlers have acknowledged.
+func (a *messageAck) done() {
+	if atomic.AddInt32(&a.remaining, -1) == 0 {
+		a.send()
+	}
+}
+
+// messageBuffer represents the pooled buffer of a PUBLISH Packet
+// which is shared by the handlers of its Application Message.
+type messageBuffer struct {
+	// remaining is the number of the handlers
+	// which have not returned.
+	remaining int32
+	// kept is set to 1 when a handler takes
+	// the ownership of the buffer.
+	kept uint32
+	// publish is the PUBLISH Packet which holds the buffer.
+	publish *packet.PUBLISH
+}
+
+// done decrements the number of the remaining handlers and
+// releases the buffer if all handlers have returned and none
+// of them has taken the ownership of it. It does nothing
+// if the buffer is nil.
+func (b *messageBuffer) done() {
+	if b == nil {
+		return
+	}
+
+	if atomic.AddInt32(&b.remaining, -1) == 0 && atomic.LoadUint32(&b.kept) == 0 {
+		b.publish.Release()
+	}
+}
+
+// newMessage creates and returns a Message from the PUBLISH Packet.
+func newMessage(p *packet.PUBLISH) *Message {
+	return &Message{
+		TopicName:  p.TopicName,
+		Payload:    p.Message,
+		QoS:        p.QoS,
+		Retain:     p.Retain,
+		DUP:        p.DUP,
+		PacketID:   p.PacketID,
+		Properties: p.Properties,
+	}
+}
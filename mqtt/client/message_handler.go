@@ -3,3 +3,7 @@ package client
 // MessageHandler is the handler which handles
 // the Application Message sent from the Server.
 type MessageHandler func(topicName, message []byte)
+
+// MessageFunc is the handler which handles the Application Message
+// sent from the Server with the attributes of the PUBLISH Packet.
+type MessageFunc func(msg *Message)
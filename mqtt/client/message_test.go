@@ -0,0 +1,80 @@
+package client
+
+import (
+	"testing"
+
+	"github.com/yosssi/gmq/mqtt/packet"
+)
+
+func TestMessage_Ack_notManual(t *testing.T) {
+	msg := newMessage(&packet.PUBLISH{})
+
+	msg.Ack()
+}
+
+func TestMessage_Ack(t *testing.T) {
+	var sent int
+
+	mack := &messageAck{
+		remaining: 2,
+		send: func() {
+			sent++
+		},
+	}
+
+	msg1 := newMessage(&packet.PUBLISH{})
+	msg1.ack = mack
+
+	msg2 := newMessage(&packet.PUBLISH{})
+	msg2.ack = mack
+
+	msg1.Ack()
+	msg1.Ack()
+
+	if sent != 0 {
+		t.Errorf("sent => %d, want => 0", sent)
+	}
+
+	msg2.Ack()
+
+	if sent != 1 {
+		t.Errorf("sent => %d, want => 1", sent)
+	}
+}
+
+func TestMessage_Keep(t *testing.T) {
+	// Keep does nothing if the buffers are not pooled.
+	(&Message{}).Keep()
+
+	buf := &messageBuffer{
+		remaining: 2,
+		publish:   &packet.PUBLISH{},
+	}
+
+	msg := &Message{buf: buf}
+
+	msg.Keep()
+
+	if buf.kept != 1 {
+		t.Errorf("buf.kept => %d, want => 1", buf.kept)
+	}
+}
+
+func Test_messageBuffer_done(t *testing.T) {
+	// done does nothing if the buffer is nil.
+	var nilBuf *messageBuffer
+
+	nilBuf.done()
+
+	buf := &messageBuffer{
+		remaining: 2,
+		publish:   &packet.PUBLISH{},
+	}
+
+	buf.done()
+	buf.done()
+
+	if buf.remaining != 0 {
+		t.Errorf("buf.remaining => %d, want => 0", buf.remaining)
+	}
+}
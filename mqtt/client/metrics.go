@@ -0,0 +1,30 @@
+package client
+
+import "time"
+
+// Metrics collects the metrics of the Client. Its methods are called
+// by the goroutines of the Client, so it must be safe for concurrent use
+// and its methods should return quickly.
+type Metrics interface {
+	// PacketSent is called when the Packet of the MQTT Control
+	// Packet type has been sent to the Server with its size in bytes.
+	PacketSent(ptype byte, size int)
+	// PacketReceived is called when the Packet of the MQTT Control
+	// Packet type has been received from the Server with its size in bytes.
+	PacketReceived(ptype byte, size int)
+	// SendQueue is called with the number of the Packets which are
+	// queued for sending when a Packet is about to be queued and when
+	// a Packet has been sent to the Server.
+	SendQueue(n int)
+	// Inflight is called with the numbers of the outgoing and the
+	// incoming Packets of the Session whose flows have not yet
+	// completed when they change.
+	Inflight(outgoing, incoming int)
+	// PublishCompleted is called with the time from the publication
+	// of the Application Message to the arrival of the PUBACK Packet
+	// of QoS 1 or the PUBCOMP Packet of QoS 2.
+	PublishCompleted(qos byte, latency time.Duration)
+	// PINGRESPReceived is called with the time from sending
+	// the PINGREQ Packet to receiving the PINGRESP Packet.
+	PINGRESPReceived(latency time.Duration)
+}
@@ -0,0 +1,177 @@
+package client
+
+import (
+	"fmt"
+	"net"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/yosssi/gmq/mqtt"
+	"github.com/yosssi/gmq/mqtt/packet"
+)
+
+// testMetrics is a Metrics which records the calls of its methods.
+type testMetrics struct {
+	mu    sync.Mutex
+	calls []string
+}
+
+func (m *testMetrics) record(format string, a ...interface{}) {
+	m.mu.Lock()
+	m.calls = append(m.calls, fmt.Sprintf(format, a...))
+	m.mu.Unlock()
+}
+
+func (m *testMetrics) PacketSent(ptype byte, size int) {
+	m.record("sent %s %d", packet.TypeName(ptype), size)
+}
+
+func (m *testMetrics) PacketReceived(ptype byte, size int) {
+	m.record("received %s %d", packet.TypeName(ptype), size)
+}
+
+func (m *testMetrics) SendQueue(n int) {
+	m.record("queue %d", n)
+}
+
+func (m *testMetrics) Inflight(outgoing, incoming int) {
+	m.record("inflight %d %d", outgoing, incoming)
+}
+
+func (m *testMetrics) PublishCompleted(qos byte, latency time.Duration) {
+	if latency > 0 {
+		m.record("published %d", qos)
+	}
+}
+
+func (m *testMetrics) PINGRESPReceived(latency time.Duration) {
+	if latency > 0 {
+		m.record("pingresp")
+	}
+}
+
+func (m *testMetrics) has(call string) bool {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for _, c := range m.calls {
+		if c == call {
+			return true
+		}
+	}
+
+	return false
+}
+
+func TestClient_metrics(t *testing.T) {
+	m := &testMetrics{}
+
+	cli, ln := newTestClientWithOptions(t, &Options{
+		ErrorHandler: func(_ error) {},
+		Metrics:      m,
+	}, &ConnectOptions{
+		ClientID: []byte("clientID"),
+	}, func(conn net.Conn) {
+		_, remaining, err := readTestPacket(conn)
+		if err != nil {
+			return
+		}
+
+		// Get the Packet Identifier which follows the Topic Name.
+		n := 2 + (int(remaining[0])<<8 | int(remaining[1]))
+
+		conn.Write([]byte{packet.TypePUBACK << 4, 0x02, remaining[n], remaining[n+1]})
+
+		readTestPacket(conn)
+	})
+
+	defer ln.Close()
+
+	defer cli.Terminate()
+
+	tk, err := cli.PublishAsync(&PublishOptions{
+		QoS:       mqtt.QoS1,
+		TopicName: []byte("topicName"),
+		Message:   []byte("message"),
+	})
+	if err != nil {
+		nilErrorExpected(t, err)
+		return
+	}
+
+	if err := tk.Wait(); err != nil {
+		nilErrorExpected(t, err)
+	}
+
+	if err := cli.Disconnect(); err != nil {
+		nilErrorExpected(t, err)
+	}
+
+	for _, call := range []string{
+		"inflight 0 0",
+		"sent CONNECT 22",
+		"received CONNACK 4",
+		"inflight 1 0",
+		"sent PUBLISH 22",
+		"queue 0",
+		"received PUBACK 4",
+		"published 1",
+		"sent DISCONNECT 2",
+	} {
+		if !m.has(call) {
+			t.Errorf("%q was not recorded in %v", call, m.calls)
+		}
+	}
+}
+
+func TestClient_reportSendQueue(t *testing.T) {
+	m := &testMetrics{}
+
+	cli := New(&Options{
+		ErrorHandler: func(_ error) {},
+		Metrics:      m,
+	})
+
+	defer cli.Terminate()
+
+	conn := &connection{
+		send: make(chan packet.Packet, 2),
+	}
+
+	// Queue a Packet which is not sent.
+	conn.send <- packet.NewPINGREQ()
+
+	cli.reportSendQueue(conn)
+
+	if !m.has("queue 1") {
+		t.Errorf("%q was not recorded in %v", "queue 1", m.calls)
+	}
+}
+
+func TestClient_handlePINGRESP_metrics(t *testing.T) {
+	m := &testMetrics{}
+
+	cli := New(&Options{
+		ErrorHandler: func(_ error) {},
+		Metrics:      m,
+	})
+
+	cli.conn = &connection{
+		pingresps:    []chan struct{}{make(chan struct{}, 1)},
+		pingreqTimes: []time.Time{time.Now().Add(-time.Second)},
+	}
+
+	if err := cli.handlePINGRESP(); err != nil {
+		nilErrorExpected(t, err)
+		return
+	}
+
+	if !m.has("pingresp") {
+		t.Errorf("the latency of the PINGRESP Packet was not recorded")
+	}
+
+	if len(cli.conn.pingreqTimes) != 0 {
+		t.Errorf("len(cli.conn.pingreqTimes) => %d, want => 0", len(cli.conn.pingreqTimes))
+	}
+}
@@ -4,4 +4,50 @@ package client
 type Options struct {
 	// ErrorHandler is the error handler.
 	ErrorHandler ErrorHandler
+	// DispatchMode is the mode of dispatching the Application
+	// Messages sent from the Server to the handlers.
+	// DispatchConcurrent is used by default.
+	DispatchMode DispatchMode
+	// DispatchWorkers is the number of the goroutines which
+	// execute the handlers in the DispatchWorkerPool mode.
+	// runtime.NumCPU() is used if it is zero.
+	DispatchWorkers int
+	// MaxPendingMessages is the maximum number of the handler
+	// executions which have been dispatched but have not yet
+	// returned. The Client stops receiving the Packets from
+	// the Server while the number reaches the maximum. There is
+	// no limit if it is zero. The handlers should not wait for
+	// the acknowledgements from the Server if it is not zero.
+	MaxPendingMessages int
+	// SessionStore is the SessionStore which persists the unacknowledged
+	// Packets of the Session. The stored Packets are loaded when
+	// the Connect method creates a Session whose Clean Session is false
+	// and they are deleted when the Clean Session is true.
+	SessionStore SessionStore
+	// PoolReceiveBuffers enables reading the PUBLISH Packets sent from
+	// the Server into pooled buffers. The buffer of a QoS 0 or QoS 1
+	// Application Message is recycled when all its handlers return, so
+	// the handlers must not retain the Topic Name, the Payload and
+	// the Properties unless they take the ownership of the buffer
+	// by the Keep method of the Message.
+	PoolReceiveBuffers bool
+	// Tracer observes the Packets which the Client sends
+	// to and receives from the Server.
+	Tracer Tracer
+	// Metrics collects the metrics of the Client.
+	Metrics Metrics
+	// OnConnect is called when the Server has accepted the connection
+	// including the reconnection.
+	OnConnect ConnectHandler
+	// OnConnectionLost is called with the cause when the Network
+	// Connection has been lost and has been cleaned.
+	OnConnectionLost ConnectionLostHandler
+	// OnReconnecting is called before each reconnection attempt.
+	OnReconnecting ReconnectingHandler
+	// OnDisconnect is called when the Client has been disconnected
+	// from the Server by the Disconnect method.
+	OnDisconnect DisconnectHandler
+	// OnAbandon is called when the flow of the PUBLISH or PUBREL
+	// Packet is abandoned after the resends.
+	OnAbandon AbandonHandler
 }
@@ -1,5 +1,7 @@
 package client
 
+import "github.com/yosssi/gmq/mqtt/packet"
+
 // PublishOptions represents options for
 // the Publish method of the Client.
 type PublishOptions struct {
@@ -11,4 +13,8 @@ type PublishOptions struct {
 	TopicName []byte
 	// Message is the Application Message of the payload.
 	Message []byte
+	// Properties is the Properties of the PUBLISH Packet.
+	// It is used only in MQTT 5.0. The Topic Name can be
+	// zero-byte if the Topic Alias is set.
+	Properties *packet.Properties
 }
@@ -0,0 +1,58 @@
+package client
+
+import (
+	"fmt"
+
+	"github.com/yosssi/gmq/mqtt/packet"
+)
+
+// Names of the MQTT Control Packets which contain the Reason Codes
+var packetNames = map[byte]string{
+	packet.TypeCONNACK:    "CONNACK",
+	packet.TypePUBACK:     "PUBACK",
+	packet.TypePUBREC:     "PUBREC",
+	packet.TypePUBREL:     "PUBREL",
+	packet.TypePUBCOMP:    "PUBCOMP",
+	packet.TypeSUBACK:     "SUBACK",
+	packet.TypeUNSUBACK:   "UNSUBACK",
+	packet.TypeDISCONNECT: "DISCONNECT",
+	packet.TypeAUTH:       "AUTH",
+}
+
+// ReasonCodeError represents the failure which the Server
+// reports with the Reason Code of an MQTT 5.0 Packet.
+type ReasonCodeError struct {
+	// PacketType is the MQTT Control Packet type of the Packet.
+	PacketType byte
+	// ReasonCode is the Reason Code of the Packet.
+	ReasonCode byte
+	// Properties is the Properties of the Packet.
+	// It may be nil.
+	Properties *packet.Properties
+}
+
+// Error returns the string which describes the error. It contains
+// the Reason String of the Properties if the Server sends it.
+func (e *ReasonCodeError) Error() string {
+	s := fmt.Sprintf("the Server sent the %s Packet with the Reason Code 0x%02X", packetNames[e.PacketType], e.ReasonCode)
+
+	if e.Properties != nil && len(e.Properties.ReasonString) > 0 {
+		s += ": " + string(e.Properties.ReasonString)
+	}
+
+	return s
+}
+
+// reasonCodeErr returns a ReasonCodeError if the Reason Code
+// represents failure. It returns nil otherwise.
+func reasonCodeErr(ptype byte, code byte, props *packet.Properties) error {
+	if code < packet.ReasonUnspecifiedError {
+		return nil
+	}
+
+	return &ReasonCodeError{
+		PacketType: ptype,
+		ReasonCode: code,
+		Properties: props,
+	}
+}
@@ -0,0 +1,41 @@
+package client
+
+import (
+	"testing"
+
+	"github.com/yosssi/gmq/mqtt/packet"
+)
+
+func TestReasonCodeError_Error(t *testing.T) {
+	testCases := []struct {
+		err  *ReasonCodeError
+		want string
+	}{
+		{
+			&ReasonCodeError{PacketType: packet.TypePUBACK, ReasonCode: packet.ReasonNotAuthorized},
+			"the Server sent the PUBACK Packet with the Reason Code 0x87",
+		},
+		{
+			&ReasonCodeError{PacketType: packet.TypeDISCONNECT, ReasonCode: packet.ReasonServerShuttingDown, Properties: &packet.Properties{ReasonString: []byte("maintenance")}},
+			"the Server sent the DISCONNECT Packet with the Reason Code 0x8B: maintenance",
+		},
+	}
+
+	for _, tc := range testCases {
+		if got := tc.err.Error(); got != tc.want {
+			t.Errorf("err.Error() => %q, want => %q", got, tc.want)
+		}
+	}
+}
+
+func Test_reasonCodeErr(t *testing.T) {
+	if err := reasonCodeErr(packet.TypePUBACK, packet.ReasonNoMatchingSubscribers, nil); err != nil {
+		nilErrorExpected(t, err)
+	}
+
+	err := reasonCodeErr(packet.TypePUBACK, packet.ReasonQuotaExceeded, nil)
+
+	if rerr, ok := err.(*ReasonCodeError); !ok || rerr.PacketType != packet.TypePUBACK || rerr.ReasonCode != packet.ReasonQuotaExceeded {
+		t.Errorf("err => %#v, want => *ReasonCodeError", err)
+	}
+}
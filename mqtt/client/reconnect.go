@@ -0,0 +1,201 @@
+package client
+
+import (
+	"context"
+	"math/rand"
+	"time"
+)
+
+// Default values of the reconnection intervals in seconds
+const (
+	defaultReconnectInterval    time.Duration = 1
+	defaultMaxReconnectInterval time.Duration = 60
+)
+
+// reconnectInfo returns the options of the last successful
+// Connect method call and the subscription requests which
+// are acknowledged and not yet acknowledged by the Server.
+func (cli *Client) reconnectInfo() (*ConnectOptions, []*SubReq, []*SubReq) {
+	// Lock for reading.
+	cli.muConn.RLock()
+
+	// Unlock.
+	defer cli.muConn.RUnlock()
+
+	// Return nil if the Client has not yet connected to the Server.
+	if cli.conn == nil {
+		return nil, nil, nil
+	}
+
+	// Collect the subscription requests.
+	var ackedSubReqs, unackSubReqs []*SubReq
+
+	cli.conn.ackedSubs.Range(func(_ string, v interface{}) {
+		ackedSubReqs = append(ackedSubReqs, v.(*SubReq))
+	})
+
+	for _, s := range cli.conn.unackSubs {
+		unackSubReqs = append(unackSubReqs, s)
+	}
+
+	return cli.connectOpts, ackedSubReqs, unackSubReqs
+}
+
+// reconnect tries to reconnect to the Server and to restore
+// the subscriptions until it succeeds or the number of the attempts
+// exceeds the maximum. It returns true if the Client is terminated
+// while reconnecting.
+func (cli *Client) reconnect(opts *ConnectOptions, ackedSubReqs, unackSubReqs []*SubReq) bool {
+	// Lock for updating.
+	cli.muConn.Lock()
+
+	// End the process if the Client has already connected to the Server.
+	if cli.conn != nil {
+		// Unlock.
+		cli.muConn.Unlock()
+
+		return false
+	}
+
+	// Create a channel which ends the reconnection attempts.
+	reconnEndc := make(chan struct{})
+
+	cli.reconnEndc = reconnEndc
+
+	// Unlock.
+	cli.muConn.Unlock()
+
+	defer func() {
+		// Lock for updating.
+		cli.muConn.Lock()
+
+		// Clean the channel if it has not been closed by the Disconnect method.
+		if cli.reconnEndc == reconnEndc {
+			cli.reconnEndc = nil
+
+			// Change the state if the reconnection has failed.
+			if cli.conn == nil {
+				cli.setState(StateDisconnected)
+			}
+		}
+
+		// Unlock.
+		cli.muConn.Unlock()
+	}()
+
+	for retries := 0; opts.MaxReconnectRetries == 0 || retries < opts.MaxReconnectRetries; retries++ {
+		// Wait for the backoff interval.
+		select {
+		case <-time.After(reconnectDelay(retries, opts.ReconnectInterval, opts.MaxReconnectInterval)):
+		case <-reconnEndc:
+			return false
+		case <-cli.disconnEndc:
+			return true
+		}
+
+		// Notify the reconnection attempt to the handler.
+		if cli.onReconnecting != nil {
+			cli.onReconnecting(retries + 1)
+		}
+
+		// Reconnect to the Server.
+		err := cli.connectWithSubs(context.Background(), opts, ackedSubReqs)
+
+		switch err {
+		case nil:
+			// Restore the subscriptions.
+			cli.restoreSubs(ackedSubReqs, unackSubReqs)
+
+			return false
+		case ErrAlreadyConnected:
+			// End the process because the Client has been
+			// connected to the Server by the other caller.
+			return false
+		default:
+			// Handle the error.
+			if cli.errorHandler != nil {
+				cli.errorHandler(err)
+			}
+		}
+	}
+
+	// Handle the error.
+	if cli.errorHandler != nil {
+		cli.errorHandler(ErrReconnectRetriesExceeded)
+	}
+
+	return false
+}
+
+// restoreSubs restores the subscriptions after reconnecting.
+// The acknowledged subscriptions have been restored by the connect
+// method without sending a SUBSCRIBE Packet if the Server has resumed
+// the Session.
+func (cli *Client) restoreSubs(ackedSubReqs, unackSubReqs []*SubReq) {
+	// Lock for updating.
+	cli.muConn.Lock()
+
+	// End the process if the Network Connection has already been lost.
+	if cli.conn == nil {
+		// Unlock.
+		cli.muConn.Unlock()
+
+		return
+	}
+
+	// Define the subscription requests which are sent to the Server.
+	var subReqs []*SubReq
+
+	if cli.conn.sessionPresent {
+		subReqs = unackSubReqs
+	} else {
+		subReqs = append(ackedSubReqs, unackSubReqs...)
+	}
+
+	// Unlock.
+	cli.muConn.Unlock()
+
+	// End the process if there is no subscription request to send.
+	if len(subReqs) == 0 {
+		return
+	}
+
+	// Send a SUBSCRIBE Packet to the Server.
+	if err := cli.Subscribe(&SubscribeOptions{SubReqs: subReqs}); err != nil {
+		if cli.errorHandler != nil {
+			cli.errorHandler(err)
+		}
+	}
+}
+
+// reconnectDelay calculates and returns the interval before
+// the reconnection attempt by using the exponential backoff
+// and the jitter.
+func reconnectDelay(retries int, interval, maxInterval time.Duration) time.Duration {
+	// Set the default values.
+	if interval <= 0 {
+		interval = defaultReconnectInterval
+	}
+
+	if maxInterval <= 0 {
+		maxInterval = defaultMaxReconnectInterval
+	}
+
+	// Convert the intervals in seconds into the durations.
+	d := interval * time.Second
+	max := maxInterval * time.Second
+
+	// Double the interval for each retry.
+	for i := 0; i < retries && d < max; i++ {
+		d *= 2
+	}
+
+	if d > max {
+		d = max
+	}
+
+	// Randomize the latter half of the interval.
+	half := d / 2
+
+	return half + time.Duration(rand.Int63n(int64(half)+1))
+}
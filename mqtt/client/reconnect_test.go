@@ -0,0 +1,460 @@
+package client
+
+import (
+	"net"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/yosssi/gmq/mqtt"
+	"github.com/yosssi/gmq/mqtt/packet"
+)
+
+func TestClient_reconnect(t *testing.T) {
+	var mu sync.Mutex
+
+	var accepted int
+
+	subscribedc := make(chan string, 2)
+
+	ln := newTestServer(t, func(conn net.Conn) {
+		mu.Lock()
+		accepted++
+		n := accepted
+		mu.Unlock()
+
+		defer conn.Close()
+
+		// Read the CONNECT Packet.
+		if _, _, err := readTestPacket(conn); err != nil {
+			return
+		}
+
+		conn.Write(testCONNACK)
+
+		// Read the SUBSCRIBE Packet.
+		first, remaining, err := readTestPacket(conn)
+		if err != nil || first>>4 != packet.TypeSUBSCRIBE {
+			return
+		}
+
+		subscribedc <- string(remaining[4 : len(remaining)-1])
+
+		conn.Write([]byte{packet.TypeSUBACK << 4, 0x03, remaining[0], remaining[1], mqtt.QoS1})
+
+		// Lose the first Network Connection.
+		if n == 1 {
+			return
+		}
+
+		readTestPacket(conn)
+	})
+
+	defer ln.Close()
+
+	cli := New(&Options{
+		ErrorHandler: func(_ error) {},
+	})
+
+	defer cli.Terminate()
+
+	err := cli.Connect(&ConnectOptions{
+		Network:       "tcp",
+		Address:       ln.Addr().String(),
+		ClientID:      []byte("clientID"),
+		CleanSession:  true,
+		AutoReconnect: true,
+	})
+	if err != nil {
+		nilErrorExpected(t, err)
+		return
+	}
+
+	err = cli.Subscribe(&SubscribeOptions{
+		SubReqs: []*SubReq{
+			&SubReq{
+				TopicFilter: []byte("a/#"),
+				QoS:         mqtt.QoS1,
+				Handler:     func(_, _ []byte) {},
+			},
+		},
+	})
+	if err != nil {
+		nilErrorExpected(t, err)
+		return
+	}
+
+	for i := 0; i < 2; i++ {
+		select {
+		case topicFilter := <-subscribedc:
+			if topicFilter != "a/#" {
+				t.Errorf("topicFilter => %q, want => %q", topicFilter, "a/#")
+			}
+		case <-time.After(5 * time.Second):
+			t.Error("the subscription was not restored")
+			return
+		}
+	}
+
+	if err := cli.Disconnect(); err != nil {
+		nilErrorExpected(t, err)
+	}
+}
+
+func TestClient_reconnect_sessionPresent(t *testing.T) {
+	var mu sync.Mutex
+
+	var accepted int
+
+	ln := newTestServer(t, func(conn net.Conn) {
+		mu.Lock()
+		accepted++
+		n := accepted
+		mu.Unlock()
+
+		defer conn.Close()
+
+		// Read the CONNECT Packet.
+		if _, _, err := readTestPacket(conn); err != nil {
+			return
+		}
+
+		if n == 1 {
+			conn.Write(testCONNACK)
+
+			// Acknowledge the SUBSCRIBE Packet and lose
+			// the first Network Connection.
+			_, remaining, err := readTestPacket(conn)
+			if err != nil {
+				return
+			}
+
+			conn.Write([]byte{packet.TypeSUBACK << 4, 0x03, remaining[0], remaining[1], mqtt.QoS1})
+
+			return
+		}
+
+		// Send the queued Application Message right after
+		// the CONNACK Packet with the Session Present.
+		conn.Write([]byte{
+			packet.TypeCONNACK << 4, 0x02, 0x01, 0x00,
+			packet.TypePUBLISH<<4 | mqtt.QoS1<<1, 0x08, 0x00, 0x03, 'a', '/', 'b', 0x00, 0x01, 'm',
+		})
+
+		readTestPacket(conn)
+		readTestPacket(conn)
+	})
+
+	defer ln.Close()
+
+	messagec := make(chan string, 1)
+	receivedc := make(chan string, 1)
+
+	cli := New(&Options{
+		ErrorHandler: func(_ error) {},
+		// Wait for the queued Application Message before
+		// the reconnect method returns.
+		OnConnect: func(sessionPresent bool) {
+			if !sessionPresent {
+				return
+			}
+
+			select {
+			case msg := <-messagec:
+				receivedc <- msg
+			case <-time.After(2 * time.Second):
+				receivedc <- ""
+			}
+		},
+	})
+
+	defer cli.Terminate()
+
+	err := cli.Connect(&ConnectOptions{
+		Network:       "tcp",
+		Address:       ln.Addr().String(),
+		ClientID:      []byte("clientID"),
+		AutoReconnect: true,
+	})
+	if err != nil {
+		nilErrorExpected(t, err)
+		return
+	}
+
+	err = cli.Subscribe(&SubscribeOptions{
+		SubReqs: []*SubReq{
+			&SubReq{
+				TopicFilter: []byte("a/#"),
+				QoS:         mqtt.QoS1,
+				Handler: func(topicName, message []byte) {
+					messagec <- string(topicName) + " " + string(message)
+				},
+			},
+		},
+	})
+	if err != nil {
+		nilErrorExpected(t, err)
+		return
+	}
+
+	select {
+	case msg := <-receivedc:
+		if msg != "a/b m" {
+			t.Errorf("msg => %q, want => %q", msg, "a/b m")
+		}
+	case <-time.After(5 * time.Second):
+		t.Error("the handler was not executed for the queued Application Message")
+	}
+
+	if err := cli.Disconnect(); err != nil {
+		nilErrorExpected(t, err)
+	}
+}
+
+func TestClient_reconnect_ErrReconnectRetriesExceeded(t *testing.T) {
+	ln := newTestServer(t, func(conn net.Conn) {
+		conn.Write(testCONNACK)
+		conn.Close()
+	})
+
+	errc := make(chan error, 10)
+
+	cli := New(&Options{
+		ErrorHandler: func(err error) {
+			errc <- err
+		},
+	})
+
+	defer cli.Terminate()
+
+	err := cli.Connect(&ConnectOptions{
+		Network:             "tcp",
+		Address:             ln.Addr().String(),
+		ClientID:            []byte("clientID"),
+		AutoReconnect:       true,
+		MaxReconnectRetries: 1,
+	})
+	if err != nil {
+		nilErrorExpected(t, err)
+		return
+	}
+
+	ln.Close()
+
+	timeoutc := time.After(5 * time.Second)
+
+	for {
+		select {
+		case err := <-errc:
+			if err == ErrReconnectRetriesExceeded {
+				return
+			}
+		case <-timeoutc:
+			t.Error("ErrReconnectRetriesExceeded was not handled")
+			return
+		}
+	}
+}
+
+func TestClient_Disconnect_reconnecting(t *testing.T) {
+	cli := New(&Options{
+		ErrorHandler: func(_ error) {},
+	})
+
+	defer cli.Terminate()
+
+	cli.connectOpts = &ConnectOptions{
+		Network:           "tcp",
+		Address:           "localhost:0",
+		ReconnectInterval: 60,
+	}
+
+	donec := make(chan bool)
+
+	go func() {
+		donec <- cli.reconnect(cli.connectOpts, nil, nil)
+	}()
+
+	for {
+		cli.muConn.RLock()
+		reconnecting := cli.reconnEndc != nil
+		cli.muConn.RUnlock()
+
+		if reconnecting {
+			break
+		}
+
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	if err := cli.Disconnect(); err != nil {
+		nilErrorExpected(t, err)
+	}
+
+	if terminated := <-donec; terminated {
+		t.Error("terminated => true, want => false")
+	}
+
+	if err := cli.Disconnect(); err != ErrNotYetConnected {
+		invalidError(t, err, ErrNotYetConnected)
+	}
+}
+
+func Test_reconnectDelay(t *testing.T) {
+	testCases := []struct {
+		retries     int
+		interval    time.Duration
+		maxInterval time.Duration
+		want        time.Duration
+	}{
+		{0, 0, 0, defaultReconnectInterval * time.Second},
+		{0, 2, 60, 2 * time.Second},
+		{3, 2, 60, 16 * time.Second},
+		{10, 2, 60, 60 * time.Second},
+	}
+
+	for _, tc := range testCases {
+		d := reconnectDelay(tc.retries, tc.interval, tc.maxInterval)
+
+		if d < tc.want/2 || d > tc.want {
+			t.Errorf("reconnectDelay(%d, %d, %d) => %s, want => [%s, %s]", tc.retries, tc.interval, tc.maxInterval, d, tc.want/2, tc.want)
+		}
+	}
+}
+
+func TestClient_Connect_resendOrder(t *testing.T) {
+	var mu sync.Mutex
+
+	var accepted int
+
+	resentc := make(chan []uint16, 1)
+
+	ln := newTestServer(t, func(conn net.Conn) {
+		mu.Lock()
+		accepted++
+		n := accepted
+		mu.Unlock()
+
+		defer conn.Close()
+
+		if _, _, err := readTestPacket(conn); err != nil {
+			return
+		}
+
+		conn.Write(testCONNACK)
+
+		if n == 1 {
+			// Acknowledge only the first PUBLISH Packet
+			// and lose the Network Connection.
+			for i := 0; i < 4; i++ {
+				_, remaining, err := readTestPacket(conn)
+				if err != nil {
+					return
+				}
+
+				if i == 0 {
+					conn.Write([]byte{packet.TypePUBACK << 4, 0x02, remaining[3], remaining[4]})
+				}
+			}
+
+			return
+		}
+
+		// Record the Packet Identifiers of the resent PUBLISH Packets.
+		var ids []uint16
+
+		for i := 0; i < 3; i++ {
+			first, remaining, err := readTestPacket(conn)
+			if err != nil || first&0x08 == 0 {
+				break
+			}
+
+			ids = append(ids, uint16(remaining[3])<<8|uint16(remaining[4]))
+		}
+
+		resentc <- ids
+
+		readTestPacket(conn)
+	})
+
+	defer ln.Close()
+
+	lostc := make(chan struct{}, 1)
+
+	cli := New(&Options{
+		ErrorHandler: func(_ error) {},
+		OnConnectionLost: func(_ error) {
+			lostc <- struct{}{}
+		},
+	})
+
+	defer cli.Terminate()
+
+	connectOpts := &ConnectOptions{
+		Network:  "tcp",
+		Address:  ln.Addr().String(),
+		ClientID: []byte("clientID"),
+	}
+
+	if err := cli.Connect(connectOpts); err != nil {
+		nilErrorExpected(t, err)
+		return
+	}
+
+	publish := func() (*Token, error) {
+		return cli.PublishAsync(&PublishOptions{
+			QoS:       mqtt.QoS1,
+			TopicName: []byte("a"),
+		})
+	}
+
+	// Publish the Application Messages whose Packet Identifiers
+	// are 1, 2, 3 and 1 because 1 is released by the PUBACK Packet.
+	var tks []*Token
+
+	for i := 0; i < 3; i++ {
+		tk, err := publish()
+		if err != nil {
+			nilErrorExpected(t, err)
+			return
+		}
+
+		tks = append(tks, tk)
+	}
+
+	if err := tks[0].Wait(); err != nil {
+		nilErrorExpected(t, err)
+		return
+	}
+
+	if _, err := publish(); err != nil {
+		nilErrorExpected(t, err)
+		return
+	}
+
+	select {
+	case <-lostc:
+	case <-time.After(5 * time.Second):
+		t.Error("the Network Connection was not lost")
+		return
+	}
+
+	if err := cli.Connect(connectOpts); err != nil {
+		nilErrorExpected(t, err)
+		return
+	}
+
+	select {
+	case ids := <-resentc:
+		if want := []uint16{2, 3, 1}; !reflect.DeepEqual(ids, want) {
+			t.Errorf("ids => %v, want => %v", ids, want)
+		}
+	case <-time.After(5 * time.Second):
+		t.Error("the PUBLISH Packets were not resent")
+	}
+
+	if err := cli.Disconnect(); err != nil {
+		nilErrorExpected(t, err)
+	}
+}
@@ -0,0 +1,68 @@
+package client
+
+import (
+	"time"
+
+	"github.com/yosssi/gmq/mqtt/packet"
+)
+
+// retryPackets resends the PUBLISH and PUBREL Packets which have not
+// been acknowledged by the Server within the interval in seconds while
+// the Network Connection is alive. The flows whose Packets have been
+// resent the maximum number of times are abandoned.
+func (cli *Client) retryPackets(conn *connection, interval time.Duration, maxRetries int) {
+	defer conn.wg.Done()
+
+	ticker := time.NewTicker(interval * time.Second)
+
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+		case <-conn.retryEnd:
+			// End this function.
+			return
+		}
+
+		// Lock for reading and updating the Session.
+		cli.muSess.Lock()
+
+		var resends, abandoned []packet.Packet
+		var err error
+
+		if cli.sess != nil {
+			resends, abandoned, err = cli.sess.expiredPackets(interval*time.Second, maxRetries)
+		}
+
+		// Unlock.
+		cli.muSess.Unlock()
+
+		if err != nil {
+			// Handle the error and disconnect the Network Connection.
+			cli.handleErrorAndDisconn(err)
+
+			// End this function.
+			return
+		}
+
+		// Notify the abandoned flows to the handler.
+		if cli.onAbandon != nil {
+			for _, p := range abandoned {
+				cli.onAbandon(p)
+			}
+		}
+
+		// Resend the Packets to the Server.
+		for _, p := range resends {
+			cli.reportSendQueue(conn)
+
+			select {
+			case conn.send <- p:
+			case <-conn.retryEnd:
+				// End this function.
+				return
+			}
+		}
+	}
+}
@@ -0,0 +1,164 @@
+package client
+
+import (
+	"context"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/yosssi/gmq/mqtt"
+	"github.com/yosssi/gmq/mqtt/packet"
+)
+
+func TestClient_retryPackets(t *testing.T) {
+	firstc := make(chan byte, 2)
+
+	cli, ln := newTestClientWithOptions(t, &Options{
+		ErrorHandler: func(_ error) {},
+	}, &ConnectOptions{
+		ClientID:      []byte("clientID"),
+		RetryInterval: 1,
+	}, func(conn net.Conn) {
+		// Ignore the first PUBLISH Packet.
+		first, _, err := readTestPacket(conn)
+		if err != nil {
+			return
+		}
+
+		firstc <- first
+
+		// Acknowledge the resent PUBLISH Packet.
+		first, remaining, err := readTestPacket(conn)
+		if err != nil {
+			return
+		}
+
+		firstc <- first
+
+		n := 2 + (int(remaining[0])<<8 | int(remaining[1]))
+
+		conn.Write([]byte{packet.TypePUBACK << 4, 0x02, remaining[n], remaining[n+1]})
+
+		readTestPacket(conn)
+	})
+
+	defer ln.Close()
+
+	defer cli.Terminate()
+
+	tk, err := cli.PublishAsync(&PublishOptions{
+		QoS:       mqtt.QoS1,
+		TopicName: []byte("topicName"),
+		Message:   []byte("message"),
+	})
+	if err != nil {
+		nilErrorExpected(t, err)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	if err := tk.WaitContext(ctx); err != nil {
+		nilErrorExpected(t, err)
+		return
+	}
+
+	if first := <-firstc; first != 0x32 {
+		t.Errorf("first => 0x%02X, want => 0x32", first)
+	}
+
+	if first := <-firstc; first != 0x3A {
+		t.Errorf("first => 0x%02X, want => 0x3A", first)
+	}
+
+	if err := cli.Disconnect(); err != nil {
+		nilErrorExpected(t, err)
+	}
+}
+
+func TestClient_retryPackets_abandon(t *testing.T) {
+	abandonedc := make(chan packet.Packet, 1)
+
+	cli, ln := newTestClientWithOptions(t, &Options{
+		ErrorHandler: func(_ error) {},
+		OnAbandon: func(p packet.Packet) {
+			abandonedc <- p
+		},
+	}, &ConnectOptions{
+		ClientID:      []byte("clientID"),
+		RetryInterval: 1,
+		MaxRetries:    1,
+	}, func(conn net.Conn) {
+		// Never acknowledge the PUBLISH Packets.
+		for {
+			if _, _, err := readTestPacket(conn); err != nil {
+				return
+			}
+		}
+	})
+
+	defer ln.Close()
+
+	defer cli.Terminate()
+
+	tk, err := cli.PublishAsync(&PublishOptions{
+		QoS:       mqtt.QoS1,
+		TopicName: []byte("topicName"),
+		Message:   []byte("message"),
+	})
+	if err != nil {
+		nilErrorExpected(t, err)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	if err := tk.WaitContext(ctx); err != ErrAbandoned {
+		invalidError(t, err, ErrAbandoned)
+		return
+	}
+
+	select {
+	case p := <-abandonedc:
+		if publish, ok := p.(*packet.PUBLISH); !ok || publish.PacketID != 1 {
+			t.Errorf("p => %v, want => the PUBLISH Packet whose Packet Identifier is 1", p)
+		}
+	case <-time.After(time.Second):
+		t.Error("OnAbandon was not called")
+	}
+
+	cli.muSess.RLock()
+	n := len(cli.sess.sendingPackets)
+	cli.muSess.RUnlock()
+
+	if n != 0 {
+		t.Errorf("len(cli.sess.sendingPackets) => %d, want => 0", n)
+	}
+
+	if err := cli.Disconnect(); err != nil {
+		nilErrorExpected(t, err)
+	}
+}
+
+func TestClient_Connect_ErrRetryIntervalV5(t *testing.T) {
+	cli := New(&Options{
+		ErrorHandler: func(_ error) {},
+	})
+
+	defer cli.Terminate()
+
+	err := cli.Connect(&ConnectOptions{
+		ClientID:        []byte("clientID"),
+		ProtocolVersion: mqtt.ProtocolVersion5,
+		RetryInterval:   1,
+	})
+	if err != ErrRetryIntervalV5 {
+		invalidError(t, err, ErrRetryIntervalV5)
+	}
+
+	if state := cli.State(); state != StateDisconnected {
+		t.Errorf("cli.State() => %s, want => %s", state, StateDisconnected)
+	}
+}
@@ -0,0 +1,140 @@
+package client
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"net"
+	"net/url"
+)
+
+// Default ports by the schemes of the server URIs
+var defaultPorts = map[string]string{
+	"tcp":   "1883",
+	"mqtt":  "1883",
+	"ssl":   "8883",
+	"tls":   "8883",
+	"mqtts": "8883",
+	"ws":    "80",
+	"wss":   "443",
+}
+
+// Error values
+var (
+	ErrInvalidServerURI = errors.New("invalid server URI")
+)
+
+// Server returns the URI of the server which the Client has connected
+// to. It returns an empty string if the Client has not yet connected
+// to the Server.
+func (cli *Client) Server() string {
+	// Lock for reading.
+	cli.muConn.RLock()
+
+	// Unlock.
+	defer cli.muConn.RUnlock()
+
+	// Check the Network Connection.
+	if cli.conn == nil {
+		return ""
+	}
+
+	return cli.conn.server
+}
+
+// connectServers connects to the first server of the options which
+// accepts the connection. The servers are tried in turn from the one
+// which the Client connected to last or the next one if the connection
+// to it has been lost. The servers whose URIs are invalid are skipped.
+// The Network and the Address of the options are used if there is no
+// server. The error of the last server is returned if all of them
+// have failed.
+func (cli *Client) connectServers(ctx context.Context, opts *ConnectOptions) error {
+	if len(opts.Servers) == 0 {
+		return cli.handshake(ctx, opts, opts, opts.Network+"://"+opts.Address)
+	}
+
+	var err error
+
+	for i := range opts.Servers {
+		index := (cli.serverIndex + i) % len(opts.Servers)
+
+		// Create the options which connect to the server.
+		var serverOpts *ConnectOptions
+
+		serverOpts, err = serverOptions(opts, opts.Servers[index])
+		if err != nil {
+			continue
+		}
+
+		// Connect to the server.
+		err = cli.handshake(ctx, opts, serverOpts, opts.Servers[index])
+		if err == nil {
+			// Remember the server for the reconnection.
+			cli.serverIndex = index
+
+			return nil
+		}
+
+		// End the attempts if the context is done.
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+	}
+
+	return err
+}
+
+// skipServer makes the Client try the server next to
+// the one of the index first when it connects next time.
+func (cli *Client) skipServer(opts *ConnectOptions, index int) {
+	if len(opts.Servers) > 0 {
+		cli.serverIndex = (index + 1) % len(opts.Servers)
+	}
+}
+
+// serverOptions returns a copy of the options whose Network,
+// Address, TLSConfig and WebSocketPath are set by the server URI.
+func serverOptions(opts *ConnectOptions, server string) (*ConnectOptions, error) {
+	// Parse the server URI.
+	u, err := url.Parse(server)
+	if err != nil {
+		return nil, err
+	}
+
+	port, ok := defaultPorts[u.Scheme]
+	if !ok || u.Hostname() == "" {
+		return nil, ErrInvalidServerURI
+	}
+
+	// Copy the options not to modify the caller's ones.
+	copiedOpts := *opts
+
+	// Set the address with the default port of the scheme if necessary.
+	copiedOpts.Address = u.Host
+
+	if u.Port() == "" {
+		copiedOpts.Address = net.JoinHostPort(u.Hostname(), port)
+	}
+
+	// Set the network and the TLS configuration by the scheme.
+	switch u.Scheme {
+	case networkWS, networkWSS:
+		copiedOpts.Network = u.Scheme
+
+		if u.Path != "" {
+			copiedOpts.WebSocketPath = u.Path
+		}
+	case "ssl", "tls", "mqtts":
+		copiedOpts.Network = "tcp"
+
+		if copiedOpts.TLSConfig == nil {
+			copiedOpts.TLSConfig = &tls.Config{}
+		}
+	default:
+		copiedOpts.Network = "tcp"
+		copiedOpts.TLSConfig = nil
+	}
+
+	return &copiedOpts, nil
+}
@@ -0,0 +1,355 @@
+package client
+
+import (
+	"net"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/yosssi/gmq/mqtt/packet"
+)
+
+func Test_serverOptions(t *testing.T) {
+	testCases := []struct {
+		server        string
+		network       string
+		address       string
+		tls           bool
+		webSocketPath string
+	}{
+		{"tcp://localhost", "tcp", "localhost:1883", false, ""},
+		{"mqtt://localhost:1884", "tcp", "localhost:1884", false, ""},
+		{"ssl://localhost", "tcp", "localhost:8883", true, ""},
+		{"mqtts://localhost:8884", "tcp", "localhost:8884", true, ""},
+		{"ws://localhost", "ws", "localhost:80", false, ""},
+		{"wss://localhost:8443/ws", "wss", "localhost:8443", false, "/ws"},
+		{"tcp://[::1]", "tcp", "[::1]:1883", false, ""},
+	}
+
+	for _, tc := range testCases {
+		opts, err := serverOptions(&ConnectOptions{}, tc.server)
+		if err != nil {
+			nilErrorExpected(t, err)
+			continue
+		}
+
+		if opts.Network != tc.network || opts.Address != tc.address || (opts.TLSConfig != nil) != tc.tls || opts.WebSocketPath != tc.webSocketPath {
+			t.Errorf("serverOptions(%q) => %q, %q, %t, %q, want => %q, %q, %t, %q", tc.server, opts.Network, opts.Address, opts.TLSConfig != nil, opts.WebSocketPath, tc.network, tc.address, tc.tls, tc.webSocketPath)
+		}
+	}
+}
+
+func Test_serverOptions_ErrInvalidServerURI(t *testing.T) {
+	for _, server := range []string{"localhost:1883", "http://localhost", "tcp://"} {
+		if _, err := serverOptions(&ConnectOptions{}, server); err != ErrInvalidServerURI {
+			invalidError(t, err, ErrInvalidServerURI)
+		}
+	}
+}
+
+func Test_serverOptions_parseErr(t *testing.T) {
+	if _, err := serverOptions(&ConnectOptions{}, "tcp://%zz"); err == nil {
+		notNilErrorExpected(t)
+	}
+}
+
+func TestClient_Connect_servers(t *testing.T) {
+	// Get an address which refuses the connection.
+	closed, err := net.Listen("tcp", "localhost:0")
+	if err != nil {
+		nilErrorExpected(t, err)
+		return
+	}
+
+	closed.Close()
+
+	ln := newTestServer(t, func(conn net.Conn) {
+		defer conn.Close()
+
+		if _, _, err := readTestPacket(conn); err != nil {
+			return
+		}
+
+		conn.Write(testCONNACK)
+
+		readTestPacket(conn)
+	})
+
+	defer ln.Close()
+
+	cli := New(&Options{
+		ErrorHandler: func(_ error) {},
+	})
+
+	defer cli.Terminate()
+
+	servers := []string{
+		"tcp://" + closed.Addr().String(),
+		"tcp://" + ln.Addr().String(),
+	}
+
+	err = cli.Connect(&ConnectOptions{
+		Servers:  servers,
+		ClientID: []byte("clientID"),
+	})
+	if err != nil {
+		nilErrorExpected(t, err)
+		return
+	}
+
+	if server := cli.Server(); server != servers[1] {
+		t.Errorf("cli.Server() => %q, want => %q", server, servers[1])
+	}
+
+	if err := cli.Disconnect(); err != nil {
+		nilErrorExpected(t, err)
+	}
+
+	if server := cli.Server(); server != "" {
+		t.Errorf("cli.Server() => %q, want => %q", server, "")
+	}
+}
+
+func TestClient_Connect_serversRefused(t *testing.T) {
+	// The first server refuses the connection by the CONNACK Packet.
+	refusing := newTestServer(t, func(conn net.Conn) {
+		defer conn.Close()
+
+		if _, _, err := readTestPacket(conn); err != nil {
+			return
+		}
+
+		conn.Write([]byte{packet.TypeCONNACK << 4, 0x02, 0x00, 0x03})
+
+		readTestPacket(conn)
+	})
+
+	defer refusing.Close()
+
+	ln := newTestServer(t, func(conn net.Conn) {
+		defer conn.Close()
+
+		if _, _, err := readTestPacket(conn); err != nil {
+			return
+		}
+
+		conn.Write(testCONNACK)
+
+		readTestPacket(conn)
+	})
+
+	defer ln.Close()
+
+	cli := New(&Options{
+		ErrorHandler: func(_ error) {},
+	})
+
+	defer cli.Terminate()
+
+	servers := []string{
+		"tcp://" + refusing.Addr().String(),
+		"tcp://" + ln.Addr().String(),
+	}
+
+	err := cli.Connect(&ConnectOptions{
+		Servers:  servers,
+		ClientID: []byte("clientID"),
+	})
+	if err != nil {
+		nilErrorExpected(t, err)
+		return
+	}
+
+	if server := cli.Server(); server != servers[1] {
+		t.Errorf("cli.Server() => %q, want => %q", server, servers[1])
+	}
+
+	if err := cli.Disconnect(); err != nil {
+		nilErrorExpected(t, err)
+	}
+}
+
+func TestClient_Connect_serversAllRefused(t *testing.T) {
+	ln := newTestServer(t, func(conn net.Conn) {
+		defer conn.Close()
+
+		if _, _, err := readTestPacket(conn); err != nil {
+			return
+		}
+
+		conn.Write([]byte{packet.TypeCONNACK << 4, 0x02, 0x00, 0x03})
+
+		readTestPacket(conn)
+	})
+
+	defer ln.Close()
+
+	cli := New(&Options{
+		ErrorHandler: func(_ error) {},
+	})
+
+	defer cli.Terminate()
+
+	err := cli.Connect(&ConnectOptions{
+		Servers: []string{
+			"tcp://" + ln.Addr().String(),
+			"tcp://" + ln.Addr().String(),
+		},
+		ClientID: []byte("clientID"),
+	})
+	if err != ErrServerUnavailable {
+		invalidError(t, err, ErrServerUnavailable)
+	}
+
+	if state := cli.State(); state != StateDisconnected {
+		t.Errorf("cli.State() => %s, want => %s", state, StateDisconnected)
+	}
+}
+
+func TestClient_Connect_serversInvalidURI(t *testing.T) {
+	ln := newTestServer(t, func(conn net.Conn) {
+		defer conn.Close()
+
+		if _, _, err := readTestPacket(conn); err != nil {
+			return
+		}
+
+		conn.Write(testCONNACK)
+
+		readTestPacket(conn)
+	})
+
+	defer ln.Close()
+
+	cli := New(&Options{
+		ErrorHandler: func(_ error) {},
+	})
+
+	defer cli.Terminate()
+
+	servers := []string{
+		"http://localhost",
+		"tcp://" + ln.Addr().String(),
+	}
+
+	err := cli.Connect(&ConnectOptions{
+		Servers:  servers,
+		ClientID: []byte("clientID"),
+	})
+	if err != nil {
+		nilErrorExpected(t, err)
+		return
+	}
+
+	if server := cli.Server(); server != servers[1] {
+		t.Errorf("cli.Server() => %q, want => %q", server, servers[1])
+	}
+
+	if err := cli.Disconnect(); err != nil {
+		nilErrorExpected(t, err)
+	}
+}
+
+func TestClient_Connect_serversErr(t *testing.T) {
+	cli := New(&Options{
+		ErrorHandler: func(_ error) {},
+	})
+
+	defer cli.Terminate()
+
+	err := cli.Connect(&ConnectOptions{
+		Servers: []string{"http://localhost"},
+	})
+	if err != ErrInvalidServerURI {
+		invalidError(t, err, ErrInvalidServerURI)
+	}
+}
+
+func TestClient_failover(t *testing.T) {
+	// The primary server loses the Network Connection.
+	primary := newTestServer(t, func(conn net.Conn) {
+		defer conn.Close()
+
+		if _, _, err := readTestPacket(conn); err != nil {
+			return
+		}
+
+		conn.Write(testCONNACK)
+
+		// Keep the Network Connection while OnConnect is called.
+		time.Sleep(100 * time.Millisecond)
+	})
+
+	defer primary.Close()
+
+	secondary := newTestServer(t, func(conn net.Conn) {
+		defer conn.Close()
+
+		if _, _, err := readTestPacket(conn); err != nil {
+			return
+		}
+
+		conn.Write(testCONNACK)
+
+		readTestPacket(conn)
+	})
+
+	defer secondary.Close()
+
+	servers := []string{
+		"tcp://" + primary.Addr().String(),
+		"tcp://" + secondary.Addr().String(),
+	}
+
+	var mu sync.Mutex
+
+	var connected []string
+
+	var cli *Client
+
+	connectedc := make(chan struct{}, 2)
+
+	cli = New(&Options{
+		ErrorHandler: func(_ error) {},
+		OnConnect: func(_ bool) {
+			mu.Lock()
+			connected = append(connected, cli.Server())
+			mu.Unlock()
+
+			connectedc <- struct{}{}
+		},
+	})
+
+	defer cli.Terminate()
+
+	err := cli.Connect(&ConnectOptions{
+		Servers:       servers,
+		ClientID:      []byte("clientID"),
+		AutoReconnect: true,
+	})
+	if err != nil {
+		nilErrorExpected(t, err)
+		return
+	}
+
+	for i := 0; i < 2; i++ {
+		select {
+		case <-connectedc:
+		case <-time.After(5 * time.Second):
+			t.Error("the Client did not fail over to the secondary server")
+			return
+		}
+	}
+
+	mu.Lock()
+
+	if len(connected) != 2 || connected[0] != servers[0] || connected[1] != servers[1] {
+		t.Errorf("connected => %v, want => %v", connected, servers)
+	}
+
+	mu.Unlock()
+
+	if err := cli.Disconnect(); err != nil {
+		nilErrorExpected(t, err)
+	}
+}
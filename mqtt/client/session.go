@@ -1,6 +1,11 @@
 package client
 
-import "github.com/yosssi/gmq/mqtt/packet"
+import (
+	"sort"
+	"time"
+
+	"github.com/yosssi/gmq/mqtt/packet"
+)
 
 // session represents a Session which is a stateful interaction
 // between a Client and a Server.
@@ -12,9 +17,32 @@ type session struct {
 	// sendingPackets contains the pairs of the Packet Identifier
 	// and the Packet.
 	sendingPackets map[uint16]packet.Packet
+	// sendingTimes contains the pairs of the Packet Identifier
+	// and the time when the sending Packet was sent last.
+	sendingTimes map[uint16]time.Time
+	// sendingRetries contains the pairs of the Packet Identifier
+	// and the number of the resends of the sending Packet.
+	sendingRetries map[uint16]int
+	// sendingSeqs contains the pairs of the Packet Identifier and
+	// the sequence number which represents the order in which
+	// the sending Packet was set to the Session.
+	sendingSeqs map[uint16]uint64
+	// seq is the last sequence number.
+	seq uint64
 	// receivingPackets contains the pairs of the Packet Identifier
 	// and the Packet.
 	receivingPackets map[uint16]packet.Packet
+	// tokens contains the pairs of the Packet Identifier
+	// and the Token which is completed when the flow of
+	// the sending Packet completes.
+	tokens map[uint16]*Token
+	// store is the SessionStore which persists the Packets.
+	// It is nil if the Packets are not persisted.
+	store SessionStore
+	// metrics is the Metrics which collects the numbers of
+	// the sending and receiving Packets. It is nil if
+	// the metrics are not collected.
+	metrics Metrics
 }
 
 // newSession creates and returns a Session.
@@ -23,6 +51,212 @@ func newSession(cleanSession bool, clientID []byte) *session {
 		cleanSession:     cleanSession,
 		clientID:         clientID,
 		sendingPackets:   make(map[uint16]packet.Packet),
+		sendingTimes:     make(map[uint16]time.Time),
+		sendingRetries:   make(map[uint16]int),
+		sendingSeqs:      make(map[uint16]uint64),
 		receivingPackets: make(map[uint16]packet.Packet),
+		tokens:           make(map[uint16]*Token),
+	}
+}
+
+// completeToken completes the Token which has the Packet Identifier
+// and deletes it from the Session.
+func (sess *session) completeToken(id uint16, returnCodes []byte, err error) {
+	if t, exist := sess.tokens[id]; exist {
+		t.complete(returnCodes, err)
+
+		delete(sess.tokens, id)
+	}
+}
+
+// failTokens completes all Tokens with the error
+// and deletes them from the Session.
+func (sess *session) failTokens(err error) {
+	for id, t := range sess.tokens {
+		t.complete(nil, err)
+
+		delete(sess.tokens, id)
+	}
+}
+
+// load loads the stored Packets from the SessionStore.
+func (sess *session) load() error {
+	if sess.store == nil {
+		return nil
+	}
+
+	outgoing, incoming, err := sess.store.Load(sess.clientID)
+	if err != nil {
+		return err
+	}
+
+	sess.sendingPackets = make(map[uint16]packet.Packet)
+	sess.receivingPackets = make(map[uint16]packet.Packet)
+
+	// Regard the loaded Packets as sent now because they are resent
+	// when connecting. The order in which they were set is restored
+	// from their sequence numbers.
+	now := time.Now()
+
+	for id, sp := range outgoing {
+		sess.sendingPackets[id] = sp.Packet
+		sess.sendingTimes[id] = now
+		sess.sendingSeqs[id] = sp.Seq
+
+		if sp.Seq > sess.seq {
+			sess.seq = sp.Seq
+		}
+	}
+
+	for id, sp := range incoming {
+		sess.receivingPackets[id] = sp.Packet
+
+		if sp.Seq > sess.seq {
+			sess.seq = sp.Seq
+		}
+	}
+
+	// Update the metrics of the inflight Packets.
+	sess.updateInflight()
+
+	return nil
+}
+
+// setPacket sets the Packet to the sending or receiving Packets
+// and stores it to the SessionStore. The UNSUBSCRIBE Packet is
+// not stored because it can not be resent.
+func (sess *session) setPacket(dir Direction, id uint16, p packet.Packet) error {
+	// Order the Packet after the others. The PUBREL Packet which
+	// replaces the PUBLISH Packet is ordered by the arrival of
+	// the PUBREC Packet.
+	sess.seq++
+
+	if dir == Outgoing {
+		sess.sendingPackets[id] = p
+		sess.sendingTimes[id] = time.Now()
+		sess.sendingSeqs[id] = sess.seq
+		delete(sess.sendingRetries, id)
+	} else {
+		sess.receivingPackets[id] = p
+	}
+
+	// Update the metrics of the inflight Packets.
+	sess.updateInflight()
+
+	if sess.store == nil {
+		return nil
+	}
+
+	if _, unsubscribe := p.(*packet.UNSUBSCRIBE); unsubscribe {
+		return nil
+	}
+
+	return sess.store.Put(sess.clientID, dir, id, sess.seq, p)
+}
+
+// deletePacket deletes the Packet from the sending or receiving
+// Packets and the SessionStore.
+func (sess *session) deletePacket(dir Direction, id uint16) error {
+	if dir == Outgoing {
+		sess.forgetSendingPacket(id)
+	} else {
+		delete(sess.receivingPackets, id)
+	}
+
+	// Update the metrics of the inflight Packets.
+	sess.updateInflight()
+
+	if sess.store == nil {
+		return nil
+	}
+
+	return sess.store.Delete(sess.clientID, dir, id)
+}
+
+// forgetSendingPacket deletes the sending Packet from the Session
+// without deleting it from the SessionStore.
+func (sess *session) forgetSendingPacket(id uint16) {
+	delete(sess.sendingPackets, id)
+	delete(sess.sendingTimes, id)
+	delete(sess.sendingRetries, id)
+	delete(sess.sendingSeqs, id)
+}
+
+// sendingIDs returns the Packet Identifiers of the sending
+// Packets in the order in which they were set to the Session.
+func (sess *session) sendingIDs() []uint16 {
+	ids := make([]uint16, 0, len(sess.sendingPackets))
+
+	for id := range sess.sendingPackets {
+		ids = append(ids, id)
+	}
+
+	sort.Slice(ids, func(i, j int) bool {
+		return sess.sendingSeqs[ids[i]] < sess.sendingSeqs[ids[j]]
+	})
+
+	return ids
+}
+
+// expiredPackets returns the PUBLISH and PUBREL Packets which have not
+// been acknowledged within the interval and the ones whose number of
+// the resends has reached the maximum. The former are marked as resent
+// and the PUBLISH Packets among them are replaced with the duplicates.
+// The latter are deleted from the Session and their Tokens fail.
+func (sess *session) expiredPackets(interval time.Duration, maxRetries int) ([]packet.Packet, []packet.Packet, error) {
+	var resends, abandoned []packet.Packet
+
+	now := time.Now()
+
+	// Resend the Packets in the order in which they were set.
+	for _, id := range sess.sendingIDs() {
+		p := sess.sendingPackets[id]
+
+		// Skip the Packets which are not resent while connected.
+		switch p.(type) {
+		case *packet.PUBLISH, *packet.PUBREL:
+		default:
+			continue
+		}
+
+		// Skip the Packets which have been sent within the interval.
+		if now.Sub(sess.sendingTimes[id]) < interval {
+			continue
+		}
+
+		// Abandon the flow if the number of the resends has reached the maximum.
+		if maxRetries > 0 && sess.sendingRetries[id] >= maxRetries {
+			if err := sess.deletePacket(Outgoing, id); err != nil {
+				return nil, nil, err
+			}
+
+			sess.completeToken(id, nil, ErrAbandoned)
+
+			abandoned = append(abandoned, p)
+
+			continue
+		}
+
+		// Set the DUP flag of the PUBLISH Packet to true.
+		if publish, ok := p.(*packet.PUBLISH); ok && !publish.DUP {
+			p = publish.Duplicate()
+
+			sess.sendingPackets[id] = p
+		}
+
+		sess.sendingTimes[id] = now
+		sess.sendingRetries[id]++
+
+		resends = append(resends, p)
+	}
+
+	return resends, abandoned, nil
+}
+
+// updateInflight reports the numbers of the sending
+// and receiving Packets to the Metrics.
+func (sess *session) updateInflight() {
+	if sess.metrics != nil {
+		sess.metrics.Inflight(len(sess.sendingPackets), len(sess.receivingPackets))
 	}
 }
@@ -0,0 +1,164 @@
+package client
+
+import (
+	"sync"
+
+	"github.com/yosssi/gmq/mqtt/packet"
+)
+
+// Directions of the Packets
+const (
+	// Outgoing represents the Packets sent to the Server.
+	Outgoing Direction = iota
+	// Incoming represents the Packets sent from the Server.
+	Incoming
+)
+
+// Direction represents the direction of the Packets.
+type Direction byte
+
+// String returns the name of the direction.
+func (d Direction) String() string {
+	if d == Incoming {
+		return "in"
+	}
+
+	return "out"
+}
+
+// SessionStore is the interface which persists the unacknowledged
+// Packets of the Session so that they survive the restarts of
+// the process. The Outgoing Packets are the PUBLISH, PUBREL and
+// SUBSCRIBE Packets sent to the Server. The Incoming Packets are
+// the QoS 2 PUBLISH Packets sent from the Server. They are kept until
+// the PUBCOMP Packets are sent even after the PUBREL Packets release
+// them so that the Application Messages which have not been
+// acknowledged are handled again after the restart of the process.
+type SessionStore interface {
+	// Load returns the stored Packets of the Session of the Client
+	// Identifier by their Packet Identifiers.
+	Load(clientID []byte) (outgoing, incoming map[uint16]StoredPacket, err error)
+	// Put stores the Packet which has the Packet Identifier
+	// with its sequence number.
+	Put(clientID []byte, dir Direction, id uint16, seq uint64, p packet.Packet) error
+	// Delete deletes the Packet which has the Packet Identifier.
+	Delete(clientID []byte, dir Direction, id uint16) error
+	// Clear deletes all Packets of the Session of the Client Identifier.
+	Clear(clientID []byte) error
+}
+
+// StoredPacket represents a Packet stored in a SessionStore.
+type StoredPacket struct {
+	// Packet is the stored Packet.
+	Packet packet.Packet
+	// Seq is the sequence number which represents the order in which
+	// the Packet was set to the Session. The unacknowledged Packets
+	// are resent in this order after the restart of the process.
+	Seq uint64
+}
+
+// storedSession represents the Packets of a Session
+// stored in a MemorySessionStore.
+type storedSession struct {
+	// packets contains the Packets by their directions
+	// and Packet Identifiers.
+	packets [2]map[uint16]StoredPacket
+}
+
+// MemorySessionStore is a SessionStore which stores the Packets
+// in memory. The Session survives the reconnections and the
+// recreations of the Client in the same process.
+type MemorySessionStore struct {
+	// mu is the Mutex for sessions.
+	mu sync.Mutex
+	// sessions contains the stored Sessions by the Client Identifiers.
+	sessions map[string]*storedSession
+}
+
+// Load returns the stored Packets of the Session of the Client
+// Identifier by their Packet Identifiers.
+func (s *MemorySessionStore) Load(clientID []byte) (map[uint16]StoredPacket, map[uint16]StoredPacket, error) {
+	// Lock for reading.
+	s.mu.Lock()
+
+	// Unlock.
+	defer s.mu.Unlock()
+
+	// Copy the Packets.
+	outgoing := make(map[uint16]StoredPacket)
+	incoming := make(map[uint16]StoredPacket)
+
+	if sess, exist := s.sessions[string(clientID)]; exist {
+		for id, p := range sess.packets[Outgoing] {
+			outgoing[id] = p
+		}
+
+		for id, p := range sess.packets[Incoming] {
+			incoming[id] = p
+		}
+	}
+
+	return outgoing, incoming, nil
+}
+
+// Put stores the Packet which has the Packet Identifier
+// with its sequence number.
+func (s *MemorySessionStore) Put(clientID []byte, dir Direction, id uint16, seq uint64, p packet.Packet) error {
+	// Lock for update.
+	s.mu.Lock()
+
+	// Unlock.
+	defer s.mu.Unlock()
+
+	// Get or create the stored Session.
+	sess, exist := s.sessions[string(clientID)]
+	if !exist {
+		sess = &storedSession{
+			packets: [2]map[uint16]StoredPacket{
+				make(map[uint16]StoredPacket),
+				make(map[uint16]StoredPacket),
+			},
+		}
+
+		s.sessions[string(clientID)] = sess
+	}
+
+	sess.packets[dir][id] = StoredPacket{Packet: p, Seq: seq}
+
+	return nil
+}
+
+// Delete deletes the Packet which has the Packet Identifier.
+func (s *MemorySessionStore) Delete(clientID []byte, dir Direction, id uint16) error {
+	// Lock for update.
+	s.mu.Lock()
+
+	// Unlock.
+	defer s.mu.Unlock()
+
+	if sess, exist := s.sessions[string(clientID)]; exist {
+		delete(sess.packets[dir], id)
+	}
+
+	return nil
+}
+
+// Clear deletes all Packets of the Session of the Client Identifier.
+func (s *MemorySessionStore) Clear(clientID []byte) error {
+	// Lock for update.
+	s.mu.Lock()
+
+	// Unlock.
+	defer s.mu.Unlock()
+
+	delete(s.sessions, string(clientID))
+
+	return nil
+}
+
+// NewMemorySessionStore creates and returns a MemorySessionStore.
+func NewMemorySessionStore() *MemorySessionStore {
+	return &MemorySessionStore{
+		sessions: make(map[string]*storedSession),
+	}
+}
@@ -0,0 +1,210 @@
+package client
+
+import (
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/yosssi/gmq/mqtt"
+	"github.com/yosssi/gmq/mqtt/packet"
+)
+
+func TestMemorySessionStore(t *testing.T) {
+	s := NewMemorySessionStore()
+
+	clientID := []byte("clientID")
+
+	p := &packet.PUBREL{PacketID: 1}
+
+	if err := s.Put(clientID, Outgoing, 1, 1, p); err != nil {
+		nilErrorExpected(t, err)
+	}
+
+	if err := s.Put(clientID, Incoming, 2, 2, p); err != nil {
+		nilErrorExpected(t, err)
+	}
+
+	outgoing, incoming, err := s.Load(clientID)
+	if err != nil {
+		nilErrorExpected(t, err)
+		return
+	}
+
+	if len(outgoing) != 1 || outgoing[1] != (StoredPacket{Packet: p, Seq: 1}) {
+		t.Errorf("outgoing => %v, want => map[1:{%v 1}]", outgoing, p)
+	}
+
+	if len(incoming) != 1 || incoming[2] != (StoredPacket{Packet: p, Seq: 2}) {
+		t.Errorf("incoming => %v, want => map[2:{%v 2}]", incoming, p)
+	}
+
+	if err := s.Delete(clientID, Outgoing, 1); err != nil {
+		nilErrorExpected(t, err)
+	}
+
+	if outgoing, _, _ := s.Load(clientID); len(outgoing) != 0 {
+		t.Errorf("len(outgoing) => %d, want => 0", len(outgoing))
+	}
+
+	if err := s.Clear(clientID); err != nil {
+		nilErrorExpected(t, err)
+	}
+
+	if _, incoming, _ := s.Load(clientID); len(incoming) != 0 {
+		t.Errorf("len(incoming) => %d, want => 0", len(incoming))
+	}
+
+	if err := s.Delete(clientID, Incoming, 2); err != nil {
+		nilErrorExpected(t, err)
+	}
+}
+
+func TestClient_Connect_SessionStore(t *testing.T) {
+	store := NewFileSessionStore(t.TempDir())
+
+	clientID := []byte("clientID")
+
+	publish, err := packet.NewPUBLISH(&packet.PUBLISHOptions{
+		QoS:       mqtt.QoS1,
+		TopicName: []byte("a"),
+		PacketID:  1,
+		Message:   []byte("message"),
+	})
+	if err != nil {
+		nilErrorExpected(t, err)
+		return
+	}
+
+	if err := store.Put(clientID, Outgoing, 1, 1, publish); err != nil {
+		nilErrorExpected(t, err)
+		return
+	}
+
+	resentc := make(chan []byte, 1)
+
+	ln := newTestServer(t, func(conn net.Conn) {
+		defer conn.Close()
+
+		if _, _, err := readTestPacket(conn); err != nil {
+			return
+		}
+
+		conn.Write(testCONNACK)
+
+		// Read the resent PUBLISH Packet.
+		first, remaining, err := readTestPacket(conn)
+		if err != nil || first>>4 != packet.TypePUBLISH {
+			return
+		}
+
+		resentc <- remaining
+
+		conn.Write([]byte{packet.TypePUBACK << 4, 0x02, 0x00, 0x01})
+
+		readTestPacket(conn)
+	})
+
+	defer ln.Close()
+
+	cli := New(&Options{
+		ErrorHandler: func(_ error) {},
+		SessionStore: store,
+	})
+
+	defer cli.Terminate()
+
+	err = cli.Connect(&ConnectOptions{
+		Network:  "tcp",
+		Address:  ln.Addr().String(),
+		ClientID: clientID,
+	})
+	if err != nil {
+		nilErrorExpected(t, err)
+		return
+	}
+
+	defer cli.Disconnect()
+
+	select {
+	case remaining := <-resentc:
+		if string(remaining) != "\x00\x01a\x00\x01message" {
+			t.Errorf("remaining => %q, want => %q", remaining, "\x00\x01a\x00\x01message")
+		}
+	case <-time.After(5 * time.Second):
+		t.Error("the stored PUBLISH Packet was not resent")
+		return
+	}
+
+	for i := 0; i < 100; i++ {
+		if outgoing, _, _ := store.Load(clientID); len(outgoing) == 0 {
+			return
+		}
+
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	t.Error("the acknowledged PUBLISH Packet was not deleted from the SessionStore")
+}
+
+func TestClient_Connect_SessionStore_CleanSession(t *testing.T) {
+	store := NewMemorySessionStore()
+
+	clientID := []byte("clientID")
+
+	store.Put(clientID, Outgoing, 1, 1, &packet.PUBREL{PacketID: 1})
+
+	cli, ln := newTestClientWithOptions(t, &Options{
+		ErrorHandler: func(_ error) {},
+		SessionStore: store,
+	}, &ConnectOptions{
+		ClientID:     clientID,
+		CleanSession: true,
+	}, func(conn net.Conn) {
+		readTestPacket(conn)
+	})
+
+	defer ln.Close()
+	defer cli.Terminate()
+	defer cli.Disconnect()
+
+	if outgoing, _, _ := store.Load(clientID); len(outgoing) != 0 {
+		t.Errorf("len(outgoing) => %d, want => 0", len(outgoing))
+	}
+}
+
+func TestClient_Connect_SessionStore_loadErr(t *testing.T) {
+	store := NewFileSessionStore(t.TempDir())
+
+	clientID := []byte("clientID")
+
+	os.MkdirAll(store.sessionDir(clientID), 0700)
+	os.WriteFile(filepath.Join(store.sessionDir(clientID), "o-1"), nil, 0600)
+
+	ln := newTestServer(t, func(conn net.Conn) {
+		conn.Close()
+	})
+
+	defer ln.Close()
+
+	cli := New(&Options{
+		ErrorHandler: func(_ error) {},
+		SessionStore: store,
+	})
+
+	defer cli.Terminate()
+
+	err := cli.Connect(&ConnectOptions{
+		Network:  "tcp",
+		Address:  ln.Addr().String(),
+		ClientID: clientID,
+	})
+	if err != ErrInvalidStoredPacket {
+		invalidError(t, err, ErrInvalidStoredPacket)
+	}
+
+	if cli.sess != nil {
+		t.Error("cli.sess => not nil, want => nil")
+	}
+}
@@ -1,6 +1,13 @@
 package client
 
-import "testing"
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/yosssi/gmq/mqtt"
+	"github.com/yosssi/gmq/mqtt/packet"
+)
 
 func Test_newSession(t *testing.T) {
 	cleanSession := true
@@ -17,3 +24,187 @@ func Test_newSession(t *testing.T) {
 		t.Errorf("string(sess.clientID) => %s, want => %s", string(sess.clientID), clientIDStr)
 	}
 }
+
+func Test_session_setPacket_deletePacket(t *testing.T) {
+	sess := newSession(false, []byte("clientID"))
+
+	sess.store = NewMemorySessionStore()
+
+	pubrel := &packet.PUBREL{PacketID: 1}
+
+	if err := sess.setPacket(Outgoing, 1, pubrel); err != nil {
+		nilErrorExpected(t, err)
+	}
+
+	if err := sess.setPacket(Incoming, 1, pubrel); err != nil {
+		nilErrorExpected(t, err)
+	}
+
+	if err := sess.setPacket(Outgoing, 2, &packet.UNSUBSCRIBE{}); err != nil {
+		nilErrorExpected(t, err)
+	}
+
+	outgoing, incoming, _ := sess.store.Load(sess.clientID)
+
+	if len(outgoing) != 1 || len(incoming) != 1 {
+		t.Errorf("len(outgoing), len(incoming) => %d, %d, want => 1, 1", len(outgoing), len(incoming))
+	}
+
+	if len(sess.sendingPackets) != 2 {
+		t.Errorf("len(sess.sendingPackets) => %d, want => 2", len(sess.sendingPackets))
+	}
+
+	if err := sess.deletePacket(Outgoing, 1); err != nil {
+		nilErrorExpected(t, err)
+	}
+
+	if err := sess.deletePacket(Incoming, 1); err != nil {
+		nilErrorExpected(t, err)
+	}
+
+	outgoing, incoming, _ = sess.store.Load(sess.clientID)
+
+	if len(outgoing) != 0 || len(incoming) != 0 {
+		t.Errorf("len(outgoing), len(incoming) => %d, %d, want => 0, 0", len(outgoing), len(incoming))
+	}
+}
+
+func Test_session_load_storeNil(t *testing.T) {
+	sess := newSession(false, []byte("clientID"))
+
+	if err := sess.load(); err != nil {
+		nilErrorExpected(t, err)
+	}
+}
+
+func Test_session_expiredPackets(t *testing.T) {
+	sess := newSession(false, []byte("clientID"))
+
+	publish, err := packet.NewPUBLISH(&packet.PUBLISHOptions{
+		QoS:       mqtt.QoS1,
+		TopicName: []byte("topicName"),
+		PacketID:  1,
+	})
+	if err != nil {
+		nilErrorExpected(t, err)
+		return
+	}
+
+	sess.setPacket(Outgoing, 1, publish)
+	sess.setPacket(Outgoing, 2, &packet.PUBREL{PacketID: 2})
+	sess.setPacket(Outgoing, 3, &packet.SUBSCRIBE{PacketID: 3})
+
+	tk := newToken()
+
+	sess.tokens[2] = tk
+
+	// No Packet has expired within the interval.
+	resends, abandoned, err := sess.expiredPackets(time.Hour, 1)
+	if err != nil {
+		nilErrorExpected(t, err)
+		return
+	}
+
+	if len(resends) != 0 || len(abandoned) != 0 {
+		t.Errorf("len(resends), len(abandoned) => %d, %d, want => 0, 0", len(resends), len(abandoned))
+	}
+
+	// The PUBLISH and PUBREL Packets are resent.
+	resends, abandoned, _ = sess.expiredPackets(0, 1)
+
+	if len(resends) != 2 || len(abandoned) != 0 {
+		t.Errorf("len(resends), len(abandoned) => %d, %d, want => 2, 0", len(resends), len(abandoned))
+	}
+
+	if p := sess.sendingPackets[1].(*packet.PUBLISH); !p.DUP {
+		t.Error("the DUP flag of the resent PUBLISH Packet is not set")
+	}
+
+	// The flows are abandoned after the maximum number of the resends.
+	resends, abandoned, _ = sess.expiredPackets(0, 1)
+
+	if len(resends) != 0 || len(abandoned) != 2 {
+		t.Errorf("len(resends), len(abandoned) => %d, %d, want => 0, 2", len(resends), len(abandoned))
+	}
+
+	if len(sess.sendingPackets) != 1 || len(sess.sendingTimes) != 1 || len(sess.sendingRetries) != 0 {
+		t.Errorf("the abandoned Packets were not deleted: %v", sess.sendingPackets)
+	}
+
+	if err := tk.Err(); err != ErrAbandoned {
+		invalidError(t, err, ErrAbandoned)
+	}
+}
+
+func Test_session_sendingIDs(t *testing.T) {
+	sess := newSession(false, []byte("clientID"))
+
+	for _, id := range []uint16{3, 1, 2} {
+		sess.setPacket(Outgoing, id, &packet.PUBLISH{PacketID: id})
+	}
+
+	// The PUBREL Packet is ordered after the others.
+	sess.setPacket(Outgoing, 3, &packet.PUBREL{PacketID: 3})
+
+	sess.deletePacket(Outgoing, 1)
+
+	sess.setPacket(Outgoing, 1, &packet.PUBLISH{PacketID: 1})
+
+	want := []uint16{2, 3, 1}
+
+	if got := sess.sendingIDs(); !reflect.DeepEqual(got, want) {
+		t.Errorf("sess.sendingIDs() => %v, want => %v", got, want)
+	}
+}
+
+func Test_session_load_order(t *testing.T) {
+	for _, store := range []SessionStore{NewMemorySessionStore(), NewFileSessionStore(t.TempDir())} {
+		sess := newSession(false, []byte("clientID"))
+
+		sess.store = store
+
+		// Set the Packets whose Packet Identifiers are 1, 2, 3 and 1
+		// because 1 is released and reused.
+		for _, id := range []uint16{1, 2, 3} {
+			sess.setPacket(Outgoing, id, newTestPUBREL(id))
+		}
+
+		sess.deletePacket(Outgoing, 1)
+
+		sess.setPacket(Outgoing, 1, newTestPUBREL(1))
+
+		// Restart the process and load the Session.
+		sess = newSession(false, []byte("clientID"))
+
+		sess.store = store
+
+		if err := sess.load(); err != nil {
+			nilErrorExpected(t, err)
+			return
+		}
+
+		want := []uint16{2, 3, 1}
+
+		if got := sess.sendingIDs(); !reflect.DeepEqual(got, want) {
+			t.Errorf("%T: sess.sendingIDs() => %v, want => %v", store, got, want)
+		}
+
+		// The Packets set after the load are ordered after the loaded ones.
+		sess.deletePacket(Outgoing, 2)
+
+		sess.setPacket(Outgoing, 2, newTestPUBREL(2))
+
+		want = []uint16{3, 1, 2}
+
+		if got := sess.sendingIDs(); !reflect.DeepEqual(got, want) {
+			t.Errorf("%T: sess.sendingIDs() => %v, want => %v", store, got, want)
+		}
+	}
+}
+
+// newTestPUBREL creates and returns a PUBREL Packet
+// which has the Packet Identifier.
+func newTestPUBREL(id uint16) packet.Packet {
+	p, _ := packet.NewPUBREL(&packet.PUBRELOptions{PacketID: id})
+	return p
+}
@@ -0,0 +1,59 @@
+package client
+
+// State represents the state of the connection of the Client.
+type State int
+
+// States of the connection
+const (
+	// StateDisconnected represents that the Client has not
+	// connected to the Server.
+	StateDisconnected State = iota
+	// StateConnecting represents that the Client is establishing
+	// a Network Connection and is waiting for the CONNACK Packet.
+	StateConnecting
+	// StateConnected represents that the Server has accepted
+	// the connection.
+	StateConnected
+	// StateReconnecting represents that the Network Connection
+	// has been lost and the Client is reconnecting to the Server.
+	StateReconnecting
+)
+
+// Names of the states
+var stateNames = map[State]string{
+	StateDisconnected: "disconnected",
+	StateConnecting:   "connecting",
+	StateConnected:    "connected",
+	StateReconnecting: "reconnecting",
+}
+
+// String returns the name of the state.
+func (s State) String() string {
+	if name, ok := stateNames[s]; ok {
+		return name
+	}
+
+	return "unknown"
+}
+
+// State returns the state of the connection of the Client.
+func (cli *Client) State() State {
+	// Lock for reading.
+	cli.muState.RLock()
+
+	// Unlock.
+	defer cli.muState.RUnlock()
+
+	return cli.state
+}
+
+// setState sets the state of the connection of the Client.
+func (cli *Client) setState(s State) {
+	// Lock for updating.
+	cli.muState.Lock()
+
+	// Unlock.
+	defer cli.muState.Unlock()
+
+	cli.state = s
+}
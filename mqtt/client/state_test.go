@@ -0,0 +1,235 @@
+package client
+
+import (
+	"net"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestState_String(t *testing.T) {
+	testCases := []struct {
+		in   State
+		want string
+	}{
+		{in: StateDisconnected, want: "disconnected"},
+		{in: StateConnecting, want: "connecting"},
+		{in: StateConnected, want: "connected"},
+		{in: StateReconnecting, want: "reconnecting"},
+		{in: State(100), want: "unknown"},
+	}
+
+	for _, tc := range testCases {
+		if got := tc.in.String(); got != tc.want {
+			t.Errorf("State(%d).String() => %q, want => %q", tc.in, got, tc.want)
+		}
+	}
+}
+
+func TestClient_State(t *testing.T) {
+	var sessionPresents []bool
+
+	disconnectedc := make(chan struct{}, 1)
+
+	cli := New(&Options{
+		ErrorHandler: func(_ error) {},
+		OnConnect: func(sessionPresent bool) {
+			sessionPresents = append(sessionPresents, sessionPresent)
+		},
+		OnDisconnect: func() {
+			disconnectedc <- struct{}{}
+		},
+	})
+
+	defer cli.Terminate()
+
+	if s := cli.State(); s != StateDisconnected {
+		t.Errorf("cli.State() => %s, want => %s", s, StateDisconnected)
+	}
+
+	ln := newTestServer(t, func(conn net.Conn) {
+		defer conn.Close()
+
+		if _, _, err := readTestPacket(conn); err != nil {
+			return
+		}
+
+		conn.Write([]byte{0x20, 0x02, 0x01, 0x00})
+
+		readTestPacket(conn)
+	})
+
+	defer ln.Close()
+
+	err := cli.Connect(&ConnectOptions{
+		Network:  "tcp",
+		Address:  ln.Addr().String(),
+		ClientID: []byte("clientID"),
+	})
+	if err != nil {
+		nilErrorExpected(t, err)
+		return
+	}
+
+	if s := cli.State(); s != StateConnected {
+		t.Errorf("cli.State() => %s, want => %s", s, StateConnected)
+	}
+
+	if len(sessionPresents) != 1 || !sessionPresents[0] {
+		t.Errorf("sessionPresents => %v, want => [true]", sessionPresents)
+	}
+
+	if err := cli.Disconnect(); err != nil {
+		nilErrorExpected(t, err)
+	}
+
+	select {
+	case <-disconnectedc:
+	default:
+		t.Error("OnDisconnect was not called")
+	}
+
+	if s := cli.State(); s != StateDisconnected {
+		t.Errorf("cli.State() => %s, want => %s", s, StateDisconnected)
+	}
+}
+
+func TestClient_State_connectError(t *testing.T) {
+	cli := New(&Options{
+		ErrorHandler: func(_ error) {},
+		OnConnect: func(_ bool) {
+			t.Error("OnConnect was called")
+		},
+	})
+
+	defer cli.Terminate()
+
+	err := cli.Connect(&ConnectOptions{
+		Network: "tcp",
+		Address: "localhost:0",
+	})
+	if err == nil {
+		notNilErrorExpected(t)
+	}
+
+	if s := cli.State(); s != StateDisconnected {
+		t.Errorf("cli.State() => %s, want => %s", s, StateDisconnected)
+	}
+}
+
+func TestClient_OnConnectionLost(t *testing.T) {
+	var mu sync.Mutex
+
+	var accepted int
+
+	ln := newTestServer(t, func(conn net.Conn) {
+		mu.Lock()
+		accepted++
+		n := accepted
+		mu.Unlock()
+
+		defer conn.Close()
+
+		if _, _, err := readTestPacket(conn); err != nil {
+			return
+		}
+
+		conn.Write(testCONNACK)
+
+		// Lose the first Network Connection.
+		if n == 1 {
+			return
+		}
+
+		readTestPacket(conn)
+	})
+
+	defer ln.Close()
+
+	lostc := make(chan error, 1)
+	attemptc := make(chan int, 1)
+	connectedc := make(chan struct{}, 2)
+	disconnectedc := make(chan struct{}, 1)
+
+	cli := New(&Options{
+		ErrorHandler: func(_ error) {},
+		OnConnect: func(_ bool) {
+			connectedc <- struct{}{}
+		},
+		OnConnectionLost: func(err error) {
+			lostc <- err
+		},
+		OnReconnecting: func(attempt int) {
+			attemptc <- attempt
+		},
+		OnDisconnect: func() {
+			disconnectedc <- struct{}{}
+		},
+	})
+
+	defer cli.Terminate()
+
+	err := cli.Connect(&ConnectOptions{
+		Network:       "tcp",
+		Address:       ln.Addr().String(),
+		ClientID:      []byte("clientID"),
+		AutoReconnect: true,
+	})
+	if err != nil {
+		nilErrorExpected(t, err)
+		return
+	}
+
+	<-connectedc
+
+	select {
+	case err := <-lostc:
+		if err == nil {
+			notNilErrorExpected(t)
+		}
+	case <-time.After(5 * time.Second):
+		t.Error("OnConnectionLost was not called")
+		return
+	}
+
+	if s := cli.State(); s != StateReconnecting {
+		t.Errorf("cli.State() => %s, want => %s", s, StateReconnecting)
+	}
+
+	select {
+	case attempt := <-attemptc:
+		if attempt != 1 {
+			t.Errorf("attempt => %d, want => 1", attempt)
+		}
+	case <-time.After(5 * time.Second):
+		t.Error("OnReconnecting was not called")
+		return
+	}
+
+	select {
+	case <-connectedc:
+	case <-time.After(5 * time.Second):
+		t.Error("OnConnect was not called after reconnecting")
+		return
+	}
+
+	if s := cli.State(); s != StateConnected {
+		t.Errorf("cli.State() => %s, want => %s", s, StateConnected)
+	}
+
+	select {
+	case <-disconnectedc:
+		t.Error("OnDisconnect was called when the Network Connection was lost")
+	default:
+	}
+
+	if err := cli.Disconnect(); err != nil {
+		nilErrorExpected(t, err)
+	}
+
+	select {
+	case <-disconnectedc:
+	default:
+		t.Error("OnDisconnect was not called")
+	}
+}
@@ -6,7 +6,30 @@ type SubReq struct {
 	TopicFilter []byte
 	// QoS is the requsting QoS.
 	QoS byte
+	// NoLocal is the No Local option. It is used only in MQTT 5.0.
+	NoLocal bool
+	// RetainAsPublished is the Retain As Published option.
+	// It is used only in MQTT 5.0.
+	RetainAsPublished bool
+	// RetainHandling is the Retain Handling option.
+	// It is used only in MQTT 5.0.
+	RetainHandling byte
 	// Handler is the handler which handles the Application Message
 	// sent from the Server.
 	Handler MessageHandler
+	// OnMessage is the handler which handles the Application Message
+	// sent from the Server with the attributes of the PUBLISH Packet.
+	// It is executed instead of Handler if it is not nil.
+	OnMessage MessageFunc
+	// ManualAck is true if the Client holds back the PUBACK Packet
+	// of the QoS 1 Application Message or the PUBCOMP Packet of
+	// the QoS 2 Application Message until the Ack method of
+	// the Message is called in OnMessage.
+	ManualAck bool
+}
+
+// manualAck returns true if the handler requires
+// the manual acknowledgement.
+func (s *SubReq) manualAck() bool {
+	return s.ManualAck && s.OnMessage != nil
 }
@@ -1,8 +1,13 @@
 package client
 
+import "github.com/yosssi/gmq/mqtt/packet"
+
 // SubscribeOptions represents options for
 // the Subscribe method of the Client.
 type SubscribeOptions struct {
 	// SubReqs is a slice of the subscription requests.
 	SubReqs []*SubReq
+	// Properties is the Properties of the SUBSCRIBE Packet.
+	// It is used only in MQTT 5.0.
+	Properties *packet.Properties
 }
@@ -0,0 +1,94 @@
+package client
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// Token represents the completion of the flow of the MQTT Control Packets
+// which is started by the PublishAsync, SubscribeAsync or UnsubscribeAsync
+// method of the Client.
+type Token struct {
+	// donec is the channel which is closed when the flow completes.
+	donec chan struct{}
+	// once is used for completing the flow only once.
+	once sync.Once
+	// err is the error which causes the flow to fail.
+	err error
+	// returnCodes is the Return Codes of the SUBACK Packet
+	// or the Reason Codes of the MQTT 5.0 UNSUBACK Packet.
+	returnCodes []byte
+	// created is the time when the Token was created.
+	created time.Time
+}
+
+// Done returns the channel which is closed when the flow completes.
+func (t *Token) Done() <-chan struct{} {
+	return t.donec
+}
+
+// Wait waits until the flow completes and returns the error
+// which causes the flow to fail.
+func (t *Token) Wait() error {
+	<-t.donec
+
+	return t.err
+}
+
+// WaitContext waits until the flow completes or the context is done
+// and returns the error which causes the flow to fail or the error
+// of the context. The flow continues even if the context is done.
+func (t *Token) WaitContext(ctx context.Context) error {
+	select {
+	case <-t.donec:
+		return t.err
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// Err returns the error which causes the flow to fail.
+// It returns nil if the flow has not yet completed.
+func (t *Token) Err() error {
+	select {
+	case <-t.donec:
+		return t.err
+	default:
+		return nil
+	}
+}
+
+// ReturnCodes returns the Return Codes of the SUBACK Packet.
+// Each Return Code is the maximum QoS granted to the corresponding
+// subscription request or packet.SUBACKRetFailure. It returns nil
+// if the flow has not yet completed or the flow is not started by
+// the SubscribeAsync method. In MQTT 5.0, it also returns the Reason
+// Codes of the UNSUBACK Packet of the flow started by the
+// UnsubscribeAsync method.
+func (t *Token) ReturnCodes() []byte {
+	select {
+	case <-t.donec:
+		return t.returnCodes
+	default:
+		return nil
+	}
+}
+
+// complete completes the flow.
+func (t *Token) complete(returnCodes []byte, err error) {
+	t.once.Do(func() {
+		t.returnCodes = returnCodes
+		t.err = err
+
+		close(t.donec)
+	})
+}
+
+// newToken creates and returns a Token.
+func newToken() *Token {
+	return &Token{
+		donec:   make(chan struct{}),
+		created: time.Now(),
+	}
+}
@@ -0,0 +1,93 @@
+package client
+
+import (
+	"bytes"
+	"context"
+	"testing"
+
+	"github.com/yosssi/gmq/mqtt"
+	"github.com/yosssi/gmq/mqtt/packet"
+)
+
+func TestToken_Err_notCompleted(t *testing.T) {
+	tk := newToken()
+
+	if err := tk.Err(); err != nil {
+		nilErrorExpected(t, err)
+	}
+}
+
+func TestToken_Err(t *testing.T) {
+	tk := newToken()
+
+	tk.complete(nil, errTest)
+
+	if err := tk.Err(); err != errTest {
+		invalidError(t, err, errTest)
+	}
+}
+
+func TestToken_ReturnCodes_notCompleted(t *testing.T) {
+	tk := newToken()
+
+	if returnCodes := tk.ReturnCodes(); returnCodes != nil {
+		t.Errorf("returnCodes => %v, want => nil", returnCodes)
+	}
+}
+
+func TestToken_ReturnCodes(t *testing.T) {
+	tk := newToken()
+
+	want := []byte{mqtt.QoS1, packet.SUBACKRetFailure}
+
+	tk.complete(want, nil)
+
+	if returnCodes := tk.ReturnCodes(); !bytes.Equal(returnCodes, want) {
+		t.Errorf("returnCodes => %v, want => %v", returnCodes, want)
+	}
+}
+
+func TestToken_Wait(t *testing.T) {
+	tk := newToken()
+
+	go tk.complete(nil, errTest)
+
+	<-tk.Done()
+
+	if err := tk.Wait(); err != errTest {
+		invalidError(t, err, errTest)
+	}
+}
+
+func TestToken_complete_once(t *testing.T) {
+	tk := newToken()
+
+	tk.complete(nil, errTest)
+	tk.complete(nil, nil)
+
+	if err := tk.Wait(); err != errTest {
+		invalidError(t, err, errTest)
+	}
+}
+
+func TestToken_WaitContext(t *testing.T) {
+	tk := newToken()
+
+	tk.complete(nil, errTest)
+
+	if err := tk.WaitContext(context.Background()); err != errTest {
+		invalidError(t, err, errTest)
+	}
+}
+
+func TestToken_WaitContext_ctxDone(t *testing.T) {
+	tk := newToken()
+
+	ctx, cancel := context.WithCancel(context.Background())
+
+	cancel()
+
+	if err := tk.WaitContext(ctx); err != context.Canceled {
+		invalidError(t, err, context.Canceled)
+	}
+}
@@ -0,0 +1,49 @@
+package client
+
+import (
+	"fmt"
+	"io"
+	"sync"
+	"time"
+
+	"github.com/yosssi/gmq/mqtt/packet"
+)
+
+// Tracer observes the Packets which the Client sends to and receives
+// from the Server including the CONNECT, DISCONNECT, PINGREQ and PINGRESP
+// Packets. Trace is called with the time when each Packet was written to
+// or read from the Network Connection. It is called by the goroutines
+// of the Client, so it must be safe for concurrent use and should return
+// quickly. The Packet must not be retained after Trace returns because
+// its buffer may be reused if the receive buffers are pooled.
+type Tracer interface {
+	Trace(t time.Time, dir Direction, p packet.Packet)
+}
+
+// WriterTracer is a Tracer which writes a line
+// for each Packet to the writer.
+type WriterTracer struct {
+	// mu is the Mutex for the writer.
+	mu sync.Mutex
+	// w is the writer.
+	w io.Writer
+}
+
+// Trace writes a line which consists of the time, the direction
+// and the human-readable representation of the Packet.
+func (tr *WriterTracer) Trace(t time.Time, dir Direction, p packet.Packet) {
+	// Lock for writing.
+	tr.mu.Lock()
+
+	fmt.Fprintf(tr.w, "%s %s %v\n", t.Format(time.RFC3339Nano), dir, p)
+
+	// Unlock.
+	tr.mu.Unlock()
+}
+
+// NewWriterTracer creates and returns a WriterTracer.
+func NewWriterTracer(w io.Writer) *WriterTracer {
+	return &WriterTracer{
+		w: w,
+	}
+}
@@ -0,0 +1,110 @@
+package client
+
+import (
+	"bytes"
+	"fmt"
+	"net"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/yosssi/gmq/mqtt/packet"
+)
+
+// testTracer is a Tracer which records the traced Packets.
+type testTracer struct {
+	mu     sync.Mutex
+	traces []string
+	tracec chan struct{}
+}
+
+func (tr *testTracer) Trace(t time.Time, dir Direction, p packet.Packet) {
+	ptype, _ := p.Type()
+
+	tr.mu.Lock()
+	tr.traces = append(tr.traces, dir.String()+" "+strings.Fields(fmt.Sprint(p))[0])
+	tr.mu.Unlock()
+
+	if ptype == packet.TypePINGRESP {
+		tr.tracec <- struct{}{}
+	}
+}
+
+func (tr *testTracer) get() []string {
+	tr.mu.Lock()
+	defer tr.mu.Unlock()
+
+	return append([]string(nil), tr.traces...)
+}
+
+func TestDirection_String(t *testing.T) {
+	if got, want := Outgoing.String(), "out"; got != want {
+		t.Errorf("Outgoing.String() => %q, want => %q", got, want)
+	}
+
+	if got, want := Incoming.String(), "in"; got != want {
+		t.Errorf("Incoming.String() => %q, want => %q", got, want)
+	}
+}
+
+func TestWriterTracer_Trace(t *testing.T) {
+	var b bytes.Buffer
+
+	tr := NewWriterTracer(&b)
+
+	tr.Trace(time.Date(2015, 1, 2, 3, 4, 5, 0, time.UTC), Incoming, packet.NewPINGREQ())
+
+	if got, want := b.String(), "2015-01-02T03:04:05Z in PINGREQ protocolVersion=4 flags=0x00\n"; got != want {
+		t.Errorf("b.String() => %q, want => %q", got, want)
+	}
+}
+
+func TestClient_tracer(t *testing.T) {
+	tr := &testTracer{
+		tracec: make(chan struct{}, 1),
+	}
+
+	cli, ln := newTestClientWithOptions(t, &Options{
+		ErrorHandler: func(_ error) {},
+		Tracer:       tr,
+	}, &ConnectOptions{
+		ClientID:  []byte("clientID"),
+		KeepAlive: 1,
+	}, func(conn net.Conn) {
+		if _, _, err := readTestPacket(conn); err != nil {
+			return
+		}
+
+		conn.Write([]byte{packet.TypePINGRESP << 4, 0x00})
+
+		readTestPacket(conn)
+	})
+	defer ln.Close()
+	defer cli.Terminate()
+
+	select {
+	case <-tr.tracec:
+	case <-time.After(5 * time.Second):
+		t.Fatal("the PINGRESP Packet was not traced")
+	}
+
+	if err := cli.Disconnect(); err != nil {
+		nilErrorExpected(t, err)
+		return
+	}
+
+	want := []string{
+		"out CONNECT",
+		"in CONNACK",
+		"out PINGREQ",
+		"in PINGRESP",
+		"out DISCONNECT",
+	}
+
+	got := tr.get()
+
+	if strings.Join(got, ",") != strings.Join(want, ",") {
+		t.Errorf("traces => %v, want => %v", got, want)
+	}
+}
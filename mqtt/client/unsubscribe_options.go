@@ -1,8 +1,13 @@
 package client
 
+import "github.com/yosssi/gmq/mqtt/packet"
+
 // UnsubscribeOptions represents options for
 // the Unsubscribe method of the Client.
 type UnsubscribeOptions struct {
 	// TopicFilters represents a slice of the Topic Filters.
 	TopicFilters [][]byte
+	// Properties is the Properties of the UNSUBSCRIBE Packet.
+	// It is used only in MQTT 5.0.
+	Properties *packet.Properties
 }
@@ -0,0 +1,406 @@
+package client
+
+import (
+	"bufio"
+	"context"
+	"crypto/rand"
+	"crypto/sha1"
+	"crypto/tls"
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Networks for the WebSocket transport
+const (
+	networkWS  = "ws"
+	networkWSS = "wss"
+)
+
+// Default path of the WebSocket endpoint
+const defaultWebSocketPath = "/mqtt"
+
+// WebSocket subprotocol for MQTT
+const webSocketProtocol = "mqtt"
+
+// GUID which is used for calculating the Sec-WebSocket-Accept
+const webSocketGUID = "258EAFA5-E914-47DA-95CA-C5AB0DC85B11"
+
+// WebSocket opcodes
+const (
+	wsOpContinuation byte = 0x0
+	wsOpText         byte = 0x1
+	wsOpBinary       byte = 0x2
+	wsOpClose        byte = 0x8
+	wsOpPing         byte = 0x9
+	wsOpPong         byte = 0xA
+)
+
+// Maximum length of the payload of the WebSocket control frame
+const maxWSControlPayloadLen = 125
+
+// Timeout for writing the close frame
+const wsCloseTimeout = time.Second
+
+// Error values
+var (
+	ErrWebSocketHandshake  = errors.New("the WebSocket opening handshake failed")
+	ErrWebSocketTextFrame  = errors.New("a WebSocket text frame was received")
+	ErrInvalidWebSocketFrm = errors.New("invalid WebSocket frame")
+)
+
+// wsConn represents a WebSocket connection which transmits
+// the MQTT Control Packets in the binary frames.
+type wsConn struct {
+	net.Conn
+	// r is the buffered reader of the underlying connection.
+	r *bufio.Reader
+	// muWrite is the Mutex for writing frames.
+	muWrite sync.Mutex
+	// remaining is the length of the unread payload
+	// of the current frame.
+	remaining uint64
+}
+
+// Read reads the payload of the binary frames.
+func (c *wsConn) Read(b []byte) (int, error) {
+	for c.remaining == 0 {
+		// Read the next frame header.
+		opcode, length, err := c.readFrameHeader()
+		if err != nil {
+			return 0, err
+		}
+
+		switch opcode {
+		case wsOpBinary, wsOpContinuation:
+			c.remaining = length
+		case wsOpText:
+			return 0, ErrWebSocketTextFrame
+		default:
+			// Handle the control frame.
+			if err := c.handleControlFrame(opcode, length); err != nil {
+				return 0, err
+			}
+		}
+	}
+
+	// Limit the length to the remaining of the payload.
+	if uint64(len(b)) > c.remaining {
+		b = b[:c.remaining]
+	}
+
+	n, err := c.r.Read(b)
+
+	c.remaining -= uint64(n)
+
+	return n, err
+}
+
+// Write writes the data as a binary frame.
+func (c *wsConn) Write(b []byte) (int, error) {
+	if err := c.writeFrame(wsOpBinary, b); err != nil {
+		return 0, err
+	}
+
+	return len(b), nil
+}
+
+// Close sends a close frame and closes the underlying connection.
+func (c *wsConn) Close() error {
+	// Set the deadline so as not to block when the Server
+	// does not read the close frame.
+	c.Conn.SetWriteDeadline(time.Now().Add(wsCloseTimeout))
+
+	// Ignore the error because the underlying connection
+	// is closed regardless of it.
+	c.writeFrame(wsOpClose, nil)
+
+	return c.Conn.Close()
+}
+
+// readFrameHeader reads the header of the frame and returns
+// its opcode and the length of its payload.
+func (c *wsConn) readFrameHeader() (byte, uint64, error) {
+	// Read the first two bytes.
+	var h [8]byte
+
+	if _, err := io.ReadFull(c.r, h[:2]); err != nil {
+		return 0, 0, err
+	}
+
+	opcode := h[0] & 0x0F
+
+	// Return an error if the frame is masked because
+	// the Server must not mask the frames.
+	if h[1]&0x80 != 0 {
+		return 0, 0, ErrInvalidWebSocketFrm
+	}
+
+	// Decode the payload length.
+	length := uint64(h[1] & 0x7F)
+
+	switch length {
+	case 126:
+		if _, err := io.ReadFull(c.r, h[:2]); err != nil {
+			return 0, 0, err
+		}
+
+		length = uint64(binary.BigEndian.Uint16(h[:2]))
+	case 127:
+		if _, err := io.ReadFull(c.r, h[:8]); err != nil {
+			return 0, 0, err
+		}
+
+		length = binary.BigEndian.Uint64(h[:8])
+	}
+
+	return opcode, length, nil
+}
+
+// handleControlFrame reads the payload of the control frame
+// and handles it.
+func (c *wsConn) handleControlFrame(opcode byte, length uint64) error {
+	// Check the length of the payload.
+	if length > maxWSControlPayloadLen {
+		return ErrInvalidWebSocketFrm
+	}
+
+	// Read the payload.
+	payload := make([]byte, length)
+
+	if _, err := io.ReadFull(c.r, payload); err != nil {
+		return err
+	}
+
+	switch opcode {
+	case wsOpClose:
+		// Reply the close frame and end reading.
+		c.writeFrame(wsOpClose, payload)
+
+		return io.EOF
+	case wsOpPing:
+		// Reply the pong frame.
+		return c.writeFrame(wsOpPong, payload)
+	case wsOpPong:
+		return nil
+	default:
+		return ErrInvalidWebSocketFrm
+	}
+}
+
+// writeFrame writes a masked frame which has the opcode and the payload.
+func (c *wsConn) writeFrame(opcode byte, payload []byte) error {
+	// Create the frame header.
+	frame := make([]byte, 0, 14+len(payload))
+
+	frame = append(frame, 0x80|opcode)
+
+	l := len(payload)
+
+	switch {
+	case l <= 125:
+		frame = append(frame, 0x80|byte(l))
+	case l <= 0xFFFF:
+		frame = append(frame, 0x80|126, byte(l>>8), byte(l))
+	default:
+		frame = append(frame, 0x80|127)
+		frame = binary.BigEndian.AppendUint64(frame, uint64(l))
+	}
+
+	// Generate a masking key.
+	var key [4]byte
+
+	if _, err := rand.Read(key[:]); err != nil {
+		return err
+	}
+
+	frame = append(frame, key[:]...)
+
+	// Append the masked payload.
+	for i, b := range payload {
+		frame = append(frame, b^key[i%4])
+	}
+
+	// Lock for writing.
+	c.muWrite.Lock()
+
+	// Unlock.
+	defer c.muWrite.Unlock()
+
+	_, err := c.Conn.Write(frame)
+
+	return err
+}
+
+// dialWebSocket establishes a WebSocket connection to the address
+// of the options and performs the opening handshake.
+func dialWebSocket(ctx context.Context, opts *ConnectOptions) (net.Conn, error) {
+	// Use TLS for the "wss" network.
+	var tlsConfig *tls.Config
+
+	if opts.Network == networkWSS {
+		tlsConfig = opts.TLSConfig
+
+		if tlsConfig == nil {
+			tlsConfig = &tls.Config{}
+		}
+	}
+
+	// Establish the underlying connection.
+	conn, err := dial(ctx, opts.Dialer, "tcp", opts.Address, tlsConfig)
+	if err != nil {
+		return nil, err
+	}
+
+	// Limit the opening handshake by the CONNACK timeout because
+	// the timeout of the CONNACK Packet starts after it.
+	if opts.CONNACKTimeout > 0 {
+		var cancel context.CancelFunc
+
+		ctx, cancel = context.WithTimeout(ctx, opts.CONNACKTimeout*time.Second)
+
+		defer cancel()
+	}
+
+	// Perform the opening handshake.
+	c, err := handshakeWebSocket(ctx, conn, opts.Network, opts.Address, opts.WebSocketPath, opts.WebSocketHeader)
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+
+	return c, nil
+}
+
+// handshakeWebSocket performs the opening handshake of the WebSocket
+// over the connection and returns the WebSocket connection. The context
+// is used for the deadline and the cancellation of the handshake.
+func handshakeWebSocket(ctx context.Context, conn net.Conn, network, address, path string, header http.Header) (net.Conn, error) {
+	// Set the default path.
+	if path == "" {
+		path = defaultWebSocketPath
+	}
+
+	// Create the URL of the WebSocket endpoint.
+	scheme := "http"
+
+	if network == networkWSS {
+		scheme = "https"
+	}
+
+	u, err := url.Parse(scheme + "://" + address + path)
+	if err != nil {
+		return nil, err
+	}
+
+	// Generate the Sec-WebSocket-Key.
+	var nonce [16]byte
+
+	if _, err := rand.Read(nonce[:]); err != nil {
+		return nil, err
+	}
+
+	key := base64.StdEncoding.EncodeToString(nonce[:])
+
+	// Create an HTTP request for the opening handshake.
+	req := &http.Request{
+		Method:     http.MethodGet,
+		URL:        u,
+		Proto:      "HTTP/1.1",
+		ProtoMajor: 1,
+		ProtoMinor: 1,
+		Header:     make(http.Header),
+		Host:       u.Host,
+	}
+
+	for k, v := range header {
+		req.Header[k] = v
+	}
+
+	req.Header.Set("Upgrade", "websocket")
+	req.Header.Set("Connection", "Upgrade")
+	req.Header.Set("Sec-WebSocket-Key", key)
+	req.Header.Set("Sec-WebSocket-Version", "13")
+	req.Header.Set("Sec-WebSocket-Protocol", webSocketProtocol)
+
+	// Set the deadline of the context to the connection.
+	if deadline, ok := ctx.Deadline(); ok {
+		conn.SetDeadline(deadline)
+	}
+
+	// Close the connection to interrupt the handshake
+	// when the context is done.
+	stopc := make(chan struct{})
+	endc := make(chan struct{})
+
+	go func() {
+		defer close(endc)
+
+		select {
+		case <-ctx.Done():
+			conn.Close()
+		case <-stopc:
+		}
+	}()
+
+	// Send the request and receive the response.
+	r := bufio.NewReader(conn)
+
+	res, err := writeWebSocketRequest(conn, r, req)
+
+	// Stop watching the context and wait until the goroutine ends.
+	close(stopc)
+	<-endc
+
+	// Return the error of the context if it is done.
+	if ctx.Err() != nil {
+		return nil, ctx.Err()
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	// Clear the deadline.
+	conn.SetDeadline(time.Time{})
+
+	// Validate the response.
+	if res.StatusCode != http.StatusSwitchingProtocols ||
+		!strings.EqualFold(res.Header.Get("Upgrade"), "websocket") ||
+		!strings.EqualFold(res.Header.Get("Connection"), "Upgrade") ||
+		res.Header.Get("Sec-WebSocket-Accept") != webSocketAccept(key) ||
+		res.Header.Get("Sec-WebSocket-Protocol") != webSocketProtocol {
+		return nil, ErrWebSocketHandshake
+	}
+
+	return &wsConn{
+		Conn: conn,
+		r:    r,
+	}, nil
+}
+
+// writeWebSocketRequest sends the request of the opening handshake
+// and receives its response from the reader of the connection.
+func writeWebSocketRequest(conn net.Conn, r *bufio.Reader, req *http.Request) (*http.Response, error) {
+	// Send the request.
+	if err := req.Write(conn); err != nil {
+		return nil, err
+	}
+
+	// Receive the response.
+	return http.ReadResponse(r, req)
+}
+
+// webSocketAccept calculates and returns the Sec-WebSocket-Accept
+// which corresponds to the Sec-WebSocket-Key.
+func webSocketAccept(key string) string {
+	h := sha1.Sum([]byte(key + webSocketGUID))
+	return base64.StdEncoding.EncodeToString(h[:])
+}
@@ -0,0 +1,602 @@
+package client
+
+import (
+	"bufio"
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/binary"
+	"io"
+	"log"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/yosssi/gmq/mqtt"
+	"github.com/yosssi/gmq/mqtt/packet"
+)
+
+// testWSServerConn is the server side of a WebSocket connection.
+type testWSServerConn struct {
+	net.Conn
+	r         *bufio.Reader
+	remaining uint64
+	key       [4]byte
+	pos       int
+}
+
+// Read reads the payload of the masked frames sent by the Client.
+func (c *testWSServerConn) Read(b []byte) (int, error) {
+	for c.remaining == 0 {
+		var h [8]byte
+
+		if _, err := io.ReadFull(c.r, h[:2]); err != nil {
+			return 0, err
+		}
+
+		opcode := h[0] & 0x0F
+
+		length := uint64(h[1] & 0x7F)
+
+		switch length {
+		case 126:
+			if _, err := io.ReadFull(c.r, h[:2]); err != nil {
+				return 0, err
+			}
+
+			length = uint64(binary.BigEndian.Uint16(h[:2]))
+		case 127:
+			if _, err := io.ReadFull(c.r, h[:8]); err != nil {
+				return 0, err
+			}
+
+			length = binary.BigEndian.Uint64(h[:8])
+		}
+
+		if _, err := io.ReadFull(c.r, c.key[:]); err != nil {
+			return 0, err
+		}
+
+		if opcode != wsOpBinary && opcode != wsOpContinuation {
+			io.CopyN(io.Discard, c.r, int64(length))
+			continue
+		}
+
+		c.remaining = length
+		c.pos = 0
+	}
+
+	if uint64(len(b)) > c.remaining {
+		b = b[:c.remaining]
+	}
+
+	n, err := c.r.Read(b)
+
+	for i := 0; i < n; i++ {
+		b[i] ^= c.key[c.pos%4]
+		c.pos++
+	}
+
+	c.remaining -= uint64(n)
+
+	return n, err
+}
+
+// Write writes the data as an unmasked binary frame.
+func (c *testWSServerConn) Write(b []byte) (int, error) {
+	if _, err := c.Conn.Write(testWSFrame(wsOpBinary, b)); err != nil {
+		return 0, err
+	}
+
+	return len(b), nil
+}
+
+// testWSFrame creates an unmasked frame.
+func testWSFrame(opcode byte, payload []byte) []byte {
+	frame := []byte{0x80 | opcode}
+
+	l := len(payload)
+
+	switch {
+	case l <= 125:
+		frame = append(frame, byte(l))
+	case l <= 0xFFFF:
+		frame = append(frame, 126, byte(l>>8), byte(l))
+	default:
+		frame = append(frame, 127)
+		frame = binary.BigEndian.AppendUint64(frame, uint64(l))
+	}
+
+	return append(frame, payload...)
+}
+
+// newTestTLSServer starts and returns an HTTPS server which does not
+// log the errors of the TLS handshakes interrupted by closing it.
+func newTestTLSServer(handler http.Handler) *httptest.Server {
+	srv := httptest.NewUnstartedServer(handler)
+
+	srv.Config.ErrorLog = log.New(io.Discard, "", 0)
+
+	srv.StartTLS()
+
+	return srv
+}
+
+// newTestWSHandler returns an HTTP handler which performs
+// the opening handshake and handles the WebSocket connection
+// by the handler.
+func newTestWSHandler(t *testing.T, reqc chan<- *http.Request, handler func(conn net.Conn)) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if reqc != nil {
+			reqc <- r
+		}
+
+		conn, rw, err := w.(http.Hijacker).Hijack()
+		if err != nil {
+			t.Errorf("err => %q, want => nil", err)
+			return
+		}
+
+		defer conn.Close()
+
+		rw.WriteString("HTTP/1.1 101 Switching Protocols\r\n" +
+			"Upgrade: websocket\r\n" +
+			"Connection: Upgrade\r\n" +
+			"Sec-WebSocket-Accept: " + webSocketAccept(r.Header.Get("Sec-WebSocket-Key")) + "\r\n" +
+			"Sec-WebSocket-Protocol: " + webSocketProtocol + "\r\n\r\n")
+		rw.Flush()
+
+		handler(&testWSServerConn{
+			Conn: conn,
+			r:    rw.Reader,
+		})
+	})
+}
+
+func TestClient_Connect_webSocket(t *testing.T) {
+	reqc := make(chan *http.Request, 1)
+
+	publishedc := make(chan string, 1)
+
+	srv := httptest.NewServer(newTestWSHandler(t, reqc, func(conn net.Conn) {
+		// Read the CONNECT Packet.
+		if _, _, err := readTestPacket(conn); err != nil {
+			return
+		}
+
+		conn.Write(testCONNACK)
+
+		// Read the PUBLISH Packet.
+		first, remaining, err := readTestPacket(conn)
+		if err != nil || first>>4 != packet.TypePUBLISH {
+			return
+		}
+
+		publishedc <- string(remaining[2:])
+
+		readTestPacket(conn)
+	}))
+
+	defer srv.Close()
+
+	cli := New(&Options{
+		ErrorHandler: func(_ error) {},
+	})
+
+	defer cli.Terminate()
+
+	err := cli.Connect(&ConnectOptions{
+		Network:         "ws",
+		Address:         srv.Listener.Addr().String(),
+		WebSocketPath:   "/path",
+		WebSocketHeader: http.Header{"X-Test": []string{"test"}},
+		ClientID:        []byte("clientID"),
+	})
+	if err != nil {
+		nilErrorExpected(t, err)
+		return
+	}
+
+	r := <-reqc
+
+	if r.URL.Path != "/path" {
+		t.Errorf("r.URL.Path => %q, want => %q", r.URL.Path, "/path")
+	}
+
+	if h := r.Header.Get("X-Test"); h != "test" {
+		t.Errorf(`r.Header.Get("X-Test") => %q, want => %q`, h, "test")
+	}
+
+	if p := r.Header.Get("Sec-WebSocket-Protocol"); p != webSocketProtocol {
+		t.Errorf(`r.Header.Get("Sec-WebSocket-Protocol") => %q, want => %q`, p, webSocketProtocol)
+	}
+
+	err = cli.Publish(&PublishOptions{
+		QoS:       mqtt.QoS0,
+		TopicName: []byte("a"),
+		Message:   []byte("message"),
+	})
+	if err != nil {
+		nilErrorExpected(t, err)
+		return
+	}
+
+	select {
+	case s := <-publishedc:
+		if want := "amessage"; s != want {
+			t.Errorf("s => %q, want => %q", s, want)
+		}
+	case <-time.After(5 * time.Second):
+		t.Error("the PUBLISH Packet was not received")
+	}
+
+	if err := cli.Disconnect(); err != nil {
+		nilErrorExpected(t, err)
+	}
+}
+
+func TestClient_Connect_webSocketTLS(t *testing.T) {
+	reqc := make(chan *http.Request, 1)
+
+	srv := newTestTLSServer(newTestWSHandler(t, reqc, func(conn net.Conn) {
+		if _, _, err := readTestPacket(conn); err != nil {
+			return
+		}
+
+		conn.Write(testCONNACK)
+
+		readTestPacket(conn)
+	}))
+
+	defer srv.Close()
+
+	pool := x509.NewCertPool()
+	pool.AddCert(srv.Certificate())
+
+	cli := New(&Options{
+		ErrorHandler: func(_ error) {},
+	})
+
+	defer cli.Terminate()
+
+	err := cli.Connect(&ConnectOptions{
+		Network:   "wss",
+		Address:   srv.Listener.Addr().String(),
+		TLSConfig: &tls.Config{RootCAs: pool},
+		ClientID:  []byte("clientID"),
+	})
+	if err != nil {
+		nilErrorExpected(t, err)
+		return
+	}
+
+	r := <-reqc
+
+	if r.URL.Path != defaultWebSocketPath {
+		t.Errorf("r.URL.Path => %q, want => %q", r.URL.Path, defaultWebSocketPath)
+	}
+
+	if err := cli.Disconnect(); err != nil {
+		nilErrorExpected(t, err)
+	}
+}
+
+func TestClient_Connect_webSocketHandshakeErr(t *testing.T) {
+	srv := httptest.NewServer(http.NotFoundHandler())
+
+	defer srv.Close()
+
+	cli := New(&Options{
+		ErrorHandler: func(_ error) {},
+	})
+
+	defer cli.Terminate()
+
+	err := cli.Connect(&ConnectOptions{
+		Network:  "ws",
+		Address:  srv.Listener.Addr().String(),
+		ClientID: []byte("clientID"),
+	})
+	if err != ErrWebSocketHandshake {
+		invalidError(t, err, ErrWebSocketHandshake)
+	}
+}
+
+func Test_dialWebSocket_dialErr(t *testing.T) {
+	ln := newTestServer(t, func(conn net.Conn) {
+		conn.Close()
+	})
+
+	addr := ln.Addr().String()
+
+	ln.Close()
+
+	if _, err := dialWebSocket(context.Background(), &ConnectOptions{Network: networkWS, Address: addr}); err == nil {
+		notNilErrorExpected(t)
+	}
+}
+
+func Test_handshakeWebSocket_invalidAccept(t *testing.T) {
+	cliConn, srvConn := net.Pipe()
+
+	defer cliConn.Close()
+
+	go func() {
+		defer srvConn.Close()
+
+		r := bufio.NewReader(srvConn)
+
+		if _, err := http.ReadRequest(r); err != nil {
+			return
+		}
+
+		srvConn.Write([]byte("HTTP/1.1 101 Switching Protocols\r\n" +
+			"Upgrade: websocket\r\n" +
+			"Connection: Upgrade\r\n" +
+			"Sec-WebSocket-Accept: invalid\r\n" +
+			"Sec-WebSocket-Protocol: mqtt\r\n\r\n"))
+	}()
+
+	if _, err := handshakeWebSocket(context.Background(), cliConn, networkWS, "localhost", "", nil); err != ErrWebSocketHandshake {
+		invalidError(t, err, ErrWebSocketHandshake)
+	}
+}
+
+func Test_handshakeWebSocket_invalidAddress(t *testing.T) {
+	cliConn, srvConn := net.Pipe()
+
+	defer cliConn.Close()
+	defer srvConn.Close()
+
+	if _, err := handshakeWebSocket(context.Background(), cliConn, networkWS, "local host:%", "", nil); err == nil {
+		notNilErrorExpected(t)
+	}
+}
+
+// newTestWSConn creates a pair of a wsConn and the server side
+// of the Network Connection.
+func newTestWSConn() (*wsConn, net.Conn) {
+	cliConn, srvConn := net.Pipe()
+
+	return &wsConn{
+		Conn: cliConn,
+		r:    bufio.NewReader(cliConn),
+	}, srvConn
+}
+
+func TestWSConn_Read_frames(t *testing.T) {
+	c, srvConn := newTestWSConn()
+
+	defer c.Close()
+	defer srvConn.Close()
+
+	long := strings.Repeat("a", 200)
+	longer := strings.Repeat("b", 0x10000)
+
+	go func() {
+		srvConn.Write(testWSFrame(wsOpBinary, []byte("ab")))
+		srvConn.Write(testWSFrame(wsOpPong, nil))
+		srvConn.Write(testWSFrame(wsOpContinuation, []byte("cd")))
+		srvConn.Write(testWSFrame(wsOpBinary, []byte(long)))
+		srvConn.Write(testWSFrame(wsOpBinary, []byte(longer)))
+	}()
+
+	want := "abcd" + long + longer
+
+	b := make([]byte, len(want))
+
+	if _, err := io.ReadFull(c, b); err != nil {
+		nilErrorExpected(t, err)
+		return
+	}
+
+	if string(b) != want {
+		t.Error("the payload of the frames was not read")
+	}
+}
+
+func TestWSConn_Read_ping(t *testing.T) {
+	c, srvConn := newTestWSConn()
+
+	defer c.Close()
+	defer srvConn.Close()
+
+	go srvConn.Write(testWSFrame(wsOpPing, []byte("ping")))
+
+	go c.Read(make([]byte, 1))
+
+	s := &testWSServerConn{
+		Conn: srvConn,
+		r:    bufio.NewReader(srvConn),
+	}
+
+	var h [2]byte
+
+	if _, err := io.ReadFull(s.r, h[:]); err != nil {
+		nilErrorExpected(t, err)
+		return
+	}
+
+	if opcode := h[0] & 0x0F; opcode != wsOpPong {
+		t.Errorf("opcode => %d, want => %d", opcode, wsOpPong)
+	}
+
+	if h[1]&0x80 == 0 {
+		t.Error("the pong frame was not masked")
+	}
+}
+
+func TestWSConn_Read_close(t *testing.T) {
+	c, srvConn := newTestWSConn()
+
+	defer c.Close()
+	defer srvConn.Close()
+
+	go func() {
+		srvConn.Write(testWSFrame(wsOpClose, nil))
+		io.Copy(io.Discard, srvConn)
+	}()
+
+	if _, err := c.Read(make([]byte, 1)); err != io.EOF {
+		invalidError(t, err, io.EOF)
+	}
+}
+
+func TestWSConn_Read_ErrWebSocketTextFrame(t *testing.T) {
+	c, srvConn := newTestWSConn()
+
+	defer c.Close()
+	defer srvConn.Close()
+
+	go srvConn.Write(testWSFrame(wsOpText, []byte("a")))
+
+	if _, err := c.Read(make([]byte, 1)); err != ErrWebSocketTextFrame {
+		invalidError(t, err, ErrWebSocketTextFrame)
+	}
+}
+
+func TestWSConn_Read_masked(t *testing.T) {
+	c, srvConn := newTestWSConn()
+
+	defer c.Close()
+	defer srvConn.Close()
+
+	go srvConn.Write([]byte{0x80 | wsOpBinary, 0x80 | 0x01, 0x00, 0x00, 0x00, 0x00, 0x00})
+
+	if _, err := c.Read(make([]byte, 1)); err != ErrInvalidWebSocketFrm {
+		invalidError(t, err, ErrInvalidWebSocketFrm)
+	}
+}
+
+func TestWSConn_Read_longControlFrame(t *testing.T) {
+	c, srvConn := newTestWSConn()
+
+	defer c.Close()
+	defer srvConn.Close()
+
+	go srvConn.Write(testWSFrame(wsOpPing, make([]byte, 126)))
+
+	if _, err := c.Read(make([]byte, 1)); err != ErrInvalidWebSocketFrm {
+		invalidError(t, err, ErrInvalidWebSocketFrm)
+	}
+}
+
+func TestWSConn_Write(t *testing.T) {
+	c, srvConn := newTestWSConn()
+
+	defer c.Close()
+	defer srvConn.Close()
+
+	s := &testWSServerConn{
+		Conn: srvConn,
+		r:    bufio.NewReader(srvConn),
+	}
+
+	want := strings.Repeat("a", 300)
+
+	go c.Write([]byte(want))
+
+	b := make([]byte, len(want))
+
+	if _, err := io.ReadFull(s, b); err != nil {
+		nilErrorExpected(t, err)
+		return
+	}
+
+	if string(b) != want {
+		t.Error("the masked payload was not unmasked correctly")
+	}
+}
+
+func TestClient_Connect_webSocketHandshakeTimeout(t *testing.T) {
+	// The server never answers the opening handshake.
+	ln := newTestServer(t, func(conn net.Conn) {
+		defer conn.Close()
+
+		io.Copy(io.Discard, conn)
+	})
+
+	defer ln.Close()
+
+	cli := New(&Options{
+		ErrorHandler: func(_ error) {},
+	})
+
+	defer cli.Terminate()
+
+	errc := make(chan error, 1)
+
+	go func() {
+		errc <- cli.Connect(&ConnectOptions{
+			Network:        networkWS,
+			Address:        ln.Addr().String(),
+			ClientID:       []byte("clientID"),
+			CONNACKTimeout: 1,
+		})
+	}()
+
+	select {
+	case err := <-errc:
+		if err != context.DeadlineExceeded {
+			invalidError(t, err, context.DeadlineExceeded)
+		}
+	case <-time.After(5 * time.Second):
+		t.Error("the opening handshake did not time out")
+	}
+}
+
+func TestClient_ConnectContext_webSocketHandshakeCanceled(t *testing.T) {
+	acceptedc := make(chan struct{})
+
+	// The server never answers the opening handshake.
+	ln := newTestServer(t, func(conn net.Conn) {
+		defer conn.Close()
+
+		close(acceptedc)
+
+		io.Copy(io.Discard, conn)
+	})
+
+	defer ln.Close()
+
+	cli := New(&Options{
+		ErrorHandler: func(_ error) {},
+	})
+
+	defer cli.Terminate()
+
+	ctx, cancel := context.WithCancel(context.Background())
+
+	errc := make(chan error, 1)
+
+	go func() {
+		errc <- cli.ConnectContext(ctx, &ConnectOptions{
+			Network:  networkWS,
+			Address:  ln.Addr().String(),
+			ClientID: []byte("clientID"),
+		})
+	}()
+
+	// Cancel the context while the handshake is stalled.
+	<-acceptedc
+
+	time.Sleep(100 * time.Millisecond)
+
+	cancel()
+
+	select {
+	case err := <-errc:
+		if err != context.Canceled {
+			invalidError(t, err, context.Canceled)
+		}
+	case <-time.After(5 * time.Second):
+		t.Error("the opening handshake was not canceled")
+	}
+
+	if s := cli.State(); s != StateDisconnected {
+		t.Errorf("cli.State() => %s, want => %s", s, StateDisconnected)
+	}
+}
@@ -0,0 +1,189 @@
+package packet
+
+import "github.com/yosssi/gmq/mqtt"
+
+// appendAckVariableHeader appends the variable header of the PUBACK,
+// PUBREC, PUBREL or PUBCOMP Packet to the slice. The Reason Code and
+// the Properties are appended only in MQTT 5.0 and are omitted if
+// they are not necessary.
+func appendAckVariableHeader(b []byte, version byte, packetID uint16, reasonCode byte, props *Properties) []byte {
+	// Append the Packet Identifier.
+	b = append(b, encodeUint16(packetID)...)
+
+	// End the process if the Packet is not encoded in MQTT 5.0.
+	if version != mqtt.ProtocolVersion5 {
+		return b
+	}
+
+	// Append the Reason Code and the Properties.
+	return appendReasonProperties(b, reasonCode, props)
+}
+
+// appendReasonProperties appends the Reason Code and the Properties to
+// the slice. The Reason Code is omitted if it is ReasonSuccess and there
+// are no Properties, and the Properties are omitted if they are empty.
+func appendReasonProperties(b []byte, reasonCode byte, props *Properties) []byte {
+	// Encode the Properties.
+	var encoded []byte
+
+	if props != nil {
+		encoded = props.encode()
+	}
+
+	// Append the Reason Code if it or the Properties are necessary.
+	if reasonCode != ReasonSuccess || len(encoded) > 0 {
+		b = append(b, reasonCode)
+	}
+
+	// Append the Properties if they exist.
+	if len(encoded) > 0 {
+		b = appendVarInt(b, uint32(len(encoded)))
+		b = append(b, encoded...)
+	}
+
+	return b
+}
+
+// decodeAck validates the fixed header and decodes the variable header
+// of the MQTT 5.0 PUBACK, PUBREC, PUBREL or PUBCOMP Packet and returns
+// the Packet Identifier, the Reason Code and the Properties.
+func decodeAck(fixedHeader FixedHeader, variableHeader []byte, ptype byte, flags byte) (uint16, byte, *Properties, error) {
+	// Check the fixed header.
+	if err := fixedHeader.validate(ptype, flags); err != nil {
+		return 0, 0, nil, err
+	}
+
+	// Check the length of the variable header.
+	if len(variableHeader) < 2 {
+		return 0, 0, nil, ErrInvalidVariableHeaderLen
+	}
+
+	// Decode and check the Packet Identifier.
+	packetID, _ := decodeUint16(variableHeader[0:2])
+
+	if packetID == 0 {
+		return 0, 0, nil, ErrInvalidPacketID
+	}
+
+	// Decode the Reason Code and the Properties.
+	reasonCode, props, err := decodeReasonProperties(variableHeader[2:], ptype)
+	if err != nil {
+		return 0, 0, nil, err
+	}
+
+	return packetID, reasonCode, props, nil
+}
+
+// decodeReasonProperties decodes the optional Reason Code and Properties
+// which end the Packet of the MQTT Control Packet type. ReasonSuccess and
+// nil are returned for the absent Reason Code and Properties.
+func decodeReasonProperties(b []byte, ptype byte) (byte, *Properties, error) {
+	// Decode and check the Reason Code if it exists.
+	var reasonCode byte
+
+	if len(b) > 0 {
+		reasonCode = b[0]
+
+		if !validReasonCode(ptype, reasonCode) {
+			return 0, nil, ErrInvalidReasonCode
+		}
+	}
+
+	// Decode the Properties if they exist.
+	var props *Properties
+
+	if len(b) > 1 {
+		var rest []byte
+		var err error
+
+		if props, rest, err = decodeProperties(b[1:], ptype); err != nil {
+			return 0, nil, err
+		}
+
+		if len(rest) != 0 {
+			return 0, nil, ErrInvalidVariableHeaderLen
+		}
+	}
+
+	return reasonCode, props, nil
+}
+
+// validateVersionedOptions validates the protocol version and the Reason
+// Code and the Properties of the options of the Packet of the MQTT
+// Control Packet type. The Reason Code and the Properties must be
+// zero values if the protocol version is not MQTT 5.0.
+func validateVersionedOptions(version byte, ptype byte, reasonCode byte, props *Properties) error {
+	// Check the protocol version.
+	if version != 0 && !mqtt.ValidProtocolVersion(version) {
+		return ErrInvalidProtocolLevel
+	}
+
+	if version != mqtt.ProtocolVersion5 {
+		// Check the absence of the Reason Code and the Properties.
+		if reasonCode != 0 {
+			return ErrInvalidReasonCode
+		}
+
+		if props != nil {
+			return ErrPropertiesNotSupported
+		}
+
+		return nil
+	}
+
+	// Check the Reason Code. ReasonSuccess is the zero value of the
+	// Packets which do not contain the Reason Code.
+	if reasonCode != ReasonSuccess && !validReasonCode(ptype, reasonCode) {
+		return ErrInvalidReasonCode
+	}
+
+	// Check the Properties.
+	if props != nil {
+		return props.validate(ptype)
+	}
+
+	return nil
+}
+
+// decodeSubAck validates the fixed header and decodes the remaining of
+// the MQTT 5.0 SUBACK or UNSUBACK Packet and returns the Packet
+// Identifier, the Properties, the variable header and the payload
+// which consists of the Reason Codes.
+func decodeSubAck(fixedHeader FixedHeader, remaining []byte, ptype byte) (uint16, *Properties, []byte, []byte, error) {
+	// Check the fixed header.
+	if err := fixedHeader.validate(ptype, 0x00); err != nil {
+		return 0, nil, nil, nil, err
+	}
+
+	// Check the length of the remaining.
+	if len(remaining) < 2 {
+		return 0, nil, nil, nil, ErrInvalidRemainingLen
+	}
+
+	// Decode and check the Packet Identifier.
+	packetID, _ := decodeUint16(remaining[0:2])
+
+	if packetID == 0 {
+		return 0, nil, nil, nil, ErrInvalidPacketID
+	}
+
+	// Decode the Properties.
+	props, payload, err := decodeProperties(remaining[2:], ptype)
+	if err != nil {
+		return 0, nil, nil, nil, err
+	}
+
+	// Check the existence of the Reason Codes.
+	if len(payload) == 0 {
+		return 0, nil, nil, nil, ErrInvalidRemainingLen
+	}
+
+	// Check each Reason Code.
+	for _, b := range payload {
+		if !validReasonCode(ptype, b) {
+			return 0, nil, nil, nil, ErrInvalidReasonCode
+		}
+	}
+
+	return packetID, props, remaining[0 : len(remaining)-len(payload)], payload, nil
+}
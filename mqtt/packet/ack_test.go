@@ -0,0 +1,155 @@
+package packet
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/yosssi/gmq/mqtt"
+)
+
+func Test_appendAckVariableHeader(t *testing.T) {
+	props := &Properties{ReasonString: []byte("r")}
+
+	testCases := []struct {
+		version    byte
+		reasonCode byte
+		props      *Properties
+		want       []byte
+	}{
+		{0, ReasonNoMatchingSubscribers, props, []byte{0x00, 0x01}},
+		{mqtt.ProtocolVersion5, ReasonSuccess, nil, []byte{0x00, 0x01}},
+		{mqtt.ProtocolVersion5, ReasonSuccess, &Properties{}, []byte{0x00, 0x01}},
+		{mqtt.ProtocolVersion5, ReasonNoMatchingSubscribers, nil, []byte{0x00, 0x01, 0x10}},
+		{mqtt.ProtocolVersion5, ReasonSuccess, props, []byte{0x00, 0x01, 0x00, 0x04, propReasonString, 0x00, 0x01, 'r'}},
+	}
+
+	for _, tc := range testCases {
+		if got := appendAckVariableHeader(nil, tc.version, 1, tc.reasonCode, tc.props); !bytes.Equal(got, tc.want) {
+			t.Errorf("appendAckVariableHeader => %v, want => %v", got, tc.want)
+		}
+	}
+}
+
+func Test_decodeAck(t *testing.T) {
+	testCases := []struct {
+		fixedHeader    FixedHeader
+		variableHeader []byte
+		reasonCode     byte
+		reasonString   string
+		err            error
+	}{
+		{[]byte{TypePUBACK << 4}, nil, 0, "", ErrInvalidFixedHeaderLen},
+		{[]byte{TypePUBREC << 4, 0x02}, nil, 0, "", ErrInvalidPacketType},
+		{[]byte{TypePUBACK<<4 | 0x02, 0x02}, nil, 0, "", ErrInvalidFixedHeader},
+		{[]byte{TypePUBACK << 4, 0x01}, []byte{0x00}, 0, "", ErrInvalidVariableHeaderLen},
+		{[]byte{TypePUBACK << 4, 0x02}, []byte{0x00, 0x00}, 0, "", ErrInvalidPacketID},
+		{[]byte{TypePUBACK << 4, 0x03}, []byte{0x00, 0x01, ReasonPacketIdentifierNotFound}, 0, "", ErrInvalidReasonCode},
+		{[]byte{TypePUBACK << 4, 0x05}, []byte{0x00, 0x01, 0x00, 0x00, 0x00}, 0, "", ErrInvalidVariableHeaderLen},
+		{[]byte{TypePUBACK << 4, 0x02}, []byte{0x00, 0x01}, ReasonSuccess, "", nil},
+		{[]byte{TypePUBACK << 4, 0x03}, []byte{0x00, 0x01, ReasonQuotaExceeded}, ReasonQuotaExceeded, "", nil},
+		{[]byte{TypePUBACK << 4, 0x08}, []byte{0x00, 0x01, 0x00, 0x04, propReasonString, 0x00, 0x01, 'r'}, ReasonSuccess, "r", nil},
+	}
+
+	for _, tc := range testCases {
+		packetID, reasonCode, props, err := decodeAck(tc.fixedHeader, tc.variableHeader, TypePUBACK, 0x00)
+		if err != tc.err {
+			invalidError(t, err, tc.err)
+			continue
+		}
+
+		if err != nil {
+			continue
+		}
+
+		if packetID != 1 || reasonCode != tc.reasonCode {
+			t.Errorf("decodeAck(%v) => (%d, 0x%02X), want => (1, 0x%02X)", tc.variableHeader, packetID, reasonCode, tc.reasonCode)
+		}
+
+		if tc.reasonString != "" && (props == nil || string(props.ReasonString) != tc.reasonString) {
+			t.Errorf("props => %+v, want the Reason String %q", props, tc.reasonString)
+		}
+	}
+}
+
+func Test_decodeSubAck(t *testing.T) {
+	testCases := []struct {
+		fixedHeader FixedHeader
+		remaining   []byte
+		err         error
+	}{
+		{[]byte{TypeSUBACK<<4 | 0x02, 0x00}, nil, ErrInvalidFixedHeader},
+		{[]byte{TypeSUBACK << 4, 0x01}, []byte{0x00}, ErrInvalidRemainingLen},
+		{[]byte{TypeSUBACK << 4, 0x04}, []byte{0x00, 0x00, 0x00, 0x00}, ErrInvalidPacketID},
+		{[]byte{TypeSUBACK << 4, 0x03}, []byte{0x00, 0x01, 0x01}, ErrInvalidRemainingLen},
+		{[]byte{TypeSUBACK << 4, 0x03}, []byte{0x00, 0x01, 0x00}, ErrInvalidRemainingLen},
+		{[]byte{TypeSUBACK << 4, 0x04}, []byte{0x00, 0x01, 0x00, ReasonNoSubscriptionExisted}, ErrInvalidReasonCode},
+		{[]byte{TypeSUBACK << 4, 0x05}, []byte{0x00, 0x01, 0x00, ReasonGrantedQoS1, ReasonNotAuthorized}, nil},
+	}
+
+	for _, tc := range testCases {
+		_, _, variableHeader, payload, err := decodeSubAck(tc.fixedHeader, tc.remaining, TypeSUBACK)
+		if err != tc.err {
+			invalidError(t, err, tc.err)
+			continue
+		}
+
+		if err != nil {
+			continue
+		}
+
+		if !bytes.Equal(variableHeader, tc.remaining[:3]) || !bytes.Equal(payload, tc.remaining[3:]) {
+			t.Errorf("decodeSubAck => (%v, %v), want => (%v, %v)", variableHeader, payload, tc.remaining[:3], tc.remaining[3:])
+		}
+	}
+}
+
+func Test_decodeReasonProperties(t *testing.T) {
+	testCases := []struct {
+		b          []byte
+		reasonCode byte
+		props      bool
+		err        error
+	}{
+		{nil, ReasonSuccess, false, nil},
+		{[]byte{ReasonServerShuttingDown}, ReasonServerShuttingDown, false, nil},
+		{[]byte{ReasonGrantedQoS1}, 0, false, ErrInvalidReasonCode},
+		{[]byte{ReasonServerShuttingDown, 0x00}, ReasonServerShuttingDown, true, nil},
+		{[]byte{ReasonServerShuttingDown, 0x00, 0x00}, 0, false, ErrInvalidVariableHeaderLen},
+		{[]byte{ReasonServerShuttingDown, 0x02, propTopicAlias}, 0, false, ErrInvalidRemainingLen},
+	}
+
+	for _, tc := range testCases {
+		reasonCode, props, err := decodeReasonProperties(tc.b, TypeDISCONNECT)
+		if err != tc.err {
+			invalidError(t, err, tc.err)
+			continue
+		}
+
+		if reasonCode != tc.reasonCode || (props != nil) != tc.props {
+			t.Errorf("decodeReasonProperties(%v) => (0x%02X, %v), want => (0x%02X, %t)", tc.b, reasonCode, props, tc.reasonCode, tc.props)
+		}
+	}
+}
+
+func Test_validateVersionedOptions(t *testing.T) {
+	testCases := []struct {
+		version    byte
+		reasonCode byte
+		props      *Properties
+		want       error
+	}{
+		{0x02, 0, nil, ErrInvalidProtocolLevel},
+		{0, ReasonNoMatchingSubscribers, nil, ErrInvalidReasonCode},
+		{mqtt.ProtocolVersion311, 0, &Properties{}, ErrPropertiesNotSupported},
+		{0, 0, nil, nil},
+		{mqtt.ProtocolVersion5, ReasonGrantedQoS1, nil, ErrInvalidReasonCode},
+		{mqtt.ProtocolVersion5, ReasonSuccess, &Properties{TopicAlias: 1}, ErrInvalidProperty},
+		{mqtt.ProtocolVersion5, ReasonNoMatchingSubscribers, &Properties{ReasonString: []byte("r")}, nil},
+	}
+
+	for _, tc := range testCases {
+		if err := validateVersionedOptions(tc.version, TypePUBACK, tc.reasonCode, tc.props); err != tc.want {
+			t.Errorf("validateVersionedOptions(%d, 0x%02X, %+v) => %v, want => %v", tc.version, tc.reasonCode, tc.props, err, tc.want)
+		}
+	}
+}
@@ -0,0 +1,108 @@
+package packet
+
+import "github.com/yosssi/gmq/mqtt"
+
+// AUTH represents an AUTH Packet of MQTT 5.0.
+type AUTH struct {
+	base
+	// ReasonCode is the Reason Code of the variable header.
+	ReasonCode byte
+	// Properties is the Properties of the variable header.
+	Properties *Properties
+}
+
+// setFixedHeader sets the fixed header to the Packet.
+func (p *AUTH) setFixedHeader() {
+	// Append the first byte to the fixed header.
+	p.fixedHeader = append(p.fixedHeader, TypeAUTH<<4)
+
+	// Append the Remaining Length to the fixed header.
+	p.appendRemainingLength()
+}
+
+// setVariableHeader sets the variable header to the Packet.
+func (p *AUTH) setVariableHeader() {
+	// Append the Reason Code and the Properties to the variable header.
+	p.variableHeader = appendReasonProperties(p.variableHeader, p.ReasonCode, p.Properties)
+}
+
+// NewAUTH creates and returns an AUTH Packet.
+func NewAUTH(opts *AUTHOptions) (Packet, error) {
+	// Initialize the options.
+	if opts == nil {
+		opts = &AUTHOptions{}
+	}
+
+	// Validate the options.
+	if err := opts.validate(); err != nil {
+		return nil, err
+	}
+
+	// Create an AUTH Packet.
+	p := &AUTH{
+		ReasonCode: opts.ReasonCode,
+		Properties: opts.Properties,
+	}
+
+	// Set the protocol version to the Packet.
+	p.version = mqtt.ProtocolVersion5
+
+	// Set the variable header to the Packet.
+	p.setVariableHeader()
+
+	// Set the fixed header to the Packet.
+	p.setFixedHeader()
+
+	// Return the Packet.
+	return p, nil
+}
+
+// NewAUTHFromBytes creates an AUTH Packet from
+// the byte data and returns it.
+func NewAUTHFromBytes(fixedHeader FixedHeader, remaining []byte) (Packet, error) {
+	// Check the fixed header.
+	if err := fixedHeader.validate(TypeAUTH, 0x00); err != nil {
+		return nil, err
+	}
+
+	// Decode the Reason Code and the Properties.
+	reasonCode, props, err := decodeReasonProperties(remaining, TypeAUTH)
+	if err != nil {
+		return nil, err
+	}
+
+	// Create an AUTH Packet.
+	p := &AUTH{
+		ReasonCode: reasonCode,
+		Properties: props,
+	}
+
+	// Set the fixed header, the variable header and
+	// the protocol version to the Packet.
+	p.fixedHeader = fixedHeader
+	p.variableHeader = remaining
+	p.version = mqtt.ProtocolVersion5
+
+	// Return the Packet.
+	return p, nil
+}
+
+// String returns the human-readable representation of the Packet.
+func (p *AUTH) String() string {
+	return p.jsonPacket().String()
+}
+
+// MarshalJSON returns the JSON representation of the Packet.
+func (p *AUTH) MarshalJSON() ([]byte, error) {
+	return p.jsonPacket().marshal()
+}
+
+// jsonPacket creates the JSON representation of the Packet.
+func (p *AUTH) jsonPacket() *jsonPacket {
+	jp := p.newJSONPacket(TypeAUTH)
+
+	jp.ReasonCode = bytePointer(p.ReasonCode)
+	jp.Properties = p.Properties
+
+	return jp
+}
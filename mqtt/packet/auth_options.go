@@ -0,0 +1,17 @@
+package packet
+
+import "github.com/yosssi/gmq/mqtt"
+
+// AUTHOptions represents options for an AUTH Packet.
+type AUTHOptions struct {
+	// ReasonCode is the Reason Code of the variable header.
+	ReasonCode byte
+	// Properties is the Properties of the variable header.
+	Properties *Properties
+}
+
+// validate validates the options.
+func (opts *AUTHOptions) validate() error {
+	// Check the Reason Code and the Properties.
+	return validateVersionedOptions(mqtt.ProtocolVersion5, TypeAUTH, opts.ReasonCode, opts.Properties)
+}
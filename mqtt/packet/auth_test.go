@@ -0,0 +1,54 @@
+package packet
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestNewAUTH_optsNil(t *testing.T) {
+	p, err := NewAUTH(nil)
+	if err != nil {
+		nilErrorExpected(t, err)
+		return
+	}
+
+	if want := []byte{TypeAUTH << 4, 0x00}; !bytes.Equal(p.(*AUTH).fixedHeader, want) {
+		t.Errorf("p.fixedHeader => %v, want => %v", p.(*AUTH).fixedHeader, want)
+	}
+}
+
+func TestNewAUTH_ErrInvalidReasonCode(t *testing.T) {
+	if _, err := NewAUTH(&AUTHOptions{ReasonCode: ReasonUnspecifiedError}); err != ErrInvalidReasonCode {
+		invalidError(t, err, ErrInvalidReasonCode)
+	}
+}
+
+func TestNewAUTH(t *testing.T) {
+	p, err := NewAUTH(&AUTHOptions{
+		ReasonCode: ReasonContinueAuthentication,
+		Properties: &Properties{
+			AuthenticationMethod: []byte("SCRAM-SHA-1"),
+			AuthenticationData:   []byte{0x01, 0x02},
+		},
+	})
+	if err != nil {
+		nilErrorExpected(t, err)
+		return
+	}
+
+	decoded := roundTrip5(t, p).(*AUTH)
+
+	if decoded.ReasonCode != ReasonContinueAuthentication || string(decoded.Properties.AuthenticationMethod) != "SCRAM-SHA-1" || !bytes.Equal(decoded.Properties.AuthenticationData, []byte{0x01, 0x02}) {
+		t.Errorf("decoded => %+v, want => the same as %+v", decoded, p)
+	}
+}
+
+func TestNewAUTHFromBytes_err(t *testing.T) {
+	if _, err := NewAUTHFromBytes([]byte{TypeAUTH<<4 | 0x01, 0x00}, nil); err != ErrInvalidFixedHeader {
+		invalidError(t, err, ErrInvalidFixedHeader)
+	}
+
+	if _, err := NewAUTHFromBytes([]byte{TypeAUTH << 4, 0x02}, []byte{ReasonReAuthenticate, 0x01}); err != ErrInvalidRemainingLen {
+		invalidError(t, err, ErrInvalidRemainingLen)
+	}
+}
@@ -1,8 +1,10 @@
 package packet
 
 import (
-	"bytes"
 	"io"
+	"net"
+
+	"github.com/yosssi/gmq/mqtt"
 )
 
 // base holds the fields and methods which are common
@@ -14,23 +16,41 @@ type base struct {
 	variableHeader []byte
 	// Payload represents the payload of the Packet.
 	payload []byte
+	// version is the protocol version in which the Packet
+	// is encoded. Zero represents MQTT 3.1.1.
+	version byte
 }
 
-// WriteTo writes the Packet data to the writer.
+// WriteTo writes the Packet data to the writer. The fixed header,
+// the variable header and the payload are written without being
+// copied to an intermediate buffer. They are written by the vectored
+// I/O if the writer is a network connection.
 func (b *base) WriteTo(w io.Writer) (int64, error) {
-	// Create a byte buffer.
-	var bf bytes.Buffer
+	// Write the Packet data by the vectored I/O.
+	if _, ok := w.(net.Conn); ok {
+		bufs := net.Buffers{b.fixedHeader, b.variableHeader, b.payload}
+		return bufs.WriteTo(w)
+	}
+
+	// Write each part of the Packet data to the writer.
+	var n int64
+
+	for _, part := range [...][]byte{b.fixedHeader, b.variableHeader, b.payload} {
+		if len(part) == 0 {
+			continue
+		}
 
-	// Write the Packet data to the buffer.
-	bf.Write(b.fixedHeader)
-	bf.Write(b.variableHeader)
-	bf.Write(b.payload)
+		m, err := w.Write(part)
 
-	// Write the buffered data to the writer.
-	n, err := w.Write(bf.Bytes())
+		n += int64(m)
+
+		if err != nil {
+			return n, err
+		}
+	}
 
 	// Return the result.
-	return int64(n), err
+	return n, nil
 }
 
 // Type extracts the MQTT Control Packet type from
@@ -39,6 +59,28 @@ func (b *base) Type() (byte, error) {
 	return b.fixedHeader.ptype()
 }
 
+// ProtocolVersion returns the protocol version
+// in which the Packet is encoded.
+func (b *base) ProtocolVersion() byte {
+	if b.version == 0 {
+		return mqtt.ProtocolVersion311
+	}
+
+	return b.version
+}
+
+// setVersion sets the protocol version in which the Packet is encoded.
+func (b *base) setVersion(version byte) {
+	if version != mqtt.ProtocolVersion311 {
+		b.version = version
+	}
+}
+
+// v5 returns true if the Packet is encoded in MQTT 5.0.
+func (b *base) v5() bool {
+	return b.version == mqtt.ProtocolVersion5
+}
+
 // appendRemainingLength appends the Remaining Length
 // to the fixed header.
 func (b *base) appendRemainingLength() {
@@ -1,8 +1,14 @@
 package packet
 
 import (
+	"bytes"
+	"errors"
+	"io"
 	"io/ioutil"
+	"net"
 	"testing"
+
+	"github.com/yosssi/gmq/mqtt"
 )
 
 func Test_base_WriteTo(t *testing.T) {
@@ -23,6 +29,68 @@ func Test_base_WriteTo(t *testing.T) {
 	}
 }
 
+var errTest = errors.New("test error")
+
+// errWriter is a writer which fails after writing the bytes of n.
+type errWriter struct {
+	n int
+}
+
+func (w *errWriter) Write(p []byte) (int, error) {
+	if len(p) > w.n {
+		n := w.n
+		w.n = 0
+		return n, errTest
+	}
+
+	w.n -= len(p)
+
+	return len(p), nil
+}
+
+func Test_base_WriteTo_writeErr(t *testing.T) {
+	b := base{
+		fixedHeader:    []byte{0x00},
+		variableHeader: []byte{0x00, 0x00},
+		payload:        []byte{0x00, 0x00, 0x00},
+	}
+
+	n, err := b.WriteTo(&errWriter{n: 2})
+	if err != errTest {
+		invalidError(t, err, errTest)
+	}
+
+	if n != 2 {
+		t.Errorf("n => %d, want => %d", n, 2)
+	}
+}
+
+func Test_base_WriteTo_netConn(t *testing.T) {
+	cliConn, srvConn := net.Pipe()
+
+	defer cliConn.Close()
+	defer srvConn.Close()
+
+	b := base{
+		fixedHeader:    []byte{0x01},
+		variableHeader: []byte{0x02, 0x03},
+		payload:        []byte{0x04, 0x05, 0x06},
+	}
+
+	go b.WriteTo(cliConn)
+
+	got := make([]byte, 6)
+
+	if _, err := io.ReadFull(srvConn, got); err != nil {
+		nilErrorExpected(t, err)
+		return
+	}
+
+	if want := []byte{0x01, 0x02, 0x03, 0x04, 0x05, 0x06}; !bytes.Equal(got, want) {
+		t.Errorf("got => %v, want => %v", got, want)
+	}
+}
+
 func Test_base_Type(t *testing.T) {
 	b := base{
 		fixedHeader: []byte{TypeCONNECT << 4},
@@ -39,6 +107,16 @@ func Test_base_Type(t *testing.T) {
 	}
 }
 
+func Test_base_ProtocolVersion(t *testing.T) {
+	if v := (&base{}).ProtocolVersion(); v != mqtt.ProtocolVersion311 {
+		t.Errorf("v => %d, want => %d", v, mqtt.ProtocolVersion311)
+	}
+
+	if v := (&base{version: mqtt.ProtocolVersion5}).ProtocolVersion(); v != mqtt.ProtocolVersion5 {
+		t.Errorf("v => %d, want => %d", v, mqtt.ProtocolVersion5)
+	}
+}
+
 func Test_base_appendRemainingLength(t *testing.T) {
 	b := base{
 		variableHeader: []byte{0x00},
@@ -0,0 +1,76 @@
+package packet
+
+import (
+	"math/bits"
+	"sync"
+)
+
+// Range of the capacities of the pooled buffers
+// which are represented by the powers of two
+const (
+	minBufferPoolShift = 8  // 256 bytes
+	maxBufferPoolShift = 20 // 1 MiB
+)
+
+// BufferPool is a pool of the buffers into which the Reader reads
+// the PUBLISH Packets. The buffers are classified by their capacities
+// which are the powers of two and the buffers larger than 1 MiB are
+// not pooled. It is safe for concurrent use.
+type BufferPool struct {
+	// pools is the pools of the buffers by their size classes.
+	pools [maxBufferPoolShift - minBufferPoolShift + 1]sync.Pool
+}
+
+// get returns a buffer whose length is the size.
+func (p *BufferPool) get(size int) *[]byte {
+	// Allocate a buffer if its size is out of the size classes.
+	i := bufferPoolClass(size)
+	if i < 0 {
+		b := make([]byte, size)
+		return &b
+	}
+
+	// Reuse a pooled buffer.
+	if v := p.pools[i].Get(); v != nil {
+		bp := v.(*[]byte)
+		*bp = (*bp)[:size]
+		return bp
+	}
+
+	// Allocate a buffer of the size class.
+	b := make([]byte, size, 1<<uint(i+minBufferPoolShift))
+
+	return &b
+}
+
+// put returns the buffer to the pool. The buffer
+// which does not belong to any size class is dropped.
+func (p *BufferPool) put(bp *[]byte) {
+	i := bufferPoolClass(cap(*bp))
+	if i < 0 || cap(*bp) != 1<<uint(i+minBufferPoolShift) {
+		return
+	}
+
+	p.pools[i].Put(bp)
+}
+
+// bufferPoolClass returns the index of the smallest size class
+// which can contain the size. It returns -1 if the size exceeds
+// the largest size class.
+func bufferPoolClass(size int) int {
+	if size <= 1<<minBufferPoolShift {
+		return 0
+	}
+
+	shift := bits.Len(uint(size - 1))
+	if shift > maxBufferPoolShift {
+		return -1
+	}
+
+	return shift - minBufferPoolShift
+}
+
+// NewBufferPool creates and returns a BufferPool.
+func NewBufferPool() *BufferPool {
+	return &BufferPool{}
+}
@@ -0,0 +1,66 @@
+package packet
+
+import "testing"
+
+func Test_bufferPoolClass(t *testing.T) {
+	testCases := []struct {
+		size int
+		want int
+	}{
+		{0, 0},
+		{256, 0},
+		{257, 1},
+		{512, 1},
+		{1 << 20, maxBufferPoolShift - minBufferPoolShift},
+		{1<<20 + 1, -1},
+	}
+
+	for _, tc := range testCases {
+		if got := bufferPoolClass(tc.size); got != tc.want {
+			t.Errorf("bufferPoolClass(%d) => %d, want => %d", tc.size, got, tc.want)
+		}
+	}
+}
+
+func TestBufferPool_get(t *testing.T) {
+	p := NewBufferPool()
+
+	bp := p.get(300)
+
+	if len(*bp) != 300 || cap(*bp) != 512 {
+		t.Errorf("len, cap => %d, %d, want => %d, %d", len(*bp), cap(*bp), 300, 512)
+	}
+
+	p.put(bp)
+
+	bp = p.get(400)
+
+	if len(*bp) != 400 || cap(*bp) != 512 {
+		t.Errorf("len, cap => %d, %d, want => %d, %d", len(*bp), cap(*bp), 400, 512)
+	}
+}
+
+func TestBufferPool_get_large(t *testing.T) {
+	p := NewBufferPool()
+
+	bp := p.get(1<<20 + 1)
+
+	if len(*bp) != 1<<20+1 {
+		t.Errorf("len(*bp) => %d, want => %d", len(*bp), 1<<20+1)
+	}
+
+	// The buffer out of the size classes is dropped.
+	p.put(bp)
+}
+
+func TestBufferPool_put_invalidCap(t *testing.T) {
+	p := NewBufferPool()
+
+	b := make([]byte, 300)
+
+	p.put(&b)
+
+	if bp := p.get(300); bp == &b {
+		t.Error("the buffer out of the size classes was pooled")
+	}
+}
@@ -1,6 +1,10 @@
 package packet
 
-import "errors"
+import (
+	"errors"
+
+	"github.com/yosssi/gmq/mqtt"
+)
 
 // Length of the fixed header of the CONNACK Packet
 const lenCONNACKFixedHeader = 2
@@ -10,12 +14,12 @@ const lenCONNACKVariableHeader = 2
 
 // Connect Return code values
 const (
-	connRetAccepted                    byte = 0x00
-	connRetUnacceptableProtocolVersion byte = 0x01
-	connRetIdentifierRejected          byte = 0x02
-	connRetServerUnavailable           byte = 0x03
-	connRetBadUserNameOrPassword       byte = 0x04
-	connRetNotAuthorized               byte = 0x05
+	ConnRetAccepted                    byte = 0x00
+	ConnRetUnacceptableProtocolVersion byte = 0x01
+	ConnRetIdentifierRejected          byte = 0x02
+	ConnRetServerUnavailable           byte = 0x03
+	ConnRetBadUserNameOrPassword       byte = 0x04
+	ConnRetNotAuthorized               byte = 0x05
 )
 
 // Error values
@@ -31,10 +35,14 @@ var (
 // CONNACK represents a CONNACK Packet.
 type CONNACK struct {
 	base
-	// sessionPresent is the Session Present of the variable header.
-	sessionPresent bool
-	// connectReturnCode is the Connect Return code of the variable header.
-	connectReturnCode byte
+	// SessionPresent is the Session Present of the variable header.
+	SessionPresent bool
+	// ConnectReturnCode is the Connect Return code of the variable header.
+	// It is the Connect Reason Code in MQTT 5.0.
+	ConnectReturnCode byte
+	// Properties is the Properties of the variable header.
+	// It is used only in MQTT 5.0.
+	Properties *Properties
 }
 
 // NewCONNACKFromBytes creates the CONNACK Packet
@@ -47,8 +55,8 @@ func NewCONNACKFromBytes(fixedHeader FixedHeader, variableHeader []byte) (Packet
 
 	// Create a CONNACK Packet.
 	p := &CONNACK{
-		sessionPresent:    variableHeader[0]<<7 == 0x80,
-		connectReturnCode: variableHeader[1],
+		SessionPresent:    variableHeader[0]<<7 == 0x80,
+		ConnectReturnCode: variableHeader[1],
 	}
 
 	// Set the fixed header to the Packet.
@@ -61,8 +69,98 @@ func NewCONNACKFromBytes(fixedHeader FixedHeader, variableHeader []byte) (Packet
 	return p, nil
 }
 
+// newCONNACKFromBytes creates a CONNACK Packet of the protocol version
+// from the byte data and returns it.
+func newCONNACKFromBytes(fixedHeader FixedHeader, variableHeader []byte, version byte) (Packet, error) {
+	// Decode the byte data of MQTT 3.1.1.
+	if version == mqtt.ProtocolVersion311 {
+		return NewCONNACKFromBytes(fixedHeader, variableHeader)
+	}
+
+	// Decode the byte data of MQTT 3.1.
+	if version == mqtt.ProtocolVersion31 {
+		return newCONNACK31FromBytes(fixedHeader, variableHeader)
+	}
+
+	// Check the fixed header.
+	if err := fixedHeader.validate(TypeCONNACK, 0x00); err != nil {
+		return nil, err
+	}
+
+	// Check the length of the variable header.
+	if len(variableHeader) < lenCONNACKVariableHeader+1 {
+		return nil, ErrInvalidVariableHeaderLen
+	}
+
+	// Check the reserved bits of the variable header.
+	if variableHeader[0]>>1 != 0x00 {
+		return nil, ErrInvalidVariableHeader
+	}
+
+	// Check the Connect Reason Code.
+	if !validReasonCode(TypeCONNACK, variableHeader[1]) {
+		return nil, ErrInvalidReasonCode
+	}
+
+	// Decode the Properties.
+	props, rest, err := decodeProperties(variableHeader[2:], TypeCONNACK)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(rest) != 0 {
+		return nil, ErrInvalidVariableHeaderLen
+	}
+
+	// Create a CONNACK Packet.
+	p := &CONNACK{
+		SessionPresent:    variableHeader[0]<<7 == 0x80,
+		ConnectReturnCode: variableHeader[1],
+		Properties:        props,
+	}
+
+	// Set the fixed header, the variable header and
+	// the protocol version to the Packet.
+	p.fixedHeader = fixedHeader
+	p.variableHeader = variableHeader
+	p.version = version
+
+	// Return the Packet.
+	return p, nil
+}
+
+// newCONNACK31FromBytes creates an MQTT 3.1 CONNACK Packet from the byte
+// data and returns it. The first byte of the variable header is reserved
+// and is not used in MQTT 3.1, so the Session Present is always false.
+func newCONNACK31FromBytes(fixedHeader FixedHeader, variableHeader []byte) (Packet, error) {
+	// Validate the byte data.
+	if err := validateVersionedCONNACKBytes(fixedHeader, variableHeader, mqtt.ProtocolVersion31); err != nil {
+		return nil, err
+	}
+
+	// Create a CONNACK Packet.
+	p := &CONNACK{
+		ConnectReturnCode: variableHeader[1],
+	}
+
+	// Set the fixed header, the variable header and
+	// the protocol version to the Packet.
+	p.fixedHeader = fixedHeader
+	p.variableHeader = variableHeader
+	p.version = mqtt.ProtocolVersion31
+
+	// Return the Packet.
+	return p, nil
+}
+
 // validateCONNACKBytes validates the fixed header and the variable header.
 func validateCONNACKBytes(fixedHeader FixedHeader, variableHeader []byte) error {
+	return validateVersionedCONNACKBytes(fixedHeader, variableHeader, mqtt.ProtocolVersion311)
+}
+
+// validateVersionedCONNACKBytes validates the fixed header and
+// the variable header of the protocol version.
+func validateVersionedCONNACKBytes(fixedHeader FixedHeader, variableHeader []byte, version byte) error {
 	// Extract the MQTT Control Packet type.
 	ptype, err := fixedHeader.ptype()
 	if err != nil {
@@ -95,22 +193,44 @@ func validateCONNACKBytes(fixedHeader FixedHeader, variableHeader []byte) error
 	}
 
 	// Check the reserved bits of the variable header.
-	if variableHeader[0]>>1 != 0x00 {
+	// They are not used in MQTT 3.1.
+	if variableHeader[0]>>1 != 0x00 && version != mqtt.ProtocolVersion31 {
 		return ErrInvalidVariableHeader
 	}
 
 	// Check the Connect Return code of the variable header.
 	switch variableHeader[1] {
 	case
-		connRetAccepted,
-		connRetUnacceptableProtocolVersion,
-		connRetIdentifierRejected,
-		connRetServerUnavailable,
-		connRetBadUserNameOrPassword,
-		connRetNotAuthorized:
+		ConnRetAccepted,
+		ConnRetUnacceptableProtocolVersion,
+		ConnRetIdentifierRejected,
+		ConnRetServerUnavailable,
+		ConnRetBadUserNameOrPassword,
+		ConnRetNotAuthorized:
 	default:
 		return ErrInvalidConnectReturnCode
 	}
 
 	return nil
 }
+
+// String returns the human-readable representation of the Packet.
+func (p *CONNACK) String() string {
+	return p.jsonPacket().String()
+}
+
+// MarshalJSON returns the JSON representation of the Packet.
+func (p *CONNACK) MarshalJSON() ([]byte, error) {
+	return p.jsonPacket().marshal()
+}
+
+// jsonPacket creates the JSON representation of the Packet.
+func (p *CONNACK) jsonPacket() *jsonPacket {
+	jp := p.newJSONPacket(TypeCONNACK)
+
+	jp.SessionPresent = p.SessionPresent
+	jp.ReturnCode = bytePointer(p.ConnectReturnCode)
+	jp.Properties = p.Properties
+
+	return jp
+}
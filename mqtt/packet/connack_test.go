@@ -1,6 +1,10 @@
 package packet
 
-import "testing"
+import (
+	"testing"
+
+	"github.com/yosssi/gmq/mqtt"
+)
 
 func TestNewCONNACKFromBytes_errValidateCONNACKBytes(t *testing.T) {
 	if _, err := NewCONNACKFromBytes(nil, nil); err != ErrInvalidFixedHeaderLen {
@@ -79,3 +83,71 @@ func Test_validateCONNACKBytes(t *testing.T) {
 		nilErrorExpected(t, err)
 	}
 }
+
+func TestNewCONNACKFromBytes_SessionPresentConnectReturnCode(t *testing.T) {
+	p, err := NewCONNACKFromBytes([]byte{TypeCONNACK << 4, 0x02}, []byte{0x01, ConnRetNotAuthorized})
+	if err != nil {
+		nilErrorExpected(t, err)
+		return
+	}
+
+	connack := p.(*CONNACK)
+
+	if !connack.SessionPresent {
+		t.Error("connack.SessionPresent => false, want => true")
+	}
+
+	if connack.ConnectReturnCode != ConnRetNotAuthorized {
+		t.Errorf("connack.ConnectReturnCode => %X, want => %X", connack.ConnectReturnCode, ConnRetNotAuthorized)
+	}
+}
+
+func Test_newCONNACKFromBytes_v5(t *testing.T) {
+	testCases := []struct {
+		fixedHeader    FixedHeader
+		variableHeader []byte
+		want           error
+	}{
+		{[]byte{TypeCONNACK<<4 | 0x01, 0x03}, nil, ErrInvalidFixedHeader},
+		{[]byte{TypeCONNACK << 4, 0x02}, []byte{0x00, 0x00}, ErrInvalidVariableHeaderLen},
+		{[]byte{TypeCONNACK << 4, 0x03}, []byte{0x02, 0x00, 0x00}, ErrInvalidVariableHeader},
+		{[]byte{TypeCONNACK << 4, 0x03}, []byte{0x00, ReasonGrantedQoS1, 0x00}, ErrInvalidReasonCode},
+		{[]byte{TypeCONNACK << 4, 0x03}, []byte{0x00, 0x00, 0x01}, ErrInvalidRemainingLen},
+		{[]byte{TypeCONNACK << 4, 0x04}, []byte{0x00, 0x00, 0x00, 0x00}, ErrInvalidVariableHeaderLen},
+		{[]byte{TypeCONNACK << 4, 0x03}, []byte{0x01, ReasonBadUserNameOrPassword, 0x00}, nil},
+	}
+
+	for _, tc := range testCases {
+		if _, err := newCONNACKFromBytes(tc.fixedHeader, tc.variableHeader, mqtt.ProtocolVersion5); err != tc.want {
+			t.Errorf("newCONNACKFromBytes(%v, %v) => %v, want => %v", tc.fixedHeader, tc.variableHeader, err, tc.want)
+		}
+	}
+
+	p, err := newCONNACKFromBytes([]byte{TypeCONNACK << 4, 0x06}, []byte{0x01, 0x00, 0x03, propServerKeepAlive, 0x00, 0x0A}, mqtt.ProtocolVersion5)
+	if err != nil {
+		nilErrorExpected(t, err)
+		return
+	}
+
+	connack := p.(*CONNACK)
+
+	if !connack.SessionPresent || connack.Properties == nil || *connack.Properties.ServerKeepAlive != 10 {
+		t.Errorf("connack => %+v", connack)
+	}
+}
+
+func Test_newCONNACKFromBytes_v31(t *testing.T) {
+	p, err := newCONNACKFromBytes([]byte{TypeCONNACK << 4, 0x02}, []byte{0xFF, ConnRetNotAuthorized}, mqtt.ProtocolVersion31)
+	if err != nil {
+		nilErrorExpected(t, err)
+		return
+	}
+
+	if connack := p.(*CONNACK); connack.SessionPresent || connack.ConnectReturnCode != ConnRetNotAuthorized {
+		t.Errorf("connack => %+v", connack)
+	}
+
+	if _, err := newCONNACKFromBytes([]byte{TypeCONNACK << 4, 0x02}, []byte{0x00, 0x06}, mqtt.ProtocolVersion31); err != ErrInvalidConnectReturnCode {
+		invalidError(t, err, ErrInvalidConnectReturnCode)
+	}
+}
@@ -1,5 +1,36 @@
 package packet
 
+import (
+	"bytes"
+	"errors"
+
+	"github.com/yosssi/gmq/mqtt"
+)
+
+// Minimum length of the fixed header of the CONNECT Packet
+const minLenCONNECTFixedHeader = 2
+
+// Length of the variable header of the CONNECT Packet
+const lenCONNECTVariableHeader = 10
+
+// Length of the variable header of the MQTT 3.1 CONNECT Packet
+const lenCONNECTVariableHeader31 = 12
+
+// Protocol Name of MQTT 3.1.1
+var protocolName = []byte("MQTT")
+
+// Protocol Name of MQTT 3.1
+var protocolName31 = []byte("MQIsdp")
+
+// Error values
+var (
+	ErrInvalidProtocolName  = errors.New("invalid Protocol Name")
+	ErrInvalidProtocolLevel = errors.New("unacceptable Protocol Level")
+	ErrInvalidConnectFlags  = errors.New("invalid Connect Flags")
+	ErrInvalidWillTopic     = errors.New("the Will Topic must not be zero-byte")
+	ErrInvalidPasswordFlag  = errors.New("the Password Flag must be 0 if the User Name Flag is 0")
+)
+
 // CONNECT represents a CONNECT Packet.
 type CONNECT struct {
 	base
@@ -21,6 +52,12 @@ type CONNECT struct {
 	willQoS byte
 	// willRetain is the Will Retain of the variable header.
 	willRetain bool
+	// properties is the Properties of the variable header.
+	// It is used only in MQTT 5.0.
+	properties *Properties
+	// willProperties is the Will Properties of the payload.
+	// It is used only in MQTT 5.0.
+	willProperties *Properties
 }
 
 // setFixedHeader sets the fixed header to the Packet.
@@ -39,16 +76,39 @@ func (p *CONNECT) setVariableHeader() {
 
 	// Create a variable header and set it to the Packet.
 	p.variableHeader = []byte{
-		0x00,             // Length MSB (0)
-		0x04,             // Length LSB (4)
-		0x4D,             // 'M'
-		0x51,             // 'Q'
-		0x54,             // 'T'
-		0x54,             // 'T'
-		0x04,             // Level(4)
-		p.connectFlags(), // Connect Flags
-		keepAlive[0],     // Keep Alive MSB
-		keepAlive[1],     // Keep Alive LSB
+		0x00,                // Length MSB (0)
+		0x04,                // Length LSB (4)
+		0x4D,                // 'M'
+		0x51,                // 'Q'
+		0x54,                // 'T'
+		0x54,                // 'T'
+		p.ProtocolVersion(), // Level(4 or 5)
+		p.connectFlags(),    // Connect Flags
+		keepAlive[0],        // Keep Alive MSB
+		keepAlive[1],        // Keep Alive LSB
+	}
+
+	// Replace the Protocol Name and the Protocol Level in MQTT 3.1.
+	if p.ProtocolVersion() == mqtt.ProtocolVersion31 {
+		p.variableHeader = []byte{
+			0x00,             // Length MSB (0)
+			0x06,             // Length LSB (6)
+			0x4D,             // 'M'
+			0x51,             // 'Q'
+			0x49,             // 'I'
+			0x73,             // 's'
+			0x64,             // 'd'
+			0x70,             // 'p'
+			0x03,             // Level(3)
+			p.connectFlags(), // Connect Flags
+			keepAlive[0],     // Keep Alive MSB
+			keepAlive[1],     // Keep Alive LSB
+		}
+	}
+
+	// Append the Properties to the variable header in MQTT 5.0.
+	if p.v5() {
+		p.variableHeader = appendProperties(p.variableHeader, p.properties)
 	}
 }
 
@@ -59,7 +119,12 @@ func (p *CONNECT) setPayload() {
 
 	// Append the Will Topic and the Will Message to the payload
 	// if the Packet has them.
+	// The Will Properties precede them in MQTT 5.0.
 	if p.will() {
+		if p.v5() {
+			p.payload = appendProperties(p.payload, p.willProperties)
+		}
+
 		p.payload = appendLenStr(p.payload, p.willTopic)
 		p.payload = appendLenStr(p.payload, p.willMessage)
 	}
@@ -131,17 +196,22 @@ func NewCONNECT(opts *CONNECTOptions) (Packet, error) {
 
 	// Create a CONNECT Packet.
 	p := &CONNECT{
-		clientID:     opts.ClientID,
-		userName:     opts.UserName,
-		password:     opts.Password,
-		cleanSession: opts.CleanSession,
-		keepAlive:    opts.KeepAlive,
-		willTopic:    opts.WillTopic,
-		willMessage:  opts.WillMessage,
-		willQoS:      opts.WillQoS,
-		willRetain:   opts.WillRetain,
+		clientID:       opts.ClientID,
+		userName:       opts.UserName,
+		password:       opts.Password,
+		cleanSession:   opts.CleanSession,
+		keepAlive:      opts.KeepAlive,
+		willTopic:      opts.WillTopic,
+		willMessage:    opts.WillMessage,
+		willQoS:        opts.WillQoS,
+		willRetain:     opts.WillRetain,
+		properties:     opts.Properties,
+		willProperties: opts.WillProperties,
 	}
 
+	// Set the protocol version to the Packet.
+	p.version = opts.ProtocolVersion
+
 	// Set the variable header to the Packet.
 	p.setVariableHeader()
 
@@ -154,3 +224,326 @@ func NewCONNECT(opts *CONNECTOptions) (Packet, error) {
 	// Return the Packet.
 	return p, nil
 }
+
+// ClientID returns the Client Identifier of the Packet.
+func (p *CONNECT) ClientID() []byte {
+	return p.clientID
+}
+
+// UserName returns the User Name of the Packet.
+func (p *CONNECT) UserName() []byte {
+	return p.userName
+}
+
+// Password returns the Password of the Packet.
+func (p *CONNECT) Password() []byte {
+	return p.password
+}
+
+// CleanSession returns the Clean Session of the Packet.
+func (p *CONNECT) CleanSession() bool {
+	return p.cleanSession
+}
+
+// KeepAlive returns the Keep Alive of the Packet.
+func (p *CONNECT) KeepAlive() uint16 {
+	return p.keepAlive
+}
+
+// WillTopic returns the Will Topic of the Packet.
+func (p *CONNECT) WillTopic() []byte {
+	return p.willTopic
+}
+
+// WillMessage returns the Will Message of the Packet.
+func (p *CONNECT) WillMessage() []byte {
+	return p.willMessage
+}
+
+// WillQoS returns the Will QoS of the Packet.
+func (p *CONNECT) WillQoS() byte {
+	return p.willQoS
+}
+
+// WillRetain returns the Will Retain of the Packet.
+func (p *CONNECT) WillRetain() bool {
+	return p.willRetain
+}
+
+// Properties returns the Properties of the Packet.
+func (p *CONNECT) Properties() *Properties {
+	return p.properties
+}
+
+// WillProperties returns the Will Properties of the Packet.
+func (p *CONNECT) WillProperties() *Properties {
+	return p.willProperties
+}
+
+// NewCONNECTFromBytes creates a CONNECT Packet from the byte data
+// and returns it. ErrInvalidProtocolLevel and ErrInvalidClientIDCleanSession
+// (or ErrInvalidClientIDLen31 in MQTT 3.1) are returned for the Packets which the Server should reject with
+// the CONNACK Packet whose Connect Return code is
+// ConnRetUnacceptableProtocolVersion and ConnRetIdentifierRejected.
+func NewCONNECTFromBytes(fixedHeader FixedHeader, remaining []byte) (Packet, error) {
+	return newCONNECTFromBytes(fixedHeader, remaining, mqtt.ProtocolVersion311)
+}
+
+// newCONNECTFromBytes creates a CONNECT Packet of the protocol version
+// from the byte data and returns it.
+func newCONNECTFromBytes(fixedHeader FixedHeader, remaining []byte, version byte) (Packet, error) {
+	// Validate the fixed header and the variable header.
+	if err := validateVersionedCONNECTBytes(fixedHeader, remaining, version); err != nil {
+		return nil, err
+	}
+
+	v5 := version == mqtt.ProtocolVersion5
+
+	// Get the length of the variable header without the Properties.
+	n := lenVersionedCONNECTVariableHeader(version)
+
+	// Decode the Properties in MQTT 5.0.
+	var props *Properties
+	var payload []byte
+	var err error
+
+	if v5 {
+		if props, payload, err = decodeProperties(remaining[n:], TypeCONNECT); err != nil {
+			return nil, err
+		}
+	} else {
+		payload = remaining[n:]
+	}
+
+	// Extract the variable header.
+	variableHeader := remaining[0 : len(remaining)-len(payload)]
+
+	// Extract the Connect Flags.
+	flags := variableHeader[n-3]
+
+	// Decode the Keep Alive.
+	// No error occur because of the precedent validation and
+	// the returned error is not be taken care of.
+	keepAlive, _ := decodeUint16(variableHeader[n-2 : n])
+
+	// Create a CONNECT Packet.
+	p := &CONNECT{
+		cleanSession: flags&0x02 != 0,
+		keepAlive:    keepAlive,
+		willQoS:      flags >> 3 & 0x03,
+		willRetain:   flags&0x20 != 0,
+		properties:   props,
+	}
+
+	// Set the protocol version to the Packet.
+	if version != mqtt.ProtocolVersion311 {
+		p.version = version
+	}
+
+	// Decode the Client Identifier.
+	var b []byte
+
+	if p.clientID, b, err = decodeLenStr(payload); err != nil {
+		return nil, err
+	}
+
+	// Check the Client Identifier and the Clean Session.
+	// A zero-byte Client Identifier is left to the Server in MQTT 5.0.
+	if len(p.clientID) == 0 && !p.cleanSession && !v5 {
+		return nil, ErrInvalidClientIDCleanSession
+	}
+
+	// Check the length of the Client Identifier in MQTT 3.1.
+	if version == mqtt.ProtocolVersion31 && (len(p.clientID) == 0 || len(p.clientID) > maxLenClientID31) {
+		return nil, ErrInvalidClientIDLen31
+	}
+
+	// Decode the Will Topic and the Will Message if the Will Flag is 1.
+	// The Will Properties precede them in MQTT 5.0.
+	if flags&0x04 != 0 {
+		if v5 {
+			if p.willProperties, b, err = decodeProperties(b, typeWill); err != nil {
+				return nil, err
+			}
+		}
+
+		if p.willTopic, b, err = decodeLenStr(b); err != nil {
+			return nil, err
+		}
+
+		if len(p.willTopic) == 0 {
+			return nil, ErrInvalidWillTopic
+		}
+
+		if p.willMessage, b, err = decodeLenStr(b); err != nil {
+			return nil, err
+		}
+	}
+
+	// Decode the User Name if the User Name Flag is 1.
+	if flags&0x80 != 0 {
+		if p.userName, b, err = decodeLenStr(b); err != nil {
+			return nil, err
+		}
+	}
+
+	// Decode the Password if the Password Flag is 1.
+	if flags&0x40 != 0 {
+		if p.password, b, err = decodeLenStr(b); err != nil {
+			return nil, err
+		}
+	}
+
+	// Check the rest of the payload.
+	if len(b) != 0 {
+		return nil, ErrInvalidRemainingLen
+	}
+
+	// Set the fixed header to the Packet.
+	p.fixedHeader = fixedHeader
+
+	// Set the variable header to the Packet.
+	p.variableHeader = variableHeader
+
+	// Set the payload to the Packet.
+	p.payload = payload
+
+	// Return the Packet.
+	return p, nil
+}
+
+// validateCONNECTBytes validates the fixed header and
+// the variable header of the remaining.
+func validateCONNECTBytes(fixedHeader FixedHeader, remaining []byte) error {
+	return validateVersionedCONNECTBytes(fixedHeader, remaining, mqtt.ProtocolVersion311)
+}
+
+// validateVersionedCONNECTBytes validates the fixed header and
+// the variable header of the remaining of the protocol version.
+func validateVersionedCONNECTBytes(fixedHeader FixedHeader, remaining []byte, version byte) error {
+	// Extract the MQTT Control Packet type.
+	ptype, err := fixedHeader.ptype()
+	if err != nil {
+		return err
+	}
+
+	// Check the length of the fixed header.
+	if len(fixedHeader) < minLenCONNECTFixedHeader {
+		return ErrInvalidFixedHeaderLen
+	}
+
+	// Check the MQTT Control Packet type.
+	if ptype != TypeCONNECT {
+		return ErrInvalidPacketType
+	}
+
+	// Check the reserved bits of the fixed header.
+	if fixedHeader[0]&0x0F != 0x00 {
+		return ErrInvalidFixedHeader
+	}
+
+	// Get the length of the variable header without the Properties.
+	n := lenVersionedCONNECTVariableHeader(version)
+
+	// Check the length of the remaining.
+	if len(remaining) < n {
+		return ErrInvalidRemainingLen
+	}
+
+	// Check the Protocol Name.
+	if !bytes.Equal(remaining[0:n-4], appendLenStr(nil, versionedProtocolName(version))) {
+		return ErrInvalidProtocolName
+	}
+
+	// Check the Protocol Level.
+	if remaining[n-4] != version {
+		return ErrInvalidProtocolLevel
+	}
+
+	// Extract the Connect Flags.
+	flags := remaining[n-3]
+
+	// Check the reserved flag.
+	if flags&0x01 != 0 {
+		return ErrInvalidConnectFlags
+	}
+
+	// Check the Will QoS.
+	if flags>>3&0x03 == 0x03 {
+		return ErrInvalidWillQoS
+	}
+
+	// Check the Will QoS and the Will Retain if the Will Flag is 0.
+	if flags&0x04 == 0 {
+		if flags&0x18 != 0 {
+			return ErrInvalidWillTopicMessageQoS
+		}
+
+		if flags&0x20 != 0 {
+			return ErrInvalidWillTopicMessageRetain
+		}
+	}
+
+	// Check the Password Flag. MQTT 5.0 allows
+	// the Password without the User Name.
+	if flags&0x80 == 0 && flags&0x40 != 0 && version != mqtt.ProtocolVersion5 {
+		return ErrInvalidPasswordFlag
+	}
+
+	return nil
+}
+
+// versionedProtocolName returns the Protocol Name of the protocol version.
+func versionedProtocolName(version byte) []byte {
+	if version == mqtt.ProtocolVersion31 {
+		return protocolName31
+	}
+
+	return protocolName
+}
+
+// lenVersionedCONNECTVariableHeader returns the length of the variable
+// header of the CONNECT Packet of the protocol version. The length of
+// the Properties of MQTT 5.0 is not included.
+func lenVersionedCONNECTVariableHeader(version byte) int {
+	if version == mqtt.ProtocolVersion31 {
+		return lenCONNECTVariableHeader31
+	}
+
+	return lenCONNECTVariableHeader
+}
+
+// String returns the human-readable representation of the Packet.
+func (p *CONNECT) String() string {
+	return p.jsonPacket().String()
+}
+
+// MarshalJSON returns the JSON representation of the Packet.
+func (p *CONNECT) MarshalJSON() ([]byte, error) {
+	return p.jsonPacket().marshal()
+}
+
+// jsonPacket creates the JSON representation of the Packet.
+func (p *CONNECT) jsonPacket() *jsonPacket {
+	jp := p.newJSONPacket(TypeCONNECT)
+
+	jp.ClientID = string(p.clientID)
+	jp.UserName = string(p.userName)
+	jp.Password = p.password
+	jp.CleanSession = p.cleanSession
+	jp.KeepAlive = p.keepAlive
+	jp.Properties = p.properties
+
+	// Set the Will.
+	if p.will() {
+		jp.Will = &jsonWill{
+			Topic:      string(p.willTopic),
+			Message:    p.willMessage,
+			QoS:        p.willQoS,
+			Retain:     p.willRetain,
+			Properties: p.willProperties,
+		}
+	}
+
+	return jp
+}
@@ -4,8 +4,12 @@ import (
 	"errors"
 
 	"github.com/yosssi/gmq/mqtt"
+	"github.com/yosssi/gmq/mqtt/topic"
 )
 
+// Maximum length of the Client Identifier in MQTT 3.1
+const maxLenClientID31 = 23
+
 // Error values
 var (
 	ErrClientIDExceedsMaxStringsLen    = errors.New("the length of the Client Identifier exceeds the maximum strings length")
@@ -13,12 +17,16 @@ var (
 	ErrPasswordExceedsMaxStringsLen    = errors.New("the length of the Password exceeds the maximum strings length")
 	ErrWillTopicExceedsMaxStringsLen   = errors.New("the length of the Will Topic exceeds the maximum strings length")
 	ErrWillMessageExceedsMaxStringsLen = errors.New("the length of the Will Message exceeds the maximum strings length")
+	ErrInvalidClientIDString           = errors.New("the Client Identifier must be a well-formed UTF-8 string without U+0000")
+	ErrInvalidUserNameString           = errors.New("the User Name must be a well-formed UTF-8 string without U+0000")
 	ErrInvalidClientIDCleanSession     = errors.New("the Clean Session must be true if the Client Identifier is zero-byte")
+	ErrInvalidClientIDLen31            = errors.New("the length of the Client Identifier must be between 1 and 23 bytes in MQTT 3.1")
 	ErrInvalidClientIDPassword         = errors.New("the Password must be zero-byte if the Client Identifier is zero-byte")
 	ErrInvalidWillTopicMessage         = errors.New("the Will Topic (Message) must not be zero-byte if the Will Message (Topic) is not zero-byte")
 	ErrInvalidWillQoS                  = errors.New("the Will QoS is invalid")
 	ErrInvalidWillTopicMessageQoS      = errors.New("the Will QoS must be zero if both the Will Topic and the Will Message are zero-byte")
 	ErrInvalidWillTopicMessageRetain   = errors.New("the Will Retain must be false if both the Will Topic and the Will Message are zero-byte")
+	ErrInvalidWillTopicMessageProps    = errors.New("the Will Properties must be nil if both the Will Topic and the Will Message are zero-byte")
 )
 
 // CONNECTOptions represents options for a CONNECT Packet.
@@ -41,16 +49,45 @@ type CONNECTOptions struct {
 	WillQoS byte
 	// WillRetain is the Will Retain of the variable header.
 	WillRetain bool
+	// ProtocolVersion is the protocol version of the Packet.
+	// MQTT 3.1.1 is used if it is zero. MQTT 3.1 requires
+	// the Client Identifier between 1 and 23 bytes.
+	ProtocolVersion byte
+	// Properties is the Properties of the variable header.
+	// It is used only in MQTT 5.0.
+	Properties *Properties
+	// WillProperties is the Will Properties of the payload.
+	// It is used only in MQTT 5.0.
+	WillProperties *Properties
 }
 
+// validate validates the options.
 func (opts *CONNECTOptions) validate() error {
 	// Check the length of the Client Identifier.
 	if len(opts.ClientID) > maxStringsLen {
 		return ErrClientIDExceedsMaxStringsLen
 	}
 
+	// Check the encoding of the Client Identifier.
+	if !validStr(opts.ClientID) {
+		return ErrInvalidClientIDString
+	}
+
+	// Check the protocol version.
+	if opts.ProtocolVersion != 0 && !mqtt.ValidProtocolVersion(opts.ProtocolVersion) {
+		return ErrInvalidProtocolLevel
+	}
+
+	v5 := opts.ProtocolVersion == mqtt.ProtocolVersion5
+
+	// Check the length of the Client Identifier in MQTT 3.1.
+	if opts.ProtocolVersion == mqtt.ProtocolVersion31 && (len(opts.ClientID) == 0 || len(opts.ClientID) > maxLenClientID31) {
+		return ErrInvalidClientIDLen31
+	}
+
 	// Check the combination of the Client Identifier and the Clean Session.
-	if len(opts.ClientID) == 0 && !opts.CleanSession {
+	// A zero-byte Client Identifier is left to the Server in MQTT 5.0.
+	if len(opts.ClientID) == 0 && !opts.CleanSession && !v5 {
 		return ErrInvalidClientIDCleanSession
 	}
 
@@ -59,13 +96,19 @@ func (opts *CONNECTOptions) validate() error {
 		return ErrUserNameExceedsMaxStringsLen
 	}
 
+	// Check the encoding of the User Name.
+	if !validStr(opts.UserName) {
+		return ErrInvalidUserNameString
+	}
+
 	// Check the length of the Password.
 	if len(opts.Password) > maxStringsLen {
 		return ErrPasswordExceedsMaxStringsLen
 	}
 
-	// Check the combination of the Client Identifier and the Password.
-	if len(opts.UserName) == 0 && len(opts.Password) > 0 {
+	// Check the combination of the User Name and the Password.
+	// MQTT 5.0 allows the Password without the User Name.
+	if len(opts.UserName) == 0 && len(opts.Password) > 0 && !v5 {
 		return ErrInvalidClientIDPassword
 	}
 
@@ -84,6 +127,13 @@ func (opts *CONNECTOptions) validate() error {
 		return ErrInvalidWillTopicMessage
 	}
 
+	// Check the Will Topic as a Topic Name.
+	if len(opts.WillTopic) > 0 {
+		if err := topic.ValidateName(string(opts.WillTopic)); err != nil {
+			return err
+		}
+	}
+
 	// Check the Will QoS.
 	if !mqtt.ValidQoS(opts.WillQoS) {
 		return ErrInvalidWillQoS
@@ -99,5 +149,31 @@ func (opts *CONNECTOptions) validate() error {
 		return ErrInvalidWillTopicMessageRetain
 	}
 
+	// Check the Properties and the Will Properties.
+	if !v5 {
+		if opts.Properties != nil || opts.WillProperties != nil {
+			return ErrPropertiesNotSupported
+		}
+
+		return nil
+	}
+
+	if opts.Properties != nil {
+		if err := opts.Properties.validate(TypeCONNECT); err != nil {
+			return err
+		}
+	}
+
+	if opts.WillProperties != nil {
+		// Check the combination of the Will Topic, the Will Message and the Will Properties.
+		if len(opts.WillTopic) == 0 && len(opts.WillMessage) == 0 {
+			return ErrInvalidWillTopicMessageProps
+		}
+
+		if err := opts.WillProperties.validate(typeWill); err != nil {
+			return err
+		}
+	}
+
 	return nil
 }
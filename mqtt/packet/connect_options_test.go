@@ -1,9 +1,11 @@
 package packet
 
 import (
+	"bytes"
 	"testing"
 
 	"github.com/yosssi/gmq/mqtt"
+	"github.com/yosssi/gmq/mqtt/topic"
 )
 
 func TestCONNECTOptions_validate_errClientIDExceedsMaxStringsLen(t *testing.T) {
@@ -26,7 +28,7 @@ func TestCONNECTOptions_validate_errInvalidClientIDCleanSession(t *testing.T) {
 
 func TestCONNECTOptions_validate_errUserNameExceedsMaxStringsLen(t *testing.T) {
 	opts := &CONNECTOptions{
-		ClientID: []byte{0x00},
+		ClientID: []byte("clientID"),
 		UserName: make([]byte, maxStringsLen+1),
 	}
 
@@ -37,7 +39,7 @@ func TestCONNECTOptions_validate_errUserNameExceedsMaxStringsLen(t *testing.T) {
 
 func TestCONNECTOptions_validate_errPasswordExceedsMaxStringsLen(t *testing.T) {
 	opts := &CONNECTOptions{
-		ClientID: []byte{0x00},
+		ClientID: []byte("clientID"),
 		Password: make([]byte, maxStringsLen+1),
 	}
 
@@ -48,7 +50,7 @@ func TestCONNECTOptions_validate_errPasswordExceedsMaxStringsLen(t *testing.T) {
 
 func TestCONNECTOptions_validate_errInvalidClientIDPassword(t *testing.T) {
 	opts := &CONNECTOptions{
-		ClientID: []byte{0x00},
+		ClientID: []byte("clientID"),
 		Password: []byte{0x00},
 	}
 
@@ -59,7 +61,7 @@ func TestCONNECTOptions_validate_errInvalidClientIDPassword(t *testing.T) {
 
 func TestCONNECTOptions_validate_errWillTopicExceedsMaxStringsLen(t *testing.T) {
 	opts := &CONNECTOptions{
-		ClientID:  []byte{0x00},
+		ClientID:  []byte("clientID"),
 		WillTopic: make([]byte, maxStringsLen+1),
 	}
 
@@ -70,7 +72,7 @@ func TestCONNECTOptions_validate_errWillTopicExceedsMaxStringsLen(t *testing.T)
 
 func TestCONNECTOptions_validate_errWillMessageExceedsMaxStringsLen(t *testing.T) {
 	opts := &CONNECTOptions{
-		ClientID:    []byte{0x00},
+		ClientID:    []byte("clientID"),
 		WillMessage: make([]byte, maxStringsLen+1),
 	}
 
@@ -81,8 +83,8 @@ func TestCONNECTOptions_validate_errWillMessageExceedsMaxStringsLen(t *testing.T
 
 func TestCONNECTOptions_validate_errInvalidWillTopicMessage(t *testing.T) {
 	opts := &CONNECTOptions{
-		ClientID:  []byte{0x00},
-		WillTopic: []byte{0x00},
+		ClientID:  []byte("clientID"),
+		WillTopic: []byte("willTopic"),
 	}
 
 	if err := opts.validate(); err != ErrInvalidWillTopicMessage {
@@ -92,7 +94,7 @@ func TestCONNECTOptions_validate_errInvalidWillTopicMessage(t *testing.T) {
 
 func TestCONNECTOptions_validate_errInvalidWillQoS(t *testing.T) {
 	opts := &CONNECTOptions{
-		ClientID: []byte{0x00},
+		ClientID: []byte("clientID"),
 		WillQoS:  byte(0x03),
 	}
 
@@ -103,7 +105,7 @@ func TestCONNECTOptions_validate_errInvalidWillQoS(t *testing.T) {
 
 func TestCONNECTOptions_validate_errInvalidWillTopicMessageQoS(t *testing.T) {
 	opts := &CONNECTOptions{
-		ClientID: []byte{0x00},
+		ClientID: []byte("clientID"),
 		WillQoS:  mqtt.QoS1,
 	}
 
@@ -114,7 +116,7 @@ func TestCONNECTOptions_validate_errInvalidWillTopicMessageQoS(t *testing.T) {
 
 func TestCONNECTOptions_validate_errInvalidWillTopicMessageRetain(t *testing.T) {
 	opts := &CONNECTOptions{
-		ClientID:   []byte{0x00},
+		ClientID:   []byte("clientID"),
 		WillRetain: true,
 	}
 
@@ -125,10 +127,103 @@ func TestCONNECTOptions_validate_errInvalidWillTopicMessageRetain(t *testing.T)
 
 func TestCONNECTOptions_validate(t *testing.T) {
 	opts := &CONNECTOptions{
-		ClientID: []byte{0x00},
+		ClientID: []byte("clientID"),
 	}
 
 	if err := opts.validate(); err != nil {
 		nilErrorExpected(t, err)
 	}
 }
+
+func TestCONNECTOptions_validate_v5(t *testing.T) {
+	testCases := []struct {
+		opts *CONNECTOptions
+		want error
+	}{
+		{&CONNECTOptions{ProtocolVersion: 0x06}, ErrInvalidProtocolLevel},
+		{&CONNECTOptions{ClientID: []byte("c"), Properties: &Properties{}}, ErrPropertiesNotSupported},
+		{&CONNECTOptions{ClientID: []byte("c"), WillProperties: &Properties{}}, ErrPropertiesNotSupported},
+		{&CONNECTOptions{ProtocolVersion: mqtt.ProtocolVersion5, Properties: &Properties{TopicAlias: 1}}, ErrInvalidProperty},
+		{&CONNECTOptions{ProtocolVersion: mqtt.ProtocolVersion5, WillProperties: &Properties{}}, ErrInvalidWillTopicMessageProps},
+		{&CONNECTOptions{ProtocolVersion: mqtt.ProtocolVersion5, WillTopic: []byte("a"), WillMessage: []byte("b"), WillProperties: &Properties{ReceiveMaximum: 1}}, ErrInvalidProperty},
+		{&CONNECTOptions{ProtocolVersion: mqtt.ProtocolVersion5, Password: []byte("p")}, nil},
+		{&CONNECTOptions{ProtocolVersion: mqtt.ProtocolVersion5, WillTopic: []byte("a"), WillMessage: []byte("b"), WillProperties: &Properties{WillDelayInterval: 1}}, nil},
+	}
+
+	for _, tc := range testCases {
+		if err := tc.opts.validate(); err != tc.want {
+			t.Errorf("validate(%+v) => %v, want => %v", tc.opts, err, tc.want)
+		}
+	}
+}
+
+func TestCONNECTOptions_validate_ErrInvalidClientIDLen31(t *testing.T) {
+	testCases := [][]byte{
+		nil,
+		bytes.Repeat([]byte("a"), 24),
+	}
+
+	for _, clientID := range testCases {
+		opts := &CONNECTOptions{
+			ClientID:        clientID,
+			CleanSession:    true,
+			ProtocolVersion: mqtt.ProtocolVersion31,
+		}
+
+		if err := opts.validate(); err != ErrInvalidClientIDLen31 {
+			invalidError(t, err, ErrInvalidClientIDLen31)
+		}
+	}
+}
+
+func TestCONNECTOptions_validate_errInvalidClientIDString(t *testing.T) {
+	testCases := [][]byte{
+		{0xFF},
+		[]byte("client\x00ID"),
+	}
+
+	for _, clientID := range testCases {
+		opts := &CONNECTOptions{
+			ClientID: clientID,
+		}
+
+		if err := opts.validate(); err != ErrInvalidClientIDString {
+			invalidError(t, err, ErrInvalidClientIDString)
+		}
+	}
+}
+
+func TestCONNECTOptions_validate_errInvalidUserNameString(t *testing.T) {
+	opts := &CONNECTOptions{
+		ClientID: []byte("clientID"),
+		UserName: []byte{0xC0, 0xAF},
+	}
+
+	if err := opts.validate(); err != ErrInvalidUserNameString {
+		invalidError(t, err, ErrInvalidUserNameString)
+	}
+}
+
+func TestCONNECTOptions_validate_errWillTopic(t *testing.T) {
+	testCases := []struct {
+		willTopic []byte
+		want      error
+	}{
+		{[]byte("will/#"), topic.ErrWildcardInName},
+		{[]byte("will/+"), topic.ErrWildcardInName},
+		{[]byte("will\x00"), topic.ErrNullCharacter},
+		{[]byte{0xFF}, topic.ErrInvalidUTF8},
+	}
+
+	for _, tc := range testCases {
+		opts := &CONNECTOptions{
+			ClientID:    []byte("clientID"),
+			WillTopic:   tc.willTopic,
+			WillMessage: []byte("willMessage"),
+		}
+
+		if err := opts.validate(); err != tc.want {
+			invalidError(t, err, tc.want)
+		}
+	}
+}
@@ -1,6 +1,7 @@
 package packet
 
 import (
+	"bytes"
 	"testing"
 
 	"github.com/yosssi/gmq/mqtt"
@@ -125,3 +126,270 @@ func TestNewCONNECT(t *testing.T) {
 		t.Errorf("ptype => %X, want => %X", ptype, TypeCONNECT)
 	}
 }
+
+func TestNewCONNECTFromBytes_errValidateCONNECTBytes(t *testing.T) {
+	if _, err := NewCONNECTFromBytes(nil, nil); err != ErrInvalidFixedHeaderLen {
+		invalidError(t, err, ErrInvalidFixedHeaderLen)
+	}
+}
+
+func TestNewCONNECTFromBytes(t *testing.T) {
+	p, err := NewCONNECT(&CONNECTOptions{
+		ClientID:     []byte("clientID"),
+		UserName:     []byte("userName"),
+		Password:     []byte("password"),
+		CleanSession: true,
+		KeepAlive:    60,
+		WillTopic:    []byte("willTopic"),
+		WillMessage:  []byte("willMessage"),
+		WillQoS:      mqtt.QoS1,
+		WillRetain:   true,
+	})
+	if err != nil {
+		nilErrorExpected(t, err)
+		return
+	}
+
+	var bf bytes.Buffer
+
+	p.WriteTo(&bf)
+
+	b := bf.Bytes()
+
+	decoded, err := NewCONNECTFromBytes(b[0:2], b[2:])
+	if err != nil {
+		nilErrorExpected(t, err)
+		return
+	}
+
+	connect := decoded.(*CONNECT)
+
+	if string(connect.ClientID()) != "clientID" {
+		t.Errorf("connect.ClientID() => %q, want => %q", connect.ClientID(), "clientID")
+	}
+
+	if string(connect.UserName()) != "userName" {
+		t.Errorf("connect.UserName() => %q, want => %q", connect.UserName(), "userName")
+	}
+
+	if string(connect.Password()) != "password" {
+		t.Errorf("connect.Password() => %q, want => %q", connect.Password(), "password")
+	}
+
+	if !connect.CleanSession() {
+		t.Error("connect.CleanSession() => false, want => true")
+	}
+
+	if connect.KeepAlive() != 60 {
+		t.Errorf("connect.KeepAlive() => %d, want => 60", connect.KeepAlive())
+	}
+
+	if string(connect.WillTopic()) != "willTopic" {
+		t.Errorf("connect.WillTopic() => %q, want => %q", connect.WillTopic(), "willTopic")
+	}
+
+	if string(connect.WillMessage()) != "willMessage" {
+		t.Errorf("connect.WillMessage() => %q, want => %q", connect.WillMessage(), "willMessage")
+	}
+
+	if connect.WillQoS() != mqtt.QoS1 {
+		t.Errorf("connect.WillQoS() => %d, want => %d", connect.WillQoS(), mqtt.QoS1)
+	}
+
+	if !connect.WillRetain() {
+		t.Error("connect.WillRetain() => false, want => true")
+	}
+
+	var decodedBf bytes.Buffer
+
+	decoded.WriteTo(&decodedBf)
+
+	if !bytes.Equal(decodedBf.Bytes(), b) {
+		t.Errorf("decoded bytes => %v, want => %v", decodedBf.Bytes(), b)
+	}
+}
+
+func TestNewCONNECTFromBytes_payloadErr(t *testing.T) {
+	// Variable header with the Connect Flags to be replaced.
+	vh := func(flags byte) []byte {
+		return []byte{0x00, 0x04, 0x4D, 0x51, 0x54, 0x54, 0x04, flags, 0x00, 0x00}
+	}
+
+	testCases := []struct {
+		remaining []byte
+		want      error
+	}{
+		{append(vh(0x02), 0x00), ErrInvalidRemainingLen},
+		{append(vh(0x00), 0x00, 0x00), ErrInvalidClientIDCleanSession},
+		{append(vh(0x02), 0x00, 0x00, 0x00, 0x00), ErrInvalidRemainingLen},
+		{append(vh(0x06), 0x00, 0x00, 0x00), ErrInvalidRemainingLen},
+		{append(vh(0x06), 0x00, 0x00, 0x00, 0x00, 0x00, 0x00), ErrInvalidWillTopic},
+		{append(vh(0x06), 0x00, 0x00, 0x00, 0x01, 0x61), ErrInvalidRemainingLen},
+		{append(vh(0x82), 0x00, 0x00), ErrInvalidRemainingLen},
+		{append(vh(0xC2), 0x00, 0x00, 0x00, 0x01, 0x61), ErrInvalidRemainingLen},
+		{append(vh(0x06), 0x00, 0x00, 0x00, 0x01, 0x61, 0x00, 0x00), nil},
+		{append(vh(0xC2), 0x00, 0x00, 0x00, 0x01, 0x61, 0x00, 0x01, 0x62), nil},
+	}
+
+	for _, tc := range testCases {
+		if _, err := NewCONNECTFromBytes([]byte{TypeCONNECT << 4, byte(len(tc.remaining))}, tc.remaining); err != tc.want {
+			t.Errorf("NewCONNECTFromBytes(%v) => %v, want => %v", tc.remaining, err, tc.want)
+		}
+	}
+}
+
+func Test_validateCONNECTBytes(t *testing.T) {
+	// Variable header with the Connect Flags to be replaced.
+	vh := func(flags byte) []byte {
+		return []byte{0x00, 0x04, 0x4D, 0x51, 0x54, 0x54, 0x04, flags, 0x00, 0x00}
+	}
+
+	testCases := []struct {
+		fixedHeader FixedHeader
+		remaining   []byte
+		want        error
+	}{
+		{[]byte{TypeCONNECT << 4}, nil, ErrInvalidFixedHeaderLen},
+		{[]byte{TypeCONNACK << 4, 0x00}, nil, ErrInvalidPacketType},
+		{[]byte{TypeCONNECT<<4 | 0x01, 0x00}, nil, ErrInvalidFixedHeader},
+		{[]byte{TypeCONNECT << 4, 0x01}, []byte{0x00}, ErrInvalidRemainingLen},
+		{[]byte{TypeCONNECT << 4, 0x0A}, []byte{0x00, 0x06, 0x4D, 0x51, 0x49, 0x73, 0x64, 0x70, 0x03, 0x02}, ErrInvalidProtocolName},
+		{[]byte{TypeCONNECT << 4, 0x0A}, []byte{0x00, 0x04, 0x4D, 0x51, 0x54, 0x54, 0x05, 0x02, 0x00, 0x00}, ErrInvalidProtocolLevel},
+		{[]byte{TypeCONNECT << 4, 0x0A}, vh(0x03), ErrInvalidConnectFlags},
+		{[]byte{TypeCONNECT << 4, 0x0A}, vh(0x1E), ErrInvalidWillQoS},
+		{[]byte{TypeCONNECT << 4, 0x0A}, vh(0x0A), ErrInvalidWillTopicMessageQoS},
+		{[]byte{TypeCONNECT << 4, 0x0A}, vh(0x22), ErrInvalidWillTopicMessageRetain},
+		{[]byte{TypeCONNECT << 4, 0x0A}, vh(0x42), ErrInvalidPasswordFlag},
+		{[]byte{TypeCONNECT << 4, 0x0A}, vh(0xF6), nil},
+	}
+
+	for _, tc := range testCases {
+		if err := validateCONNECTBytes(tc.fixedHeader, tc.remaining); err != tc.want {
+			t.Errorf("validateCONNECTBytes(%v, %v) => %v, want => %v", tc.fixedHeader, tc.remaining, err, tc.want)
+		}
+	}
+}
+
+func TestNewCONNECT_v5(t *testing.T) {
+	expiry := uint32(3600)
+
+	p, err := NewCONNECT(&CONNECTOptions{
+		ClientID:        []byte("cid"),
+		Password:        []byte("pass"),
+		KeepAlive:       30,
+		WillTopic:       []byte("will"),
+		WillMessage:     []byte("bye"),
+		ProtocolVersion: mqtt.ProtocolVersion5,
+		Properties:      &Properties{SessionExpiryInterval: &expiry, TopicAliasMaximum: 10},
+		WillProperties:  &Properties{WillDelayInterval: 5},
+	})
+	if err != nil {
+		nilErrorExpected(t, err)
+		return
+	}
+
+	if p.(*CONNECT).variableHeader[6] != mqtt.ProtocolVersion5 {
+		t.Errorf("Protocol Level => %d, want => %d", p.(*CONNECT).variableHeader[6], mqtt.ProtocolVersion5)
+	}
+
+	decoded := roundTrip5(t, p).(*CONNECT)
+
+	if string(decoded.ClientID()) != "cid" || string(decoded.Password()) != "pass" || decoded.KeepAlive() != 30 {
+		t.Errorf("decoded => %+v, want => the same as %+v", decoded, p)
+	}
+
+	if string(decoded.WillTopic()) != "will" || string(decoded.WillMessage()) != "bye" {
+		t.Errorf("decoded => %+v, want => the same as %+v", decoded, p)
+	}
+
+	if props := decoded.Properties(); props == nil || *props.SessionExpiryInterval != expiry || props.TopicAliasMaximum != 10 {
+		t.Errorf("decoded.Properties() => %+v", props)
+	}
+
+	if props := decoded.WillProperties(); props == nil || props.WillDelayInterval != 5 {
+		t.Errorf("decoded.WillProperties() => %+v", props)
+	}
+}
+
+func Test_newCONNECTFromBytes_v5Err(t *testing.T) {
+	// Variable header of MQTT 5.0 with the Connect Flags to be replaced.
+	vh := func(flags byte) []byte {
+		return []byte{0x00, 0x04, 0x4D, 0x51, 0x54, 0x54, 0x05, flags, 0x00, 0x00}
+	}
+
+	testCases := []struct {
+		remaining []byte
+		want      error
+	}{
+		{[]byte{0x00, 0x04, 0x4D, 0x51, 0x54, 0x54, 0x04, 0x02, 0x00, 0x00, 0x00, 0x00, 0x00}, ErrInvalidProtocolLevel},
+		{append(vh(0x02), 0x01), ErrInvalidRemainingLen},
+		{append(vh(0x02), 0x02, propReceiveMaximum, 0x00), ErrInvalidRemainingLen},
+		{append(vh(0x02), 0x03, propTopicAlias, 0x00, 0x01), ErrInvalidProperty},
+		{append(vh(0x06), 0x00, 0x00, 0x00, 0x01), ErrInvalidRemainingLen},
+		{append(vh(0x06), 0x00, 0x00, 0x00, 0x02, propWillDelayInterval, 0x00), ErrInvalidRemainingLen},
+		{append(vh(0x00), 0x00, 0x00, 0x00), nil},
+		{append(vh(0x42), 0x00, 0x00, 0x00, 0x00, 0x01, 0x61), nil},
+	}
+
+	for _, tc := range testCases {
+		if _, err := newCONNECTFromBytes([]byte{TypeCONNECT << 4, byte(len(tc.remaining))}, tc.remaining, mqtt.ProtocolVersion5); err != tc.want {
+			t.Errorf("newCONNECTFromBytes(%v) => %v, want => %v", tc.remaining, err, tc.want)
+		}
+	}
+}
+
+func TestNewCONNECT_v31(t *testing.T) {
+	p, err := NewCONNECT(&CONNECTOptions{
+		ClientID:        []byte("cid"),
+		KeepAlive:       60,
+		ProtocolVersion: mqtt.ProtocolVersion31,
+	})
+	if err != nil {
+		nilErrorExpected(t, err)
+		return
+	}
+
+	want := []byte{0x00, 0x06, 'M', 'Q', 'I', 's', 'd', 'p', 0x03, 0x00, 0x00, 0x3C}
+
+	if got := p.(*CONNECT).variableHeader; !bytes.Equal(got, want) {
+		t.Errorf("p.variableHeader => %v, want => %v", got, want)
+	}
+
+	var bf bytes.Buffer
+
+	if _, err := p.WriteTo(&bf); err != nil {
+		nilErrorExpected(t, err)
+		return
+	}
+
+	b := bf.Bytes()
+
+	decoded, err := newCONNECTFromBytes(b[0:2], b[2:], mqtt.ProtocolVersion31)
+	if err != nil {
+		nilErrorExpected(t, err)
+		return
+	}
+
+	if c := decoded.(*CONNECT); string(c.ClientID()) != "cid" || c.KeepAlive() != 60 || c.ProtocolVersion() != mqtt.ProtocolVersion31 {
+		t.Errorf("decoded => %+v, want => the same as %+v", c, p)
+	}
+}
+
+func Test_newCONNECTFromBytes_v31Err(t *testing.T) {
+	testCases := []struct {
+		remaining []byte
+		want      error
+	}{
+		{[]byte{0x00, 0x06, 'M', 'Q', 'I', 's', 'd', 'p', 0x03, 0x02, 0x00}, ErrInvalidRemainingLen},
+		{[]byte{0x00, 0x04, 'M', 'Q', 'T', 'T', 0x04, 0x02, 0x00, 0x00, 0x00, 0x00}, ErrInvalidProtocolName},
+		{[]byte{0x00, 0x06, 'M', 'Q', 'I', 's', 'd', 'p', 0x04, 0x02, 0x00, 0x00, 0x00, 0x00}, ErrInvalidProtocolLevel},
+		{[]byte{0x00, 0x06, 'M', 'Q', 'I', 's', 'd', 'p', 0x03, 0x02, 0x00, 0x00, 0x00, 0x00}, ErrInvalidClientIDLen31},
+		{append([]byte{0x00, 0x06, 'M', 'Q', 'I', 's', 'd', 'p', 0x03, 0x02, 0x00, 0x00}, appendLenStr(nil, bytes.Repeat([]byte("a"), 24))...), ErrInvalidClientIDLen31},
+	}
+
+	for _, tc := range testCases {
+		if _, err := newCONNECTFromBytes([]byte{TypeCONNECT << 4, byte(len(tc.remaining))}, tc.remaining, mqtt.ProtocolVersion31); err != tc.want {
+			t.Errorf("newCONNECTFromBytes(%v) => %v, want => %v", tc.remaining, err, tc.want)
+		}
+	}
+}
@@ -2,8 +2,14 @@ package packet
 
 import "errors"
 
-// Error value
-var ErrInvalidByteLen = errors.New("invalid byte length")
+// Maximum number of the bytes of the Variable Byte Integer
+const maxLenVarInt = 4
+
+// Error values
+var (
+	ErrInvalidByteLen = errors.New("invalid byte length")
+	ErrInvalidVarInt  = errors.New("the Variable Byte Integer exceeds four bytes")
+)
 
 // decodeUint16 converts the slice of bytes in big-endian order
 // into an unsigned 16-bit integer.
@@ -15,3 +21,41 @@ func decodeUint16(b []byte) (uint16, error) {
 
 	return uint16(b[0])<<8 | uint16(b[1]), nil
 }
+
+// decodeUint32 converts the slice of bytes in big-endian order
+// into an unsigned 32-bit integer.
+func decodeUint32(b []byte) (uint32, error) {
+	// Check the length of the slice of bytes.
+	if len(b) != 4 {
+		return 0, ErrInvalidByteLen
+	}
+
+	return uint32(b[0])<<24 | uint32(b[1])<<16 | uint32(b[2])<<8 | uint32(b[3]), nil
+}
+
+// decodeVarInt decodes the Variable Byte Integer from the head
+// of the slice and returns it and the rest of the slice.
+func decodeVarInt(b []byte) (uint32, []byte, error) {
+	var mp uint32 = 1 // multiplier
+	var n uint32
+
+	for i := 0; ; i++ {
+		// Check the number of the bytes.
+		if i == maxLenVarInt {
+			return 0, nil, ErrInvalidVarInt
+		}
+
+		// Check the length of the slice.
+		if i == len(b) {
+			return 0, nil, ErrInvalidRemainingLen
+		}
+
+		n += uint32(b[i]&0x7F) * mp
+
+		if b[i]&0x80 == 0 {
+			return n, b[i+1:], nil
+		}
+
+		mp *= 128
+	}
+}
@@ -31,3 +31,41 @@ func Test_decodeUint16(t *testing.T) {
 		i++
 	}
 }
+
+func Test_decodeUint32(t *testing.T) {
+	if _, err := decodeUint32(nil); err != ErrInvalidByteLen {
+		invalidError(t, err, ErrInvalidByteLen)
+	}
+
+	if n, _ := decodeUint32(encodeUint32(0x01234567)); n != 0x01234567 {
+		t.Errorf("n => %X, want => %X", n, 0x01234567)
+	}
+}
+
+func Test_decodeVarInt(t *testing.T) {
+	testCases := []struct {
+		in   []byte
+		n    uint32
+		rest []byte
+		err  error
+	}{
+		{in: nil, err: ErrInvalidRemainingLen},
+		{in: []byte{0x80}, err: ErrInvalidRemainingLen},
+		{in: []byte{0x80, 0x80, 0x80, 0x80, 0x01}, err: ErrInvalidVarInt},
+		{in: []byte{0x00, 0x01}, n: 0, rest: []byte{0x01}},
+		{in: []byte{0x80, 0x01}, n: 128, rest: []byte{}},
+		{in: []byte{0xFF, 0xFF, 0xFF, 0x7F}, n: 268435455, rest: []byte{}},
+	}
+
+	for _, tc := range testCases {
+		n, rest, err := decodeVarInt(tc.in)
+		if err != tc.err {
+			invalidError(t, err, tc.err)
+			continue
+		}
+
+		if n != tc.n || string(rest) != string(tc.rest) {
+			t.Errorf("decodeVarInt(%v) => (%d, %v), want => (%d, %v)", tc.in, n, rest, tc.n, tc.rest)
+		}
+	}
+}
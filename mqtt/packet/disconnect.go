@@ -1,8 +1,19 @@
 package packet
 
+import "github.com/yosssi/gmq/mqtt"
+
+// Length of the fixed header of the DISCONNECT Packet
+const lenDISCONNECTFixedHeader = 2
+
 // DISCONNECT represents a DISCONNECT Packet.
 type DISCONNECT struct {
 	base
+	// ReasonCode is the Reason Code of the variable header.
+	// It is used only in MQTT 5.0.
+	ReasonCode byte
+	// Properties is the Properties of the variable header.
+	// It is used only in MQTT 5.0.
+	Properties *Properties
 }
 
 // NewDISCONNECT creates and returns a DISCONNECT Packet.
@@ -16,3 +27,150 @@ func NewDISCONNECT() Packet {
 	// Return the Packet.
 	return p
 }
+
+// NewDISCONNECTWithOptions creates and returns a DISCONNECT Packet
+// which can contain the Reason Code and the Properties in MQTT 5.0.
+func NewDISCONNECTWithOptions(opts *DISCONNECTOptions) (Packet, error) {
+	// Initialize the options.
+	if opts == nil {
+		opts = &DISCONNECTOptions{}
+	}
+
+	// Validate the options.
+	if err := opts.validate(); err != nil {
+		return nil, err
+	}
+
+	// Create a DISCONNECT Packet.
+	p := &DISCONNECT{
+		ReasonCode: opts.ReasonCode,
+		Properties: opts.Properties,
+	}
+
+	// Set the protocol version to the Packet.
+	p.version = opts.ProtocolVersion
+
+	// Set the variable header to the Packet in MQTT 5.0.
+	if p.v5() {
+		p.variableHeader = appendReasonProperties(p.variableHeader, p.ReasonCode, p.Properties)
+	}
+
+	// Set the fixed header to the Packet.
+	p.fixedHeader = append(p.fixedHeader, TypeDISCONNECT<<4)
+	p.appendRemainingLength()
+
+	// Return the Packet.
+	return p, nil
+}
+
+// NewDISCONNECTFromBytes creates a DISCONNECT Packet from
+// the byte data and returns it.
+func NewDISCONNECTFromBytes(fixedHeader FixedHeader, remaining []byte) (Packet, error) {
+	// Validate the byte data.
+	if err := validateDISCONNECTBytes(fixedHeader, remaining); err != nil {
+		return nil, err
+	}
+
+	// Create a DISCONNECT Packet.
+	p := &DISCONNECT{}
+
+	// Set the fixed header to the Packet.
+	p.fixedHeader = fixedHeader
+
+	// Return the Packet.
+	return p, nil
+}
+
+// newDISCONNECTFromBytes creates a DISCONNECT Packet of the protocol
+// version from the byte data and returns it.
+func newDISCONNECTFromBytes(fixedHeader FixedHeader, remaining []byte, version byte) (Packet, error) {
+	// Decode the byte data of MQTT 3.1.1.
+	if version != mqtt.ProtocolVersion5 {
+		return NewDISCONNECTFromBytes(fixedHeader, remaining)
+	}
+
+	// Check the fixed header.
+	if err := fixedHeader.validate(TypeDISCONNECT, 0x00); err != nil {
+		return nil, err
+	}
+
+	// Decode the Reason Code and the Properties.
+	reasonCode, props, err := decodeReasonProperties(remaining, TypeDISCONNECT)
+	if err != nil {
+		return nil, err
+	}
+
+	// Create a DISCONNECT Packet.
+	p := &DISCONNECT{
+		ReasonCode: reasonCode,
+		Properties: props,
+	}
+
+	// Set the fixed header, the variable header and
+	// the protocol version to the Packet.
+	p.fixedHeader = fixedHeader
+	p.variableHeader = remaining
+	p.version = version
+
+	// Return the Packet.
+	return p, nil
+}
+
+// validateDISCONNECTBytes validates the fixed header and the remaining.
+func validateDISCONNECTBytes(fixedHeader FixedHeader, remaining []byte) error {
+	// Extract the MQTT Control Packet type.
+	ptype, err := fixedHeader.ptype()
+	if err != nil {
+		return err
+	}
+
+	// Check the length of the fixed header.
+	if len(fixedHeader) != lenDISCONNECTFixedHeader {
+		return ErrInvalidFixedHeaderLen
+	}
+
+	// Check the MQTT Control Packet type.
+	if ptype != TypeDISCONNECT {
+		return ErrInvalidPacketType
+	}
+
+	// Check the reserved bits of the fixed header.
+	if fixedHeader[0]<<4 != 0x00 {
+		return ErrInvalidFixedHeader
+	}
+
+	// Check the Remaining Length of the fixed header.
+	if fixedHeader[1] != 0x00 {
+		return ErrInvalidRemainingLength
+	}
+
+	// Check the length of the remaining.
+	if len(remaining) != 0 {
+		return ErrInvalidRemainingLen
+	}
+
+	return nil
+}
+
+// String returns the human-readable representation of the Packet.
+func (p *DISCONNECT) String() string {
+	return p.jsonPacket().String()
+}
+
+// MarshalJSON returns the JSON representation of the Packet.
+func (p *DISCONNECT) MarshalJSON() ([]byte, error) {
+	return p.jsonPacket().marshal()
+}
+
+// jsonPacket creates the JSON representation of the Packet.
+func (p *DISCONNECT) jsonPacket() *jsonPacket {
+	jp := p.newJSONPacket(TypeDISCONNECT)
+
+	// Set the Reason Code and the Properties of MQTT 5.0.
+	if p.v5() {
+		jp.ReasonCode = bytePointer(p.ReasonCode)
+		jp.Properties = p.Properties
+	}
+
+	return jp
+}
@@ -0,0 +1,20 @@
+package packet
+
+// DISCONNECTOptions represents options for a DISCONNECT Packet.
+type DISCONNECTOptions struct {
+	// ProtocolVersion is the protocol version of the Packet.
+	// MQTT 3.1.1 is used if it is zero.
+	ProtocolVersion byte
+	// ReasonCode is the Reason Code of the variable header.
+	// It is used only in MQTT 5.0.
+	ReasonCode byte
+	// Properties is the Properties of the variable header.
+	// It is used only in MQTT 5.0.
+	Properties *Properties
+}
+
+// validate validates the options.
+func (opts *DISCONNECTOptions) validate() error {
+	// Check the protocol version, the Reason Code and the Properties.
+	return validateVersionedOptions(opts.ProtocolVersion, TypeDISCONNECT, opts.ReasonCode, opts.Properties)
+}
@@ -1,6 +1,11 @@
 package packet
 
-import "testing"
+import (
+	"bytes"
+	"testing"
+
+	"github.com/yosssi/gmq/mqtt"
+)
 
 func TestNewDISCONNECT(t *testing.T) {
 	p := NewDISCONNECT()
@@ -15,3 +20,107 @@ func TestNewDISCONNECT(t *testing.T) {
 		t.Errorf("ptype => %X, want => %X", ptype, TypeDISCONNECT)
 	}
 }
+
+func TestNewDISCONNECTFromBytes_errValidateDISCONNECTBytes(t *testing.T) {
+	if _, err := NewDISCONNECTFromBytes(nil, nil); err != ErrInvalidFixedHeaderLen {
+		invalidError(t, err, ErrInvalidFixedHeaderLen)
+	}
+}
+
+func TestNewDISCONNECTFromBytes(t *testing.T) {
+	if _, err := NewDISCONNECTFromBytes([]byte{TypeDISCONNECT << 4, 0x00}, nil); err != nil {
+		nilErrorExpected(t, err)
+	}
+}
+
+func Test_validateDISCONNECTBytes_ptypeErrInvalidFixedHeaderLen(t *testing.T) {
+	if err := validateDISCONNECTBytes(nil, nil); err != ErrInvalidFixedHeaderLen {
+		invalidError(t, err, ErrInvalidFixedHeaderLen)
+	}
+}
+
+func Test_validateDISCONNECTBytes_ErrInvalidFixedHeaderLen(t *testing.T) {
+	if err := validateDISCONNECTBytes([]byte{TypeDISCONNECT << 4}, nil); err != ErrInvalidFixedHeaderLen {
+		invalidError(t, err, ErrInvalidFixedHeaderLen)
+	}
+}
+
+func Test_validateDISCONNECTBytes_ErrInvalidPacketType(t *testing.T) {
+	if err := validateDISCONNECTBytes([]byte{0x00 << 4, 0x00}, nil); err != ErrInvalidPacketType {
+		invalidError(t, err, ErrInvalidPacketType)
+	}
+}
+
+func Test_validateDISCONNECTBytes_ErrInvalidFixedHeader(t *testing.T) {
+	if err := validateDISCONNECTBytes([]byte{TypeDISCONNECT<<4 | 0x01, 0x00}, nil); err != ErrInvalidFixedHeader {
+		invalidError(t, err, ErrInvalidFixedHeader)
+	}
+}
+
+func Test_validateDISCONNECTBytes_ErrInvalidRemainingLength(t *testing.T) {
+	if err := validateDISCONNECTBytes([]byte{TypeDISCONNECT << 4, 0x01}, nil); err != ErrInvalidRemainingLength {
+		invalidError(t, err, ErrInvalidRemainingLength)
+	}
+}
+
+func Test_validateDISCONNECTBytes_ErrInvalidRemainingLen(t *testing.T) {
+	if err := validateDISCONNECTBytes([]byte{TypeDISCONNECT << 4, 0x00}, []byte{0x00}); err != ErrInvalidRemainingLen {
+		invalidError(t, err, ErrInvalidRemainingLen)
+	}
+}
+
+func TestNewDISCONNECTWithOptions(t *testing.T) {
+	p, err := NewDISCONNECTWithOptions(nil)
+	if err != nil {
+		nilErrorExpected(t, err)
+		return
+	}
+
+	if want := []byte{TypeDISCONNECT << 4, 0x00}; !bytes.Equal(p.(*DISCONNECT).fixedHeader, want) {
+		t.Errorf("p.fixedHeader => %v, want => %v", p.(*DISCONNECT).fixedHeader, want)
+	}
+
+	if _, err := NewDISCONNECTWithOptions(&DISCONNECTOptions{ReasonCode: ReasonDisconnectWithWillMessage}); err != ErrInvalidReasonCode {
+		invalidError(t, err, ErrInvalidReasonCode)
+	}
+}
+
+func TestNewDISCONNECTWithOptions_v5(t *testing.T) {
+	expiry := uint32(0)
+
+	p, err := NewDISCONNECTWithOptions(&DISCONNECTOptions{
+		ProtocolVersion: mqtt.ProtocolVersion5,
+		ReasonCode:      ReasonDisconnectWithWillMessage,
+		Properties:      &Properties{SessionExpiryInterval: &expiry},
+	})
+	if err != nil {
+		nilErrorExpected(t, err)
+		return
+	}
+
+	decoded := roundTrip5(t, p).(*DISCONNECT)
+
+	if decoded.ReasonCode != ReasonDisconnectWithWillMessage || decoded.Properties == nil || *decoded.Properties.SessionExpiryInterval != 0 {
+		t.Errorf("decoded => %+v, want => the same as %+v", decoded, p)
+	}
+}
+
+func Test_newDISCONNECTFromBytes_v5(t *testing.T) {
+	if _, err := newDISCONNECTFromBytes([]byte{TypeDISCONNECT<<4 | 0x01, 0x00}, nil, mqtt.ProtocolVersion5); err != ErrInvalidFixedHeader {
+		invalidError(t, err, ErrInvalidFixedHeader)
+	}
+
+	if _, err := newDISCONNECTFromBytes([]byte{TypeDISCONNECT << 4, 0x01}, []byte{ReasonGrantedQoS1}, mqtt.ProtocolVersion5); err != ErrInvalidReasonCode {
+		invalidError(t, err, ErrInvalidReasonCode)
+	}
+
+	p, err := newDISCONNECTFromBytes([]byte{TypeDISCONNECT << 4, 0x00}, nil, mqtt.ProtocolVersion5)
+	if err != nil {
+		nilErrorExpected(t, err)
+		return
+	}
+
+	if d := p.(*DISCONNECT); d.ReasonCode != ReasonNormalDisconnection || d.Properties != nil {
+		t.Errorf("p => %+v", d)
+	}
+}
@@ -6,6 +6,18 @@ func encodeUint16(n uint16) []byte {
 	return []byte{byte(n >> 8), byte(n)}
 }
 
+// encodeUint32 converts the unsigned 32-bit integer
+// into a slice of bytes in big-endian order.
+func encodeUint32(n uint32) []byte {
+	return []byte{byte(n >> 24), byte(n >> 16), byte(n >> 8), byte(n)}
+}
+
+// appendVarInt appends the unsigned integer encoded by
+// the variable length encoding scheme to the slice.
+func appendVarInt(b []byte, n uint32) []byte {
+	return appendRemainingLength(b, encodeLength(n))
+}
+
 // encodeLength encodes the unsigned integer
 // by using a variable length encoding scheme.
 func encodeLength(n uint32) uint32 {
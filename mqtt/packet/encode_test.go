@@ -33,3 +33,31 @@ func Test_encodeLength(t *testing.T) {
 		}
 	}
 }
+
+func Test_encodeUint32(t *testing.T) {
+	b := encodeUint32(0x01234567)
+
+	want := []byte{0x01, 0x23, 0x45, 0x67}
+
+	if len(b) != len(want) || b[0] != want[0] || b[1] != want[1] || b[2] != want[2] || b[3] != want[3] {
+		t.Errorf("b => %v, want => %v", b, want)
+	}
+}
+
+func Test_appendVarInt(t *testing.T) {
+	testCases := []struct {
+		in  uint32
+		out []byte
+	}{
+		{in: 0, out: []byte{0x00}},
+		{in: 127, out: []byte{0x7F}},
+		{in: 128, out: []byte{0x80, 0x01}},
+		{in: 268435455, out: []byte{0xFF, 0xFF, 0xFF, 0x7F}},
+	}
+
+	for _, tc := range testCases {
+		if got := appendVarInt(nil, tc.in); string(got) != string(tc.out) {
+			t.Errorf("appendVarInt(nil, %d) => %v, want => %v", tc.in, got, tc.out)
+		}
+	}
+}
@@ -20,3 +20,30 @@ func (fixedHeader FixedHeader) ptype() (byte, error) {
 	// the fixed header and return it.
 	return fixedHeader[0] >> 4, nil
 }
+
+// validate checks the length, the MQTT Control Packet type
+// and the flags of the fixed header.
+func (fixedHeader FixedHeader) validate(ptype byte, flags byte) error {
+	// Extract the MQTT Control Packet type.
+	t, err := fixedHeader.ptype()
+	if err != nil {
+		return err
+	}
+
+	// Check the length of the fixed header.
+	if len(fixedHeader) < 2 {
+		return ErrInvalidFixedHeaderLen
+	}
+
+	// Check the MQTT Control Packet type.
+	if t != ptype {
+		return ErrInvalidPacketType
+	}
+
+	// Check the flags of the fixed header.
+	if fixedHeader[0]&0x0F != flags {
+		return ErrInvalidFixedHeader
+	}
+
+	return nil
+}
@@ -31,3 +31,22 @@ func invalidError(t *testing.T, err, want error) {
 		t.Errorf("err => %q, want => %q", err, want)
 	}
 }
+
+func Test_fixedHeader_validate(t *testing.T) {
+	testCases := []struct {
+		in   FixedHeader
+		want error
+	}{
+		{in: nil, want: ErrInvalidFixedHeaderLen},
+		{in: []byte{TypePUBREL<<4 | 0x02}, want: ErrInvalidFixedHeaderLen},
+		{in: []byte{TypePUBACK<<4 | 0x02, 0x00}, want: ErrInvalidPacketType},
+		{in: []byte{TypePUBREL << 4, 0x00}, want: ErrInvalidFixedHeader},
+		{in: []byte{TypePUBREL<<4 | 0x02, 0x00}, want: nil},
+	}
+
+	for _, tc := range testCases {
+		if err := tc.in.validate(TypePUBREL, 0x02); err != tc.want {
+			t.Errorf("validate() of %v => %v, want => %v", tc.in, err, tc.want)
+		}
+	}
+}
@@ -0,0 +1,494 @@
+package packet
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"unicode/utf8"
+
+	"github.com/yosssi/gmq/mqtt"
+)
+
+// Maximum length of the payload which is shown
+// in the string representation of the Packet
+const maxLenStringPayload = 32
+
+// jsonPacket is the JSON representation of the Packets.
+// The fields which the Packet does not have are omitted.
+type jsonPacket struct {
+	// Type is the name of the MQTT Control Packet type.
+	Type string `json:"type"`
+	// Flags is the flags of the fixed header. It is
+	// ignored when the Packet is created from JSON.
+	Flags byte `json:"flags"`
+	// ProtocolVersion is the protocol version of the Packet.
+	// MQTT 3.1.1 is used if it is zero.
+	ProtocolVersion byte `json:"protocolVersion,omitempty"`
+	// DUP is the DUP flag of the PUBLISH Packet.
+	DUP bool `json:"dup,omitempty"`
+	// QoS is the QoS of the PUBLISH Packet.
+	QoS *byte `json:"qos,omitempty"`
+	// Retain is the Retain of the PUBLISH Packet.
+	Retain bool `json:"retain,omitempty"`
+	// PacketID is the Packet Identifier.
+	PacketID uint16 `json:"packetID,omitempty"`
+	// Topic is the Topic Name of the PUBLISH Packet.
+	Topic string `json:"topic,omitempty"`
+	// Payload is the Application Message of the PUBLISH Packet.
+	Payload []byte `json:"payload,omitempty"`
+	// ClientID is the Client Identifier of the CONNECT Packet.
+	ClientID string `json:"clientID,omitempty"`
+	// UserName is the User Name of the CONNECT Packet.
+	UserName string `json:"userName,omitempty"`
+	// Password is the Password of the CONNECT Packet.
+	Password []byte `json:"password,omitempty"`
+	// CleanSession is the Clean Session of the CONNECT Packet.
+	CleanSession bool `json:"cleanSession,omitempty"`
+	// KeepAlive is the Keep Alive of the CONNECT Packet.
+	KeepAlive uint16 `json:"keepAlive,omitempty"`
+	// Will is the Will of the CONNECT Packet.
+	Will *jsonWill `json:"will,omitempty"`
+	// SessionPresent is the Session Present of the CONNACK Packet.
+	SessionPresent bool `json:"sessionPresent,omitempty"`
+	// ReturnCode is the Connect Return code of the CONNACK Packet.
+	ReturnCode *byte `json:"returnCode,omitempty"`
+	// ReasonCode is the Reason Code of MQTT 5.0.
+	ReasonCode *byte `json:"reasonCode,omitempty"`
+	// Subscriptions is the subscription requests of the SUBSCRIBE Packet.
+	Subscriptions []*jsonSubReq `json:"subscriptions,omitempty"`
+	// TopicFilters is the Topic Filters of the UNSUBSCRIBE Packet.
+	TopicFilters []string `json:"topicFilters,omitempty"`
+	// ReturnCodes is the Return Codes of the SUBACK Packet.
+	ReturnCodes []int `json:"returnCodes,omitempty"`
+	// ReasonCodes is the Reason Codes of the UNSUBACK Packet.
+	ReasonCodes []int `json:"reasonCodes,omitempty"`
+	// Properties is the Properties of MQTT 5.0.
+	Properties *Properties `json:"properties,omitempty"`
+}
+
+// jsonWill is the JSON representation of the Will
+// of the CONNECT Packet.
+type jsonWill struct {
+	// Topic is the Will Topic.
+	Topic string `json:"topic"`
+	// Message is the Will Message.
+	Message []byte `json:"message"`
+	// QoS is the Will QoS.
+	QoS byte `json:"qos"`
+	// Retain is the Will Retain.
+	Retain bool `json:"retain,omitempty"`
+	// Properties is the Will Properties of MQTT 5.0.
+	Properties *Properties `json:"properties,omitempty"`
+}
+
+// jsonSubReq is the JSON representation of
+// the subscription request.
+type jsonSubReq struct {
+	// TopicFilter is the Topic Filter.
+	TopicFilter string `json:"topicFilter"`
+	// QoS is the requesting QoS.
+	QoS byte `json:"qos"`
+	// NoLocal is the No Local option.
+	NoLocal bool `json:"noLocal,omitempty"`
+	// RetainAsPublished is the Retain As Published option.
+	RetainAsPublished bool `json:"retainAsPublished,omitempty"`
+	// RetainHandling is the Retain Handling option.
+	RetainHandling byte `json:"retainHandling,omitempty"`
+}
+
+// newJSONPacket creates a JSON representation of
+// the Packet which has the common fields.
+func (b *base) newJSONPacket(ptype byte) *jsonPacket {
+	jp := &jsonPacket{
+		Type:            typeNames[ptype],
+		ProtocolVersion: b.ProtocolVersion(),
+	}
+
+	// Set the flags of the fixed header.
+	if len(b.fixedHeader) > 0 {
+		jp.Flags = b.fixedHeader[0] & 0x0F
+	}
+
+	return jp
+}
+
+// marshal returns the JSON encoding of the Packet.
+func (jp *jsonPacket) marshal() ([]byte, error) {
+	return json.Marshal(jp)
+}
+
+// String returns the human-readable representation of the Packet.
+// The Password is not shown and the payload is truncated.
+func (jp *jsonPacket) String() string {
+	var buf bytes.Buffer
+
+	buf.WriteString(jp.Type)
+
+	// field writes a field which has the key and the value.
+	field := func(key string, value interface{}) {
+		fmt.Fprintf(&buf, " %s=%v", key, value)
+	}
+
+	field("protocolVersion", jp.ProtocolVersion)
+	field("flags", fmt.Sprintf("0x%02X", jp.Flags))
+
+	if jp.Type == typeNames[TypePUBLISH] {
+		field("dup", jp.DUP)
+		field("qos", *jp.QoS)
+		field("retain", jp.Retain)
+	}
+
+	if jp.PacketID != 0 {
+		field("packetID", jp.PacketID)
+	}
+
+	if jp.Type == typeNames[TypePUBLISH] {
+		field("topic", strconv.Quote(jp.Topic))
+		field("payload", formatPayload(jp.Payload))
+	}
+
+	if jp.Type == typeNames[TypeCONNECT] {
+		field("clientID", strconv.Quote(jp.ClientID))
+		field("cleanSession", jp.CleanSession)
+		field("keepAlive", jp.KeepAlive)
+
+		if jp.UserName != "" {
+			field("userName", strconv.Quote(jp.UserName))
+		}
+
+		if jp.Password != nil {
+			field("password", fmt.Sprintf("<%d bytes>", len(jp.Password)))
+		}
+
+		if jp.Will != nil {
+			field("willTopic", strconv.Quote(jp.Will.Topic))
+			field("willMessage", formatPayload(jp.Will.Message))
+			field("willQoS", jp.Will.QoS)
+			field("willRetain", jp.Will.Retain)
+		}
+	}
+
+	if jp.Type == typeNames[TypeCONNACK] {
+		field("sessionPresent", jp.SessionPresent)
+		field("returnCode", fmt.Sprintf("0x%02X", *jp.ReturnCode))
+	}
+
+	if jp.ReasonCode != nil {
+		field("reasonCode", fmt.Sprintf("0x%02X", *jp.ReasonCode))
+	}
+
+	for _, s := range jp.Subscriptions {
+		// Show the Subscription Options of MQTT 5.0 as well.
+		if jp.ProtocolVersion == mqtt.ProtocolVersion5 {
+			field("topicFilter", fmt.Sprintf("%q(qos=%d,noLocal=%t,retainAsPublished=%t,retainHandling=%d)",
+				s.TopicFilter, s.QoS, s.NoLocal, s.RetainAsPublished, s.RetainHandling))
+		} else {
+			field("topicFilter", fmt.Sprintf("%q(qos=%d)", s.TopicFilter, s.QoS))
+		}
+	}
+
+	for _, s := range jp.TopicFilters {
+		field("topicFilter", strconv.Quote(s))
+	}
+
+	if jp.ReturnCodes != nil {
+		field("returnCodes", jp.ReturnCodes)
+	}
+
+	if jp.ReasonCodes != nil {
+		field("reasonCodes", jp.ReasonCodes)
+	}
+
+	if jp.Properties != nil {
+		if b, err := json.Marshal(jp.Properties); err == nil {
+			field("properties", string(b))
+		}
+	}
+
+	if jp.Will != nil && jp.Will.Properties != nil {
+		if b, err := json.Marshal(jp.Will.Properties); err == nil {
+			field("willProperties", string(b))
+		}
+	}
+
+	return buf.String()
+}
+
+// packet creates a Packet from the JSON representation.
+func (jp *jsonPacket) packet() (Packet, error) {
+	switch jp.Type {
+	case typeNames[TypeCONNECT]:
+		opts := &CONNECTOptions{
+			ClientID:        []byte(jp.ClientID),
+			UserName:        []byte(jp.UserName),
+			Password:        jp.Password,
+			CleanSession:    jp.CleanSession,
+			KeepAlive:       jp.KeepAlive,
+			ProtocolVersion: jp.ProtocolVersion,
+			Properties:      jp.Properties,
+		}
+
+		if jp.Will != nil {
+			opts.WillTopic = []byte(jp.Will.Topic)
+			opts.WillMessage = jp.Will.Message
+			opts.WillQoS = jp.Will.QoS
+			opts.WillRetain = jp.Will.Retain
+			opts.WillProperties = jp.Will.Properties
+		}
+
+		return NewCONNECT(opts)
+	case typeNames[TypeCONNACK]:
+		// Encode the variable header.
+		variableHeader := []byte{0x00, byteValue(jp.ReturnCode)}
+
+		if jp.SessionPresent {
+			variableHeader[0] = 0x01
+		}
+
+		if jp.ProtocolVersion == mqtt.ProtocolVersion5 {
+			variableHeader = appendProperties(variableHeader, jp.Properties)
+		}
+
+		return newCONNACKFromBytes(newFixedHeader(TypeCONNACK<<4, len(variableHeader)), variableHeader, jp.version())
+	case typeNames[TypePUBLISH]:
+		return NewPUBLISH(&PUBLISHOptions{
+			DUP:             jp.DUP,
+			QoS:             byteValue(jp.QoS),
+			Retain:          jp.Retain,
+			TopicName:       []byte(jp.Topic),
+			PacketID:        jp.PacketID,
+			Message:         jp.Payload,
+			ProtocolVersion: jp.ProtocolVersion,
+			Properties:      jp.Properties,
+		})
+	case typeNames[TypePUBACK]:
+		return NewPUBACK(&PUBACKOptions{
+			PacketID:        jp.PacketID,
+			ProtocolVersion: jp.ProtocolVersion,
+			ReasonCode:      byteValue(jp.ReasonCode),
+			Properties:      jp.Properties,
+		})
+	case typeNames[TypePUBREC]:
+		return NewPUBREC(&PUBRECOptions{
+			PacketID:        jp.PacketID,
+			ProtocolVersion: jp.ProtocolVersion,
+			ReasonCode:      byteValue(jp.ReasonCode),
+			Properties:      jp.Properties,
+		})
+	case typeNames[TypePUBREL]:
+		return NewPUBREL(&PUBRELOptions{
+			PacketID:        jp.PacketID,
+			ProtocolVersion: jp.ProtocolVersion,
+			ReasonCode:      byteValue(jp.ReasonCode),
+			Properties:      jp.Properties,
+		})
+	case typeNames[TypePUBCOMP]:
+		return NewPUBCOMP(&PUBCOMPOptions{
+			PacketID:        jp.PacketID,
+			ProtocolVersion: jp.ProtocolVersion,
+			ReasonCode:      byteValue(jp.ReasonCode),
+			Properties:      jp.Properties,
+		})
+	case typeNames[TypeSUBSCRIBE]:
+		opts := &SUBSCRIBEOptions{
+			PacketID:        jp.PacketID,
+			ProtocolVersion: jp.ProtocolVersion,
+			Properties:      jp.Properties,
+		}
+
+		for _, s := range jp.Subscriptions {
+			opts.SubReqs = append(opts.SubReqs, &SubReq{
+				TopicFilter:       []byte(s.TopicFilter),
+				QoS:               s.QoS,
+				NoLocal:           s.NoLocal,
+				RetainAsPublished: s.RetainAsPublished,
+				RetainHandling:    s.RetainHandling,
+			})
+		}
+
+		return NewSUBSCRIBE(opts)
+	case typeNames[TypeSUBACK]:
+		// Encode the remaining.
+		remaining := encodeUint16(jp.PacketID)
+
+		if jp.ProtocolVersion == mqtt.ProtocolVersion5 {
+			remaining = appendProperties(remaining, jp.Properties)
+		}
+
+		remaining = appendCodes(remaining, jp.ReturnCodes)
+
+		return newSUBACKFromBytes(newFixedHeader(TypeSUBACK<<4, len(remaining)), remaining, jp.version())
+	case typeNames[TypeUNSUBSCRIBE]:
+		opts := &UNSUBSCRIBEOptions{
+			PacketID:        jp.PacketID,
+			ProtocolVersion: jp.ProtocolVersion,
+			Properties:      jp.Properties,
+		}
+
+		for _, s := range jp.TopicFilters {
+			opts.TopicFilters = append(opts.TopicFilters, []byte(s))
+		}
+
+		return NewUNSUBSCRIBE(opts)
+	case typeNames[TypeUNSUBACK]:
+		// Encode the remaining.
+		remaining := encodeUint16(jp.PacketID)
+
+		if jp.ProtocolVersion == mqtt.ProtocolVersion5 {
+			remaining = appendProperties(remaining, jp.Properties)
+		}
+
+		// The Reason Codes are appended in any protocol version
+		// so that the decoder rejects them before MQTT 5.0.
+		remaining = appendCodes(remaining, jp.ReasonCodes)
+
+		return newUNSUBACKFromBytes(newFixedHeader(TypeUNSUBACK<<4, len(remaining)), remaining, jp.version())
+	case typeNames[TypePINGREQ]:
+		return NewPINGREQ(), nil
+	case typeNames[TypePINGRESP]:
+		return NewPINGRESPFromBytes(newFixedHeader(TypePINGRESP<<4, 0), nil)
+	case typeNames[TypeDISCONNECT]:
+		return NewDISCONNECTWithOptions(&DISCONNECTOptions{
+			ProtocolVersion: jp.ProtocolVersion,
+			ReasonCode:      byteValue(jp.ReasonCode),
+			Properties:      jp.Properties,
+		})
+	case typeNames[TypeAUTH]:
+		return NewAUTH(&AUTHOptions{
+			ReasonCode: byteValue(jp.ReasonCode),
+			Properties: jp.Properties,
+		})
+	default:
+		return nil, ErrInvalidPacketType
+	}
+}
+
+// version returns the protocol version of the Packet.
+func (jp *jsonPacket) version() byte {
+	if jp.ProtocolVersion == 0 {
+		return mqtt.ProtocolVersion311
+	}
+
+	return jp.ProtocolVersion
+}
+
+// NewFromJSON creates a Packet from the JSON representation
+// which the MarshalJSON methods of the Packets return. The Packet
+// is validated in the same way as the other constructors and
+// then by the decoder of the byte data so that the JSON
+// representation does not describe a Packet which the decoder
+// rejects.
+func NewFromJSON(data []byte) (Packet, error) {
+	// Decode the JSON representation.
+	var jp jsonPacket
+
+	if err := json.Unmarshal(data, &jp); err != nil {
+		return nil, err
+	}
+
+	// Create a Packet.
+	p, err := jp.packet()
+	if err != nil {
+		return nil, err
+	}
+
+	// Encode the Packet.
+	var buf bytes.Buffer
+
+	if _, err := p.WriteTo(&buf); err != nil {
+		return nil, err
+	}
+
+	// Decode the byte data in the protocol version of the JSON
+	// representation, or in that of the Packet if it is omitted,
+	// and return the Packet.
+	r := NewReader(&buf)
+	r.ProtocolVersion = jp.ProtocolVersion
+
+	if r.ProtocolVersion == 0 {
+		r.ProtocolVersion = p.(interface{ ProtocolVersion() byte }).ProtocolVersion()
+	}
+
+	return r.ReadPacket()
+}
+
+// newFixedHeader creates a fixed header which has
+// the first byte and the Remaining Length.
+func newFixedHeader(b byte, remainingLen int) FixedHeader {
+	return appendRemainingLength([]byte{b}, encodeLength(uint32(remainingLen)))
+}
+
+// formatPayload returns the human-readable representation of the
+// payload. The payload is quoted if it is a UTF-8 string or is encoded
+// in base64 otherwise, and it is truncated if it is too long.
+func formatPayload(b []byte) string {
+	l := len(b)
+
+	text := utf8.Valid(b)
+
+	// Truncate the payload at the boundary of the characters.
+	if l > maxLenStringPayload {
+		b = b[:maxLenStringPayload]
+
+		for text && !utf8.Valid(b) {
+			b = b[:len(b)-1]
+		}
+	}
+
+	// Format the payload.
+	var s string
+
+	if text {
+		s = strconv.Quote(string(b))
+	} else {
+		s = "base64:" + base64.StdEncoding.EncodeToString(b)
+	}
+
+	if len(b) < l {
+		s += "..."
+	}
+
+	return fmt.Sprintf("%s(%d bytes)", s, l)
+}
+
+// byteValue returns the value which the pointer refers to
+// or zero if the pointer is nil.
+func byteValue(b *byte) byte {
+	if b == nil {
+		return 0
+	}
+
+	return *b
+}
+
+// bytePointer returns a pointer to the byte.
+func bytePointer(b byte) *byte {
+	return &b
+}
+
+// appendCodes appends the Return Codes or the Reason Codes to the slice.
+func appendCodes(b []byte, codes []int) []byte {
+	for _, c := range codes {
+		b = append(b, byte(c))
+	}
+
+	return b
+}
+
+// intCodes converts the Return Codes or the Reason Codes
+// into a slice of the integers.
+func intCodes(codes []byte) []int {
+	if codes == nil {
+		return nil
+	}
+
+	ints := make([]int, len(codes))
+
+	for i, c := range codes {
+		ints[i] = int(c)
+	}
+
+	return ints
+}
@@ -0,0 +1,245 @@
+package packet
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/yosssi/gmq/mqtt"
+)
+
+func TestNewFromJSON(t *testing.T) {
+	testCases := []struct {
+		in   string
+		want string
+	}{
+		{
+			in:   `{"type":"CONNECT","clientID":"cid","userName":"u","password":"cGFzcw==","cleanSession":true,"keepAlive":60,"will":{"topic":"w","message":"bXNn","qos":1}}`,
+			want: `{"type":"CONNECT","flags":0,"protocolVersion":4,"clientID":"cid","userName":"u","password":"cGFzcw==","cleanSession":true,"keepAlive":60,"will":{"topic":"w","message":"bXNn","qos":1}}`,
+		},
+		{
+			in:   `{"type":"CONNACK","protocolVersion":5,"sessionPresent":true,"returnCode":0,"properties":{"topicAliasMaximum":10}}`,
+			want: `{"type":"CONNACK","flags":0,"protocolVersion":5,"sessionPresent":true,"returnCode":0,"properties":{"topicAliasMaximum":10}}`,
+		},
+		{
+			in:   `{"type":"PUBLISH","dup":true,"qos":1,"retain":true,"packetID":1,"topic":"a/b","payload":"aGVsbG8="}`,
+			want: `{"type":"PUBLISH","flags":11,"protocolVersion":4,"dup":true,"qos":1,"retain":true,"packetID":1,"topic":"a/b","payload":"aGVsbG8="}`,
+		},
+		{
+			in:   `{"type":"PUBACK","packetID":1}`,
+			want: `{"type":"PUBACK","flags":0,"protocolVersion":4,"packetID":1}`,
+		},
+		{
+			in:   `{"type":"PUBREC","protocolVersion":5,"packetID":1,"reasonCode":16}`,
+			want: `{"type":"PUBREC","flags":0,"protocolVersion":5,"packetID":1,"reasonCode":16}`,
+		},
+		{
+			in:   `{"type":"PUBREL","packetID":1}`,
+			want: `{"type":"PUBREL","flags":2,"protocolVersion":4,"packetID":1}`,
+		},
+		{
+			in:   `{"type":"PUBCOMP","packetID":1}`,
+			want: `{"type":"PUBCOMP","flags":0,"protocolVersion":4,"packetID":1}`,
+		},
+		{
+			in:   `{"type":"SUBSCRIBE","packetID":1,"subscriptions":[{"topicFilter":"a/+","qos":1},{"topicFilter":"b/#","qos":2}]}`,
+			want: `{"type":"SUBSCRIBE","flags":2,"protocolVersion":4,"packetID":1,"subscriptions":[{"topicFilter":"a/+","qos":1},{"topicFilter":"b/#","qos":2}]}`,
+		},
+		{
+			in:   `{"type":"SUBSCRIBE","protocolVersion":5,"packetID":1,"subscriptions":[{"topicFilter":"a/+","qos":1,"noLocal":true,"retainAsPublished":true,"retainHandling":2}]}`,
+			want: `{"type":"SUBSCRIBE","flags":2,"protocolVersion":5,"packetID":1,"subscriptions":[{"topicFilter":"a/+","qos":1,"noLocal":true,"retainAsPublished":true,"retainHandling":2}],"properties":{}}`,
+		},
+		{
+			in:   `{"type":"SUBACK","packetID":1,"returnCodes":[1,128]}`,
+			want: `{"type":"SUBACK","flags":0,"protocolVersion":4,"packetID":1,"returnCodes":[1,128]}`,
+		},
+		{
+			in:   `{"type":"UNSUBSCRIBE","packetID":1,"topicFilters":["a/+","b/#"]}`,
+			want: `{"type":"UNSUBSCRIBE","flags":2,"protocolVersion":4,"packetID":1,"topicFilters":["a/+","b/#"]}`,
+		},
+		{
+			in:   `{"type":"UNSUBACK","protocolVersion":5,"packetID":1,"reasonCodes":[0,17],"properties":{}}`,
+			want: `{"type":"UNSUBACK","flags":0,"protocolVersion":5,"packetID":1,"reasonCodes":[0,17],"properties":{}}`,
+		},
+		{
+			in:   `{"type":"PINGREQ"}`,
+			want: `{"type":"PINGREQ","flags":0,"protocolVersion":4}`,
+		},
+		{
+			in:   `{"type":"PINGRESP"}`,
+			want: `{"type":"PINGRESP","flags":0,"protocolVersion":4}`,
+		},
+		{
+			in:   `{"type":"DISCONNECT","protocolVersion":5,"reasonCode":4}`,
+			want: `{"type":"DISCONNECT","flags":0,"protocolVersion":5,"reasonCode":4}`,
+		},
+		{
+			in:   `{"type":"AUTH","reasonCode":24,"properties":{"authenticationMethod":"bWV0aG9k"}}`,
+			want: `{"type":"AUTH","flags":0,"protocolVersion":5,"reasonCode":24,"properties":{"authenticationMethod":"bWV0aG9k"}}`,
+		},
+	}
+
+	for _, tc := range testCases {
+		p, err := NewFromJSON([]byte(tc.in))
+		if err != nil {
+			nilErrorExpected(t, err)
+			continue
+		}
+
+		got, err := json.Marshal(p)
+		if err != nil {
+			nilErrorExpected(t, err)
+			continue
+		}
+
+		if string(got) != tc.want {
+			t.Errorf("json.Marshal(p) => %s, want => %s", got, tc.want)
+		}
+
+		// The JSON representation creates the same Packet.
+		q, err := NewFromJSON(got)
+		if err != nil {
+			nilErrorExpected(t, err)
+			continue
+		}
+
+		var bp, bq bytes.Buffer
+
+		p.WriteTo(&bp)
+		q.WriteTo(&bq)
+
+		if !bytes.Equal(bp.Bytes(), bq.Bytes()) {
+			t.Errorf("%s => %v, want => %v", got, bq.Bytes(), bp.Bytes())
+		}
+	}
+}
+
+func TestNewFromJSON_bytes(t *testing.T) {
+	p, err := NewFromJSON([]byte(`{"type":"PUBLISH","qos":1,"packetID":10,"topic":"a","payload":"YmM="}`))
+	if err != nil {
+		nilErrorExpected(t, err)
+		return
+	}
+
+	var b bytes.Buffer
+
+	p.WriteTo(&b)
+
+	want := []byte{TypePUBLISH<<4 | 0x02, 0x07, 0x00, 0x01, 0x61, 0x00, 0x0A, 0x62, 0x63}
+
+	if !bytes.Equal(b.Bytes(), want) {
+		t.Errorf("b.Bytes() => %v, want => %v", b.Bytes(), want)
+	}
+}
+
+func TestNewFromJSON_err(t *testing.T) {
+	testCases := []struct {
+		in   string
+		want error
+	}{
+		{in: `{"type":"UNKNOWN"}`, want: ErrInvalidPacketType},
+		{in: `{}`, want: ErrInvalidPacketType},
+		{in: `{"type":"PUBLISH"}`, want: ErrNoTopicName},
+		{in: `{"type":"PUBACK"}`, want: ErrInvalidPacketID},
+		{in: `{"type":"CONNACK","returnCode":6}`, want: ErrInvalidConnectReturnCode},
+		{in: `{"type":"AUTH","protocolVersion":4,"reasonCode":24}`, want: ErrInvalidPacketType},
+		{in: `{"type":"PINGREQ","protocolVersion":9}`, want: ErrInvalidProtocolLevel},
+		{in: `{"type":"UNSUBACK","packetID":1,"reasonCodes":[17]}`, want: ErrInvalidRemainingLength},
+	}
+
+	for _, tc := range testCases {
+		if _, err := NewFromJSON([]byte(tc.in)); err != tc.want {
+			invalidError(t, err, tc.want)
+		}
+	}
+
+	if _, err := NewFromJSON([]byte(`{`)); err == nil {
+		t.Error("err => nil, want => not nil")
+	}
+}
+
+func TestPUBLISH_String(t *testing.T) {
+	p, err := NewPUBLISH(&PUBLISHOptions{
+		QoS:       mqtt.QoS1,
+		TopicName: []byte("a/b"),
+		PacketID:  1,
+		Message:   []byte(strings.Repeat("a", maxLenStringPayload+1)),
+	})
+	if err != nil {
+		nilErrorExpected(t, err)
+		return
+	}
+
+	want := `PUBLISH protocolVersion=4 flags=0x02 dup=false qos=1 retain=false packetID=1 topic="a/b" payload="` + strings.Repeat("a", maxLenStringPayload) + `"...(33 bytes)`
+
+	if got := p.(*PUBLISH).String(); got != want {
+		t.Errorf("p.String() => %s, want => %s", got, want)
+	}
+}
+
+func TestCONNECT_String(t *testing.T) {
+	p, err := NewCONNECT(&CONNECTOptions{
+		ClientID: []byte("cid"),
+		UserName: []byte("u"),
+		Password: []byte("pass"),
+	})
+	if err != nil {
+		nilErrorExpected(t, err)
+		return
+	}
+
+	got := p.(*CONNECT).String()
+
+	if strings.Contains(got, "pass\"") {
+		t.Errorf("p.String() => %s, the Password must not be shown", got)
+	}
+
+	if want := `CONNECT protocolVersion=4 flags=0x00 clientID="cid" cleanSession=false keepAlive=0 userName="u" password=<4 bytes>`; got != want {
+		t.Errorf("p.String() => %s, want => %s", got, want)
+	}
+}
+
+func TestSUBSCRIBE_String(t *testing.T) {
+	p, err := NewSUBSCRIBE(&SUBSCRIBEOptions{
+		PacketID: 1,
+		SubReqs: []*SubReq{
+			{
+				TopicFilter:       []byte("a/+"),
+				QoS:               mqtt.QoS1,
+				NoLocal:           true,
+				RetainAsPublished: true,
+				RetainHandling:    2,
+			},
+		},
+		ProtocolVersion: mqtt.ProtocolVersion5,
+	})
+	if err != nil {
+		nilErrorExpected(t, err)
+		return
+	}
+
+	want := `SUBSCRIBE protocolVersion=5 flags=0x02 packetID=1 topicFilter="a/+"(qos=1,noLocal=true,retainAsPublished=true,retainHandling=2)`
+
+	if got := p.(*SUBSCRIBE).String(); got != want {
+		t.Errorf("p.String() => %s, want => %s", got, want)
+	}
+}
+
+func Test_formatPayload(t *testing.T) {
+	testCases := []struct {
+		in   []byte
+		want string
+	}{
+		{in: nil, want: `""(0 bytes)`},
+		{in: []byte("abc"), want: `"abc"(3 bytes)`},
+		{in: []byte{0xFF, 0x00}, want: `base64:/wA=(2 bytes)`},
+		{in: []byte(strings.Repeat("a", maxLenStringPayload-1) + "あ"), want: `"` + strings.Repeat("a", maxLenStringPayload-1) + `"...(34 bytes)`},
+	}
+
+	for _, tc := range testCases {
+		if got := formatPayload(tc.in); got != tc.want {
+			t.Errorf("formatPayload(%v) => %s, want => %s", tc.in, got, tc.want)
+		}
+	}
+}
@@ -3,6 +3,8 @@ package packet
 import (
 	"errors"
 	"io"
+
+	"github.com/yosssi/gmq/mqtt"
 )
 
 // Error value
@@ -15,34 +17,80 @@ type Packet interface {
 	Type() (byte, error)
 }
 
-// NewFromBytes creates a Packet from the byte data and returns it.
+// NewFromBytes creates a Packet of MQTT 3.1.1 from
+// the byte data and returns it.
 func NewFromBytes(fixedHeader FixedHeader, remaining []byte) (Packet, error) {
+	return NewFromBytesVersion(fixedHeader, remaining, mqtt.ProtocolVersion311)
+}
+
+// NewFromBytesVersion creates a Packet of the protocol version
+// from the byte data and returns it.
+func NewFromBytesVersion(fixedHeader FixedHeader, remaining []byte, version byte) (Packet, error) {
 	// Extract the MQTT Control Packet type from the fixed header.
 	ptype, err := fixedHeader.ptype()
 	if err != nil {
 		return nil, err
 	}
 
+	// Check the protocol version.
+	if !mqtt.ValidProtocolVersion(version) {
+		return nil, ErrInvalidProtocolLevel
+	}
+
+	// Create a Packet.
+	p, err := newFromBytes(ptype, fixedHeader, remaining, version)
+	if err != nil {
+		return nil, err
+	}
+
+	// Set the protocol version to the Packet. Some Packets of MQTT 3.1
+	// are decoded in the same way as MQTT 3.1.1.
+	p.(interface{ setVersion(byte) }).setVersion(version)
+
+	// Return the Packet.
+	return p, nil
+}
+
+// newFromBytes creates a Packet of the MQTT Control Packet type
+// and the protocol version from the byte data and returns it.
+func newFromBytes(ptype byte, fixedHeader FixedHeader, remaining []byte, version byte) (Packet, error) {
 	// Create and return a Packet.
 	switch ptype {
+	case TypeCONNECT:
+		return newCONNECTFromBytes(fixedHeader, remaining, version)
 	case TypeCONNACK:
-		return NewCONNACKFromBytes(fixedHeader, remaining)
+		return newCONNACKFromBytes(fixedHeader, remaining, version)
 	case TypePUBLISH:
-		return NewPUBLISHFromBytes(fixedHeader, remaining)
+		return newPUBLISHFromBytes(fixedHeader, remaining, version)
 	case TypePUBACK:
-		return NewPUBACKFromBytes(fixedHeader, remaining)
+		return newPUBACKFromBytes(fixedHeader, remaining, version)
 	case TypePUBREC:
-		return NewPUBRECFromBytes(fixedHeader, remaining)
+		return newPUBRECFromBytes(fixedHeader, remaining, version)
 	case TypePUBREL:
-		return NewPUBRELFromBytes(fixedHeader, remaining)
+		return newPUBRELFromBytes(fixedHeader, remaining, version)
 	case TypePUBCOMP:
-		return NewPUBCOMPFromBytes(fixedHeader, remaining)
+		return newPUBCOMPFromBytes(fixedHeader, remaining, version)
+	case TypeSUBSCRIBE:
+		return newSUBSCRIBEFromBytes(fixedHeader, remaining, version)
 	case TypeSUBACK:
-		return NewSUBACKFromBytes(fixedHeader, remaining)
+		return newSUBACKFromBytes(fixedHeader, remaining, version)
+	case TypeUNSUBSCRIBE:
+		return newUNSUBSCRIBEFromBytes(fixedHeader, remaining, version)
 	case TypeUNSUBACK:
-		return NewUNSUBACKFromBytes(fixedHeader, remaining)
+		return newUNSUBACKFromBytes(fixedHeader, remaining, version)
+	case TypePINGREQ:
+		return NewPINGREQFromBytes(fixedHeader, remaining)
 	case TypePINGRESP:
 		return NewPINGRESPFromBytes(fixedHeader, remaining)
+	case TypeDISCONNECT:
+		return newDISCONNECTFromBytes(fixedHeader, remaining, version)
+	case TypeAUTH:
+		// The AUTH Packet exists only in MQTT 5.0.
+		if version != mqtt.ProtocolVersion5 {
+			return nil, ErrInvalidPacketType
+		}
+
+		return NewAUTHFromBytes(fixedHeader, remaining)
 	default:
 		return nil, ErrInvalidPacketType
 	}
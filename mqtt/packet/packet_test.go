@@ -1,6 +1,12 @@
 package packet
 
-import "testing"
+import (
+	"bytes"
+	"encoding/json"
+	"testing"
+
+	"github.com/yosssi/gmq/mqtt"
+)
 
 func TestNewFromBytes_ptypeErr(t *testing.T) {
 	if _, err := NewFromBytes([]byte{}, nil); err != ErrInvalidFixedHeaderLen {
@@ -8,6 +14,12 @@ func TestNewFromBytes_ptypeErr(t *testing.T) {
 	}
 }
 
+func TestNewFromBytes_CONNECT(t *testing.T) {
+	if _, err := NewFromBytes([]byte{TypeCONNECT << 4, 0x0C}, []byte{0x00, 0x04, 0x4D, 0x51, 0x54, 0x54, 0x04, 0x02, 0x00, 0x00, 0x00, 0x00}); err != nil {
+		nilErrorExpected(t, err)
+	}
+}
+
 func TestNewFromBytes_CONNACK(t *testing.T) {
 	if _, err := NewFromBytes([]byte{TypeCONNACK << 4, 0x02}, []byte{0x00, 0x00}); err != nil {
 		nilErrorExpected(t, err)
@@ -44,26 +56,151 @@ func TestNewFromBytes_PUBCOMP(t *testing.T) {
 	}
 }
 
+func TestNewFromBytes_SUBSCRIBE(t *testing.T) {
+	if _, err := NewFromBytes([]byte{TypeSUBSCRIBE<<4 | 0x02, 0x06}, []byte{0x00, 0x01, 0x00, 0x01, 0x61, 0x00}); err != nil {
+		nilErrorExpected(t, err)
+	}
+}
+
 func TestNewFromBytes_SUBACK(t *testing.T) {
 	if _, err := NewFromBytes([]byte{TypeSUBACK << 4, 0x03}, []byte{0x00, 0x01, 0x00}); err != nil {
 		nilErrorExpected(t, err)
 	}
 }
 
+func TestNewFromBytes_UNSUBSCRIBE(t *testing.T) {
+	if _, err := NewFromBytes([]byte{TypeUNSUBSCRIBE<<4 | 0x02, 0x05}, []byte{0x00, 0x01, 0x00, 0x01, 0x61}); err != nil {
+		nilErrorExpected(t, err)
+	}
+}
+
 func TestNewFromBytes_UNSUBACK(t *testing.T) {
 	if _, err := NewFromBytes([]byte{TypeUNSUBACK << 4, 0x02}, []byte{0x00, 0x01}); err != nil {
 		nilErrorExpected(t, err)
 	}
 }
 
+func TestNewFromBytes_PINGREQ(t *testing.T) {
+	if _, err := NewFromBytes([]byte{TypePINGREQ << 4, 0x00}, nil); err != nil {
+		nilErrorExpected(t, err)
+	}
+}
+
 func TestNewFromBytes_PINGRESP(t *testing.T) {
 	if _, err := NewFromBytes([]byte{TypePINGRESP << 4, 0x00}, nil); err != nil {
 		nilErrorExpected(t, err)
 	}
 }
 
+func TestNewFromBytes_DISCONNECT(t *testing.T) {
+	if _, err := NewFromBytes([]byte{TypeDISCONNECT << 4, 0x00}, nil); err != nil {
+		nilErrorExpected(t, err)
+	}
+}
+
 func TestNewFromBytes_ErrInvalidPacketType(t *testing.T) {
 	if _, err := NewFromBytes([]byte{0x00 << 4}, nil); err != ErrInvalidPacketType {
 		invalidError(t, err, ErrInvalidPacketType)
 	}
 }
+
+func TestNewFromBytesVersion_ErrInvalidProtocolLevel(t *testing.T) {
+	if _, err := NewFromBytesVersion([]byte{TypePINGRESP << 4, 0x00}, nil, 0x06); err != ErrInvalidProtocolLevel {
+		invalidError(t, err, ErrInvalidProtocolLevel)
+	}
+}
+
+func TestNewFromBytesVersion_AUTH(t *testing.T) {
+	if _, err := NewFromBytesVersion([]byte{TypeAUTH << 4, 0x00}, nil, mqtt.ProtocolVersion311); err != ErrInvalidPacketType {
+		invalidError(t, err, ErrInvalidPacketType)
+	}
+
+	if _, err := NewFromBytesVersion([]byte{TypeAUTH << 4, 0x00}, nil, mqtt.ProtocolVersion5); err != nil {
+		nilErrorExpected(t, err)
+	}
+}
+
+func TestNewFromBytesVersion_v5(t *testing.T) {
+	testCases := []struct {
+		fixedHeader FixedHeader
+		remaining   []byte
+	}{
+		{[]byte{TypeCONNECT << 4, 0x0E}, []byte{0x00, 0x04, 0x4D, 0x51, 0x54, 0x54, 0x05, 0x02, 0x00, 0x00, 0x00, 0x00, 0x01, 0x61}},
+		{[]byte{TypeCONNACK << 4, 0x03}, []byte{0x00, 0x00, 0x00}},
+		{[]byte{TypePUBLISH << 4, 0x04}, []byte{0x00, 0x01, 0x61, 0x00}},
+		{[]byte{TypePUBACK << 4, 0x02}, []byte{0x00, 0x01}},
+		{[]byte{TypePUBREC << 4, 0x03}, []byte{0x00, 0x01, 0x10}},
+		{[]byte{TypePUBREL<<4 | 0x02, 0x03}, []byte{0x00, 0x01, 0x92}},
+		{[]byte{TypePUBCOMP << 4, 0x02}, []byte{0x00, 0x01}},
+		{[]byte{TypeSUBSCRIBE<<4 | 0x02, 0x07}, []byte{0x00, 0x01, 0x00, 0x00, 0x01, 0x61, 0x2D}},
+		{[]byte{TypeSUBACK << 4, 0x04}, []byte{0x00, 0x01, 0x00, 0x02}},
+		{[]byte{TypeUNSUBSCRIBE<<4 | 0x02, 0x06}, []byte{0x00, 0x01, 0x00, 0x00, 0x01, 0x61}},
+		{[]byte{TypeUNSUBACK << 4, 0x04}, []byte{0x00, 0x01, 0x00, 0x11}},
+		{[]byte{TypeDISCONNECT << 4, 0x01}, []byte{0x8B}},
+	}
+
+	for _, tc := range testCases {
+		p, err := NewFromBytesVersion(tc.fixedHeader, tc.remaining, mqtt.ProtocolVersion5)
+		if err != nil {
+			t.Errorf("NewFromBytesVersion(%v, %v) => %v, want => nil", tc.fixedHeader, tc.remaining, err)
+			continue
+		}
+
+		if v := p.(interface{ ProtocolVersion() byte }).ProtocolVersion(); v != mqtt.ProtocolVersion5 {
+			t.Errorf("ProtocolVersion() => %d, want => %d", v, mqtt.ProtocolVersion5)
+		}
+	}
+}
+
+func TestNewFromBytesVersion_v31(t *testing.T) {
+	testCases := []struct {
+		fixedHeader FixedHeader
+		remaining   []byte
+	}{
+		{[]byte{TypeCONNACK << 4, 0x02}, []byte{0x00, 0x00}},
+		{[]byte{TypePUBLISH << 4, 0x04}, []byte{0x00, 0x01, 0x61, 0x62}},
+		{[]byte{TypePUBACK << 4, 0x02}, []byte{0x00, 0x01}},
+		{[]byte{TypePUBREC << 4, 0x02}, []byte{0x00, 0x01}},
+		{[]byte{TypePUBREL<<4 | 0x02, 0x02}, []byte{0x00, 0x01}},
+		{[]byte{TypePUBCOMP << 4, 0x02}, []byte{0x00, 0x01}},
+		{[]byte{TypeSUBACK << 4, 0x03}, []byte{0x00, 0x01, 0x00}},
+		{[]byte{TypeUNSUBACK << 4, 0x02}, []byte{0x00, 0x01}},
+		{[]byte{TypePINGRESP << 4, 0x00}, nil},
+		{[]byte{TypeDISCONNECT << 4, 0x00}, nil},
+	}
+
+	for _, tc := range testCases {
+		p, err := NewFromBytesVersion(tc.fixedHeader, tc.remaining, mqtt.ProtocolVersion31)
+		if err != nil {
+			t.Errorf("NewFromBytesVersion(%v, %v) => %v, want => nil", tc.fixedHeader, tc.remaining, err)
+			continue
+		}
+
+		if v := p.(interface{ ProtocolVersion() byte }).ProtocolVersion(); v != mqtt.ProtocolVersion31 {
+			t.Errorf("ProtocolVersion() => %d, want => %d", v, mqtt.ProtocolVersion31)
+		}
+
+		if b, _ := json.Marshal(p); !bytes.Contains(b, []byte(`"protocolVersion":3`)) {
+			t.Errorf("json.Marshal(p) => %s, want => the protocol version 3", b)
+		}
+	}
+}
+
+// roundTrip5 encodes the Packet and decodes it in MQTT 5.0.
+func roundTrip5(t *testing.T, p Packet) Packet {
+	var bf bytes.Buffer
+
+	if _, err := p.WriteTo(&bf); err != nil {
+		t.Fatalf("err => %q, want => nil", err)
+	}
+
+	r := NewReader(&bf)
+	r.ProtocolVersion = mqtt.ProtocolVersion5
+
+	decoded, err := r.ReadPacket()
+	if err != nil {
+		t.Fatalf("err => %q, want => nil", err)
+	}
+
+	return decoded
+}
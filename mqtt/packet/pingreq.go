@@ -1,5 +1,8 @@
 package packet
 
+// Length of the fixed header of the PINGREQ Packet
+const lenPINGREQFixedHeader = 2
+
 // PINGREQ represents a PINGREQ Packet.
 type PINGREQ struct {
 	base
@@ -16,3 +19,72 @@ func NewPINGREQ() Packet {
 	// Return the Packet.
 	return p
 }
+
+// NewPINGREQFromBytes creates a PINGREQ Packet from
+// the byte data and returns it.
+func NewPINGREQFromBytes(fixedHeader FixedHeader, remaining []byte) (Packet, error) {
+	// Validate the byte data.
+	if err := validatePINGREQBytes(fixedHeader, remaining); err != nil {
+		return nil, err
+	}
+
+	// Create a PINGREQ Packet.
+	p := &PINGREQ{}
+
+	// Set the fixed header to the Packet.
+	p.fixedHeader = fixedHeader
+
+	// Return the Packet.
+	return p, nil
+}
+
+// validatePINGREQBytes validates the fixed header and the remaining.
+func validatePINGREQBytes(fixedHeader FixedHeader, remaining []byte) error {
+	// Extract the MQTT Control Packet type.
+	ptype, err := fixedHeader.ptype()
+	if err != nil {
+		return err
+	}
+
+	// Check the length of the fixed header.
+	if len(fixedHeader) != lenPINGREQFixedHeader {
+		return ErrInvalidFixedHeaderLen
+	}
+
+	// Check the MQTT Control Packet type.
+	if ptype != TypePINGREQ {
+		return ErrInvalidPacketType
+	}
+
+	// Check the reserved bits of the fixed header.
+	if fixedHeader[0]<<4 != 0x00 {
+		return ErrInvalidFixedHeader
+	}
+
+	// Check the Remaining Length of the fixed header.
+	if fixedHeader[1] != 0x00 {
+		return ErrInvalidRemainingLength
+	}
+
+	// Check the length of the remaining.
+	if len(remaining) != 0 {
+		return ErrInvalidRemainingLen
+	}
+
+	return nil
+}
+
+// String returns the human-readable representation of the Packet.
+func (p *PINGREQ) String() string {
+	return p.jsonPacket().String()
+}
+
+// MarshalJSON returns the JSON representation of the Packet.
+func (p *PINGREQ) MarshalJSON() ([]byte, error) {
+	return p.jsonPacket().marshal()
+}
+
+// jsonPacket creates the JSON representation of the Packet.
+func (p *PINGREQ) jsonPacket() *jsonPacket {
+	return p.newJSONPacket(TypePINGREQ)
+}
@@ -15,3 +15,51 @@ func TestNewPINGREQ(t *testing.T) {
 		t.Errorf("ptype => %X, want => %X", ptype, TypePINGREQ)
 	}
 }
+
+func TestNewPINGREQFromBytes_errValidatePINGREQBytes(t *testing.T) {
+	if _, err := NewPINGREQFromBytes(nil, nil); err != ErrInvalidFixedHeaderLen {
+		invalidError(t, err, ErrInvalidFixedHeaderLen)
+	}
+}
+
+func TestNewPINGREQFromBytes(t *testing.T) {
+	if _, err := NewPINGREQFromBytes([]byte{TypePINGREQ << 4, 0x00}, nil); err != nil {
+		nilErrorExpected(t, err)
+	}
+}
+
+func Test_validatePINGREQBytes_ptypeErrInvalidFixedHeaderLen(t *testing.T) {
+	if err := validatePINGREQBytes(nil, nil); err != ErrInvalidFixedHeaderLen {
+		invalidError(t, err, ErrInvalidFixedHeaderLen)
+	}
+}
+
+func Test_validatePINGREQBytes_ErrInvalidFixedHeaderLen(t *testing.T) {
+	if err := validatePINGREQBytes([]byte{TypePINGREQ << 4}, nil); err != ErrInvalidFixedHeaderLen {
+		invalidError(t, err, ErrInvalidFixedHeaderLen)
+	}
+}
+
+func Test_validatePINGREQBytes_ErrInvalidPacketType(t *testing.T) {
+	if err := validatePINGREQBytes([]byte{0x00 << 4, 0x00}, nil); err != ErrInvalidPacketType {
+		invalidError(t, err, ErrInvalidPacketType)
+	}
+}
+
+func Test_validatePINGREQBytes_ErrInvalidFixedHeader(t *testing.T) {
+	if err := validatePINGREQBytes([]byte{TypePINGREQ<<4 | 0x01, 0x00}, nil); err != ErrInvalidFixedHeader {
+		invalidError(t, err, ErrInvalidFixedHeader)
+	}
+}
+
+func Test_validatePINGREQBytes_ErrInvalidRemainingLength(t *testing.T) {
+	if err := validatePINGREQBytes([]byte{TypePINGREQ << 4, 0x01}, nil); err != ErrInvalidRemainingLength {
+		invalidError(t, err, ErrInvalidRemainingLength)
+	}
+}
+
+func Test_validatePINGREQBytes_ErrInvalidRemainingLen(t *testing.T) {
+	if err := validatePINGREQBytes([]byte{TypePINGREQ << 4, 0x00}, []byte{0x00}); err != ErrInvalidRemainingLen {
+		invalidError(t, err, ErrInvalidRemainingLen)
+	}
+}
@@ -61,3 +61,18 @@ func validatePINGRESPBytes(fixedHeader FixedHeader, remaining []byte) error {
 
 	return nil
 }
+
+// String returns the human-readable representation of the Packet.
+func (p *PINGRESP) String() string {
+	return p.jsonPacket().String()
+}
+
+// MarshalJSON returns the JSON representation of the Packet.
+func (p *PINGRESP) MarshalJSON() ([]byte, error) {
+	return p.jsonPacket().marshal()
+}
+
+// jsonPacket creates the JSON representation of the Packet.
+func (p *PINGRESP) jsonPacket() *jsonPacket {
+	return p.newJSONPacket(TypePINGRESP)
+}
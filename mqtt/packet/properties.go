@@ -0,0 +1,526 @@
+package packet
+
+import "errors"
+
+// Property identifiers of MQTT 5.0
+const (
+	propPayloadFormatIndicator          byte = 0x01
+	propMessageExpiryInterval           byte = 0x02
+	propContentType                     byte = 0x03
+	propResponseTopic                   byte = 0x08
+	propCorrelationData                 byte = 0x09
+	propSubscriptionIdentifier          byte = 0x0B
+	propSessionExpiryInterval           byte = 0x11
+	propAssignedClientIdentifier        byte = 0x12
+	propServerKeepAlive                 byte = 0x13
+	propAuthenticationMethod            byte = 0x15
+	propAuthenticationData              byte = 0x16
+	propRequestProblemInformation       byte = 0x17
+	propWillDelayInterval               byte = 0x18
+	propRequestResponseInformation      byte = 0x19
+	propResponseInformation             byte = 0x1A
+	propServerReference                 byte = 0x1C
+	propReasonString                    byte = 0x1F
+	propReceiveMaximum                  byte = 0x21
+	propTopicAliasMaximum               byte = 0x22
+	propTopicAlias                      byte = 0x23
+	propMaximumQoS                      byte = 0x24
+	propRetainAvailable                 byte = 0x25
+	propUserProperty                    byte = 0x26
+	propMaximumPacketSize               byte = 0x27
+	propWildcardSubscriptionAvailable   byte = 0x28
+	propSubscriptionIdentifierAvailable byte = 0x29
+	propSharedSubscriptionAvailable     byte = 0x2A
+)
+
+// typeWill is the pseudo MQTT Control Packet type which represents
+// the Will Properties of the CONNECT Packet.
+const typeWill byte = 0x00
+
+// Maximum value of the Subscription Identifier
+const maxSubscriptionIdentifier = 268435455
+
+// Data types of the Properties
+const (
+	propTypeByte = iota
+	propTypeUint16
+	propTypeUint32
+	propTypeVarInt
+	propTypeStr
+	propTypeStrPair
+)
+
+// propDef represents the definition of a Property.
+type propDef struct {
+	// dataType is the data type of the Property.
+	dataType int
+	// ptypes is the bit set of the MQTT Control Packet
+	// types of the Packets which can contain the Property.
+	ptypes uint16
+}
+
+// propDefs is the definitions of the Properties by their identifiers.
+var propDefs = map[byte]propDef{
+	propPayloadFormatIndicator:          {propTypeByte, 1<<TypePUBLISH | 1<<typeWill},
+	propMessageExpiryInterval:           {propTypeUint32, 1<<TypePUBLISH | 1<<typeWill},
+	propContentType:                     {propTypeStr, 1<<TypePUBLISH | 1<<typeWill},
+	propResponseTopic:                   {propTypeStr, 1<<TypePUBLISH | 1<<typeWill},
+	propCorrelationData:                 {propTypeStr, 1<<TypePUBLISH | 1<<typeWill},
+	propSubscriptionIdentifier:          {propTypeVarInt, 1<<TypePUBLISH | 1<<TypeSUBSCRIBE},
+	propSessionExpiryInterval:           {propTypeUint32, 1<<TypeCONNECT | 1<<TypeCONNACK | 1<<TypeDISCONNECT},
+	propAssignedClientIdentifier:        {propTypeStr, 1 << TypeCONNACK},
+	propServerKeepAlive:                 {propTypeUint16, 1 << TypeCONNACK},
+	propAuthenticationMethod:            {propTypeStr, 1<<TypeCONNECT | 1<<TypeCONNACK | 1<<TypeAUTH},
+	propAuthenticationData:              {propTypeStr, 1<<TypeCONNECT | 1<<TypeCONNACK | 1<<TypeAUTH},
+	propRequestProblemInformation:       {propTypeByte, 1 << TypeCONNECT},
+	propWillDelayInterval:               {propTypeUint32, 1 << typeWill},
+	propRequestResponseInformation:      {propTypeByte, 1 << TypeCONNECT},
+	propResponseInformation:             {propTypeStr, 1 << TypeCONNACK},
+	propServerReference:                 {propTypeStr, 1<<TypeCONNACK | 1<<TypeDISCONNECT},
+	propReasonString:                    {propTypeStr, 1<<TypeCONNACK | 1<<TypePUBACK | 1<<TypePUBREC | 1<<TypePUBREL | 1<<TypePUBCOMP | 1<<TypeSUBACK | 1<<TypeUNSUBACK | 1<<TypeDISCONNECT | 1<<TypeAUTH},
+	propReceiveMaximum:                  {propTypeUint16, 1<<TypeCONNECT | 1<<TypeCONNACK},
+	propTopicAliasMaximum:               {propTypeUint16, 1<<TypeCONNECT | 1<<TypeCONNACK},
+	propTopicAlias:                      {propTypeUint16, 1 << TypePUBLISH},
+	propMaximumQoS:                      {propTypeByte, 1 << TypeCONNACK},
+	propRetainAvailable:                 {propTypeByte, 1 << TypeCONNACK},
+	propUserProperty:                    {propTypeStrPair, 0xFFFF},
+	propMaximumPacketSize:               {propTypeUint32, 1<<TypeCONNECT | 1<<TypeCONNACK},
+	propWildcardSubscriptionAvailable:   {propTypeByte, 1 << TypeCONNACK},
+	propSubscriptionIdentifierAvailable: {propTypeByte, 1 << TypeCONNACK},
+	propSharedSubscriptionAvailable:     {propTypeByte, 1 << TypeCONNACK},
+}
+
+// Error values
+var (
+	ErrInvalidProperty              = errors.New("the Property is not allowed in the Packet")
+	ErrDuplicateProperty            = errors.New("the Property is included more than once")
+	ErrInvalidPropertyValue         = errors.New("the value of the Property is invalid")
+	ErrPropertyExceedsMaxStringsLen = errors.New("the length of the Property exceeds the maximum strings length")
+	ErrPropertiesNotSupported       = errors.New("the Properties are not supported in the protocol version")
+)
+
+// UserProperty represents a User Property which is a name-value pair.
+type UserProperty struct {
+	// Key is the name of the User Property.
+	Key []byte `json:"key"`
+	// Value is the value of the User Property.
+	Value []byte `json:"value"`
+}
+
+// Properties represents the Properties of an MQTT 5.0 Packet.
+// The nil pointers and the nil slices are not encoded. The other
+// fields are not encoded if they are zero because their zero values
+// are the same as the values which the absence of the Properties means
+// or are not allowed.
+type Properties struct {
+	// PayloadFormatIndicator is the Payload Format Indicator.
+	PayloadFormatIndicator byte `json:"payloadFormatIndicator,omitempty"`
+	// MessageExpiryInterval is the Message Expiry Interval in seconds.
+	MessageExpiryInterval *uint32 `json:"messageExpiryInterval,omitempty"`
+	// ContentType is the Content Type.
+	ContentType []byte `json:"contentType,omitempty"`
+	// ResponseTopic is the Response Topic.
+	ResponseTopic []byte `json:"responseTopic,omitempty"`
+	// CorrelationData is the Correlation Data.
+	CorrelationData []byte `json:"correlationData,omitempty"`
+	// SubscriptionIdentifiers is the Subscription Identifiers.
+	SubscriptionIdentifiers []uint32 `json:"subscriptionIdentifiers,omitempty"`
+	// SessionExpiryInterval is the Session Expiry Interval in seconds.
+	SessionExpiryInterval *uint32 `json:"sessionExpiryInterval,omitempty"`
+	// AssignedClientIdentifier is the Assigned Client Identifier.
+	AssignedClientIdentifier []byte `json:"assignedClientIdentifier,omitempty"`
+	// ServerKeepAlive is the Server Keep Alive in seconds.
+	ServerKeepAlive *uint16 `json:"serverKeepAlive,omitempty"`
+	// AuthenticationMethod is the Authentication Method.
+	AuthenticationMethod []byte `json:"authenticationMethod,omitempty"`
+	// AuthenticationData is the Authentication Data.
+	AuthenticationData []byte `json:"authenticationData,omitempty"`
+	// RequestProblemInformation is the Request Problem Information.
+	RequestProblemInformation *byte `json:"requestProblemInformation,omitempty"`
+	// WillDelayInterval is the Will Delay Interval in seconds.
+	WillDelayInterval uint32 `json:"willDelayInterval,omitempty"`
+	// RequestResponseInformation is the Request Response Information.
+	RequestResponseInformation byte `json:"requestResponseInformation,omitempty"`
+	// ResponseInformation is the Response Information.
+	ResponseInformation []byte `json:"responseInformation,omitempty"`
+	// ServerReference is the Server Reference.
+	ServerReference []byte `json:"serverReference,omitempty"`
+	// ReasonString is the Reason String.
+	ReasonString []byte `json:"reasonString,omitempty"`
+	// ReceiveMaximum is the Receive Maximum.
+	ReceiveMaximum uint16 `json:"receiveMaximum,omitempty"`
+	// TopicAliasMaximum is the Topic Alias Maximum.
+	TopicAliasMaximum uint16 `json:"topicAliasMaximum,omitempty"`
+	// TopicAlias is the Topic Alias.
+	TopicAlias uint16 `json:"topicAlias,omitempty"`
+	// MaximumQoS is the Maximum QoS.
+	MaximumQoS *byte `json:"maximumQoS,omitempty"`
+	// RetainAvailable is the Retain Available.
+	RetainAvailable *byte `json:"retainAvailable,omitempty"`
+	// UserProperties is the User Properties.
+	UserProperties []UserProperty `json:"userProperties,omitempty"`
+	// MaximumPacketSize is the Maximum Packet Size.
+	MaximumPacketSize uint32 `json:"maximumPacketSize,omitempty"`
+	// WildcardSubscriptionAvailable is the Wildcard Subscription Available.
+	WildcardSubscriptionAvailable *byte `json:"wildcardSubscriptionAvailable,omitempty"`
+	// SubscriptionIdentifierAvailable is the Subscription Identifier Available.
+	SubscriptionIdentifierAvailable *byte `json:"subscriptionIdentifierAvailable,omitempty"`
+	// SharedSubscriptionAvailable is the Shared Subscription Available.
+	SharedSubscriptionAvailable *byte `json:"sharedSubscriptionAvailable,omitempty"`
+}
+
+// encode encodes the Properties in the order of their identifiers.
+func (props *Properties) encode() []byte {
+	var b []byte
+
+	if props.PayloadFormatIndicator != 0 {
+		b = append(b, propPayloadFormatIndicator, props.PayloadFormatIndicator)
+	}
+
+	if props.MessageExpiryInterval != nil {
+		b = append(b, propMessageExpiryInterval)
+		b = append(b, encodeUint32(*props.MessageExpiryInterval)...)
+	}
+
+	if props.ContentType != nil {
+		b = appendLenStr(append(b, propContentType), props.ContentType)
+	}
+
+	if props.ResponseTopic != nil {
+		b = appendLenStr(append(b, propResponseTopic), props.ResponseTopic)
+	}
+
+	if props.CorrelationData != nil {
+		b = appendLenStr(append(b, propCorrelationData), props.CorrelationData)
+	}
+
+	for _, id := range props.SubscriptionIdentifiers {
+		b = appendVarInt(append(b, propSubscriptionIdentifier), id)
+	}
+
+	if props.SessionExpiryInterval != nil {
+		b = append(b, propSessionExpiryInterval)
+		b = append(b, encodeUint32(*props.SessionExpiryInterval)...)
+	}
+
+	if props.AssignedClientIdentifier != nil {
+		b = appendLenStr(append(b, propAssignedClientIdentifier), props.AssignedClientIdentifier)
+	}
+
+	if props.ServerKeepAlive != nil {
+		b = append(b, propServerKeepAlive)
+		b = append(b, encodeUint16(*props.ServerKeepAlive)...)
+	}
+
+	if props.AuthenticationMethod != nil {
+		b = appendLenStr(append(b, propAuthenticationMethod), props.AuthenticationMethod)
+	}
+
+	if props.AuthenticationData != nil {
+		b = appendLenStr(append(b, propAuthenticationData), props.AuthenticationData)
+	}
+
+	if props.RequestProblemInformation != nil {
+		b = append(b, propRequestProblemInformation, *props.RequestProblemInformation)
+	}
+
+	if props.WillDelayInterval != 0 {
+		b = append(b, propWillDelayInterval)
+		b = append(b, encodeUint32(props.WillDelayInterval)...)
+	}
+
+	if props.RequestResponseInformation != 0 {
+		b = append(b, propRequestResponseInformation, props.RequestResponseInformation)
+	}
+
+	if props.ResponseInformation != nil {
+		b = appendLenStr(append(b, propResponseInformation), props.ResponseInformation)
+	}
+
+	if props.ServerReference != nil {
+		b = appendLenStr(append(b, propServerReference), props.ServerReference)
+	}
+
+	if props.ReasonString != nil {
+		b = appendLenStr(append(b, propReasonString), props.ReasonString)
+	}
+
+	if props.ReceiveMaximum != 0 {
+		b = append(b, propReceiveMaximum)
+		b = append(b, encodeUint16(props.ReceiveMaximum)...)
+	}
+
+	if props.TopicAliasMaximum != 0 {
+		b = append(b, propTopicAliasMaximum)
+		b = append(b, encodeUint16(props.TopicAliasMaximum)...)
+	}
+
+	if props.TopicAlias != 0 {
+		b = append(b, propTopicAlias)
+		b = append(b, encodeUint16(props.TopicAlias)...)
+	}
+
+	if props.MaximumQoS != nil {
+		b = append(b, propMaximumQoS, *props.MaximumQoS)
+	}
+
+	if props.RetainAvailable != nil {
+		b = append(b, propRetainAvailable, *props.RetainAvailable)
+	}
+
+	for _, up := range props.UserProperties {
+		b = appendLenStr(append(b, propUserProperty), up.Key)
+		b = appendLenStr(b, up.Value)
+	}
+
+	if props.MaximumPacketSize != 0 {
+		b = append(b, propMaximumPacketSize)
+		b = append(b, encodeUint32(props.MaximumPacketSize)...)
+	}
+
+	if props.WildcardSubscriptionAvailable != nil {
+		b = append(b, propWildcardSubscriptionAvailable, *props.WildcardSubscriptionAvailable)
+	}
+
+	if props.SubscriptionIdentifierAvailable != nil {
+		b = append(b, propSubscriptionIdentifierAvailable, *props.SubscriptionIdentifierAvailable)
+	}
+
+	if props.SharedSubscriptionAvailable != nil {
+		b = append(b, propSharedSubscriptionAvailable, *props.SharedSubscriptionAvailable)
+	}
+
+	return b
+}
+
+// strs returns the strings and the binary data of the Properties.
+func (props *Properties) strs() [][]byte {
+	strs := [][]byte{
+		props.ContentType,
+		props.ResponseTopic,
+		props.CorrelationData,
+		props.AssignedClientIdentifier,
+		props.AuthenticationMethod,
+		props.AuthenticationData,
+		props.ResponseInformation,
+		props.ServerReference,
+		props.ReasonString,
+	}
+
+	for _, up := range props.UserProperties {
+		strs = append(strs, up.Key, up.Value)
+	}
+
+	return strs
+}
+
+// validate validates the Properties of the Packet
+// of the MQTT Control Packet type.
+func (props *Properties) validate(ptype byte) error {
+	// Check the length of the strings and the binary data.
+	for _, s := range props.strs() {
+		if len(s) > maxStringsLen {
+			return ErrPropertyExceedsMaxStringsLen
+		}
+	}
+
+	// Check the range of the Subscription Identifiers.
+	for _, id := range props.SubscriptionIdentifiers {
+		if id > maxSubscriptionIdentifier {
+			return ErrInvalidPropertyValue
+		}
+	}
+
+	// Check the other rules by decoding the encoded Properties.
+	_, _, err := decodeProperties(appendProperties(nil, props), ptype)
+
+	return err
+}
+
+// set sets the decoded value of the Property.
+func (props *Properties) set(id byte, n uint32, s, v []byte) error {
+	// Check the value of the Property.
+	switch id {
+	case propPayloadFormatIndicator, propRequestProblemInformation,
+		propRequestResponseInformation, propMaximumQoS, propRetainAvailable,
+		propWildcardSubscriptionAvailable, propSubscriptionIdentifierAvailable,
+		propSharedSubscriptionAvailable:
+		if n > 1 {
+			return ErrInvalidPropertyValue
+		}
+	case propSubscriptionIdentifier, propReceiveMaximum,
+		propTopicAlias, propMaximumPacketSize:
+		if n == 0 {
+			return ErrInvalidPropertyValue
+		}
+	}
+
+	// Set the value to the field.
+	b := byte(n)
+
+	switch id {
+	case propPayloadFormatIndicator:
+		props.PayloadFormatIndicator = b
+	case propMessageExpiryInterval:
+		props.MessageExpiryInterval = &n
+	case propContentType:
+		props.ContentType = s
+	case propResponseTopic:
+		props.ResponseTopic = s
+	case propCorrelationData:
+		props.CorrelationData = s
+	case propSubscriptionIdentifier:
+		props.SubscriptionIdentifiers = append(props.SubscriptionIdentifiers, n)
+	case propSessionExpiryInterval:
+		props.SessionExpiryInterval = &n
+	case propAssignedClientIdentifier:
+		props.AssignedClientIdentifier = s
+	case propServerKeepAlive:
+		u := uint16(n)
+		props.ServerKeepAlive = &u
+	case propAuthenticationMethod:
+		props.AuthenticationMethod = s
+	case propAuthenticationData:
+		props.AuthenticationData = s
+	case propRequestProblemInformation:
+		props.RequestProblemInformation = &b
+	case propWillDelayInterval:
+		props.WillDelayInterval = n
+	case propRequestResponseInformation:
+		props.RequestResponseInformation = b
+	case propResponseInformation:
+		props.ResponseInformation = s
+	case propServerReference:
+		props.ServerReference = s
+	case propReasonString:
+		props.ReasonString = s
+	case propReceiveMaximum:
+		props.ReceiveMaximum = uint16(n)
+	case propTopicAliasMaximum:
+		props.TopicAliasMaximum = uint16(n)
+	case propTopicAlias:
+		props.TopicAlias = uint16(n)
+	case propMaximumQoS:
+		props.MaximumQoS = &b
+	case propRetainAvailable:
+		props.RetainAvailable = &b
+	case propUserProperty:
+		props.UserProperties = append(props.UserProperties, UserProperty{Key: s, Value: v})
+	case propMaximumPacketSize:
+		props.MaximumPacketSize = n
+	case propWildcardSubscriptionAvailable:
+		props.WildcardSubscriptionAvailable = &b
+	case propSubscriptionIdentifierAvailable:
+		props.SubscriptionIdentifierAvailable = &b
+	case propSharedSubscriptionAvailable:
+		props.SharedSubscriptionAvailable = &b
+	}
+
+	return nil
+}
+
+// appendProperties appends the Property Length
+// and the Properties to the slice.
+func appendProperties(b []byte, props *Properties) []byte {
+	// Encode the Properties.
+	var encoded []byte
+
+	if props != nil {
+		encoded = props.encode()
+	}
+
+	// Append the Property Length and the Properties.
+	b = appendVarInt(b, uint32(len(encoded)))
+
+	return append(b, encoded...)
+}
+
+// decodeProperties decodes the Property Length and the Properties
+// of the Packet of the MQTT Control Packet type from the head of
+// the slice and returns the Properties and the rest of the slice.
+func decodeProperties(b []byte, ptype byte) (*Properties, []byte, error) {
+	// Decode the Property Length.
+	l, b, err := decodeVarInt(b)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	// Check the Property Length.
+	if uint32(len(b)) < l {
+		return nil, nil, ErrInvalidRemainingLen
+	}
+
+	props := &Properties{}
+
+	// seen is the bit set of the identifiers of the decoded Properties.
+	var seen uint64
+
+	for pb := b[:l]; len(pb) > 0; {
+		// Extract the Property identifier.
+		id := pb[0]
+		pb = pb[1:]
+
+		// Check if the Packet can contain the Property.
+		def, ok := propDefs[id]
+		if !ok || def.ptypes&(1<<ptype) == 0 {
+			return nil, nil, ErrInvalidProperty
+		}
+
+		// Check the duplication of the Property.
+		if id != propUserProperty && (id != propSubscriptionIdentifier || ptype != TypePUBLISH) {
+			if seen&(1<<id) != 0 {
+				return nil, nil, ErrDuplicateProperty
+			}
+
+			seen |= 1 << id
+		}
+
+		// Decode the value of the Property.
+		var n uint32
+		var s, v []byte
+
+		switch def.dataType {
+		case propTypeByte:
+			if len(pb) < 1 {
+				return nil, nil, ErrInvalidRemainingLen
+			}
+
+			n, pb = uint32(pb[0]), pb[1:]
+		case propTypeUint16:
+			if len(pb) < 2 {
+				return nil, nil, ErrInvalidRemainingLen
+			}
+
+			u, _ := decodeUint16(pb[0:2])
+
+			n, pb = uint32(u), pb[2:]
+		case propTypeUint32:
+			if len(pb) < 4 {
+				return nil, nil, ErrInvalidRemainingLen
+			}
+
+			n, _ = decodeUint32(pb[0:4])
+
+			pb = pb[4:]
+		case propTypeVarInt:
+			n, pb, err = decodeVarInt(pb)
+		case propTypeStr:
+			s, pb, err = decodeLenStr(pb)
+		case propTypeStrPair:
+			if s, pb, err = decodeLenStr(pb); err == nil {
+				v, pb, err = decodeLenStr(pb)
+			}
+		}
+
+		if err != nil {
+			return nil, nil, err
+		}
+
+		// Set the value to the Properties.
+		if err := props.set(id, n, s, v); err != nil {
+			return nil, nil, err
+		}
+	}
+
+	return props, b[l:], nil
+}
@@ -0,0 +1,119 @@
+package packet
+
+import (
+	"bytes"
+	"reflect"
+	"testing"
+)
+
+func TestProperties_encode_decodeProperties(t *testing.T) {
+	expiry := uint32(60)
+
+	props := &Properties{
+		PayloadFormatIndicator:  0x01,
+		MessageExpiryInterval:   &expiry,
+		ContentType:             []byte("text/plain"),
+		ResponseTopic:           []byte("a/b"),
+		CorrelationData:         []byte{0x00, 0x01},
+		SubscriptionIdentifiers: []uint32{1, 268435455},
+		TopicAlias:              3,
+		UserProperties: []UserProperty{
+			{Key: []byte("k"), Value: []byte("v1")},
+			{Key: []byte("k"), Value: []byte("v2")},
+		},
+	}
+
+	b := appendProperties(nil, props)
+
+	got, rest, err := decodeProperties(append(b, 0xFF), TypePUBLISH)
+	if err != nil {
+		nilErrorExpected(t, err)
+		return
+	}
+
+	if !bytes.Equal(rest, []byte{0xFF}) {
+		t.Errorf("rest => %v, want => %v", rest, []byte{0xFF})
+	}
+
+	if !reflect.DeepEqual(got, props) {
+		t.Errorf("decodeProperties => %+v, want => %+v", got, props)
+	}
+}
+
+func TestProperties_encode_order(t *testing.T) {
+	props := &Properties{
+		ReasonString:    []byte("r"),
+		ReceiveMaximum:  10,
+		MaximumQoS:      new(byte),
+		UserProperties:  []UserProperty{{Key: []byte("a"), Value: []byte("b")}},
+		ServerReference: []byte("s"),
+	}
+
+	want := []byte{
+		propServerReference, 0x00, 0x01, 's',
+		propReasonString, 0x00, 0x01, 'r',
+		propReceiveMaximum, 0x00, 0x0A,
+		propMaximumQoS, 0x00,
+		propUserProperty, 0x00, 0x01, 'a', 0x00, 0x01, 'b',
+	}
+
+	if got := props.encode(); !bytes.Equal(got, want) {
+		t.Errorf("props.encode() => %v, want => %v", got, want)
+	}
+}
+
+func Test_appendProperties_nil(t *testing.T) {
+	if got := appendProperties([]byte{0x01}, nil); !bytes.Equal(got, []byte{0x01, 0x00}) {
+		t.Errorf("appendProperties => %v, want => %v", got, []byte{0x01, 0x00})
+	}
+}
+
+func Test_decodeProperties_err(t *testing.T) {
+	testCases := []struct {
+		b     []byte
+		ptype byte
+		want  error
+	}{
+		{nil, TypePUBLISH, ErrInvalidRemainingLen},
+		{[]byte{0x02, propPayloadFormatIndicator}, TypePUBLISH, ErrInvalidRemainingLen},
+		{[]byte{0x02, propPayloadFormatIndicator, 0x01}, TypeCONNACK, ErrInvalidProperty},
+		{[]byte{0x02, 0x7F, 0x00}, TypePUBLISH, ErrInvalidProperty},
+		{[]byte{0x04, propPayloadFormatIndicator, 0x01, propPayloadFormatIndicator, 0x01}, TypePUBLISH, ErrDuplicateProperty},
+		{[]byte{0x04, propSubscriptionIdentifier, 0x01, propSubscriptionIdentifier, 0x02}, TypeSUBSCRIBE, ErrDuplicateProperty},
+		{[]byte{0x02, propPayloadFormatIndicator, 0x02}, TypePUBLISH, ErrInvalidPropertyValue},
+		{[]byte{0x03, propTopicAlias, 0x00, 0x00}, TypePUBLISH, ErrInvalidPropertyValue},
+		{[]byte{0x02, propSubscriptionIdentifier, 0x00}, TypePUBLISH, ErrInvalidPropertyValue},
+		{[]byte{0x01, propPayloadFormatIndicator}, TypePUBLISH, ErrInvalidRemainingLen},
+		{[]byte{0x02, propTopicAlias, 0x01}, TypePUBLISH, ErrInvalidRemainingLen},
+		{[]byte{0x02, propMessageExpiryInterval, 0x01}, TypePUBLISH, ErrInvalidRemainingLen},
+		{[]byte{0x02, propContentType, 0x00}, TypePUBLISH, ErrInvalidRemainingLen},
+		{[]byte{0x04, propUserProperty, 0x00, 0x01, 'a'}, TypePUBLISH, ErrInvalidRemainingLen},
+	}
+
+	for _, tc := range testCases {
+		if _, _, err := decodeProperties(tc.b, tc.ptype); err != tc.want {
+			t.Errorf("decodeProperties(%v, %d) => %v, want => %v", tc.b, tc.ptype, err, tc.want)
+		}
+	}
+}
+
+func TestProperties_validate(t *testing.T) {
+	testCases := []struct {
+		props *Properties
+		ptype byte
+		want  error
+	}{
+		{&Properties{ContentType: make([]byte, maxStringsLen+1)}, TypePUBLISH, ErrPropertyExceedsMaxStringsLen},
+		{&Properties{SubscriptionIdentifiers: []uint32{maxSubscriptionIdentifier + 1}}, TypeSUBSCRIBE, ErrInvalidPropertyValue},
+		{&Properties{TopicAlias: 1}, TypeSUBSCRIBE, ErrInvalidProperty},
+		{&Properties{PayloadFormatIndicator: 2}, TypePUBLISH, ErrInvalidPropertyValue},
+		{&Properties{WillDelayInterval: 1}, typeWill, nil},
+		{&Properties{ReasonString: []byte("r")}, TypeAUTH, nil},
+	}
+
+	for _, tc := range testCases {
+		if err := tc.props.validate(tc.ptype); err != tc.want {
+			t.Errorf("validate(%d) => %v, want => %v", tc.ptype, err, tc.want)
+		}
+	}
+}
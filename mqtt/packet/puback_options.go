@@ -4,6 +4,15 @@ package packet
 type PUBACKOptions struct {
 	// PacketID is the Packet Identifier of the variable header.
 	PacketID uint16
+	// ProtocolVersion is the protocol version of the Packet.
+	// MQTT 3.1.1 is used if it is zero.
+	ProtocolVersion byte
+	// ReasonCode is the Reason Code of the variable header.
+	// It is used only in MQTT 5.0.
+	ReasonCode byte
+	// Properties is the Properties of the variable header.
+	// It is used only in MQTT 5.0.
+	Properties *Properties
 }
 
 // validate validates the options.
@@ -13,5 +22,6 @@ func (opts *PUBACKOptions) validate() error {
 		return ErrInvalidPacketID
 	}
 
-	return nil
+	// Check the protocol version, the Reason Code and the Properties.
+	return validateVersionedOptions(opts.ProtocolVersion, TypePUBACK, opts.ReasonCode, opts.Properties)
 }
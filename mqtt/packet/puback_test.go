@@ -1,6 +1,10 @@
 package packet
 
-import "testing"
+import (
+	"testing"
+
+	"github.com/yosssi/gmq/mqtt"
+)
 
 func TestPUBACK_setFixedHeader(t *testing.T) {
 	p := &PUBACK{
@@ -104,3 +108,38 @@ func Test_validatePUBACKBytes(t *testing.T) {
 		nilErrorExpected(t, err)
 	}
 }
+
+func TestNewPUBACK_v5(t *testing.T) {
+	p, err := NewPUBACK(&PUBACKOptions{
+		PacketID:        1,
+		ProtocolVersion: mqtt.ProtocolVersion5,
+		ReasonCode:      ReasonNoMatchingSubscribers,
+		Properties:      &Properties{ReasonString: []byte("r")},
+	})
+	if err != nil {
+		nilErrorExpected(t, err)
+		return
+	}
+
+	decoded := roundTrip5(t, p).(*PUBACK)
+
+	if decoded.PacketID != 1 || decoded.ReasonCode != ReasonNoMatchingSubscribers || string(decoded.Properties.ReasonString) != "r" {
+		t.Errorf("decoded => %+v, want => the same as %+v", decoded, p)
+	}
+}
+
+func TestNewPUBACK_ErrPropertiesNotSupported(t *testing.T) {
+	if _, err := NewPUBACK(&PUBACKOptions{PacketID: 1, Properties: &Properties{}}); err != ErrPropertiesNotSupported {
+		invalidError(t, err, ErrPropertiesNotSupported)
+	}
+}
+
+func Test_newPUBACKFromBytes(t *testing.T) {
+	if _, err := newPUBACKFromBytes([]byte{TypePUBACK << 4}, nil, mqtt.ProtocolVersion311); err != ErrInvalidFixedHeaderLen {
+		invalidError(t, err, ErrInvalidFixedHeaderLen)
+	}
+
+	if _, err := newPUBACKFromBytes([]byte{TypeCONNACK << 4, 0x02}, []byte{0x00, 0x01}, mqtt.ProtocolVersion5); err != ErrInvalidPacketType {
+		invalidError(t, err, ErrInvalidPacketType)
+	}
+}
@@ -1,6 +1,10 @@
 package packet
 
-import "testing"
+import (
+	"testing"
+
+	"github.com/yosssi/gmq/mqtt"
+)
 
 func TestPUBCOMP_setFixedHeader(t *testing.T) {
 	p := &PUBCOMP{
@@ -104,3 +108,38 @@ func Test_validatePUBCOMPBytes(t *testing.T) {
 		nilErrorExpected(t, err)
 	}
 }
+
+func TestNewPUBCOMP_v5(t *testing.T) {
+	p, err := NewPUBCOMP(&PUBCOMPOptions{
+		PacketID:        1,
+		ProtocolVersion: mqtt.ProtocolVersion5,
+		ReasonCode:      ReasonPacketIdentifierNotFound,
+		Properties:      &Properties{ReasonString: []byte("r")},
+	})
+	if err != nil {
+		nilErrorExpected(t, err)
+		return
+	}
+
+	decoded := roundTrip5(t, p).(*PUBCOMP)
+
+	if decoded.PacketID != 1 || decoded.ReasonCode != ReasonPacketIdentifierNotFound || string(decoded.Properties.ReasonString) != "r" {
+		t.Errorf("decoded => %+v, want => the same as %+v", decoded, p)
+	}
+}
+
+func TestNewPUBCOMP_ErrPropertiesNotSupported(t *testing.T) {
+	if _, err := NewPUBCOMP(&PUBCOMPOptions{PacketID: 1, Properties: &Properties{}}); err != ErrPropertiesNotSupported {
+		invalidError(t, err, ErrPropertiesNotSupported)
+	}
+}
+
+func Test_newPUBCOMPFromBytes(t *testing.T) {
+	if _, err := newPUBCOMPFromBytes([]byte{TypePUBCOMP << 4}, nil, mqtt.ProtocolVersion311); err != ErrInvalidFixedHeaderLen {
+		invalidError(t, err, ErrInvalidFixedHeaderLen)
+	}
+
+	if _, err := newPUBCOMPFromBytes([]byte{TypeCONNACK << 4, 0x02}, []byte{0x00, 0x01}, mqtt.ProtocolVersion5); err != ErrInvalidPacketType {
+		invalidError(t, err, ErrInvalidPacketType)
+	}
+}
@@ -25,14 +25,64 @@ type PUBLISH struct {
 	DUP bool
 	// qos is the QoS of the fixed header.
 	QoS byte
-	// retain is the Retain of the fixed header.
-	retain bool
+	// Retain is the Retain of the fixed header.
+	Retain bool
 	// topicName is the Topic Name of the varible header.
 	TopicName []byte
 	// packetID is the Packet Identifier of the variable header.
 	PacketID uint16
 	// message is the Application Message of the payload.
 	Message []byte
+	// Properties is the Properties of the variable header.
+	// It is used only in MQTT 5.0.
+	Properties *Properties
+
+	// pool is the BufferPool which buf belongs to.
+	pool *BufferPool
+	// buf is the pooled buffer which the Packet was read into.
+	buf *[]byte
+}
+
+// Release returns the buffer which the Packet was read into to
+// the BufferPool of the Reader. The Packet and the slices decoded
+// from it, such as TopicName, Message and Properties, must not be
+// used after that. It does nothing if the Packet was not read by
+// the Reader with a BufferPool.
+func (p *PUBLISH) Release() {
+	if p.buf == nil {
+		return
+	}
+
+	p.pool.put(p.buf)
+
+	p.pool = nil
+	p.buf = nil
+}
+
+// Duplicate returns a copy of the Packet whose DUP flag is set
+// to true for resending it. The fixed header of the copy is encoded
+// again and its variable header and payload are shared with the Packet.
+func (p *PUBLISH) Duplicate() *PUBLISH {
+	// Create a copy of the Packet.
+	d := &PUBLISH{
+		DUP:        true,
+		QoS:        p.QoS,
+		Retain:     p.Retain,
+		TopicName:  p.TopicName,
+		PacketID:   p.PacketID,
+		Message:    p.Message,
+		Properties: p.Properties,
+	}
+
+	// Share the protocol version, the variable header and the payload.
+	d.version = p.version
+	d.variableHeader = p.variableHeader
+	d.payload = p.payload
+
+	// Set the Fixed header to the copy.
+	d.setFixedHeader()
+
+	return d
 }
 
 // setFixedHeader sets the fixed header to the Packet.
@@ -49,7 +99,7 @@ func (p *PUBLISH) setFixedHeader() {
 	b |= p.QoS << 1
 
 	// Set 1 to the Bit 0 if the Retain is true.
-	if p.retain {
+	if p.Retain {
 		b |= 0x01
 	}
 
@@ -62,6 +112,10 @@ func (p *PUBLISH) setFixedHeader() {
 
 // setVariableHeader sets the variable header to the Packet.
 func (p *PUBLISH) setVariableHeader() {
+	// Allocate the variable header at once. The Properties
+	// may extend it in MQTT 5.0.
+	p.variableHeader = make([]byte, 0, 2+len(p.TopicName)+2)
+
 	// Append the Topic Name to the variable header.
 	p.variableHeader = appendLenStr(p.variableHeader, p.TopicName)
 
@@ -69,6 +123,11 @@ func (p *PUBLISH) setVariableHeader() {
 		// Append the Packet Identifier to the variable header.
 		p.variableHeader = append(p.variableHeader, encodeUint16(p.PacketID)...)
 	}
+
+	// Append the Properties to the variable header in MQTT 5.0.
+	if p.v5() {
+		p.variableHeader = appendProperties(p.variableHeader, p.Properties)
+	}
 }
 
 // setPayload sets the payload to the Packet.
@@ -90,14 +149,18 @@ func NewPUBLISH(opts *PUBLISHOptions) (Packet, error) {
 
 	// Create a PUBLISH Packet.
 	p := &PUBLISH{
-		DUP:       opts.DUP,
-		QoS:       opts.QoS,
-		retain:    opts.Retain,
-		TopicName: opts.TopicName,
-		PacketID:  opts.PacketID,
-		Message:   opts.Message,
+		DUP:        opts.DUP,
+		QoS:        opts.QoS,
+		Retain:     opts.Retain,
+		TopicName:  opts.TopicName,
+		PacketID:   opts.PacketID,
+		Message:    opts.Message,
+		Properties: opts.Properties,
 	}
 
+	// Set the protocol version to the Packet.
+	p.version = opts.ProtocolVersion
+
 	// Set the variable header to the Packet.
 	p.setVariableHeader()
 
@@ -114,6 +177,12 @@ func NewPUBLISH(opts *PUBLISHOptions) (Packet, error) {
 // NewPUBLISHFromBytes creates the PUBLISH Packet
 // from the byte data and returns it.
 func NewPUBLISHFromBytes(fixedHeader FixedHeader, remaining []byte) (Packet, error) {
+	return newPUBLISHFromBytes(fixedHeader, remaining, mqtt.ProtocolVersion311)
+}
+
+// newPUBLISHFromBytes creates the PUBLISH Packet of the protocol version
+// from the byte data and returns it.
+func newPUBLISHFromBytes(fixedHeader FixedHeader, remaining []byte, version byte) (Packet, error) {
 	// Validate the byte data.
 	if err := validatePUBLISHBytes(fixedHeader, remaining); err != nil {
 		return nil, err
@@ -126,7 +195,7 @@ func NewPUBLISHFromBytes(fixedHeader FixedHeader, remaining []byte) (Packet, err
 	p := &PUBLISH{
 		DUP:    b&0x08 == 0x08,
 		QoS:    b & 0x06 >> 1,
-		retain: b&0x01 == 0x01,
+		Retain: b&0x01 == 0x01,
 	}
 
 	// Set the fixed header to the Packet.
@@ -144,6 +213,19 @@ func NewPUBLISHFromBytes(fixedHeader FixedHeader, remaining []byte) (Packet, err
 		lenVariableHeader = 2 + int(lenTopicName) + 2
 	}
 
+	// Decode the Properties which follow the Packet Identifier in MQTT 5.0.
+	if version == mqtt.ProtocolVersion5 {
+		props, rest, err := decodeProperties(remaining[lenVariableHeader:], TypePUBLISH)
+		if err != nil {
+			return nil, err
+		}
+
+		p.Properties = props
+		p.version = version
+
+		lenVariableHeader = len(remaining) - len(rest)
+	}
+
 	// Set the variable header to the Packet.
 	p.variableHeader = remaining[:lenVariableHeader]
 
@@ -233,3 +315,28 @@ func validatePUBLISHBytes(fixedHeader FixedHeader, remaining []byte) error {
 
 	return nil
 }
+
+// String returns the human-readable representation of the Packet.
+func (p *PUBLISH) String() string {
+	return p.jsonPacket().String()
+}
+
+// MarshalJSON returns the JSON representation of the Packet.
+func (p *PUBLISH) MarshalJSON() ([]byte, error) {
+	return p.jsonPacket().marshal()
+}
+
+// jsonPacket creates the JSON representation of the Packet.
+func (p *PUBLISH) jsonPacket() *jsonPacket {
+	jp := p.newJSONPacket(TypePUBLISH)
+
+	jp.DUP = p.DUP
+	jp.QoS = bytePointer(p.QoS)
+	jp.Retain = p.Retain
+	jp.PacketID = p.PacketID
+	jp.Topic = string(p.TopicName)
+	jp.Payload = p.Message
+	jp.Properties = p.Properties
+
+	return jp
+}
@@ -1,24 +1,18 @@
 package packet
 
 import (
-	"bytes"
 	"errors"
 
 	"github.com/yosssi/gmq/mqtt"
-)
-
-// Wildcard characters
-const (
-	wildcardMulti  = "#"
-	wildcardSingle = "+"
-	wildcards      = wildcardMulti + wildcardSingle
+	"github.com/yosssi/gmq/mqtt/topic"
 )
 
 // Error values
 var (
 	ErrInvalidQoS                    = errors.New("the QoS is invalid")
+	ErrNoTopicName                   = errors.New("the Topic Name must be specified")
 	ErrTopicNameExceedsMaxStringsLen = errors.New("the length of the Topic Name exceeds the maximum strings length")
-	ErrTopicNameContainsWildcards    = errors.New("the Topic Name contains wildcard characters")
+	ErrTopicNameContainsWildcards    = topic.ErrWildcardInName
 	ErrMessageExceedsMaxStringsLen   = errors.New("the length of the Message exceeds the maximum strings length")
 )
 
@@ -36,6 +30,12 @@ type PUBLISHOptions struct {
 	PacketID uint16
 	// Message is the Application Message of the payload.
 	Message []byte
+	// ProtocolVersion is the protocol version of the Packet.
+	// MQTT 3.1.1 is used if it is zero.
+	ProtocolVersion byte
+	// Properties is the Properties of the variable header.
+	// It is used only in MQTT 5.0.
+	Properties *Properties
 }
 
 // validate validates the options.
@@ -50,9 +50,14 @@ func (opts *PUBLISHOptions) validate() error {
 		return ErrTopicNameExceedsMaxStringsLen
 	}
 
-	// Check if the Topic Name contains the wildcard characters.
-	if bytes.IndexAny(opts.TopicName, wildcards) != -1 {
-		return ErrTopicNameContainsWildcards
+	// Check the Topic Name. It can be zero-byte only if
+	// the Topic Alias of MQTT 5.0 refers to a Topic Name.
+	if len(opts.TopicName) > 0 {
+		if err := topic.ValidateName(string(opts.TopicName)); err != nil {
+			return err
+		}
+	} else if opts.ProtocolVersion != mqtt.ProtocolVersion5 || opts.Properties == nil || opts.Properties.TopicAlias == 0 {
+		return ErrNoTopicName
 	}
 
 	// Check the length of the Application Message.
@@ -60,6 +65,11 @@ func (opts *PUBLISHOptions) validate() error {
 		return ErrMessageExceedsMaxStringsLen
 	}
 
+	// Check the protocol version and the Properties.
+	if err := validateVersionedOptions(opts.ProtocolVersion, TypePUBLISH, 0, opts.Properties); err != nil {
+		return err
+	}
+
 	// End the validation if the QoS equals to QoS 0.
 	if opts.QoS == mqtt.QoS0 {
 		return nil
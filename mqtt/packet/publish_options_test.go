@@ -4,6 +4,7 @@ import (
 	"testing"
 
 	"github.com/yosssi/gmq/mqtt"
+	"github.com/yosssi/gmq/mqtt/topic"
 )
 
 func TestPUBLISHOptions_validate_ErrInvalidQoS(t *testing.T) {
@@ -29,13 +30,13 @@ func TestPUBLISHOptions_validate_ErrTopicNameExceedsMaxStringsLen(t *testing.T)
 func TestPUBLISHOptions_validate_ErrTopicNameContainsWildcards(t *testing.T) {
 	sliceOpts := []*PUBLISHOptions{
 		&PUBLISHOptions{
-			TopicName: []byte(wildcardMulti),
+			TopicName: []byte("#"),
 		},
 		&PUBLISHOptions{
-			TopicName: []byte(wildcardSingle),
+			TopicName: []byte("+"),
 		},
 		&PUBLISHOptions{
-			TopicName: []byte(wildcards),
+			TopicName: []byte("#+"),
 		},
 	}
 
@@ -48,7 +49,8 @@ func TestPUBLISHOptions_validate_ErrTopicNameContainsWildcards(t *testing.T) {
 
 func TestPUBLISHOptions_validate_ErrMessageExceedsMaxStringsLen(t *testing.T) {
 	opts := &PUBLISHOptions{
-		Message: make([]byte, maxStringsLen+1),
+		TopicName: []byte("a"),
+		Message:   make([]byte, maxStringsLen+1),
 	}
 
 	if err := opts.validate(); err != ErrMessageExceedsMaxStringsLen {
@@ -57,7 +59,9 @@ func TestPUBLISHOptions_validate_ErrMessageExceedsMaxStringsLen(t *testing.T) {
 }
 
 func TestPUBLISHOptions_validate_QoS0(t *testing.T) {
-	opts := &PUBLISHOptions{}
+	opts := &PUBLISHOptions{
+		TopicName: []byte("a"),
+	}
 
 	if err := opts.validate(); err != nil {
 		nilErrorExpected(t, err)
@@ -66,7 +70,8 @@ func TestPUBLISHOptions_validate_QoS0(t *testing.T) {
 
 func TestPUBLISHOptions_validate_ErrInvalidPacketID(t *testing.T) {
 	opts := &PUBLISHOptions{
-		QoS: mqtt.QoS1,
+		TopicName: []byte("a"),
+		QoS:       mqtt.QoS1,
 	}
 
 	if err := opts.validate(); err != ErrInvalidPacketID {
@@ -76,11 +81,71 @@ func TestPUBLISHOptions_validate_ErrInvalidPacketID(t *testing.T) {
 
 func TestPUBLISHOptions_validate(t *testing.T) {
 	opts := &PUBLISHOptions{
-		QoS:      mqtt.QoS1,
-		PacketID: 1,
+		TopicName: []byte("a"),
+		QoS:       mqtt.QoS1,
+		PacketID:  1,
 	}
 
 	if err := opts.validate(); err != nil {
 		nilErrorExpected(t, err)
 	}
 }
+
+func TestPUBLISHOptions_validate_ErrPropertiesNotSupported(t *testing.T) {
+	opts := &PUBLISHOptions{
+		TopicName:  []byte("a"),
+		Properties: &Properties{},
+	}
+
+	if err := opts.validate(); err != ErrPropertiesNotSupported {
+		invalidError(t, err, ErrPropertiesNotSupported)
+	}
+}
+
+func TestPUBLISHOptions_validate_ErrNoTopicName(t *testing.T) {
+	testCases := []*PUBLISHOptions{
+		{},
+		{ProtocolVersion: mqtt.ProtocolVersion5},
+		{ProtocolVersion: mqtt.ProtocolVersion5, Properties: &Properties{}},
+	}
+
+	for _, opts := range testCases {
+		if err := opts.validate(); err != ErrNoTopicName {
+			invalidError(t, err, ErrNoTopicName)
+		}
+	}
+}
+
+func TestPUBLISHOptions_validate_topicAlias(t *testing.T) {
+	opts := &PUBLISHOptions{
+		ProtocolVersion: mqtt.ProtocolVersion5,
+		Properties: &Properties{
+			TopicAlias: 1,
+		},
+	}
+
+	if err := opts.validate(); err != nil {
+		nilErrorExpected(t, err)
+	}
+}
+
+func TestPUBLISHOptions_validate_invalidTopicName(t *testing.T) {
+	testCases := []struct {
+		topicName []byte
+		want      error
+	}{
+		{[]byte("a/\x00"), topic.ErrNullCharacter},
+		{[]byte{0x61, 0xFF}, topic.ErrInvalidUTF8},
+		{[]byte{0xED, 0xA0, 0x80}, topic.ErrInvalidUTF8},
+	}
+
+	for _, tc := range testCases {
+		opts := &PUBLISHOptions{
+			TopicName: tc.topicName,
+		}
+
+		if err := opts.validate(); err != tc.want {
+			invalidError(t, err, tc.want)
+		}
+	}
+}
@@ -1,6 +1,8 @@
 package packet
 
 import (
+	"bufio"
+	"io/ioutil"
 	"testing"
 
 	"github.com/yosssi/gmq/mqtt"
@@ -9,7 +11,7 @@ import (
 func TestPUBLISH_setFixedHeader(t *testing.T) {
 	p := &PUBLISH{
 		DUP:    true,
-		retain: true,
+		Retain: true,
 	}
 
 	p.variableHeader = []byte{0x00}
@@ -62,8 +64,8 @@ func TestPUBLISH_setPayload(t *testing.T) {
 }
 
 func TestNewPUBLISH_optsNil(t *testing.T) {
-	if _, err := NewPUBLISH(nil); err != nil {
-		nilErrorExpected(t, err)
+	if _, err := NewPUBLISH(nil); err != ErrNoTopicName {
+		invalidError(t, err, ErrNoTopicName)
 	}
 }
 
@@ -89,6 +91,24 @@ func TestNewPUBLISHFromBytes(t *testing.T) {
 	}
 }
 
+func TestNewPUBLISHFromBytes_DUPRetain(t *testing.T) {
+	p, err := NewPUBLISHFromBytes([]byte{TypePUBLISH<<4 | 0x09, 0x05}, []byte{0x00, 0x03, 0x61, 0x2F, 0x62})
+	if err != nil {
+		nilErrorExpected(t, err)
+		return
+	}
+
+	publish := p.(*PUBLISH)
+
+	if !publish.DUP {
+		t.Error("publish.DUP => false, want => true")
+	}
+
+	if !publish.Retain {
+		t.Error("publish.Retain => false, want => true")
+	}
+}
+
 func Test_validatePUBLISHBytes_fixedHeaderErrInvalidFixedHeaderLen(t *testing.T) {
 	if err := validatePUBLISHBytes(nil, nil); err != ErrInvalidFixedHeaderLen {
 		invalidError(t, err, ErrInvalidFixedHeaderLen)
@@ -136,3 +156,132 @@ func Test_validatePUBLISHBytes(t *testing.T) {
 		nilErrorExpected(t, err)
 	}
 }
+
+func TestNewPUBLISH_v5(t *testing.T) {
+	p, err := NewPUBLISH(&PUBLISHOptions{
+		QoS:             mqtt.QoS1,
+		TopicName:       []byte("a/b"),
+		PacketID:        1,
+		Message:         []byte("m"),
+		ProtocolVersion: mqtt.ProtocolVersion5,
+		Properties: &Properties{
+			ContentType:    []byte("text/plain"),
+			UserProperties: []UserProperty{{Key: []byte("k"), Value: []byte("v")}},
+		},
+	})
+	if err != nil {
+		nilErrorExpected(t, err)
+		return
+	}
+
+	decoded := roundTrip5(t, p).(*PUBLISH)
+
+	if string(decoded.TopicName) != "a/b" || decoded.PacketID != 1 || string(decoded.Message) != "m" {
+		t.Errorf("decoded => %+v, want => the same as %+v", decoded, p)
+	}
+
+	if props := decoded.Properties; props == nil || string(props.ContentType) != "text/plain" || len(props.UserProperties) != 1 {
+		t.Errorf("decoded.Properties => %+v", props)
+	}
+}
+
+func Test_newPUBLISHFromBytes_v5Err(t *testing.T) {
+	if _, err := newPUBLISHFromBytes([]byte{TypePUBLISH << 4, 0x03}, []byte{0x00, 0x01, 0x61}, mqtt.ProtocolVersion5); err != ErrInvalidRemainingLen {
+		invalidError(t, err, ErrInvalidRemainingLen)
+	}
+
+	if _, err := newPUBLISHFromBytes([]byte{TypePUBLISH << 4, 0x05}, []byte{0x00, 0x01, 0x61, 0x01, propTopicAlias}, mqtt.ProtocolVersion5); err != ErrInvalidRemainingLen {
+		invalidError(t, err, ErrInvalidRemainingLen)
+	}
+}
+
+// benchmarkPUBLISHMessage is the Application Message of the PUBLISH
+// Packets of the benchmarks.
+var benchmarkPUBLISHMessage = make([]byte, 32*1024)
+
+func BenchmarkNewPUBLISH(b *testing.B) {
+	opts := &PUBLISHOptions{
+		QoS:       mqtt.QoS1,
+		TopicName: []byte("sensors/plant1/line3/temperature"),
+		PacketID:  1,
+		Message:   benchmarkPUBLISHMessage,
+	}
+
+	b.ReportAllocs()
+
+	for i := 0; i < b.N; i++ {
+		if _, err := NewPUBLISH(opts); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkPUBLISH_WriteTo(b *testing.B) {
+	p, err := NewPUBLISH(&PUBLISHOptions{
+		QoS:       mqtt.QoS1,
+		TopicName: []byte("sensors/plant1/line3/temperature"),
+		PacketID:  1,
+		Message:   benchmarkPUBLISHMessage,
+	})
+	if err != nil {
+		b.Fatal(err)
+	}
+
+	w := bufio.NewWriter(ioutil.Discard)
+
+	b.ReportAllocs()
+	b.SetBytes(int64(len(benchmarkPUBLISHMessage)))
+
+	for i := 0; i < b.N; i++ {
+		if _, err := p.WriteTo(w); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func TestPUBLISH_Release(t *testing.T) {
+	pool := NewBufferPool()
+
+	p := &PUBLISH{
+		pool: pool,
+		buf:  pool.get(10),
+	}
+
+	p.Release()
+
+	if p.pool != nil || p.buf != nil {
+		t.Errorf("p.pool, p.buf => %v, %v, want => nil, nil", p.pool, p.buf)
+	}
+
+	// Release does nothing after the buffer is released.
+	p.Release()
+}
+
+func TestPUBLISH_Duplicate(t *testing.T) {
+	p, err := NewPUBLISH(&PUBLISHOptions{
+		QoS:       mqtt.QoS1,
+		TopicName: []byte("topicName"),
+		PacketID:  1,
+		Message:   []byte("message"),
+	})
+	if err != nil {
+		nilErrorExpected(t, err)
+		return
+	}
+
+	publish := p.(*PUBLISH)
+
+	d := publish.Duplicate()
+
+	if !d.DUP || d.fixedHeader[0] != 0x3A {
+		t.Errorf("d.DUP, d.fixedHeader[0] => %t, 0x%02X, want => true, 0x3A", d.DUP, d.fixedHeader[0])
+	}
+
+	if publish.DUP || publish.fixedHeader[0] != 0x32 {
+		t.Errorf("publish.DUP, publish.fixedHeader[0] => %t, 0x%02X, want => false, 0x32", publish.DUP, publish.fixedHeader[0])
+	}
+
+	if d.fixedHeader[1] != publish.fixedHeader[1] || string(d.payload) != "message" {
+		t.Errorf("d.fixedHeader, d.payload => %v, %q", d.fixedHeader, d.payload)
+	}
+}
@@ -1,6 +1,10 @@
 package packet
 
-import "testing"
+import (
+	"testing"
+
+	"github.com/yosssi/gmq/mqtt"
+)
 
 func TestPUBREC_setFixedHeader(t *testing.T) {
 	p := &PUBREC{
@@ -105,3 +109,38 @@ func Test_validatePUBRECBytes(t *testing.T) {
 		nilErrorExpected(t, err)
 	}
 }
+
+func TestNewPUBREC_v5(t *testing.T) {
+	p, err := NewPUBREC(&PUBRECOptions{
+		PacketID:        1,
+		ProtocolVersion: mqtt.ProtocolVersion5,
+		ReasonCode:      ReasonNoMatchingSubscribers,
+		Properties:      &Properties{ReasonString: []byte("r")},
+	})
+	if err != nil {
+		nilErrorExpected(t, err)
+		return
+	}
+
+	decoded := roundTrip5(t, p).(*PUBREC)
+
+	if decoded.PacketID != 1 || decoded.ReasonCode != ReasonNoMatchingSubscribers || string(decoded.Properties.ReasonString) != "r" {
+		t.Errorf("decoded => %+v, want => the same as %+v", decoded, p)
+	}
+}
+
+func TestNewPUBREC_ErrPropertiesNotSupported(t *testing.T) {
+	if _, err := NewPUBREC(&PUBRECOptions{PacketID: 1, Properties: &Properties{}}); err != ErrPropertiesNotSupported {
+		invalidError(t, err, ErrPropertiesNotSupported)
+	}
+}
+
+func Test_newPUBRECFromBytes(t *testing.T) {
+	if _, err := newPUBRECFromBytes([]byte{TypePUBREC << 4}, nil, mqtt.ProtocolVersion311); err != ErrInvalidFixedHeaderLen {
+		invalidError(t, err, ErrInvalidFixedHeaderLen)
+	}
+
+	if _, err := newPUBRECFromBytes([]byte{TypeCONNACK << 4, 0x02}, []byte{0x00, 0x01}, mqtt.ProtocolVersion5); err != ErrInvalidPacketType {
+		invalidError(t, err, ErrInvalidPacketType)
+	}
+}
@@ -1,5 +1,7 @@
 package packet
 
+import "github.com/yosssi/gmq/mqtt"
+
 // Length of the fixed header of the PUBREL Packet
 const lenPUBRELFixedHeader = 2
 
@@ -11,6 +13,12 @@ type PUBREL struct {
 	base
 	// PacketID is the Packet Identifier of the variable header.
 	PacketID uint16
+	// ReasonCode is the Reason Code of the variable header.
+	// It is used only in MQTT 5.0.
+	ReasonCode byte
+	// Properties is the Properties of the variable header.
+	// It is used only in MQTT 5.0.
+	Properties *Properties
 }
 
 // setFixedHeader sets the fixed header to the Packet.
@@ -24,8 +32,9 @@ func (p *PUBREL) setFixedHeader() {
 
 // setVariableHeader sets the variable header to the Packet.
 func (p *PUBREL) setVariableHeader() {
-	// Append the Packet Identifier to the variable header.
-	p.variableHeader = append(p.variableHeader, encodeUint16(p.PacketID)...)
+	// Append the Packet Identifier and, in MQTT 5.0,
+	// the Reason Code and the Properties to the variable header.
+	p.variableHeader = appendAckVariableHeader(p.variableHeader, p.version, p.PacketID, p.ReasonCode, p.Properties)
 }
 
 // NewPUBREL creates and returns a PUBREL Packet.
@@ -42,9 +51,14 @@ func NewPUBREL(opts *PUBRELOptions) (Packet, error) {
 
 	// Create a PUBREL Packet.
 	p := &PUBREL{
-		PacketID: opts.PacketID,
+		PacketID:   opts.PacketID,
+		ReasonCode: opts.ReasonCode,
+		Properties: opts.Properties,
 	}
 
+	// Set the protocol version to the Packet.
+	p.version = opts.ProtocolVersion
+
 	// Set the variable header to the Packet.
 	p.setVariableHeader()
 
@@ -83,6 +97,37 @@ func NewPUBRELFromBytes(fixedHeader FixedHeader, variableHeader []byte) (Packet,
 	return p, nil
 }
 
+// newPUBRELFromBytes creates a PUBREL Packet of the protocol version
+// from the byte data and returns it.
+func newPUBRELFromBytes(fixedHeader FixedHeader, variableHeader []byte, version byte) (Packet, error) {
+	// Decode the byte data of MQTT 3.1.1.
+	if version != mqtt.ProtocolVersion5 {
+		return NewPUBRELFromBytes(fixedHeader, variableHeader)
+	}
+
+	// Validate and decode the byte data of MQTT 5.0.
+	packetID, reasonCode, props, err := decodeAck(fixedHeader, variableHeader, TypePUBREL, 0x02)
+	if err != nil {
+		return nil, err
+	}
+
+	// Create a PUBREL Packet.
+	p := &PUBREL{
+		PacketID:   packetID,
+		ReasonCode: reasonCode,
+		Properties: props,
+	}
+
+	// Set the fixed header, the variable header and
+	// the protocol version to the Packet.
+	p.fixedHeader = fixedHeader
+	p.variableHeader = variableHeader
+	p.version = version
+
+	// Return the Packet.
+	return p, nil
+}
+
 // validatePUBRELBytes validates the fixed header and the variable header.
 func validatePUBRELBytes(fixedHeader FixedHeader, variableHeader []byte) error {
 	// Extract the MQTT Control Packet type.
@@ -126,3 +171,28 @@ func validatePUBRELBytes(fixedHeader FixedHeader, variableHeader []byte) error {
 
 	return nil
 }
+
+// String returns the human-readable representation of the Packet.
+func (p *PUBREL) String() string {
+	return p.jsonPacket().String()
+}
+
+// MarshalJSON returns the JSON representation of the Packet.
+func (p *PUBREL) MarshalJSON() ([]byte, error) {
+	return p.jsonPacket().marshal()
+}
+
+// jsonPacket creates the JSON representation of the Packet.
+func (p *PUBREL) jsonPacket() *jsonPacket {
+	jp := p.newJSONPacket(TypePUBREL)
+
+	jp.PacketID = p.PacketID
+
+	// Set the Reason Code and the Properties of MQTT 5.0.
+	if p.v5() {
+		jp.ReasonCode = bytePointer(p.ReasonCode)
+		jp.Properties = p.Properties
+	}
+
+	return jp
+}
@@ -1,6 +1,10 @@
 package packet
 
-import "testing"
+import (
+	"testing"
+
+	"github.com/yosssi/gmq/mqtt"
+)
 
 func TestPUBREL_setFixedHeader(t *testing.T) {
 	p := &PUBREL{
@@ -108,3 +112,38 @@ func Test_validatePUBRELBytes_ErrInvalidPacketID(t *testing.T) {
 		invalidError(t, err, ErrInvalidPacketID)
 	}
 }
+
+func TestNewPUBREL_v5(t *testing.T) {
+	p, err := NewPUBREL(&PUBRELOptions{
+		PacketID:        1,
+		ProtocolVersion: mqtt.ProtocolVersion5,
+		ReasonCode:      ReasonPacketIdentifierNotFound,
+		Properties:      &Properties{ReasonString: []byte("r")},
+	})
+	if err != nil {
+		nilErrorExpected(t, err)
+		return
+	}
+
+	decoded := roundTrip5(t, p).(*PUBREL)
+
+	if decoded.PacketID != 1 || decoded.ReasonCode != ReasonPacketIdentifierNotFound || string(decoded.Properties.ReasonString) != "r" {
+		t.Errorf("decoded => %+v, want => the same as %+v", decoded, p)
+	}
+}
+
+func TestNewPUBREL_ErrPropertiesNotSupported(t *testing.T) {
+	if _, err := NewPUBREL(&PUBRELOptions{PacketID: 1, Properties: &Properties{}}); err != ErrPropertiesNotSupported {
+		invalidError(t, err, ErrPropertiesNotSupported)
+	}
+}
+
+func Test_newPUBRELFromBytes(t *testing.T) {
+	if _, err := newPUBRELFromBytes([]byte{TypePUBREL << 4}, nil, mqtt.ProtocolVersion311); err != ErrInvalidFixedHeaderLen {
+		invalidError(t, err, ErrInvalidFixedHeaderLen)
+	}
+
+	if _, err := newPUBRELFromBytes([]byte{TypeCONNACK << 4, 0x02}, []byte{0x00, 0x01}, mqtt.ProtocolVersion5); err != ErrInvalidPacketType {
+		invalidError(t, err, ErrInvalidPacketType)
+	}
+}
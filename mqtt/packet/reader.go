@@ -0,0 +1,188 @@
+package packet
+
+import (
+	"bufio"
+	"errors"
+	"io"
+
+	"github.com/yosssi/gmq/mqtt"
+)
+
+// Maximum number of the bytes of the Remaining Length
+const maxLenRemainingLength = 4
+
+// Error values
+var (
+	ErrRemainingLengthTooLong = errors.New("the Remaining Length exceeds four bytes")
+	ErrPacketTooLarge         = errors.New("the size of the Packet exceeds the maximum packet size")
+)
+
+// byteReader is the interface that groups
+// the basic Read and ReadByte methods.
+type byteReader interface {
+	io.Reader
+	io.ByteReader
+}
+
+// Reader reads MQTT Control Packets from the underlying io.Reader.
+type Reader struct {
+	// MaxPacketSize is the maximum size in bytes of the Packet
+	// including the fixed header. The size is not limited other
+	// than by the Remaining Length if it is zero.
+	MaxPacketSize uint32
+
+	// ProtocolVersion is the protocol version in which the Packets
+	// are decoded. MQTT 3.1.1 is used if it is zero.
+	ProtocolVersion byte
+
+	// BufferPool is the pool of the buffers into which the PUBLISH
+	// Packets are read. The buffer of each PUBLISH Packet is returned
+	// to the pool by its Release method. The buffers are not pooled
+	// if it is nil.
+	BufferPool *BufferPool
+
+	// r is the underlying reader.
+	r byteReader
+	// size is the size of the Packet which was read last.
+	size int
+}
+
+// ReadPacket reads an MQTT Control Packet and returns it.
+// ErrRemainingLengthTooLong is returned if the Remaining Length
+// is encoded in more than four bytes and ErrPacketTooLarge is
+// returned if the size of the Packet exceeds MaxPacketSize.
+// The Remaining of the Packet is not read in both cases.
+func (r *Reader) ReadPacket() (Packet, error) {
+	// Get the first byte of the Packet.
+	b, err := r.r.ReadByte()
+	if err != nil {
+		return nil, err
+	}
+
+	// Create the Fixed header on the stack. It is copied
+	// to the head of the buffer of the Packet later.
+	var header [1 + maxLenRemainingLength]byte
+
+	header[0] = b
+
+	n := 1 // the length of the Fixed header
+
+	// Get and decode the Remaining Length.
+	var mp uint32 = 1 // multiplier
+	var rl uint32     // the Remaining Length
+	for {
+		// Check the number of the bytes of the Remaining Length.
+		if n > maxLenRemainingLength {
+			return nil, ErrRemainingLengthTooLong
+		}
+
+		// Get the next byte of the Packet.
+		b, err = r.r.ReadByte()
+		if err != nil {
+			if err == io.EOF {
+				err = io.ErrUnexpectedEOF
+			}
+
+			return nil, err
+		}
+
+		header[n] = b
+		n++
+
+		rl += uint32(b&0x7F) * mp
+
+		if b&0x80 == 0 {
+			break
+		}
+
+		mp *= 128
+	}
+
+	// Check the size of the Packet.
+	if r.MaxPacketSize > 0 && uint32(n)+rl > r.MaxPacketSize {
+		return nil, ErrPacketTooLarge
+	}
+
+	// Create a buffer of the whole Packet. It is taken from
+	// the BufferPool if the Packet is a PUBLISH Packet.
+	size := n + int(rl)
+
+	var bp *[]byte
+	var buf []byte
+
+	if r.BufferPool != nil && header[0]>>4 == TypePUBLISH {
+		bp = r.BufferPool.get(size)
+		buf = *bp
+	} else {
+		buf = make([]byte, size)
+	}
+
+	// Split the buffer into the Fixed header and
+	// the Remaining (the Variable header and the Payload).
+	copy(buf, header[:n])
+
+	fixedHeader := FixedHeader(buf[:n:n])
+	remaining := buf[n:]
+
+	if rl > 0 {
+		// Get the remaining of the Packet.
+		if _, err = io.ReadFull(r.r, remaining); err != nil {
+			if bp != nil {
+				r.BufferPool.put(bp)
+			}
+
+			if err == io.EOF {
+				err = io.ErrUnexpectedEOF
+			}
+
+			return nil, err
+		}
+	}
+
+	// Remember the size of the Packet.
+	r.size = size
+
+	// Determine the protocol version.
+	version := r.ProtocolVersion
+
+	if version == 0 {
+		version = mqtt.ProtocolVersion311
+	}
+
+	// Create a Packet.
+	p, err := NewFromBytesVersion(fixedHeader, remaining, version)
+
+	// Attach the pooled buffer to the PUBLISH Packet.
+	if bp != nil {
+		if err != nil {
+			r.BufferPool.put(bp)
+			return nil, err
+		}
+
+		publish := p.(*PUBLISH)
+		publish.pool = r.BufferPool
+		publish.buf = bp
+	}
+
+	// Return the Packet.
+	return p, err
+}
+
+// Size returns the size in bytes of the Packet which ReadPacket
+// read last including the fixed header.
+func (r *Reader) Size() int {
+	return r.size
+}
+
+// NewReader creates and returns a Reader which reads from r.
+// r is buffered if it does not implement io.ByteReader.
+func NewReader(r io.Reader) *Reader {
+	br, ok := r.(byteReader)
+	if !ok {
+		br = bufio.NewReader(r)
+	}
+
+	return &Reader{
+		r: br,
+	}
+}
@@ -0,0 +1,230 @@
+package packet
+
+import (
+	"bytes"
+	"io"
+	"testing"
+
+	"github.com/yosssi/gmq/mqtt"
+)
+
+// onlyReader hides the methods of the io.Reader other than Read.
+type onlyReader struct {
+	io.Reader
+}
+
+func TestReader_ReadPacket(t *testing.T) {
+	r := NewReader(onlyReader{bytes.NewReader([]byte{
+		TypePUBACK << 4, 0x02, 0x00, 0x01,
+		TypePINGRESP << 4, 0x00,
+	})})
+
+	p, err := r.ReadPacket()
+	if err != nil {
+		nilErrorExpected(t, err)
+		return
+	}
+
+	if puback, ok := p.(*PUBACK); !ok || puback.PacketID != 1 {
+		t.Errorf("p => %#v, want => PUBACK with the Packet Identifier 1", p)
+	}
+
+	if size := r.Size(); size != 4 {
+		t.Errorf("r.Size() => %d, want => %d", size, 4)
+	}
+
+	p, err = r.ReadPacket()
+	if err != nil {
+		nilErrorExpected(t, err)
+		return
+	}
+
+	if _, ok := p.(*PINGRESP); !ok {
+		t.Errorf("p => %#v, want => PINGRESP", p)
+	}
+
+	if size := r.Size(); size != 2 {
+		t.Errorf("r.Size() => %d, want => %d", size, 2)
+	}
+
+	if _, err := r.ReadPacket(); err != io.EOF {
+		invalidError(t, err, io.EOF)
+	}
+}
+
+func TestReader_ReadPacket_largeRemainingLength(t *testing.T) {
+	b := []byte{TypePUBLISH << 4, 0x80, 0x01}
+
+	b = append(b, 0x00, 0x7D)
+	b = append(b, bytes.Repeat([]byte{0x61}, 0x7D)...)
+	b = append(b, 0x62)
+
+	p, err := NewReader(bytes.NewReader(b)).ReadPacket()
+	if err != nil {
+		nilErrorExpected(t, err)
+		return
+	}
+
+	if publish := p.(*PUBLISH); len(publish.TopicName) != 0x7D || string(publish.Message) != "b" {
+		t.Errorf("publish => %#v, want => PUBLISH with the 125-byte Topic Name and the Message \"b\"", publish)
+	}
+}
+
+func TestReader_ReadPacket_err(t *testing.T) {
+	testCases := []struct {
+		in            []byte
+		maxPacketSize uint32
+		want          error
+	}{
+		{in: []byte{}, want: io.EOF},
+		{in: []byte{TypePUBACK << 4}, want: io.ErrUnexpectedEOF},
+		{in: []byte{TypePUBACK << 4, 0x80}, want: io.ErrUnexpectedEOF},
+		{in: []byte{TypePUBACK << 4, 0x02, 0x00}, want: io.ErrUnexpectedEOF},
+		{in: []byte{TypePUBLISH << 4, 0xFF, 0xFF, 0xFF, 0x7F}, maxPacketSize: 5, want: ErrPacketTooLarge},
+		{in: []byte{TypePUBLISH << 4, 0xFF, 0xFF, 0xFF, 0x80, 0x01}, want: ErrRemainingLengthTooLong},
+		{in: []byte{TypePUBACK << 4, 0x02, 0x00, 0x01}, maxPacketSize: 3, want: ErrPacketTooLarge},
+		{in: []byte{TypePUBACK << 4, 0x02, 0x00, 0x00}, want: ErrInvalidPacketID},
+	}
+
+	for _, tc := range testCases {
+		r := NewReader(bytes.NewReader(tc.in))
+
+		r.MaxPacketSize = tc.maxPacketSize
+
+		if _, err := r.ReadPacket(); err != tc.want {
+			t.Errorf("ReadPacket() of %v => %v, want => %v", tc.in, err, tc.want)
+		}
+	}
+}
+
+func TestNewReader(t *testing.T) {
+	br := bytes.NewReader(nil)
+
+	if r := NewReader(br); r.r != br {
+		t.Errorf("r.r => %v, want => %v", r.r, br)
+	}
+
+	if r := NewReader(onlyReader{br}); r.r == nil {
+		t.Error("r.r => nil, want => not nil")
+	}
+}
+
+func TestReader_ReadPacket_ProtocolVersion(t *testing.T) {
+	r := NewReader(bytes.NewReader([]byte{TypePUBACK << 4, 0x03, 0x00, 0x01, ReasonNoMatchingSubscribers}))
+	r.ProtocolVersion = mqtt.ProtocolVersion5
+
+	p, err := r.ReadPacket()
+	if err != nil {
+		nilErrorExpected(t, err)
+		return
+	}
+
+	if puback := p.(*PUBACK); puback.ReasonCode != ReasonNoMatchingSubscribers {
+		t.Errorf("puback.ReasonCode => 0x%02X, want => 0x%02X", puback.ReasonCode, ReasonNoMatchingSubscribers)
+	}
+
+	r = NewReader(bytes.NewReader([]byte{TypePUBACK << 4, 0x03, 0x00, 0x01, ReasonNoMatchingSubscribers}))
+
+	if _, err := r.ReadPacket(); err != ErrInvalidFixedHeaderLen && err != ErrInvalidRemainingLength {
+		t.Errorf("err => %v, want => an error of MQTT 3.1.1", err)
+	}
+}
+
+func TestReader_ReadPacket_BufferPool(t *testing.T) {
+	r := NewReader(bytes.NewReader([]byte{
+		TypePUBLISH << 4, 0x05, 0x00, 0x01, 0x61, 0x62, 0x63,
+		TypePUBACK << 4, 0x02, 0x00, 0x01,
+	}))
+	r.BufferPool = NewBufferPool()
+
+	p, err := r.ReadPacket()
+	if err != nil {
+		nilErrorExpected(t, err)
+		return
+	}
+
+	publish := p.(*PUBLISH)
+
+	if publish.pool != r.BufferPool || publish.buf == nil {
+		t.Errorf("publish.pool, publish.buf => %v, %v, want => not nil", publish.pool, publish.buf)
+	}
+
+	if string(publish.TopicName) != "a" || string(publish.Message) != "bc" {
+		t.Errorf("publish => %+v", publish)
+	}
+
+	if want := []byte{TypePUBLISH << 4, 0x05}; !bytes.Equal(publish.fixedHeader, want) || cap(publish.fixedHeader) != len(want) {
+		t.Errorf("publish.fixedHeader => %v, want => %v", publish.fixedHeader, want)
+	}
+
+	publish.Release()
+
+	// The buffers of the other Packets are not pooled.
+	if _, err := r.ReadPacket(); err != nil {
+		nilErrorExpected(t, err)
+	}
+}
+
+func TestReader_ReadPacket_BufferPoolErr(t *testing.T) {
+	testCases := []struct {
+		b    []byte
+		want error
+	}{
+		{[]byte{TypePUBLISH << 4, 0x05, 0x00, 0x01}, io.ErrUnexpectedEOF},
+		{[]byte{TypePUBLISH << 4, 0x01, 0x00}, ErrInvalidRemainingLen},
+	}
+
+	for _, tc := range testCases {
+		r := NewReader(bytes.NewReader(tc.b))
+		r.BufferPool = NewBufferPool()
+
+		if _, err := r.ReadPacket(); err != tc.want {
+			invalidError(t, err, tc.want)
+		}
+	}
+}
+
+// benchmarkReadPUBLISH benchmarks reading the PUBLISH Packets
+// with the BufferPool.
+func benchmarkReadPUBLISH(b *testing.B, pool *BufferPool) {
+	p, err := NewPUBLISH(&PUBLISHOptions{
+		TopicName: []byte("sensors/plant1/line3/temperature"),
+		Message:   make([]byte, 1024),
+	})
+	if err != nil {
+		b.Fatal(err)
+	}
+
+	var bf bytes.Buffer
+
+	if _, err := p.WriteTo(&bf); err != nil {
+		b.Fatal(err)
+	}
+
+	br := bytes.NewReader(bf.Bytes())
+
+	r := NewReader(br)
+	r.BufferPool = pool
+
+	b.ReportAllocs()
+	b.SetBytes(int64(bf.Len()))
+
+	for i := 0; i < b.N; i++ {
+		br.Reset(bf.Bytes())
+
+		p, err := r.ReadPacket()
+		if err != nil {
+			b.Fatal(err)
+		}
+
+		p.(*PUBLISH).Release()
+	}
+}
+
+func BenchmarkReader_ReadPacket(b *testing.B) {
+	benchmarkReadPUBLISH(b, nil)
+}
+
+func BenchmarkReader_ReadPacket_BufferPool(b *testing.B) {
+	benchmarkReadPUBLISH(b, NewBufferPool())
+}
@@ -0,0 +1,117 @@
+package packet
+
+import "errors"
+
+// Reason Codes of MQTT 5.0
+const (
+	ReasonSuccess                             byte = 0x00
+	ReasonNormalDisconnection                 byte = 0x00
+	ReasonGrantedQoS0                         byte = 0x00
+	ReasonGrantedQoS1                         byte = 0x01
+	ReasonGrantedQoS2                         byte = 0x02
+	ReasonDisconnectWithWillMessage           byte = 0x04
+	ReasonNoMatchingSubscribers               byte = 0x10
+	ReasonNoSubscriptionExisted               byte = 0x11
+	ReasonContinueAuthentication              byte = 0x18
+	ReasonReAuthenticate                      byte = 0x19
+	ReasonUnspecifiedError                    byte = 0x80
+	ReasonMalformedPacket                     byte = 0x81
+	ReasonProtocolError                       byte = 0x82
+	ReasonImplementationSpecificError         byte = 0x83
+	ReasonUnsupportedProtocolVersion          byte = 0x84
+	ReasonClientIdentifierNotValid            byte = 0x85
+	ReasonBadUserNameOrPassword               byte = 0x86
+	ReasonNotAuthorized                       byte = 0x87
+	ReasonServerUnavailable                   byte = 0x88
+	ReasonServerBusy                          byte = 0x89
+	ReasonBanned                              byte = 0x8A
+	ReasonServerShuttingDown                  byte = 0x8B
+	ReasonBadAuthenticationMethod             byte = 0x8C
+	ReasonKeepAliveTimeout                    byte = 0x8D
+	ReasonSessionTakenOver                    byte = 0x8E
+	ReasonTopicFilterInvalid                  byte = 0x8F
+	ReasonTopicNameInvalid                    byte = 0x90
+	ReasonPacketIdentifierInUse               byte = 0x91
+	ReasonPacketIdentifierNotFound            byte = 0x92
+	ReasonReceiveMaximumExceeded              byte = 0x93
+	ReasonTopicAliasInvalid                   byte = 0x94
+	ReasonPacketTooLarge                      byte = 0x95
+	ReasonMessageRateTooHigh                  byte = 0x96
+	ReasonQuotaExceeded                       byte = 0x97
+	ReasonAdministrativeAction                byte = 0x98
+	ReasonPayloadFormatInvalid                byte = 0x99
+	ReasonRetainNotSupported                  byte = 0x9A
+	ReasonQoSNotSupported                     byte = 0x9B
+	ReasonUseAnotherServer                    byte = 0x9C
+	ReasonServerMoved                         byte = 0x9D
+	ReasonSharedSubscriptionsNotSupported     byte = 0x9E
+	ReasonConnectionRateExceeded              byte = 0x9F
+	ReasonMaximumConnectTime                  byte = 0xA0
+	ReasonSubscriptionIdentifiersNotSupported byte = 0xA1
+	ReasonWildcardSubscriptionsNotSupported   byte = 0xA2
+)
+
+// Bit sets of the MQTT Control Packet types
+const (
+	reasonAcks       = 1<<TypePUBACK | 1<<TypePUBREC
+	reasonSubAcks    = 1<<TypeSUBACK | 1<<TypeUNSUBACK
+	reasonConnDisc   = 1<<TypeCONNACK | 1<<TypeDISCONNECT
+	reasonAllFailure = reasonConnDisc | reasonAcks | reasonSubAcks
+)
+
+// Error value
+var ErrInvalidReasonCode = errors.New("invalid Reason Code")
+
+// reasonCodePacketTypes is the bit sets of the MQTT Control Packet
+// types of the Packets which can contain the Reason Codes.
+var reasonCodePacketTypes = map[byte]uint16{
+	ReasonSuccess:                             reasonConnDisc | reasonAcks | reasonSubAcks | 1<<TypePUBREL | 1<<TypePUBCOMP | 1<<TypeAUTH,
+	ReasonGrantedQoS1:                         1 << TypeSUBACK,
+	ReasonGrantedQoS2:                         1 << TypeSUBACK,
+	ReasonDisconnectWithWillMessage:           1 << TypeDISCONNECT,
+	ReasonNoMatchingSubscribers:               reasonAcks,
+	ReasonNoSubscriptionExisted:               1 << TypeUNSUBACK,
+	ReasonContinueAuthentication:              1 << TypeAUTH,
+	ReasonReAuthenticate:                      1 << TypeAUTH,
+	ReasonUnspecifiedError:                    reasonAllFailure,
+	ReasonMalformedPacket:                     reasonConnDisc,
+	ReasonProtocolError:                       reasonConnDisc,
+	ReasonImplementationSpecificError:         reasonAllFailure,
+	ReasonUnsupportedProtocolVersion:          1 << TypeCONNACK,
+	ReasonClientIdentifierNotValid:            1 << TypeCONNACK,
+	ReasonBadUserNameOrPassword:               1 << TypeCONNACK,
+	ReasonNotAuthorized:                       reasonAllFailure,
+	ReasonServerUnavailable:                   1 << TypeCONNACK,
+	ReasonServerBusy:                          reasonConnDisc,
+	ReasonBanned:                              1 << TypeCONNACK,
+	ReasonServerShuttingDown:                  1 << TypeDISCONNECT,
+	ReasonBadAuthenticationMethod:             reasonConnDisc,
+	ReasonKeepAliveTimeout:                    1 << TypeDISCONNECT,
+	ReasonSessionTakenOver:                    1 << TypeDISCONNECT,
+	ReasonTopicFilterInvalid:                  reasonSubAcks | 1<<TypeDISCONNECT,
+	ReasonTopicNameInvalid:                    reasonConnDisc | reasonAcks,
+	ReasonPacketIdentifierInUse:               reasonAcks | reasonSubAcks,
+	ReasonPacketIdentifierNotFound:            1<<TypePUBREL | 1<<TypePUBCOMP,
+	ReasonReceiveMaximumExceeded:              1 << TypeDISCONNECT,
+	ReasonTopicAliasInvalid:                   1 << TypeDISCONNECT,
+	ReasonPacketTooLarge:                      reasonConnDisc,
+	ReasonMessageRateTooHigh:                  1 << TypeDISCONNECT,
+	ReasonQuotaExceeded:                       reasonConnDisc | reasonAcks | 1<<TypeSUBACK,
+	ReasonAdministrativeAction:                1 << TypeDISCONNECT,
+	ReasonPayloadFormatInvalid:                reasonConnDisc | reasonAcks,
+	ReasonRetainNotSupported:                  reasonConnDisc,
+	ReasonQoSNotSupported:                     reasonConnDisc,
+	ReasonUseAnotherServer:                    reasonConnDisc,
+	ReasonServerMoved:                         reasonConnDisc,
+	ReasonSharedSubscriptionsNotSupported:     1<<TypeSUBACK | 1<<TypeDISCONNECT,
+	ReasonConnectionRateExceeded:              reasonConnDisc,
+	ReasonMaximumConnectTime:                  1 << TypeDISCONNECT,
+	ReasonSubscriptionIdentifiersNotSupported: 1<<TypeSUBACK | 1<<TypeDISCONNECT,
+	ReasonWildcardSubscriptionsNotSupported:   1<<TypeSUBACK | 1<<TypeDISCONNECT,
+}
+
+// validReasonCode returns true if the Packet of the MQTT Control
+// Packet type can contain the Reason Code.
+func validReasonCode(ptype byte, code byte) bool {
+	return reasonCodePacketTypes[code]&(1<<ptype) != 0
+}
@@ -0,0 +1,31 @@
+package packet
+
+import "testing"
+
+func Test_validReasonCode(t *testing.T) {
+	testCases := []struct {
+		ptype byte
+		code  byte
+		want  bool
+	}{
+		{TypeCONNACK, ReasonSuccess, true},
+		{TypeCONNACK, ReasonBadUserNameOrPassword, true},
+		{TypeCONNACK, ReasonGrantedQoS1, false},
+		{TypePUBACK, ReasonNoMatchingSubscribers, true},
+		{TypePUBREL, ReasonNoMatchingSubscribers, false},
+		{TypePUBCOMP, ReasonPacketIdentifierNotFound, true},
+		{TypeSUBACK, ReasonGrantedQoS2, true},
+		{TypeUNSUBACK, ReasonNoSubscriptionExisted, true},
+		{TypeDISCONNECT, ReasonDisconnectWithWillMessage, true},
+		{TypeAUTH, ReasonReAuthenticate, true},
+		{TypeAUTH, ReasonUnspecifiedError, false},
+		{TypePUBLISH, ReasonSuccess, false},
+		{TypeDISCONNECT, 0x03, false},
+	}
+
+	for _, tc := range testCases {
+		if got := validReasonCode(tc.ptype, tc.code); got != tc.want {
+			t.Errorf("validReasonCode(%d, 0x%02X) => %t, want => %t", tc.ptype, tc.code, got, tc.want)
+		}
+	}
+}
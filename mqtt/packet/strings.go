@@ -1,8 +1,19 @@
 package packet
 
+import (
+	"bytes"
+	"unicode/utf8"
+)
+
 // Maximum length of the UTF-8 encoded strings
 const maxStringsLen = 65535
 
+// validStr returns true if the strings are well-formed UTF-8
+// and do not contain U+0000. [MQTT-1.5.3-1] [MQTT-1.5.3-2]
+func validStr(s []byte) bool {
+	return utf8.Valid(s) && bytes.IndexByte(s, 0x00) == -1
+}
+
 // appendLenStr appends the length of the strings
 // and the strings to the byte slice.
 func appendLenStr(b []byte, s []byte) []byte {
@@ -10,3 +21,22 @@ func appendLenStr(b []byte, s []byte) []byte {
 	b = append(b, s...)
 	return b
 }
+
+// decodeLenStr extracts the length-prefixed strings from the head
+// of the byte slice and returns them and the rest of the byte slice.
+func decodeLenStr(b []byte) ([]byte, []byte, error) {
+	// Check the length of the length field.
+	if len(b) < 2 {
+		return nil, nil, ErrInvalidRemainingLen
+	}
+
+	// Extract the length of the strings.
+	l, _ := decodeUint16(b[0:2])
+
+	// Check the length of the strings.
+	if len(b) < 2+int(l) {
+		return nil, nil, ErrInvalidRemainingLen
+	}
+
+	return b[2 : 2+l], b[2+l:], nil
+}
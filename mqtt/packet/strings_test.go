@@ -11,3 +11,29 @@ func Test_appendLenStr(t *testing.T) {
 		t.Errorf("got => %v, want => %v", got, want)
 	}
 }
+
+func Test_decodeLenStr(t *testing.T) {
+	testCases := []struct {
+		in   []byte
+		s    string
+		rest []byte
+		err  error
+	}{
+		{in: []byte{0x00}, err: ErrInvalidRemainingLen},
+		{in: []byte{0x00, 0x02, 0x61}, err: ErrInvalidRemainingLen},
+		{in: []byte{0x00, 0x00}, s: "", rest: []byte{}},
+		{in: []byte{0x00, 0x01, 0x61, 0x62}, s: "a", rest: []byte{0x62}},
+	}
+
+	for _, tc := range testCases {
+		s, rest, err := decodeLenStr(tc.in)
+		if err != tc.err {
+			invalidError(t, err, tc.err)
+			continue
+		}
+
+		if string(s) != tc.s || string(rest) != string(tc.rest) {
+			t.Errorf("decodeLenStr(%v) => (%v, %v), want => (%q, %v)", tc.in, s, rest, tc.s, tc.rest)
+		}
+	}
+}
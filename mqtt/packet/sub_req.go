@@ -4,20 +4,62 @@ import (
 	"errors"
 
 	"github.com/yosssi/gmq/mqtt"
+	"github.com/yosssi/gmq/mqtt/topic"
 )
 
 // Error values
 var (
 	ErrNoTopicFilter                   = errors.New("the Topic Filter must be specified")
 	ErrTopicFilterExceedsMaxStringsLen = errors.New("the length of the Topic Filter exceeds the maximum strings length")
+	ErrInvalidSubscriptionOptions      = errors.New("invalid Subscription Options")
 )
 
+// Maximum value of the Retain Handling
+const maxRetainHandling = 2
+
 // SubReq represents subscription request.
 type SubReq struct {
 	// TopicFilter is the Topic Filter of the Subscription.
 	TopicFilter []byte
 	// QoS is the requsting QoS.
 	QoS byte
+	// NoLocal is the No Local option. It is used only in MQTT 5.0.
+	NoLocal bool
+	// RetainAsPublished is the Retain As Published option.
+	// It is used only in MQTT 5.0.
+	RetainAsPublished bool
+	// RetainHandling is the Retain Handling option.
+	// It is used only in MQTT 5.0.
+	RetainHandling byte
+}
+
+// options creates and returns a byte which represents the Subscription
+// Options. It equals to the requesting QoS in MQTT 3.1.1.
+func (s *SubReq) options() byte {
+	// Set the requesting QoS to the Bit 1 and 0.
+	b := s.QoS
+
+	// Set 1 to the Bit 2 if the No Local is true.
+	if s.NoLocal {
+		b |= 0x04
+	}
+
+	// Set 1 to the Bit 3 if the Retain As Published is true.
+	if s.RetainAsPublished {
+		b |= 0x08
+	}
+
+	// Set the value of the Retain Handling to the Bit 5 and 4.
+	b |= s.RetainHandling << 4
+
+	// Return the byte.
+	return b
+}
+
+// v5 returns true if the subscription request has
+// the Subscription Options of MQTT 5.0.
+func (s *SubReq) v5() bool {
+	return s.NoLocal || s.RetainAsPublished || s.RetainHandling != 0
 }
 
 // validate validates the subscription request.
@@ -33,10 +75,20 @@ func (s *SubReq) validate() error {
 		return ErrTopicFilterExceedsMaxStringsLen
 	}
 
+	// Check the encoding and the wildcard characters of the Topic Filter.
+	if err := topic.ValidateFilter(string(s.TopicFilter)); err != nil {
+		return err
+	}
+
 	// Check the QoS.
 	if !mqtt.ValidQoS(s.QoS) {
 		return ErrInvalidQoS
 	}
 
+	// Check the Retain Handling.
+	if s.RetainHandling > maxRetainHandling {
+		return ErrInvalidSubscriptionOptions
+	}
+
 	return nil
 }
@@ -1,6 +1,11 @@
 package packet
 
-import "testing"
+import (
+	"testing"
+
+	"github.com/yosssi/gmq/mqtt"
+	"github.com/yosssi/gmq/mqtt/topic"
+)
 
 func TestSubReq_validate_ErrNoTopicFilter(t *testing.T) {
 	s := &SubReq{}
@@ -22,7 +27,7 @@ func TestSubReq_validate_ErrTopicFilterExceedsMaxStringsLen(t *testing.T) {
 
 func TestSubReq_validate_ErrInvalidQoS(t *testing.T) {
 	s := &SubReq{
-		TopicFilter: make([]byte, 1),
+		TopicFilter: []byte("a"),
 		QoS:         0x03,
 	}
 
@@ -33,10 +38,57 @@ func TestSubReq_validate_ErrInvalidQoS(t *testing.T) {
 
 func TestSubReq_validate(t *testing.T) {
 	s := &SubReq{
-		TopicFilter: make([]byte, 1),
+		TopicFilter: []byte("a"),
 	}
 
 	if err := s.validate(); err != nil {
 		nilErrorExpected(t, err)
 	}
 }
+
+func TestSubReq_options(t *testing.T) {
+	s := &SubReq{
+		QoS:               mqtt.QoS2,
+		NoLocal:           true,
+		RetainAsPublished: true,
+		RetainHandling:    2,
+	}
+
+	if got := s.options(); got != 0x2E {
+		t.Errorf("s.options() => 0x%02X, want => 0x%02X", got, 0x2E)
+	}
+}
+
+func TestSubReq_validate_ErrInvalidSubscriptionOptions(t *testing.T) {
+	s := &SubReq{
+		TopicFilter:    []byte("a"),
+		RetainHandling: 3,
+	}
+
+	if err := s.validate(); err != ErrInvalidSubscriptionOptions {
+		invalidError(t, err, ErrInvalidSubscriptionOptions)
+	}
+}
+
+func TestSubReq_validate_invalidTopicFilter(t *testing.T) {
+	testCases := []struct {
+		topicFilter []byte
+		want        error
+	}{
+		{[]byte("a/#/b"), topic.ErrInvalidMultiLevelWildcard},
+		{[]byte("a#"), topic.ErrInvalidMultiLevelWildcard},
+		{[]byte("a/b+"), topic.ErrInvalidSingleLevelWildcard},
+		{[]byte("a/\x00"), topic.ErrNullCharacter},
+		{[]byte{0xFF}, topic.ErrInvalidUTF8},
+	}
+
+	for _, tc := range testCases {
+		s := &SubReq{
+			TopicFilter: tc.topicFilter,
+		}
+
+		if err := s.validate(); err != tc.want {
+			invalidError(t, err, tc.want)
+		}
+	}
+}
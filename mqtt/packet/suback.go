@@ -24,7 +24,11 @@ type SUBACK struct {
 	// PacketID is the Packet Identifier of the variable header.
 	PacketID uint16
 	// ReturnCodes is the Return Codes of the payload.
+	// They are the Reason Codes in MQTT 5.0.
 	ReturnCodes []byte
+	// Properties is the Properties of the variable header.
+	// It is used only in MQTT 5.0.
+	Properties *Properties
 }
 
 // NewSUBACKFromBytes creates a SUBACK Packet
@@ -65,6 +69,60 @@ func NewSUBACKFromBytes(fixedHeader FixedHeader, remaining []byte) (Packet, erro
 	return p, nil
 }
 
+// newSUBACKFromBytes creates a SUBACK Packet of the protocol version
+// from the byte data and returns it.
+func newSUBACKFromBytes(fixedHeader FixedHeader, remaining []byte, version byte) (Packet, error) {
+	// Decode the byte data of MQTT 3.1.1.
+	if version == mqtt.ProtocolVersion311 {
+		return NewSUBACKFromBytes(fixedHeader, remaining)
+	}
+
+	// Decode the byte data of MQTT 3.1.
+	if version == mqtt.ProtocolVersion31 {
+		return newSUBACK31FromBytes(fixedHeader, remaining)
+	}
+
+	// Validate and decode the byte data of MQTT 5.0.
+	packetID, props, variableHeader, payload, err := decodeSubAck(fixedHeader, remaining, TypeSUBACK)
+	if err != nil {
+		return nil, err
+	}
+
+	// Create a SUBACK Packet.
+	p := &SUBACK{
+		PacketID:    packetID,
+		ReturnCodes: payload,
+		Properties:  props,
+	}
+
+	// Set the fixed header, the variable header, the payload
+	// and the protocol version to the Packet.
+	p.fixedHeader = fixedHeader
+	p.variableHeader = variableHeader
+	p.payload = payload
+	p.version = version
+
+	// Return the Packet.
+	return p, nil
+}
+
+// newSUBACK31FromBytes creates an MQTT 3.1 SUBACK Packet from the byte
+// data and returns it. The failure Return Code is accepted though MQTT 3.1
+// does not define it because the Servers send it to the MQTT 3.1 Clients.
+func newSUBACK31FromBytes(fixedHeader FixedHeader, remaining []byte) (Packet, error) {
+	// Validate and decode the byte data in the same way as MQTT 3.1.1.
+	p, err := NewSUBACKFromBytes(fixedHeader, remaining)
+	if err != nil {
+		return nil, err
+	}
+
+	// Set the protocol version to the Packet.
+	p.(*SUBACK).version = mqtt.ProtocolVersion31
+
+	// Return the Packet.
+	return p, nil
+}
+
 // validateSUBACKBytes validates the fixed header and the remaining.
 func validateSUBACKBytes(fixedHeader FixedHeader, remaining []byte) error {
 	// Extract the MQTT Control Packet type.
@@ -110,3 +168,24 @@ func validateSUBACKBytes(fixedHeader FixedHeader, remaining []byte) error {
 
 	return nil
 }
+
+// String returns the human-readable representation of the Packet.
+func (p *SUBACK) String() string {
+	return p.jsonPacket().String()
+}
+
+// MarshalJSON returns the JSON representation of the Packet.
+func (p *SUBACK) MarshalJSON() ([]byte, error) {
+	return p.jsonPacket().marshal()
+}
+
+// jsonPacket creates the JSON representation of the Packet.
+func (p *SUBACK) jsonPacket() *jsonPacket {
+	jp := p.newJSONPacket(TypeSUBACK)
+
+	jp.PacketID = p.PacketID
+	jp.ReturnCodes = intCodes(p.ReturnCodes)
+	jp.Properties = p.Properties
+
+	return jp
+}
@@ -1,6 +1,11 @@
 package packet
 
-import "testing"
+import (
+	"bytes"
+	"testing"
+
+	"github.com/yosssi/gmq/mqtt"
+)
 
 func TestNewSUBACKFromBytes_err(t *testing.T) {
 	if _, err := NewSUBACKFromBytes(nil, nil); err != ErrInvalidFixedHeaderLen {
@@ -49,3 +54,43 @@ func Test_validateSUBACKBytes_ErrInvalidSUBACKReturnCode(t *testing.T) {
 		invalidError(t, err, ErrInvalidSUBACKReturnCode)
 	}
 }
+
+func Test_newSUBACKFromBytes_v5(t *testing.T) {
+	p, err := newSUBACKFromBytes([]byte{TypeSUBACK << 4, 0x09}, []byte{0x00, 0x01, 0x04, propReasonString, 0x00, 0x01, 'r', ReasonGrantedQoS1, ReasonNotAuthorized}, mqtt.ProtocolVersion5)
+	if err != nil {
+		nilErrorExpected(t, err)
+		return
+	}
+
+	suback := p.(*SUBACK)
+
+	if suback.PacketID != 1 || !bytes.Equal(suback.ReturnCodes, []byte{ReasonGrantedQoS1, ReasonNotAuthorized}) || string(suback.Properties.ReasonString) != "r" {
+		t.Errorf("suback => %+v", suback)
+	}
+}
+
+func Test_newSUBACKFromBytes_v31(t *testing.T) {
+	p, err := newSUBACKFromBytes([]byte{TypeSUBACK << 4, 0x04}, []byte{0x00, 0x01, 0x00, 0x02}, mqtt.ProtocolVersion31)
+	if err != nil {
+		nilErrorExpected(t, err)
+		return
+	}
+
+	if suback := p.(*SUBACK); !bytes.Equal(suback.ReturnCodes, []byte{0x00, 0x02}) || suback.ProtocolVersion() != mqtt.ProtocolVersion31 {
+		t.Errorf("suback => %+v", suback)
+	}
+
+	p, err = newSUBACKFromBytes([]byte{TypeSUBACK << 4, 0x03}, []byte{0x00, 0x01, SUBACKRetFailure}, mqtt.ProtocolVersion31)
+	if err != nil {
+		nilErrorExpected(t, err)
+		return
+	}
+
+	if suback := p.(*SUBACK); !bytes.Equal(suback.ReturnCodes, []byte{SUBACKRetFailure}) {
+		t.Errorf("suback => %+v", suback)
+	}
+
+	if _, err := newSUBACKFromBytes([]byte{TypeSUBACK << 4, 0x03}, []byte{0x00, 0x01, 0x03}, mqtt.ProtocolVersion31); err != ErrInvalidSUBACKReturnCode {
+		invalidError(t, err, ErrInvalidSUBACKReturnCode)
+	}
+}
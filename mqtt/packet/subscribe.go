@@ -1,5 +1,13 @@
 package packet
 
+import "github.com/yosssi/gmq/mqtt"
+
+// Minimum length of the fixed header of the SUBSCRIBE Packet
+const minLenSUBSCRIBEFixedHeader = 2
+
+// Length of the variable header of the SUBSCRIBE Packet
+const lenSUBSCRIBEVariableHeader = 2
+
 // SUBSCRIBE represents a SUBSCRIBE Packet.
 type SUBSCRIBE struct {
 	base
@@ -7,6 +15,9 @@ type SUBSCRIBE struct {
 	PacketID uint16
 	// SubReqs is a slice of the subscription requests.
 	SubReqs []*SubReq
+	// Properties is the Properties of the variable header.
+	// It is used only in MQTT 5.0.
+	Properties *Properties
 }
 
 // setFixedHeader sets the fixed header to the Packet.
@@ -22,6 +33,11 @@ func (p *SUBSCRIBE) setFixedHeader() {
 func (p *SUBSCRIBE) setVariableHeader() {
 	// Append the Packet Identifier to the variable header.
 	p.variableHeader = append(p.variableHeader, encodeUint16(p.PacketID)...)
+
+	// Append the Properties to the variable header in MQTT 5.0.
+	if p.v5() {
+		p.variableHeader = appendProperties(p.variableHeader, p.Properties)
+	}
 }
 
 // setPayload sets the payload to the Packet.
@@ -31,8 +47,9 @@ func (p *SUBSCRIBE) setPayload() {
 		// Append the Topic Filter to the payload.
 		p.payload = appendLenStr(p.payload, s.TopicFilter)
 
-		// Append the QoS to the payload.
-		p.payload = append(p.payload, s.QoS)
+		// Append the QoS, or the Subscription Options
+		// in MQTT 5.0, to the payload.
+		p.payload = append(p.payload, s.options())
 	}
 }
 
@@ -50,10 +67,14 @@ func NewSUBSCRIBE(opts *SUBSCRIBEOptions) (Packet, error) {
 
 	// Create a SUBSCRIBE Packet.
 	p := &SUBSCRIBE{
-		PacketID: opts.PacketID,
-		SubReqs:  opts.SubReqs,
+		PacketID:   opts.PacketID,
+		SubReqs:    opts.SubReqs,
+		Properties: opts.Properties,
 	}
 
+	// Set the protocol version to the Packet.
+	p.version = opts.ProtocolVersion
+
 	// Set the variable header to the Packet.
 	p.setVariableHeader()
 
@@ -66,3 +87,211 @@ func NewSUBSCRIBE(opts *SUBSCRIBEOptions) (Packet, error) {
 	// Return the Packet.
 	return p, nil
 }
+
+// NewSUBSCRIBEFromBytes creates a SUBSCRIBE Packet
+// from the byte data and returns it.
+func NewSUBSCRIBEFromBytes(fixedHeader FixedHeader, remaining []byte) (Packet, error) {
+	return newSUBSCRIBEFromBytes(fixedHeader, remaining, mqtt.ProtocolVersion311)
+}
+
+// newSUBSCRIBEFromBytes creates a SUBSCRIBE Packet of the protocol
+// version from the byte data and returns it.
+func newSUBSCRIBEFromBytes(fixedHeader FixedHeader, remaining []byte, version byte) (Packet, error) {
+	// Validate the byte data.
+	if err := validateVersionedSUBSCRIBEBytes(fixedHeader, remaining, version); err != nil {
+		return nil, err
+	}
+
+	// Decode the Properties in MQTT 5.0.
+	// No error occur because of the precedent validation and
+	// the returned error is not be taken care of.
+	var props *Properties
+
+	payload := remaining[lenSUBSCRIBEVariableHeader:]
+
+	if version == mqtt.ProtocolVersion5 {
+		props, payload, _ = decodeProperties(payload, TypeSUBSCRIBE)
+	}
+
+	// Extract the variable header.
+	variableHeader := remaining[0 : len(remaining)-len(payload)]
+
+	// Decode the Packet Identifier.
+	// No error occur because of the precedent validation and
+	// the returned error is not be taken care of.
+	packetID, _ := decodeUint16(variableHeader[0:lenSUBSCRIBEVariableHeader])
+
+	// Decode the subscription requests.
+	var subReqs []*SubReq
+
+	for b := payload; len(b) > 0; {
+		// Extract the length of the Topic Filter.
+		l, _ := decodeUint16(b[0:2])
+
+		// Extract the QoS or the Subscription Options.
+		o := b[2+l]
+
+		subReqs = append(subReqs, &SubReq{
+			TopicFilter:       b[2 : 2+l],
+			QoS:               o & 0x03,
+			NoLocal:           o&0x04 != 0,
+			RetainAsPublished: o&0x08 != 0,
+			RetainHandling:    o >> 4 & 0x03,
+		})
+
+		b = b[2+l+1:]
+	}
+
+	// Create a SUBSCRIBE Packet.
+	p := &SUBSCRIBE{
+		PacketID:   packetID,
+		SubReqs:    subReqs,
+		Properties: props,
+	}
+
+	// Set the protocol version to the Packet.
+	if version != mqtt.ProtocolVersion311 {
+		p.version = version
+	}
+
+	// Set the fixed header to the Packet.
+	p.fixedHeader = fixedHeader
+
+	// Set the variable header to the Packet.
+	p.variableHeader = variableHeader
+
+	// Set the payload to the Packet.
+	p.payload = payload
+
+	// Return the Packet.
+	return p, nil
+}
+
+// validateSUBSCRIBEBytes validates the fixed header and the remaining.
+func validateSUBSCRIBEBytes(fixedHeader FixedHeader, remaining []byte) error {
+	return validateVersionedSUBSCRIBEBytes(fixedHeader, remaining, mqtt.ProtocolVersion311)
+}
+
+// validateVersionedSUBSCRIBEBytes validates the fixed header
+// and the remaining of the protocol version.
+func validateVersionedSUBSCRIBEBytes(fixedHeader FixedHeader, remaining []byte, version byte) error {
+	// Extract the MQTT Control Packet type.
+	ptype, err := fixedHeader.ptype()
+	if err != nil {
+		return err
+	}
+
+	// Check the length of the fixed header.
+	if len(fixedHeader) < minLenSUBSCRIBEFixedHeader {
+		return ErrInvalidFixedHeaderLen
+	}
+
+	// Check the MQTT Control Packet type.
+	if ptype != TypeSUBSCRIBE {
+		return ErrInvalidPacketType
+	}
+
+	// Check the reserved bits of the fixed header.
+	if fixedHeader[0]&0x0F != 0x02 {
+		return ErrInvalidFixedHeader
+	}
+
+	// Check the length of the remaining.
+	if len(remaining) < lenSUBSCRIBEVariableHeader {
+		return ErrInvalidRemainingLen
+	}
+
+	// Extract the Packet Identifier.
+	packetID, _ := decodeUint16(remaining[0:lenSUBSCRIBEVariableHeader])
+
+	// Check the Packet Identifier.
+	if packetID == 0 {
+		return ErrInvalidPacketID
+	}
+
+	// Extract the payload.
+	payload := remaining[lenSUBSCRIBEVariableHeader:]
+
+	// Check the Properties which precede the payload in MQTT 5.0.
+	if version == mqtt.ProtocolVersion5 {
+		if _, payload, err = decodeProperties(payload, TypeSUBSCRIBE); err != nil {
+			return err
+		}
+	}
+
+	// Check the existence of the subscription requests.
+	if len(payload) == 0 {
+		return ErrInvalidNoSubReq
+	}
+
+	// Check each subscription request.
+	for b := payload; len(b) > 0; {
+		// Check the length of the Topic Filter.
+		if len(b) < 2 {
+			return ErrInvalidRemainingLen
+		}
+
+		l, _ := decodeUint16(b[0:2])
+
+		if l == 0 {
+			return ErrNoTopicFilter
+		}
+
+		if len(b) < 2+int(l)+1 {
+			return ErrInvalidRemainingLen
+		}
+
+		// Extract the QoS or the Subscription Options.
+		o := b[2+l]
+
+		// Check the reserved bits and the Retain Handling
+		// of the Subscription Options in MQTT 5.0.
+		if version == mqtt.ProtocolVersion5 {
+			if o&0xC0 != 0 || o>>4&0x03 > maxRetainHandling {
+				return ErrInvalidSubscriptionOptions
+			}
+
+			o &= 0x03
+		}
+
+		// Check the Requested QoS.
+		if !mqtt.ValidQoS(o) {
+			return ErrInvalidQoS
+		}
+
+		b = b[2+l+1:]
+	}
+
+	return nil
+}
+
+// String returns the human-readable representation of the Packet.
+func (p *SUBSCRIBE) String() string {
+	return p.jsonPacket().String()
+}
+
+// MarshalJSON returns the JSON representation of the Packet.
+func (p *SUBSCRIBE) MarshalJSON() ([]byte, error) {
+	return p.jsonPacket().marshal()
+}
+
+// jsonPacket creates the JSON representation of the Packet.
+func (p *SUBSCRIBE) jsonPacket() *jsonPacket {
+	jp := p.newJSONPacket(TypeSUBSCRIBE)
+
+	jp.PacketID = p.PacketID
+	jp.Properties = p.Properties
+
+	// Set the subscription requests.
+	for _, s := range p.SubReqs {
+		jp.Subscriptions = append(jp.Subscriptions, &jsonSubReq{
+			TopicFilter:       string(s.TopicFilter),
+			QoS:               s.QoS,
+			NoLocal:           s.NoLocal,
+			RetainAsPublished: s.RetainAsPublished,
+			RetainHandling:    s.RetainHandling,
+		})
+	}
+
+	return jp
+}
@@ -1,6 +1,10 @@
 package packet
 
-import "errors"
+import (
+	"errors"
+
+	"github.com/yosssi/gmq/mqtt"
+)
 
 // Error value
 var ErrInvalidNoSubReq = errors.New("subscription request must be specified")
@@ -11,6 +15,12 @@ type SUBSCRIBEOptions struct {
 	PacketID uint16
 	// SubReqs is a slice of the subscription requests.
 	SubReqs []*SubReq
+	// ProtocolVersion is the protocol version of the Packet.
+	// MQTT 3.1.1 is used if it is zero.
+	ProtocolVersion byte
+	// Properties is the Properties of the variable header.
+	// It is used only in MQTT 5.0.
+	Properties *Properties
 }
 
 // validate validates the options.
@@ -30,7 +40,13 @@ func (opts *SUBSCRIBEOptions) validate() error {
 		if err := s.validate(); err != nil {
 			return err
 		}
+
+		// Check the Subscription Options of MQTT 5.0.
+		if s.v5() && opts.ProtocolVersion != mqtt.ProtocolVersion5 {
+			return ErrInvalidSubscriptionOptions
+		}
 	}
 
-	return nil
+	// Check the protocol version and the Properties.
+	return validateVersionedOptions(opts.ProtocolVersion, TypeSUBSCRIBE, 0, opts.Properties)
 }
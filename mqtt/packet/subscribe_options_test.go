@@ -24,3 +24,16 @@ func TestSUBSCRIBEOptions_validate_ErrNoTopicFilter(t *testing.T) {
 		invalidError(t, err, ErrNoTopicFilter)
 	}
 }
+
+func TestSUBSCRIBEOptions_validate_ErrInvalidSubscriptionOptions(t *testing.T) {
+	opts := &SUBSCRIBEOptions{
+		PacketID: 1,
+		SubReqs: []*SubReq{
+			{TopicFilter: []byte("a"), NoLocal: true},
+		},
+	}
+
+	if err := opts.validate(); err != ErrInvalidSubscriptionOptions {
+		invalidError(t, err, ErrInvalidSubscriptionOptions)
+	}
+}
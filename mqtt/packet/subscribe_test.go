@@ -1,6 +1,11 @@
 package packet
 
-import "testing"
+import (
+	"bytes"
+	"testing"
+
+	"github.com/yosssi/gmq/mqtt"
+)
 
 func TestSUBSCRIBE_setFixedHeader(t *testing.T) {
 	p := &SUBSCRIBE{}
@@ -68,3 +73,143 @@ func TestNewSUBSCRIBE(t *testing.T) {
 		nilErrorExpected(t, err)
 	}
 }
+
+func TestNewSUBSCRIBEFromBytes_ErrInvalidFixedHeaderLen(t *testing.T) {
+	if _, err := NewSUBSCRIBEFromBytes(nil, nil); err != ErrInvalidFixedHeaderLen {
+		invalidError(t, err, ErrInvalidFixedHeaderLen)
+	}
+}
+
+func TestNewSUBSCRIBEFromBytes(t *testing.T) {
+	p, err := NewSUBSCRIBE(&SUBSCRIBEOptions{
+		PacketID: 1,
+		SubReqs: []*SubReq{
+			&SubReq{
+				TopicFilter: []byte("a/#"),
+				QoS:         mqtt.QoS1,
+			},
+			&SubReq{
+				TopicFilter: []byte("b"),
+				QoS:         mqtt.QoS2,
+			},
+		},
+	})
+	if err != nil {
+		nilErrorExpected(t, err)
+		return
+	}
+
+	var bf bytes.Buffer
+
+	p.WriteTo(&bf)
+
+	b := bf.Bytes()
+
+	decoded, err := NewSUBSCRIBEFromBytes(b[0:2], b[2:])
+	if err != nil {
+		nilErrorExpected(t, err)
+		return
+	}
+
+	subscribe := decoded.(*SUBSCRIBE)
+
+	if subscribe.PacketID != 1 {
+		t.Errorf("subscribe.PacketID => %d, want => 1", subscribe.PacketID)
+	}
+
+	if len(subscribe.SubReqs) != 2 {
+		t.Errorf("len(subscribe.SubReqs) => %d, want => 2", len(subscribe.SubReqs))
+		return
+	}
+
+	if string(subscribe.SubReqs[0].TopicFilter) != "a/#" || subscribe.SubReqs[0].QoS != mqtt.QoS1 {
+		t.Errorf("subscribe.SubReqs[0] => %+v, want => {a/# 1}", subscribe.SubReqs[0])
+	}
+
+	if string(subscribe.SubReqs[1].TopicFilter) != "b" || subscribe.SubReqs[1].QoS != mqtt.QoS2 {
+		t.Errorf("subscribe.SubReqs[1] => %+v, want => {b 2}", subscribe.SubReqs[1])
+	}
+
+	var decodedBf bytes.Buffer
+
+	decoded.WriteTo(&decodedBf)
+
+	if !bytes.Equal(decodedBf.Bytes(), b) {
+		t.Errorf("decoded bytes => %v, want => %v", decodedBf.Bytes(), b)
+	}
+}
+
+func Test_validateSUBSCRIBEBytes(t *testing.T) {
+	testCases := []struct {
+		fixedHeader FixedHeader
+		remaining   []byte
+		want        error
+	}{
+		{[]byte{TypeSUBSCRIBE<<4 | 0x02}, nil, ErrInvalidFixedHeaderLen},
+		{[]byte{TypeCONNECT << 4, 0x00}, nil, ErrInvalidPacketType},
+		{[]byte{TypeSUBSCRIBE << 4, 0x00}, nil, ErrInvalidFixedHeader},
+		{[]byte{TypeSUBSCRIBE<<4 | 0x02, 0x01}, []byte{0x00}, ErrInvalidRemainingLen},
+		{[]byte{TypeSUBSCRIBE<<4 | 0x02, 0x02}, []byte{0x00, 0x00}, ErrInvalidPacketID},
+		{[]byte{TypeSUBSCRIBE<<4 | 0x02, 0x02}, []byte{0x00, 0x01}, ErrInvalidNoSubReq},
+		{[]byte{TypeSUBSCRIBE<<4 | 0x02, 0x03}, []byte{0x00, 0x01, 0x00}, ErrInvalidRemainingLen},
+		{[]byte{TypeSUBSCRIBE<<4 | 0x02, 0x05}, []byte{0x00, 0x01, 0x00, 0x00, 0x00}, ErrNoTopicFilter},
+		{[]byte{TypeSUBSCRIBE<<4 | 0x02, 0x05}, []byte{0x00, 0x01, 0x00, 0x01, 0x61}, ErrInvalidRemainingLen},
+		{[]byte{TypeSUBSCRIBE<<4 | 0x02, 0x06}, []byte{0x00, 0x01, 0x00, 0x01, 0x61, 0x03}, ErrInvalidQoS},
+		{[]byte{TypeSUBSCRIBE<<4 | 0x02, 0x06}, []byte{0x00, 0x01, 0x00, 0x01, 0x61, 0x01}, nil},
+	}
+
+	for _, tc := range testCases {
+		if err := validateSUBSCRIBEBytes(tc.fixedHeader, tc.remaining); err != tc.want {
+			t.Errorf("validateSUBSCRIBEBytes(%v, %v) => %v, want => %v", tc.fixedHeader, tc.remaining, err, tc.want)
+		}
+	}
+}
+
+func TestNewSUBSCRIBE_v5(t *testing.T) {
+	p, err := NewSUBSCRIBE(&SUBSCRIBEOptions{
+		PacketID: 1,
+		SubReqs: []*SubReq{
+			{TopicFilter: []byte("a"), QoS: mqtt.QoS1, NoLocal: true, RetainHandling: 1},
+		},
+		ProtocolVersion: mqtt.ProtocolVersion5,
+		Properties:      &Properties{SubscriptionIdentifiers: []uint32{7}},
+	})
+	if err != nil {
+		nilErrorExpected(t, err)
+		return
+	}
+
+	decoded := roundTrip5(t, p).(*SUBSCRIBE)
+
+	if len(decoded.SubReqs) != 1 {
+		t.Fatalf("len(decoded.SubReqs) => %d, want => 1", len(decoded.SubReqs))
+	}
+
+	if s := decoded.SubReqs[0]; string(s.TopicFilter) != "a" || s.QoS != mqtt.QoS1 || !s.NoLocal || s.RetainAsPublished || s.RetainHandling != 1 {
+		t.Errorf("decoded.SubReqs[0] => %+v", s)
+	}
+
+	if props := decoded.Properties; props == nil || len(props.SubscriptionIdentifiers) != 1 || props.SubscriptionIdentifiers[0] != 7 {
+		t.Errorf("decoded.Properties => %+v", props)
+	}
+}
+
+func Test_validateVersionedSUBSCRIBEBytes_v5(t *testing.T) {
+	testCases := []struct {
+		remaining []byte
+		want      error
+	}{
+		{[]byte{0x00, 0x01, 0x01}, ErrInvalidRemainingLen},
+		{[]byte{0x00, 0x01, 0x00}, ErrInvalidNoSubReq},
+		{[]byte{0x00, 0x01, 0x00, 0x00, 0x01, 0x61, 0x40}, ErrInvalidSubscriptionOptions},
+		{[]byte{0x00, 0x01, 0x00, 0x00, 0x01, 0x61, 0x30}, ErrInvalidSubscriptionOptions},
+		{[]byte{0x00, 0x01, 0x00, 0x00, 0x01, 0x61, 0x03}, ErrInvalidQoS},
+		{[]byte{0x00, 0x01, 0x00, 0x00, 0x01, 0x61, 0x2E}, nil},
+	}
+
+	for _, tc := range testCases {
+		if err := validateVersionedSUBSCRIBEBytes([]byte{TypeSUBSCRIBE<<4 | 0x02, byte(len(tc.remaining))}, tc.remaining, mqtt.ProtocolVersion5); err != tc.want {
+			t.Errorf("validateVersionedSUBSCRIBEBytes(%v) => %v, want => %v", tc.remaining, err, tc.want)
+		}
+	}
+}
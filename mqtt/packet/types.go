@@ -16,4 +16,30 @@ const (
 	TypePINGREQ     byte = 0x0C
 	TypePINGRESP    byte = 0x0D
 	TypeDISCONNECT  byte = 0x0E
+	TypeAUTH        byte = 0x0F
 )
+
+// Names of the MQTT Control Packet types
+var typeNames = map[byte]string{
+	TypeCONNECT:     "CONNECT",
+	TypeCONNACK:     "CONNACK",
+	TypePUBLISH:     "PUBLISH",
+	TypePUBACK:      "PUBACK",
+	TypePUBREC:      "PUBREC",
+	TypePUBREL:      "PUBREL",
+	TypePUBCOMP:     "PUBCOMP",
+	TypeSUBSCRIBE:   "SUBSCRIBE",
+	TypeSUBACK:      "SUBACK",
+	TypeUNSUBSCRIBE: "UNSUBSCRIBE",
+	TypeUNSUBACK:    "UNSUBACK",
+	TypePINGREQ:     "PINGREQ",
+	TypePINGRESP:    "PINGRESP",
+	TypeDISCONNECT:  "DISCONNECT",
+	TypeAUTH:        "AUTH",
+}
+
+// TypeName returns the name of the MQTT Control Packet type.
+// It returns an empty string if the type is invalid.
+func TypeName(ptype byte) string {
+	return typeNames[ptype]
+}
@@ -0,0 +1,21 @@
+package packet
+
+import "testing"
+
+func TestTypeName(t *testing.T) {
+	testCases := []struct {
+		in   byte
+		want string
+	}{
+		{in: TypeCONNECT, want: "CONNECT"},
+		{in: TypePUBLISH, want: "PUBLISH"},
+		{in: TypeAUTH, want: "AUTH"},
+		{in: 0x00, want: ""},
+	}
+
+	for _, tc := range testCases {
+		if got := TypeName(tc.in); got != tc.want {
+			t.Errorf("TypeName(0x%02X) => %q, want => %q", tc.in, got, tc.want)
+		}
+	}
+}
@@ -1,5 +1,7 @@
 package packet
 
+import "github.com/yosssi/gmq/mqtt"
+
 // Length of the fixed header of the UNSUBACK Packet
 const lenUNSUBACKFixedHeader = 2
 
@@ -11,6 +13,12 @@ type UNSUBACK struct {
 	base
 	// PacketID is the Packet Identifier of the variable header.
 	PacketID uint16
+	// ReasonCodes is the Reason Codes of the payload.
+	// It is used only in MQTT 5.0.
+	ReasonCodes []byte
+	// Properties is the Properties of the variable header.
+	// It is used only in MQTT 5.0.
+	Properties *Properties
 }
 
 // NewUNSUBACKFromBytes creates an UNSUBACK Packet
@@ -41,6 +49,38 @@ func NewUNSUBACKFromBytes(fixedHeader FixedHeader, variableHeader []byte) (Packe
 	return p, nil
 }
 
+// newUNSUBACKFromBytes creates an UNSUBACK Packet of the protocol version
+// from the byte data and returns it.
+func newUNSUBACKFromBytes(fixedHeader FixedHeader, remaining []byte, version byte) (Packet, error) {
+	// Decode the byte data of MQTT 3.1.1.
+	if version != mqtt.ProtocolVersion5 {
+		return NewUNSUBACKFromBytes(fixedHeader, remaining)
+	}
+
+	// Validate and decode the byte data of MQTT 5.0.
+	packetID, props, variableHeader, payload, err := decodeSubAck(fixedHeader, remaining, TypeUNSUBACK)
+	if err != nil {
+		return nil, err
+	}
+
+	// Create an UNSUBACK Packet.
+	p := &UNSUBACK{
+		PacketID:    packetID,
+		ReasonCodes: payload,
+		Properties:  props,
+	}
+
+	// Set the fixed header, the variable header, the payload
+	// and the protocol version to the Packet.
+	p.fixedHeader = fixedHeader
+	p.variableHeader = variableHeader
+	p.payload = payload
+	p.version = version
+
+	// Return the Packet.
+	return p, nil
+}
+
 // validateUNSUBACKBytes validates the fixed header and the variable header.
 func validateUNSUBACKBytes(fixedHeader FixedHeader, variableHeader []byte) error {
 	// Extract the MQTT Control Packet type.
@@ -84,3 +124,24 @@ func validateUNSUBACKBytes(fixedHeader FixedHeader, variableHeader []byte) error
 
 	return nil
 }
+
+// String returns the human-readable representation of the Packet.
+func (p *UNSUBACK) String() string {
+	return p.jsonPacket().String()
+}
+
+// MarshalJSON returns the JSON representation of the Packet.
+func (p *UNSUBACK) MarshalJSON() ([]byte, error) {
+	return p.jsonPacket().marshal()
+}
+
+// jsonPacket creates the JSON representation of the Packet.
+func (p *UNSUBACK) jsonPacket() *jsonPacket {
+	jp := p.newJSONPacket(TypeUNSUBACK)
+
+	jp.PacketID = p.PacketID
+	jp.ReasonCodes = intCodes(p.ReasonCodes)
+	jp.Properties = p.Properties
+
+	return jp
+}
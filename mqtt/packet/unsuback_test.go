@@ -1,6 +1,11 @@
 package packet
 
-import "testing"
+import (
+	"bytes"
+	"testing"
+
+	"github.com/yosssi/gmq/mqtt"
+)
 
 func TestNewUNSUBACKFromBytes_err(t *testing.T) {
 	if _, err := NewUNSUBACKFromBytes(nil, nil); err != ErrInvalidFixedHeaderLen {
@@ -49,3 +54,21 @@ func Test_validateUNSUBACKBytes_ErrInvalidPacketID(t *testing.T) {
 		invalidError(t, err, ErrInvalidPacketID)
 	}
 }
+
+func Test_newUNSUBACKFromBytes_v5(t *testing.T) {
+	p, err := newUNSUBACKFromBytes([]byte{TypeUNSUBACK << 4, 0x05}, []byte{0x00, 0x01, 0x00, ReasonSuccess, ReasonNoSubscriptionExisted}, mqtt.ProtocolVersion5)
+	if err != nil {
+		nilErrorExpected(t, err)
+		return
+	}
+
+	unsuback := p.(*UNSUBACK)
+
+	if unsuback.PacketID != 1 || !bytes.Equal(unsuback.ReasonCodes, []byte{ReasonSuccess, ReasonNoSubscriptionExisted}) {
+		t.Errorf("unsuback => %+v", unsuback)
+	}
+
+	if _, err := newUNSUBACKFromBytes([]byte{TypeUNSUBACK << 4, 0x04}, []byte{0x00, 0x01, 0x00, ReasonGrantedQoS1}, mqtt.ProtocolVersion5); err != ErrInvalidReasonCode {
+		invalidError(t, err, ErrInvalidReasonCode)
+	}
+}
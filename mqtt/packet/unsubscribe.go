@@ -1,5 +1,13 @@
 package packet
 
+import "github.com/yosssi/gmq/mqtt"
+
+// Minimum length of the fixed header of the UNSUBSCRIBE Packet
+const minLenUNSUBSCRIBEFixedHeader = 2
+
+// Length of the variable header of the UNSUBSCRIBE Packet
+const lenUNSUBSCRIBEVariableHeader = 2
+
 // UNSUBSCRIBE represents an UNSUBSCRIBE Packet.
 type UNSUBSCRIBE struct {
 	base
@@ -7,6 +15,9 @@ type UNSUBSCRIBE struct {
 	PacketID uint16
 	// TopicFilters represents a slice of the Topic Filters
 	TopicFilters [][]byte
+	// Properties is the Properties of the variable header.
+	// It is used only in MQTT 5.0.
+	Properties *Properties
 }
 
 // setFixedHeader sets the fixed header to the Packet.
@@ -22,6 +33,11 @@ func (p *UNSUBSCRIBE) setFixedHeader() {
 func (p *UNSUBSCRIBE) setVariableHeader() {
 	// Append the Packet Identifier to the variable header.
 	p.variableHeader = append(p.variableHeader, encodeUint16(p.PacketID)...)
+
+	// Append the Properties to the variable header in MQTT 5.0.
+	if p.v5() {
+		p.variableHeader = appendProperties(p.variableHeader, p.Properties)
+	}
 }
 
 // setPayload sets the payload to the Packet.
@@ -49,8 +65,12 @@ func NewUNSUBSCRIBE(opts *UNSUBSCRIBEOptions) (Packet, error) {
 	p := &UNSUBSCRIBE{
 		PacketID:     opts.PacketID,
 		TopicFilters: opts.TopicFilters,
+		Properties:   opts.Properties,
 	}
 
+	// Set the protocol version to the Packet.
+	p.version = opts.ProtocolVersion
+
 	// Set the variable header to the Packet.
 	p.setVariableHeader()
 
@@ -63,3 +83,170 @@ func NewUNSUBSCRIBE(opts *UNSUBSCRIBEOptions) (Packet, error) {
 	// Return the Packet.
 	return p, nil
 }
+
+// NewUNSUBSCRIBEFromBytes creates an UNSUBSCRIBE Packet
+// from the byte data and returns it.
+func NewUNSUBSCRIBEFromBytes(fixedHeader FixedHeader, remaining []byte) (Packet, error) {
+	return newUNSUBSCRIBEFromBytes(fixedHeader, remaining, mqtt.ProtocolVersion311)
+}
+
+// newUNSUBSCRIBEFromBytes creates an UNSUBSCRIBE Packet of the protocol
+// version from the byte data and returns it.
+func newUNSUBSCRIBEFromBytes(fixedHeader FixedHeader, remaining []byte, version byte) (Packet, error) {
+	// Validate the byte data.
+	if err := validateVersionedUNSUBSCRIBEBytes(fixedHeader, remaining, version); err != nil {
+		return nil, err
+	}
+
+	// Decode the Properties in MQTT 5.0.
+	// No error occur because of the precedent validation and
+	// the returned error is not be taken care of.
+	var props *Properties
+
+	payload := remaining[lenUNSUBSCRIBEVariableHeader:]
+
+	if version == mqtt.ProtocolVersion5 {
+		props, payload, _ = decodeProperties(payload, TypeUNSUBSCRIBE)
+	}
+
+	// Extract the variable header.
+	variableHeader := remaining[0 : len(remaining)-len(payload)]
+
+	// Decode the Packet Identifier.
+	// No error occur because of the precedent validation and
+	// the returned error is not be taken care of.
+	packetID, _ := decodeUint16(variableHeader[0:lenUNSUBSCRIBEVariableHeader])
+
+	// Decode the Topic Filters.
+	var topicFilters [][]byte
+
+	for b := payload; len(b) > 0; {
+		var tf []byte
+
+		tf, b, _ = decodeLenStr(b)
+
+		topicFilters = append(topicFilters, tf)
+	}
+
+	// Create an UNSUBSCRIBE Packet.
+	p := &UNSUBSCRIBE{
+		PacketID:     packetID,
+		TopicFilters: topicFilters,
+		Properties:   props,
+	}
+
+	// Set the protocol version to the Packet.
+	if version != mqtt.ProtocolVersion311 {
+		p.version = version
+	}
+
+	// Set the fixed header to the Packet.
+	p.fixedHeader = fixedHeader
+
+	// Set the variable header to the Packet.
+	p.variableHeader = variableHeader
+
+	// Set the payload to the Packet.
+	p.payload = payload
+
+	// Return the Packet.
+	return p, nil
+}
+
+// validateUNSUBSCRIBEBytes validates the fixed header and the remaining.
+func validateUNSUBSCRIBEBytes(fixedHeader FixedHeader, remaining []byte) error {
+	return validateVersionedUNSUBSCRIBEBytes(fixedHeader, remaining, mqtt.ProtocolVersion311)
+}
+
+// validateVersionedUNSUBSCRIBEBytes validates the fixed header
+// and the remaining of the protocol version.
+func validateVersionedUNSUBSCRIBEBytes(fixedHeader FixedHeader, remaining []byte, version byte) error {
+	// Extract the MQTT Control Packet type.
+	ptype, err := fixedHeader.ptype()
+	if err != nil {
+		return err
+	}
+
+	// Check the length of the fixed header.
+	if len(fixedHeader) < minLenUNSUBSCRIBEFixedHeader {
+		return ErrInvalidFixedHeaderLen
+	}
+
+	// Check the MQTT Control Packet type.
+	if ptype != TypeUNSUBSCRIBE {
+		return ErrInvalidPacketType
+	}
+
+	// Check the reserved bits of the fixed header.
+	if fixedHeader[0]&0x0F != 0x02 {
+		return ErrInvalidFixedHeader
+	}
+
+	// Check the length of the remaining.
+	if len(remaining) < lenUNSUBSCRIBEVariableHeader {
+		return ErrInvalidRemainingLen
+	}
+
+	// Extract the Packet Identifier.
+	packetID, _ := decodeUint16(remaining[0:lenUNSUBSCRIBEVariableHeader])
+
+	// Check the Packet Identifier.
+	if packetID == 0 {
+		return ErrInvalidPacketID
+	}
+
+	// Extract the payload.
+	payload := remaining[lenUNSUBSCRIBEVariableHeader:]
+
+	// Check the Properties which precede the payload in MQTT 5.0.
+	if version == mqtt.ProtocolVersion5 {
+		if _, payload, err = decodeProperties(payload, TypeUNSUBSCRIBE); err != nil {
+			return err
+		}
+	}
+
+	// Check the existence of the Topic Filters.
+	if len(payload) == 0 {
+		return ErrNoTopicFilter
+	}
+
+	// Check each Topic Filter.
+	for b := payload; len(b) > 0; {
+		var tf []byte
+
+		if tf, b, err = decodeLenStr(b); err != nil {
+			return err
+		}
+
+		if len(tf) == 0 {
+			return ErrNoTopicFilter
+		}
+	}
+
+	return nil
+}
+
+// String returns the human-readable representation of the Packet.
+func (p *UNSUBSCRIBE) String() string {
+	return p.jsonPacket().String()
+}
+
+// MarshalJSON returns the JSON representation of the Packet.
+func (p *UNSUBSCRIBE) MarshalJSON() ([]byte, error) {
+	return p.jsonPacket().marshal()
+}
+
+// jsonPacket creates the JSON representation of the Packet.
+func (p *UNSUBSCRIBE) jsonPacket() *jsonPacket {
+	jp := p.newJSONPacket(TypeUNSUBSCRIBE)
+
+	jp.PacketID = p.PacketID
+	jp.Properties = p.Properties
+
+	// Set the Topic Filters.
+	for _, s := range p.TopicFilters {
+		jp.TopicFilters = append(jp.TopicFilters, string(s))
+	}
+
+	return jp
+}
@@ -1,11 +1,19 @@
 package packet
 
+import "github.com/yosssi/gmq/mqtt/topic"
+
 // UNSUBSCRIBEOptions represents options for an UNSUBSCRIBE Packet.
 type UNSUBSCRIBEOptions struct {
 	// PacketID is the Packet Identifier of the variable header.
 	PacketID uint16
 	// TopicFilters represents a slice of the Topic Filters
 	TopicFilters [][]byte
+	// ProtocolVersion is the protocol version of the Packet.
+	// MQTT 3.1.1 is used if it is zero.
+	ProtocolVersion byte
+	// Properties is the Properties of the variable header.
+	// It is used only in MQTT 5.0.
+	Properties *Properties
 }
 
 // validate validates the options.
@@ -32,7 +40,13 @@ func (opts *UNSUBSCRIBEOptions) validate() error {
 		if l > maxStringsLen {
 			return ErrTopicFilterExceedsMaxStringsLen
 		}
+
+		// Check the encoding and the wildcard characters of the Topic Filter.
+		if err := topic.ValidateFilter(string(topicFilter)); err != nil {
+			return err
+		}
 	}
 
-	return nil
+	// Check the protocol version and the Properties.
+	return validateVersionedOptions(opts.ProtocolVersion, TypeUNSUBSCRIBE, 0, opts.Properties)
 }
@@ -1,6 +1,10 @@
 package packet
 
-import "testing"
+import (
+	"testing"
+
+	"github.com/yosssi/gmq/mqtt/topic"
+)
 
 func TestUNSUBSCRIBEOptions_validate_ErrInvalidPacketID(t *testing.T) {
 	opts := &UNSUBSCRIBEOptions{}
@@ -48,7 +52,7 @@ func TestUNSUBSCRIBEOptions_validate(t *testing.T) {
 	opts := &UNSUBSCRIBEOptions{
 		PacketID: 1,
 		TopicFilters: [][]byte{
-			make([]byte, 1),
+			[]byte("a"),
 		},
 	}
 
@@ -56,3 +60,29 @@ func TestUNSUBSCRIBEOptions_validate(t *testing.T) {
 		nilErrorExpected(t, err)
 	}
 }
+
+func TestUNSUBSCRIBEOptions_validate_ErrPropertiesNotSupported(t *testing.T) {
+	opts := &UNSUBSCRIBEOptions{
+		PacketID:     1,
+		TopicFilters: [][]byte{[]byte("a")},
+		Properties:   &Properties{},
+	}
+
+	if err := opts.validate(); err != ErrPropertiesNotSupported {
+		invalidError(t, err, ErrPropertiesNotSupported)
+	}
+}
+
+func TestUNSUBSCRIBEOptions_validate_invalidTopicFilter(t *testing.T) {
+	opts := &UNSUBSCRIBEOptions{
+		PacketID: 1,
+		TopicFilters: [][]byte{
+			[]byte("a/+"),
+			[]byte("a/+b"),
+		},
+	}
+
+	if err := opts.validate(); err != topic.ErrInvalidSingleLevelWildcard {
+		invalidError(t, err, topic.ErrInvalidSingleLevelWildcard)
+	}
+}
@@ -1,6 +1,11 @@
 package packet
 
-import "testing"
+import (
+	"bytes"
+	"testing"
+
+	"github.com/yosssi/gmq/mqtt"
+)
 
 func TestUNSUBSCRIBE_setFixedHeader(t *testing.T) {
 	p := &UNSUBSCRIBE{}
@@ -65,3 +70,104 @@ func TestNewUNSUBSCRIBE(t *testing.T) {
 		nilErrorExpected(t, err)
 	}
 }
+
+func TestNewUNSUBSCRIBEFromBytes_ErrInvalidFixedHeaderLen(t *testing.T) {
+	if _, err := NewUNSUBSCRIBEFromBytes(nil, nil); err != ErrInvalidFixedHeaderLen {
+		invalidError(t, err, ErrInvalidFixedHeaderLen)
+	}
+}
+
+func TestNewUNSUBSCRIBEFromBytes(t *testing.T) {
+	p, err := NewUNSUBSCRIBE(&UNSUBSCRIBEOptions{
+		PacketID:     1,
+		TopicFilters: [][]byte{[]byte("a/#"), []byte("b")},
+	})
+	if err != nil {
+		nilErrorExpected(t, err)
+		return
+	}
+
+	var bf bytes.Buffer
+
+	p.WriteTo(&bf)
+
+	b := bf.Bytes()
+
+	decoded, err := NewUNSUBSCRIBEFromBytes(b[0:2], b[2:])
+	if err != nil {
+		nilErrorExpected(t, err)
+		return
+	}
+
+	unsubscribe := decoded.(*UNSUBSCRIBE)
+
+	if unsubscribe.PacketID != 1 {
+		t.Errorf("unsubscribe.PacketID => %d, want => 1", unsubscribe.PacketID)
+	}
+
+	if len(unsubscribe.TopicFilters) != 2 || string(unsubscribe.TopicFilters[0]) != "a/#" || string(unsubscribe.TopicFilters[1]) != "b" {
+		t.Errorf("unsubscribe.TopicFilters => %q, want => [a/# b]", unsubscribe.TopicFilters)
+	}
+
+	var decodedBf bytes.Buffer
+
+	decoded.WriteTo(&decodedBf)
+
+	if !bytes.Equal(decodedBf.Bytes(), b) {
+		t.Errorf("decoded bytes => %v, want => %v", decodedBf.Bytes(), b)
+	}
+}
+
+func Test_validateUNSUBSCRIBEBytes(t *testing.T) {
+	testCases := []struct {
+		fixedHeader FixedHeader
+		remaining   []byte
+		want        error
+	}{
+		{[]byte{TypeUNSUBSCRIBE<<4 | 0x02}, nil, ErrInvalidFixedHeaderLen},
+		{[]byte{TypeCONNECT << 4, 0x00}, nil, ErrInvalidPacketType},
+		{[]byte{TypeUNSUBSCRIBE << 4, 0x00}, nil, ErrInvalidFixedHeader},
+		{[]byte{TypeUNSUBSCRIBE<<4 | 0x02, 0x01}, []byte{0x00}, ErrInvalidRemainingLen},
+		{[]byte{TypeUNSUBSCRIBE<<4 | 0x02, 0x02}, []byte{0x00, 0x00}, ErrInvalidPacketID},
+		{[]byte{TypeUNSUBSCRIBE<<4 | 0x02, 0x02}, []byte{0x00, 0x01}, ErrNoTopicFilter},
+		{[]byte{TypeUNSUBSCRIBE<<4 | 0x02, 0x03}, []byte{0x00, 0x01, 0x00}, ErrInvalidRemainingLen},
+		{[]byte{TypeUNSUBSCRIBE<<4 | 0x02, 0x04}, []byte{0x00, 0x01, 0x00, 0x00}, ErrNoTopicFilter},
+		{[]byte{TypeUNSUBSCRIBE<<4 | 0x02, 0x05}, []byte{0x00, 0x01, 0x00, 0x02, 0x61}, ErrInvalidRemainingLen},
+		{[]byte{TypeUNSUBSCRIBE<<4 | 0x02, 0x05}, []byte{0x00, 0x01, 0x00, 0x01, 0x61}, nil},
+	}
+
+	for _, tc := range testCases {
+		if err := validateUNSUBSCRIBEBytes(tc.fixedHeader, tc.remaining); err != tc.want {
+			t.Errorf("validateUNSUBSCRIBEBytes(%v, %v) => %v, want => %v", tc.fixedHeader, tc.remaining, err, tc.want)
+		}
+	}
+}
+
+func TestNewUNSUBSCRIBE_v5(t *testing.T) {
+	p, err := NewUNSUBSCRIBE(&UNSUBSCRIBEOptions{
+		PacketID:        1,
+		TopicFilters:    [][]byte{[]byte("a"), []byte("b")},
+		ProtocolVersion: mqtt.ProtocolVersion5,
+		Properties:      &Properties{UserProperties: []UserProperty{{Key: []byte("k"), Value: []byte("v")}}},
+	})
+	if err != nil {
+		nilErrorExpected(t, err)
+		return
+	}
+
+	decoded := roundTrip5(t, p).(*UNSUBSCRIBE)
+
+	if len(decoded.TopicFilters) != 2 || decoded.Properties == nil || len(decoded.Properties.UserProperties) != 1 {
+		t.Errorf("decoded => %+v, want => the same as %+v", decoded, p)
+	}
+}
+
+func Test_validateVersionedUNSUBSCRIBEBytes_v5(t *testing.T) {
+	if err := validateVersionedUNSUBSCRIBEBytes([]byte{TypeUNSUBSCRIBE<<4 | 0x02, 0x03}, []byte{0x00, 0x01, 0x01}, mqtt.ProtocolVersion5); err != ErrInvalidRemainingLen {
+		invalidError(t, err, ErrInvalidRemainingLen)
+	}
+
+	if err := validateVersionedUNSUBSCRIBEBytes([]byte{TypeUNSUBSCRIBE<<4 | 0x02, 0x03}, []byte{0x00, 0x01, 0x00}, mqtt.ProtocolVersion5); err != ErrNoTopicFilter {
+		invalidError(t, err, ErrNoTopicFilter)
+	}
+}
@@ -0,0 +1,3 @@
+// Package topic provides the validation and the matching
+// of the MQTT Topic Names and Topic Filters.
+package topic
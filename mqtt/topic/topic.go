@@ -0,0 +1,148 @@
+package topic
+
+import (
+	"errors"
+	"strings"
+	"unicode/utf8"
+)
+
+// Maximum length of the Topic Name and the Topic Filter
+const maxLen = 65535
+
+// Wildcard characters and the topic level separator
+const (
+	multiLevelWildcard  = "#"
+	singleLevelWildcard = "+"
+	levelSeparator      = '/'
+)
+
+// Error values
+var (
+	ErrEmpty                      = errors.New("the Topic Name or the Topic Filter must not be zero-byte")
+	ErrTooLong                    = errors.New("the length of the Topic Name or the Topic Filter exceeds 65535 bytes")
+	ErrInvalidUTF8                = errors.New("the Topic Name or the Topic Filter is not a valid UTF-8 string")
+	ErrNullCharacter              = errors.New("the Topic Name or the Topic Filter must not contain U+0000")
+	ErrWildcardInName             = errors.New("the Topic Name must not contain the wildcard characters")
+	ErrInvalidMultiLevelWildcard  = errors.New("the multi-level wildcard must occupy the last level of the Topic Filter")
+	ErrInvalidSingleLevelWildcard = errors.New("the single-level wildcard must occupy an entire level of the Topic Filter")
+)
+
+// ValidateName validates the Topic Name of the PUBLISH Packet.
+func ValidateName(name string) error {
+	// Check the common rules.
+	if err := validate(name); err != nil {
+		return err
+	}
+
+	// Check the wildcard characters.
+	if strings.ContainsAny(name, multiLevelWildcard+singleLevelWildcard) {
+		return ErrWildcardInName
+	}
+
+	return nil
+}
+
+// ValidateFilter validates the Topic Filter of the SUBSCRIBE
+// or UNSUBSCRIBE Packet.
+func ValidateFilter(filter string) error {
+	// Check the common rules.
+	if err := validate(filter); err != nil {
+		return err
+	}
+
+	// Check the placement of the wildcard characters.
+	for rest, more := filter, true; more; {
+		var level string
+
+		level, rest, more = cut(rest)
+
+		switch {
+		case level == multiLevelWildcard:
+			if more {
+				return ErrInvalidMultiLevelWildcard
+			}
+		case strings.Contains(level, multiLevelWildcard):
+			return ErrInvalidMultiLevelWildcard
+		case level != singleLevelWildcard && strings.Contains(level, singleLevelWildcard):
+			return ErrInvalidSingleLevelWildcard
+		}
+	}
+
+	return nil
+}
+
+// Match returns true if the Topic Name matches the Topic Filter.
+// The Topic Names starting with '$' are not matched by the Topic
+// Filters starting with the wildcard characters.
+func Match(name, filter string) bool {
+	// Check the Topic Name starting with '$'.
+	if strings.HasPrefix(name, "$") && (strings.HasPrefix(filter, multiLevelWildcard) || strings.HasPrefix(filter, singleLevelWildcard)) {
+		return false
+	}
+
+	for {
+		// Extract the first levels.
+		filterLevel, filterRest, filterMore := cut(filter)
+
+		// The multi-level wildcard matches the rest of the Topic Name.
+		if filterLevel == multiLevelWildcard {
+			return true
+		}
+
+		nameLevel, nameRest, nameMore := cut(name)
+
+		// Compare the levels.
+		if filterLevel != singleLevelWildcard && filterLevel != nameLevel {
+			return false
+		}
+
+		// Check the end of the Topic Filter.
+		if !filterMore {
+			return !nameMore
+		}
+
+		// Check the end of the Topic Name. The multi-level wildcard
+		// also matches the parent level.
+		if !nameMore {
+			return filterRest == multiLevelWildcard
+		}
+
+		name, filter = nameRest, filterRest
+	}
+}
+
+// validate validates the rules which are common between
+// the Topic Name and the Topic Filter.
+func validate(s string) error {
+	// Check the length.
+	if len(s) == 0 {
+		return ErrEmpty
+	}
+
+	if len(s) > maxLen {
+		return ErrTooLong
+	}
+
+	// Check the encoding.
+	if !utf8.ValidString(s) {
+		return ErrInvalidUTF8
+	}
+
+	// Check the null character.
+	if strings.IndexByte(s, 0x00) >= 0 {
+		return ErrNullCharacter
+	}
+
+	return nil
+}
+
+// cut returns the first level, the rest of the levels
+// and true if the rest exists.
+func cut(s string) (string, string, bool) {
+	i := strings.IndexByte(s, levelSeparator)
+	if i < 0 {
+		return s, "", false
+	}
+
+	return s[:i], s[i+1:], true
+}
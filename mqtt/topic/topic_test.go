@@ -0,0 +1,434 @@
+package topic
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestMatch(t *testing.T) {
+	testCases := []struct {
+		in struct {
+			topicName   string
+			topicFilter string
+		}
+		out bool
+	}{
+		{
+			in: struct {
+				topicName   string
+				topicFilter string
+			}{
+				topicName:   "sport/tennis/player1",
+				topicFilter: "sport/tennis/player1/#",
+			},
+			out: true,
+		},
+		{
+			in: struct {
+				topicName   string
+				topicFilter string
+			}{
+				topicName:   "sport/tennis/player1/ranking",
+				topicFilter: "sport/tennis/player1/#",
+			},
+			out: true,
+		},
+		{
+			in: struct {
+				topicName   string
+				topicFilter string
+			}{
+				topicName:   "sport/tennis/player1/score/wimbledon",
+				topicFilter: "sport/tennis/player1/#",
+			},
+			out: true,
+		},
+		{
+			in: struct {
+				topicName   string
+				topicFilter string
+			}{
+				topicName:   "sport/tennis/player2",
+				topicFilter: "sport/tennis/player1/#",
+			},
+			out: false,
+		},
+		{
+			in: struct {
+				topicName   string
+				topicFilter string
+			}{
+				topicName:   "",
+				topicFilter: "#",
+			},
+			out: true,
+		},
+		{
+			in: struct {
+				topicName   string
+				topicFilter string
+			}{
+				topicName:   "test",
+				topicFilter: "#",
+			},
+			out: true,
+		},
+		{
+			in: struct {
+				topicName   string
+				topicFilter string
+			}{
+				topicName:   "test/test",
+				topicFilter: "#",
+			},
+			out: true,
+		},
+		{
+			in: struct {
+				topicName   string
+				topicFilter string
+			}{
+				topicName:   "sport/tennis/player1",
+				topicFilter: "sport/tennis/+",
+			},
+			out: true,
+		},
+		{
+			in: struct {
+				topicName   string
+				topicFilter string
+			}{
+				topicName:   "sport/tennis/player2",
+				topicFilter: "sport/tennis/+",
+			},
+			out: true,
+		},
+		{
+			in: struct {
+				topicName   string
+				topicFilter string
+			}{
+				topicName:   "sport/tennis/player1/ranking",
+				topicFilter: "sport/tennis/+",
+			},
+			out: false,
+		},
+		{
+			in: struct {
+				topicName   string
+				topicFilter string
+			}{
+				topicName:   "sport/tennis/",
+				topicFilter: "sport/tennis/+",
+			},
+			out: true,
+		},
+		{
+			in: struct {
+				topicName   string
+				topicFilter string
+			}{
+				topicName:   "sport/tennis",
+				topicFilter: "sport/tennis/+",
+			},
+			out: false,
+		},
+		{
+			in: struct {
+				topicName   string
+				topicFilter string
+			}{
+				topicName:   "",
+				topicFilter: "+",
+			},
+			out: true,
+		},
+		{
+			in: struct {
+				topicName   string
+				topicFilter string
+			}{
+				topicName:   "test",
+				topicFilter: "+",
+			},
+			out: true,
+		},
+		{
+			in: struct {
+				topicName   string
+				topicFilter string
+			}{
+				topicName:   "test/test",
+				topicFilter: "+",
+			},
+			out: false,
+		},
+		{
+			in: struct {
+				topicName   string
+				topicFilter string
+			}{
+				topicName:   "/tennis",
+				topicFilter: "+/tennis/#",
+			},
+			out: true,
+		},
+		{
+			in: struct {
+				topicName   string
+				topicFilter string
+			}{
+				topicName:   "test/tennis",
+				topicFilter: "+/tennis/#",
+			},
+			out: true,
+		},
+		{
+			in: struct {
+				topicName   string
+				topicFilter string
+			}{
+				topicName:   "test/tennis/test",
+				topicFilter: "+/tennis/#",
+			},
+			out: true,
+		},
+		{
+			in: struct {
+				topicName   string
+				topicFilter string
+			}{
+				topicName:   "test/tennis/test/test",
+				topicFilter: "+/tennis/#",
+			},
+			out: true,
+		},
+		{
+			in: struct {
+				topicName   string
+				topicFilter string
+			}{
+				topicName:   "test/tennis2/",
+				topicFilter: "+/tennis/#",
+			},
+			out: false,
+		},
+		{
+			in: struct {
+				topicName   string
+				topicFilter string
+			}{
+				topicName:   "sport//player1",
+				topicFilter: "sport/+/player1",
+			},
+			out: true,
+		},
+		{
+			in: struct {
+				topicName   string
+				topicFilter string
+			}{
+				topicName:   "sport/test/player1",
+				topicFilter: "sport/+/player1",
+			},
+			out: true,
+		},
+		{
+			in: struct {
+				topicName   string
+				topicFilter string
+			}{
+				topicName:   "sport/player1",
+				topicFilter: "sport/+/player1",
+			},
+			out: false,
+		},
+		{
+			in: struct {
+				topicName   string
+				topicFilter string
+			}{
+				topicName:   "/finance",
+				topicFilter: "+/+",
+			},
+			out: true,
+		},
+		{
+			in: struct {
+				topicName   string
+				topicFilter string
+			}{
+				topicName:   "/finance",
+				topicFilter: "/+",
+			},
+			out: true,
+		},
+		{
+			in: struct {
+				topicName   string
+				topicFilter string
+			}{
+				topicName:   "/finance",
+				topicFilter: "+",
+			},
+			out: false,
+		},
+		{
+			in: struct {
+				topicName   string
+				topicFilter string
+			}{
+				topicName:   "$SYS",
+				topicFilter: "#",
+			},
+			out: false,
+		},
+		{
+			in: struct {
+				topicName   string
+				topicFilter string
+			}{
+				topicName:   "$SYS/monitor/Clients",
+				topicFilter: "+/monitor/Clients",
+			},
+			out: false,
+		},
+		{
+			in: struct {
+				topicName   string
+				topicFilter string
+			}{
+				topicName:   "$SYS",
+				topicFilter: "$SYS/#",
+			},
+			out: true,
+		},
+		{
+			in: struct {
+				topicName   string
+				topicFilter string
+			}{
+				topicName:   "$SYS/",
+				topicFilter: "$SYS/#",
+			},
+			out: true,
+		},
+		{
+			in: struct {
+				topicName   string
+				topicFilter string
+			}{
+				topicName:   "$SYS/test",
+				topicFilter: "$SYS/#",
+			},
+			out: true,
+		},
+		{
+			in: struct {
+				topicName   string
+				topicFilter string
+			}{
+				topicName:   "$SYS/test/test",
+				topicFilter: "$SYS/#",
+			},
+			out: true,
+		},
+		{
+			in: struct {
+				topicName   string
+				topicFilter string
+			}{
+				topicName:   "$SYS/monitor/",
+				topicFilter: "$SYS/monitor/+",
+			},
+			out: true,
+		},
+		{
+			in: struct {
+				topicName   string
+				topicFilter string
+			}{
+				topicName:   "$SYS/monitor/Clients",
+				topicFilter: "$SYS/monitor/+",
+			},
+			out: true,
+		},
+		{
+			in: struct {
+				topicName   string
+				topicFilter string
+			}{
+				topicName:   "$SYS/monitor/Clients/test",
+				topicFilter: "$SYS/monitor/+",
+			},
+			out: false,
+		},
+	}
+
+	for _, tc := range testCases {
+		if got := Match(tc.in.topicName, tc.in.topicFilter); got != tc.out {
+			t.Errorf("got => %t, want => %t", got, tc.out)
+		}
+	}
+}
+
+func TestValidateName(t *testing.T) {
+	testCases := []struct {
+		name string
+		want error
+	}{
+		{"", ErrEmpty},
+		{strings.Repeat("a", 65536), ErrTooLong},
+		{"a/\xff", ErrInvalidUTF8},
+		{"a/\x00", ErrNullCharacter},
+		{"a/+", ErrWildcardInName},
+		{"a/#", ErrWildcardInName},
+		{"a/b+c", ErrWildcardInName},
+		{"/", nil},
+		{"$SYS/monitor", nil},
+		{"sport/tennis/player1", nil},
+	}
+
+	for _, tc := range testCases {
+		if err := ValidateName(tc.name); err != tc.want {
+			t.Errorf("ValidateName(%q) => %v, want => %v", tc.name, err, tc.want)
+		}
+	}
+}
+
+func TestValidateFilter(t *testing.T) {
+	testCases := []struct {
+		filter string
+		want   error
+	}{
+		{"", ErrEmpty},
+		{strings.Repeat("a", 65536), ErrTooLong},
+		{"a/\xff", ErrInvalidUTF8},
+		{"a/\x00", ErrNullCharacter},
+		{"#/a", ErrInvalidMultiLevelWildcard},
+		{"a/b#", ErrInvalidMultiLevelWildcard},
+		{"a/#/", ErrInvalidMultiLevelWildcard},
+		{"a+", ErrInvalidSingleLevelWildcard},
+		{"a/+b/c", ErrInvalidSingleLevelWildcard},
+		{"#", nil},
+		{"+", nil},
+		{"+/+", nil},
+		{"/+", nil},
+		{"sport/tennis/#", nil},
+		{"sport/+/player1", nil},
+	}
+
+	for _, tc := range testCases {
+		if err := ValidateFilter(tc.filter); err != tc.want {
+			t.Errorf("ValidateFilter(%q) => %v, want => %v", tc.filter, err, tc.want)
+		}
+	}
+}
+
+func BenchmarkMatch(b *testing.B) {
+	b.ReportAllocs()
+
+	for i := 0; i < b.N; i++ {
+		Match("sensors/plant1/line3/temperature", "sensors/+/line3/#")
+	}
+}
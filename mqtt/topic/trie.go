@@ -0,0 +1,245 @@
+package topic
+
+import "sync"
+
+// node represents a level of the Topic Filters.
+type node struct {
+	// children is the child nodes by their levels.
+	children map[string]*node
+	// filter is the Topic Filter which ends at the node.
+	filter string
+	// value is the value of the Topic Filter.
+	value interface{}
+	// set is true if a Topic Filter ends at the node.
+	set bool
+}
+
+// match calls f with the Topic Filters under the node which match
+// the rest of the Topic Name. first is true if the rest is the whole
+// Topic Name and dollar is true if the Topic Name starts with '$'.
+func (n *node) match(name string, first, dollar bool, f func(string, interface{})) {
+	// The wildcard characters of the first level
+	// do not match the Topic Name starting with '$'.
+	wildcard := !first || !dollar
+
+	// The multi-level wildcard matches the rest of the Topic Name.
+	if c := n.children[multiLevelWildcard]; wildcard && c != nil && c.set {
+		f(c.filter, c.value)
+	}
+
+	level, rest, more := cut(name)
+
+	// The single-level wildcard matches the level.
+	if c := n.children[singleLevelWildcard]; wildcard && c != nil {
+		c.matchRest(rest, more, f)
+	}
+
+	// Match the level exactly. The wildcard characters
+	// in the Topic Name are not handled as the wildcards.
+	if level == multiLevelWildcard || level == singleLevelWildcard {
+		return
+	}
+
+	if c := n.children[level]; c != nil {
+		c.matchRest(rest, more, f)
+	}
+}
+
+// matchRest calls f with the Topic Filters under the node which
+// match the rest of the Topic Name. more is false if the node
+// matches the last level of the Topic Name.
+func (n *node) matchRest(rest string, more bool, f func(string, interface{})) {
+	if more {
+		n.match(rest, false, false, f)
+		return
+	}
+
+	if n.set {
+		f(n.filter, n.value)
+	}
+
+	// The multi-level wildcard also matches the parent level.
+	if c := n.children[multiLevelWildcard]; c != nil && c.set {
+		f(c.filter, c.value)
+	}
+}
+
+// rangeNodes calls f with the Topic Filters under the node.
+func (n *node) rangeNodes(f func(string, interface{})) {
+	if n.set {
+		f(n.filter, n.value)
+	}
+
+	for _, c := range n.children {
+		c.rangeNodes(f)
+	}
+}
+
+// Trie is a trie of the Topic Filters and their values which finds
+// the Topic Filters matching a Topic Name without comparing it with
+// all of them. It is safe for concurrent use.
+type Trie struct {
+	// mu is the RWMutex for the nodes.
+	mu sync.RWMutex
+	// root is the root node.
+	root node
+	// n is the number of the Topic Filters.
+	n int
+}
+
+// Add adds the Topic Filter and its value to the Trie.
+// It replaces the value if the Topic Filter exists.
+func (t *Trie) Add(filter string, value interface{}) {
+	// Lock for update.
+	t.mu.Lock()
+
+	// Unlock.
+	defer t.mu.Unlock()
+
+	// Get or create the nodes of the levels.
+	n := &t.root
+
+	for rest, more := filter, true; more; {
+		var level string
+
+		level, rest, more = cut(rest)
+
+		c := n.children[level]
+		if c == nil {
+			if n.children == nil {
+				n.children = make(map[string]*node)
+			}
+
+			c = &node{}
+			n.children[level] = c
+		}
+
+		n = c
+	}
+
+	// Set the Topic Filter and the value.
+	if !n.set {
+		t.n++
+	}
+
+	n.filter = filter
+	n.value = value
+	n.set = true
+}
+
+// Remove removes the Topic Filter from the Trie and returns
+// true if the Topic Filter existed.
+func (t *Trie) Remove(filter string) bool {
+	// Lock for update.
+	t.mu.Lock()
+
+	// Unlock.
+	defer t.mu.Unlock()
+
+	// Find the nodes of the levels.
+	path := []*node{&t.root}
+	var levels []string
+
+	for rest, more := filter, true; more; {
+		var level string
+
+		level, rest, more = cut(rest)
+
+		c := path[len(path)-1].children[level]
+		if c == nil {
+			return false
+		}
+
+		path = append(path, c)
+		levels = append(levels, level)
+	}
+
+	n := path[len(path)-1]
+
+	if !n.set {
+		return false
+	}
+
+	// Unset the Topic Filter.
+	n.filter = ""
+	n.value = nil
+	n.set = false
+
+	t.n--
+
+	// Delete the nodes which are no longer used.
+	for i := len(path) - 1; i > 0; i-- {
+		if path[i].set || len(path[i].children) > 0 {
+			break
+		}
+
+		delete(path[i-1].children, levels[i-1])
+	}
+
+	return true
+}
+
+// Get returns the value of the Topic Filter and true
+// if the Topic Filter exists.
+func (t *Trie) Get(filter string) (interface{}, bool) {
+	// Lock for reading.
+	t.mu.RLock()
+
+	// Unlock.
+	defer t.mu.RUnlock()
+
+	// Find the node of the last level.
+	n := &t.root
+
+	for rest, more := filter, true; more; {
+		var level string
+
+		level, rest, more = cut(rest)
+
+		if n = n.children[level]; n == nil {
+			return nil, false
+		}
+	}
+
+	return n.value, n.set
+}
+
+// Match calls f with each Topic Filter which matches the Topic
+// Name and its value. f must not modify the Trie.
+func (t *Trie) Match(name string, f func(filter string, value interface{})) {
+	// Lock for reading.
+	t.mu.RLock()
+
+	// Unlock.
+	defer t.mu.RUnlock()
+
+	t.root.match(name, true, len(name) > 0 && name[0] == '$', f)
+}
+
+// Range calls f with each Topic Filter of the Trie and its
+// value in no particular order. f must not modify the Trie.
+func (t *Trie) Range(f func(filter string, value interface{})) {
+	// Lock for reading.
+	t.mu.RLock()
+
+	// Unlock.
+	defer t.mu.RUnlock()
+
+	t.root.rangeNodes(f)
+}
+
+// Len returns the number of the Topic Filters of the Trie.
+func (t *Trie) Len() int {
+	// Lock for reading.
+	t.mu.RLock()
+
+	// Unlock.
+	defer t.mu.RUnlock()
+
+	return t.n
+}
+
+// NewTrie creates and returns a Trie.
+func NewTrie() *Trie {
+	return &Trie{}
+}
@@ -0,0 +1,192 @@
+package topic
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+	"sync"
+	"testing"
+)
+
+// matchTrie returns the sorted Topic Filters of the Trie
+// which match the Topic Name.
+func matchTrie(tr *Trie, name string) []string {
+	var filters []string
+
+	tr.Match(name, func(filter string, _ interface{}) {
+		filters = append(filters, filter)
+	})
+
+	sort.Strings(filters)
+
+	return filters
+}
+
+func TestTrie_Match(t *testing.T) {
+	filters := []string{
+		"#",
+		"+",
+		"+/+",
+		"/+",
+		"+/tennis/#",
+		"sport/tennis/player1/#",
+		"sport/tennis/+",
+		"sport/+/player1",
+		"$SYS/#",
+		"$SYS/monitor/+",
+		"+/monitor/Clients",
+	}
+
+	names := []string{
+		"",
+		"/",
+		"/finance",
+		"/tennis",
+		"test",
+		"test/tennis/test",
+		"sport/tennis",
+		"sport/tennis/",
+		"sport/tennis/player1",
+		"sport/tennis/player1/ranking",
+		"sport//player1",
+		"$SYS",
+		"$SYS/monitor/Clients",
+		"a/+",
+		"a/#",
+	}
+
+	tr := NewTrie()
+
+	for _, filter := range filters {
+		tr.Add(filter, nil)
+	}
+
+	// The Trie must be consistent with Match.
+	for _, name := range names {
+		var want []string
+
+		for _, filter := range filters {
+			if Match(name, filter) {
+				want = append(want, filter)
+			}
+		}
+
+		sort.Strings(want)
+
+		if got := matchTrie(tr, name); !reflect.DeepEqual(got, want) {
+			t.Errorf("matchTrie(%q) => %v, want => %v", name, got, want)
+		}
+	}
+}
+
+func TestTrie_AddGetRemove(t *testing.T) {
+	tr := NewTrie()
+
+	tr.Add("a/b", 1)
+	tr.Add("a/b/c", 2)
+	tr.Add("a/b", 3)
+
+	if n := tr.Len(); n != 2 {
+		t.Errorf("tr.Len() => %d, want => 2", n)
+	}
+
+	if v, ok := tr.Get("a/b"); !ok || v != 3 {
+		t.Errorf("tr.Get(%q) => %v, %t, want => 3, true", "a/b", v, ok)
+	}
+
+	if _, ok := tr.Get("a"); ok {
+		t.Errorf("tr.Get(%q) => true, want => false", "a")
+	}
+
+	if _, ok := tr.Get("x/y"); ok {
+		t.Errorf("tr.Get(%q) => true, want => false", "x/y")
+	}
+
+	if tr.Remove("a") || tr.Remove("x") {
+		t.Error("tr.Remove returned true for the Topic Filter which does not exist")
+	}
+
+	if !tr.Remove("a/b/c") {
+		t.Errorf("tr.Remove(%q) => false, want => true", "a/b/c")
+	}
+
+	if _, exist := tr.root.children["a"].children["b"].children["c"]; exist {
+		t.Error("the node which is no longer used was not deleted")
+	}
+
+	if !tr.Remove("a/b") {
+		t.Errorf("tr.Remove(%q) => false, want => true", "a/b")
+	}
+
+	if len(tr.root.children) != 0 || tr.Len() != 0 {
+		t.Errorf("tr.root.children => %v, tr.Len() => %d, want => empty", tr.root.children, tr.Len())
+	}
+}
+
+func TestTrie_Range(t *testing.T) {
+	tr := NewTrie()
+
+	want := []string{"#", "a", "a/+/c"}
+
+	for _, filter := range want {
+		tr.Add(filter, filter)
+	}
+
+	var got []string
+
+	tr.Range(func(filter string, value interface{}) {
+		if filter != value {
+			t.Errorf("value => %v, want => %q", value, filter)
+		}
+
+		got = append(got, filter)
+	})
+
+	sort.Strings(got)
+
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got => %v, want => %v", got, want)
+	}
+}
+
+func TestTrie_concurrent(t *testing.T) {
+	tr := NewTrie()
+
+	var wg sync.WaitGroup
+
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+
+		go func(i int) {
+			defer wg.Done()
+
+			filter := fmt.Sprintf("a/%d/#", i)
+
+			tr.Add(filter, i)
+			matchTrie(tr, "a/1/b")
+			tr.Remove(filter)
+		}(i)
+	}
+
+	wg.Wait()
+
+	if n := tr.Len(); n != 0 {
+		t.Errorf("tr.Len() => %d, want => 0", n)
+	}
+}
+
+func BenchmarkTrie_Match(b *testing.B) {
+	tr := NewTrie()
+
+	for i := 0; i < 10000; i++ {
+		tr.Add(fmt.Sprintf("sensors/plant%d/+/temperature", i), i)
+	}
+
+	f := func(_ string, _ interface{}) {}
+
+	b.ReportAllocs()
+
+	for i := 0; i < b.N; i++ {
+		tr.Match("sensors/plant42/line3/temperature", f)
+	}
+}
@@ -0,0 +1,18 @@
+package mqtt
+
+// Protocol versions which are represented by the Protocol Level
+// of the CONNECT Packet
+const (
+	// ProtocolVersion31 represents MQTT 3.1.
+	ProtocolVersion31 byte = 0x03
+	// ProtocolVersion311 represents MQTT 3.1.1.
+	ProtocolVersion311 byte = 0x04
+	// ProtocolVersion5 represents MQTT 5.0.
+	ProtocolVersion5 byte = 0x05
+)
+
+// ValidProtocolVersion returns true if the input protocol version
+// equals to ProtocolVersion31, ProtocolVersion311 or ProtocolVersion5.
+func ValidProtocolVersion(version byte) bool {
+	return version == ProtocolVersion31 || version == ProtocolVersion311 || version == ProtocolVersion5
+}
@@ -0,0 +1,22 @@
+package mqtt
+
+import "testing"
+
+func TestValidProtocolVersion(t *testing.T) {
+	testCases := []struct {
+		in  byte
+		out bool
+	}{
+		{in: 0x02, out: false},
+		{in: ProtocolVersion31, out: true},
+		{in: ProtocolVersion311, out: true},
+		{in: ProtocolVersion5, out: true},
+		{in: 0x06, out: false},
+	}
+
+	for _, tc := range testCases {
+		if got := ValidProtocolVersion(tc.in); got != tc.out {
+			t.Errorf("got => %t, want => %t", got, tc.out)
+		}
+	}
+}